@@ -15,6 +15,7 @@ func main() {
 	simpleLog := false
 	enableLog := false
 	command := ""
+	routeModel := ""
 
 	if len(os.Args) > 1 {
 		for i := 1; i < len(os.Args); i++ {
@@ -26,8 +27,14 @@ func main() {
 				simpleLog = true
 			case "-l", "--log":
 				enableLog = true
-			case "stop", "status", "version", "help", "-h", "--help":
+			case "stop", "status", "reload", "version", "help", "-h", "--help":
 				command = arg
+			case "route":
+				command = arg
+				if i+1 < len(os.Args) {
+					routeModel = os.Args[i+1]
+					i++
+				}
 			}
 		}
 
@@ -39,6 +46,12 @@ func main() {
 		case "status":
 			daemon.Status()
 			return
+		case "reload":
+			daemon.Reload()
+			return
+		case "route":
+			explainRoute(routeModel)
+			return
 		case "version":
 			fmt.Println("claude-code-proxy v1.0.0")
 			return
@@ -106,6 +119,8 @@ func printHelp() {
   claude-code-proxy [-d|--debug] [-s|--simple] [-l|--log]  启动代理守护进程
   claude-code-proxy stop                                   停止代理守护进程
   claude-code-proxy status                                 检查代理是否正在运行
+  claude-code-proxy reload                                 重新加载配置并显示变化
+  claude-code-proxy route <模型名称>                       说明模型名称匹配的路由规则
   claude-code-proxy version                                显示版本
   claude-code-proxy help                                   显示此帮助
 
@@ -120,6 +135,14 @@ func printHelp() {
     2. ~/.claude/proxy.env
     3. ~/.claude-code-proxy
 
+  结构化配置文件（按顺序合并，环境变量优先）:
+    1. ~/.claude/proxy.yaml
+    2. ./.claude/proxy.yaml
+    3. $CLAUDE_PROXY_CONFIG
+
+  配置文件变化时自动重新加载（CONFIG_WATCH_INTERVAL，默认 2s，0 为禁用），
+  也可以发送 SIGHUP 或运行 reload 命令；进行中的请求继续使用旧配置。
+
   必需:
     OPENAI_API_KEY         您的 OpenAI API 密钥
 
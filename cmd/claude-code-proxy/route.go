@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+)
+
+// explainRoute 加载配置并说明模型名称匹配的路由规则、目标后端和上游模型
+func explainRoute(model string) {
+	if model == "" {
+		fmt.Fprintln(os.Stderr, "用法: claude-code-proxy route <模型名称>")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ 配置无效，代理将无法启动:\n%v\n\n", err)
+	}
+	printRoute(os.Stdout, model, cfg)
+}
+
+// printRoute 输出模型名称的路由说明
+func printRoute(w io.Writer, model string, cfg *config.Config) {
+	route := converter.ResolveRoute(model, cfg)
+	fmt.Fprintf(w, "模型:     %s\n", model)
+	fmt.Fprintf(w, "匹配规则: %s\n", route.Rule)
+	if route.Source != "" {
+		fmt.Fprintf(w, "规则来源: %s\n", route.Source)
+	}
+	if backend, ok := cfg.Backends[route.Backend]; ok {
+		fmt.Fprintf(w, "后端:     %s（%s，协议 %s）\n", route.Backend, backend.BaseURL, backend.Protocol)
+	} else {
+		fmt.Fprintf(w, "后端:     %s（未定义）\n", route.Backend)
+	}
+	fmt.Fprintf(w, "上游模型: %s\n", route.Model)
+	if !route.Params.IsZero() {
+		fmt.Fprintf(w, "参数覆盖: %s\n", route.Params)
+	}
+	if cfg.ClampMaxTokens {
+		route.Policy = route.Policy.WithMaxTokensAuto()
+	}
+	if !route.Policy.IsZero() {
+		fmt.Fprintf(w, "参数策略: %s\n", route.Policy)
+	}
+	if limit, ok := converter.MaxOutputTokens(route.Model); ok {
+		fmt.Fprintf(w, "最大输出: %d 令牌（已知模型上限，clamp.max_tokens 为 auto 或 CLAMP_MAX_TOKENS 启用时使用）\n", limit)
+	}
+
+	conditional := 0
+	for _, rule := range cfg.Routes {
+		if !rule.When.IsZero() {
+			conditional++
+		}
+	}
+	if conditional > 0 {
+		fmt.Fprintf(w, "\n注意: %d 条规则带有请求内容条件（when），取决于实际请求，此处未评估\n", conditional)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+func TestPrintRoute(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	for _, name := range []string{
+		"OPENAI_BASE_URL", "OPENAI_API_KEY", "OPENAI_PROVIDER", "OPENAI_PROTOCOL", "CLAMP_MAX_TOKENS",
+		"ANTHROPIC_DEFAULT_OPUS_MODEL", "ANTHROPIC_DEFAULT_SONNET_MODEL", "ANTHROPIC_DEFAULT_HAIKU_MODEL",
+	} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(dir, "proxy.yaml")
+	if err := os.WriteFile(path, []byte(`backends:
+  default:
+    base_url: http://localhost:8000/v1
+  local:
+    base_url: http://localhost:11434
+routes:
+  - when: {images: true}
+    model: vision
+  - glob: claude-sonnet-*
+    backend: local
+    model: qwen3-coder
+    params:
+      temperature: 0.2
+`), 0o644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	t.Setenv(config.ConfigFileEnv, path)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		model   string
+		want    []string
+		notWant string
+	}{
+		{
+			name:  "路由规则",
+			model: "claude-sonnet-4-5",
+			want: []string{
+				"模型:     claude-sonnet-4-5\n",
+				`匹配规则: routes[1]: glob "claude-sonnet-*" → local:qwen3-coder（temperature=0.2）` + "\n",
+				"规则来源: " + path + ":9:5\n",
+				"后端:     local（http://localhost:11434，协议 ",
+				"上游模型: qwen3-coder\n",
+				"参数覆盖: temperature=0.2\n",
+				"注意: 1 条规则带有请求内容条件（when），取决于实际请求，此处未评估\n",
+			},
+		},
+		{
+			name:  "原样传递",
+			model: "gpt-4o",
+			want: []string{
+				"匹配规则: 没有匹配的规则，原样传递\n",
+				"后端:     default（http://localhost:8000/v1，协议 ",
+				"上游模型: gpt-4o\n",
+			},
+			notWant: "规则来源",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			printRoute(&out, tt.model, cfg)
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("输出缺少 %q:\n%s", want, out.String())
+				}
+			}
+			if tt.notWant != "" && strings.Contains(out.String(), tt.notWant) {
+				t.Errorf("输出不应包含 %q:\n%s", tt.notWant, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultAWSProfile 未设置 AWS_PROFILE 时使用的共享凭证配置文件
+const defaultAWSProfile = "default"
+
+// BedrockSettings 描述 AWS Bedrock 后端的区域和凭证来源。
+// 静态凭证（AWS_ACCESS_KEY_ID 等）优先；未设置时每次请求从共享凭证文件读取，
+// 以便获取外部工具轮换后的临时凭证。
+type BedrockSettings struct {
+	Region          string // AWS 区域（用于签名和默认端点）
+	AccessKeyID     string // 静态访问密钥 ID
+	SecretAccessKey string // 静态秘密访问密钥
+	SessionToken    string // 静态会话令牌（临时凭证）
+	Profile         string // 共享凭证文件中的配置文件名称
+	CredentialsFile string // 共享凭证文件路径（默认 ~/.aws/credentials）
+}
+
+// AWSCredentials 是用于 SigV4 签名的 AWS 凭证
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// loadBedrockSettings 从 AWS 标准环境变量（或配置文件中后端的 bedrock 设置）读取 Bedrock 设置。
+// 区域依次取 AWS_REGION、AWS_DEFAULT_REGION，最后从基础 URL 的主机名中解析。
+func loadBedrockSettings(s settings, baseURL string) BedrockSettings {
+	settings := BedrockSettings{
+		Region:          s.get("AWS_REGION"),
+		AccessKeyID:     s.get("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: s.get("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    s.get("AWS_SESSION_TOKEN"),
+		Profile:         s.getOrDefault("AWS_PROFILE", defaultAWSProfile),
+		CredentialsFile: s.get("AWS_SHARED_CREDENTIALS_FILE"),
+	}
+	if settings.Region == "" {
+		settings.Region = s.get("AWS_DEFAULT_REGION")
+	}
+	if settings.Region == "" {
+		settings.Region = regionFromBedrockURL(baseURL)
+	}
+	if settings.CredentialsFile == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			settings.CredentialsFile = filepath.Join(home, ".aws", "credentials")
+		}
+	}
+	return settings
+}
+
+// BedrockRuntimeURL 返回区域的 Bedrock Runtime 端点
+func BedrockRuntimeURL(region string) string {
+	return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+}
+
+// regionFromBedrockURL 从 bedrock-runtime.{区域}.amazonaws.com 形式的 URL 中解析区域
+func regionFromBedrockURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	labels := strings.Split(u.Hostname(), ".")
+	if len(labels) >= 4 && strings.HasPrefix(labels[0], "bedrock-runtime") && labels[2] == "amazonaws" {
+		return labels[1]
+	}
+	return ""
+}
+
+// Credentials 返回用于签名的 AWS 凭证：优先使用静态凭证，否则读取共享凭证文件中的配置文件
+func (s BedrockSettings) Credentials() (AWSCredentials, error) {
+	if s.AccessKeyID != "" && s.SecretAccessKey != "" {
+		return AWSCredentials{
+			AccessKeyID:     s.AccessKeyID,
+			SecretAccessKey: s.SecretAccessKey,
+			SessionToken:    s.SessionToken,
+		}, nil
+	}
+	if s.CredentialsFile == "" {
+		return AWSCredentials{}, fmt.Errorf("未找到 AWS 凭证：请设置 AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY 或共享凭证文件")
+	}
+
+	values, err := readINISection(s.CredentialsFile, s.Profile)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("读取 AWS 凭证失败: %w", err)
+	}
+	creds := AWSCredentials{
+		AccessKeyID:     values["aws_access_key_id"],
+		SecretAccessKey: values["aws_secret_access_key"],
+		SessionToken:    values["aws_session_token"],
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, fmt.Errorf("配置文件 %s 中缺少 aws_access_key_id 或 aws_secret_access_key（%s）", s.Profile, s.CredentialsFile)
+	}
+	return creds, nil
+}
+
+// readINISection 读取 INI 文件中指定节的键值对（键名转为小写）
+func readINISection(path, section string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	values := make(map[string]string)
+	found, inSection := false, false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+			found = found || inSection
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("配置文件 %s 不存在于 %s", section, path)
+	}
+	return values, nil
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultBudgetWarnAt 未设置 warn_at 时发出预算警告的用量比例
+const defaultBudgetWarnAt = 0.8
+
+// ModelPrice 是上游模型的令牌价格（美元 / 百万令牌），用于计算客户端的费用
+type ModelPrice struct {
+	// Pattern 上游模型名称的通配符（不区分大小写，* 匹配任意字符，? 匹配单个字符）
+	Pattern string
+	// Input 输入令牌的价格
+	Input float64
+	// Output 输出令牌的价格
+	Output float64
+	// Position 价格在配置文件中的位置
+	Position Position
+
+	re *regexp.Regexp
+}
+
+// Cost 返回令牌用量的费用（美元）
+func (p ModelPrice) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// String 返回价格的可读形式（如 "gpt-4o（输入 $2.5 / 输出 $10 每百万令牌）"）
+func (p ModelPrice) String() string {
+	return fmt.Sprintf("%s（输入 $%s / 输出 $%s 每百万令牌）", p.Pattern,
+		strconv.FormatFloat(p.Input, 'g', -1, 64), strconv.FormatFloat(p.Output, 'g', -1, 64))
+}
+
+// PriceFor 返回上游模型的价格。多个模式匹配时使用最长（最具体）的模式；没有匹配时 ok 为 false（费用按 0 计算）。
+func (c *Config) PriceFor(model string) (price ModelPrice, ok bool) {
+	for _, candidate := range c.Pricing {
+		if candidate.re.MatchString(model) && (!ok || len(candidate.Pattern) > len(price.Pattern)) {
+			price, ok = candidate, true
+		}
+	}
+	return price, ok
+}
+
+// BudgetLimit 是一个周期的用量上限，零值字段表示不限制
+type BudgetLimit struct {
+	// Tokens 输入和输出令牌总数
+	Tokens int64
+	// Cost 费用（美元，按 pricing 价格表计算）
+	Cost float64
+}
+
+// IsZero 如果没有设置任何上限则返回 true
+func (l BudgetLimit) IsZero() bool {
+	return l.Tokens == 0 && l.Cost == 0
+}
+
+// ClientBudget 是客户端的令牌和费用预算（用量按本地时间的自然日和自然月累计）
+type ClientBudget struct {
+	// Daily 每日预算，用完后请求以 rate_limit_error 拒绝，直到次日零点
+	Daily BudgetLimit
+	// Monthly 每月预算，用完后请求以 permission_error 拒绝，直到下月 1 日
+	Monthly BudgetLimit
+	// WarnAt 用量达到上限的该比例时在响应头中发出警告（软限制）
+	WarnAt float64
+}
+
+// IsZero 如果没有设置每日和每月预算则返回 true
+func (b ClientBudget) IsZero() bool {
+	return b.Daily.IsZero() && b.Monthly.IsZero()
+}
+
+// walkPricing 解析 pricing 映射（模型通配符 -> 价格）。后加载的配置文件中的 pricing 整体替换之前的价格表。
+func (p *fileParser) walkPricing(node *yaml.Node) {
+	if isNull(node) {
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, "pricing", "应为模型名称到价格的映射（如 gpt-4o: {input: 2.5, output: 10}）")
+		return
+	}
+
+	pricing := make([]ModelPrice, 0, len(node.Content)/2)
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		patternNode, priceNode := node.Content[i], resolveAlias(node.Content[i+1])
+		pattern := strings.TrimSpace(patternNode.Value)
+		path := "pricing." + pattern
+		if pattern == "" {
+			p.fail(patternNode, "pricing", "模型名称不能为空")
+			continue
+		}
+		if seen[strings.ToLower(pattern)] {
+			p.fail(patternNode, path, "价格重复定义")
+			continue
+		}
+		seen[strings.ToLower(pattern)] = true
+		if price, ok := p.modelPrice(priceNode, path, pattern); ok {
+			price.Position = p.position(patternNode)
+			pricing = append(pricing, price)
+		}
+	}
+	p.settings.pricing = pricing
+}
+
+// modelPrice 解析一个模型的输入和输出价格
+func (p *fileParser) modelPrice(node *yaml.Node, path, pattern string) (ModelPrice, bool) {
+	price := ModelPrice{Pattern: pattern}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射（input、output：美元 / 百万令牌）")
+		return price, false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+
+		switch keyNode.Value {
+		case "input", "output":
+			value, valid := p.nonNegativeNumber(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			if keyNode.Value == "input" {
+				price.Input = value
+			} else {
+				price.Output = value
+			}
+		default:
+			p.fail(keyNode, keyPath, "未知的设置项（支持 input、output）")
+			ok = false
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("(?i)^")
+	writeGlob(&sb, pattern)
+	sb.WriteString("$")
+	price.re = regexp.MustCompile(sb.String())
+	return price, ok
+}
+
+// clientBudget 解析客户端的每日和每月预算
+func (p *fileParser) clientBudget(node *yaml.Node, path string, budget *ClientBudget) bool {
+	if isNull(node) {
+		return true
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射（如 daily: {cost: 5}）")
+		return false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+
+		switch keyNode.Value {
+		case "daily":
+			ok = p.budgetLimit(valueNode, keyPath, &budget.Daily) && ok
+		case "monthly":
+			ok = p.budgetLimit(valueNode, keyPath, &budget.Monthly) && ok
+		case "warn_at":
+			value, valid := p.nonNegativeNumber(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			if value <= 0 || value > 1 {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为 0 到 1 之间的比例（如 0.8），当前为 %v", value))
+				ok = false
+				continue
+			}
+			budget.WarnAt = value
+		default:
+			p.fail(keyNode, keyPath, "未知的设置项（支持 daily、monthly、warn_at）")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// budgetLimit 解析一个周期的令牌和费用上限
+func (p *fileParser) budgetLimit(node *yaml.Node, path string, limit *BudgetLimit) bool {
+	if isNull(node) {
+		return true
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射（tokens、cost）")
+		return false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+
+		switch keyNode.Value {
+		case "tokens":
+			text, valid := p.routeString(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			value, err := strconv.ParseInt(text, 10, 64)
+			if err != nil || value <= 0 {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为正整数，当前为 %q", text))
+				ok = false
+				continue
+			}
+			limit.Tokens = value
+		case "cost":
+			value, valid := p.nonNegativeNumber(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			if value == 0 {
+				p.fail(valueNode, keyPath, "应为正数（美元）")
+				ok = false
+				continue
+			}
+			limit.Cost = value
+		default:
+			p.fail(keyNode, keyPath, "未知的设置项（支持 tokens、cost）")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// nonNegativeNumber 返回配置文件中的非负数值
+func (p *fileParser) nonNegativeNumber(node *yaml.Node, path string) (float64, bool) {
+	text, ok := p.routeString(node, path)
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || value < 0 {
+		p.fail(node, path, fmt.Sprintf("应为非负数，当前为 %q", text))
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+)
+
+// defaultCapabilityCacheTTL 未设置 CAPABILITY_CACHE_TTL 时学习到的模型能力的有效期
+const defaultCapabilityCacheTTL = 7 * 24 * time.Hour
+
+// unsupportedFeatureTTL 是“不支持工具调用或图片输入”记录的最长有效期。
+// 这两项无法从请求中删除后重试，记录期间同类请求在本地直接被拒绝，
+// 一次被误判的上游错误不应长期禁用模型，因此过期后重新请求上游验证。
+const unsupportedFeatureTTL = 10 * time.Minute
+
+// capabilitySaveInterval 能力没有变化时重新写入缓存文件（更新 LastChecked）的最短间隔
+const capabilitySaveInterval = time.Hour
+
+// capabilityCacheVersion 是缓存文件的格式版本，版本不同时忽略文件
+const capabilityCacheVersion = 1
+
+// Capability 是通过上游响应学习的模型能力
+type Capability string
+
+const (
+	// CapabilityMaxCompletionTokens 使用 max_completion_tokens（而不是 max_tokens）限制输出
+	CapabilityMaxCompletionTokens Capability = "max_completion_tokens"
+	// CapabilityTools 支持工具调用
+	CapabilityTools Capability = "tools"
+	// CapabilityReasoning 接受推理参数（reasoning_effort、reasoning）
+	CapabilityReasoning Capability = "reasoning"
+	// CapabilityImages 接受图片输入
+	CapabilityImages Capability = "images"
+	// CapabilityStreamOptions 接受 stream_options
+	CapabilityStreamOptions Capability = "stream_options"
+	// CapabilityParallelToolCalls 接受 parallel_tool_calls
+	CapabilityParallelToolCalls Capability = "parallel_tool_calls"
+	// CapabilityTemperature 接受 temperature（部分推理模型只允许默认值）
+	CapabilityTemperature Capability = "temperature"
+	// CapabilityTopP 接受 top_p
+	CapabilityTopP Capability = "top_p"
+	// CapabilityToolChoiceRequired 接受 tool_choice: "required"
+	CapabilityToolChoiceRequired Capability = "tool_choice_required"
+)
+
+// CacheKey 唯一标识用于能力缓存的（提供商，模型）组合
+// 使用结构体作为 map 键提供类型安全性和零冲突风险
+type CacheKey struct {
+	BaseURL string // 提供商基础 URL（例如 "https://openrouter.ai/api/v1"）
+	Model   string // 模型名称（例如 "gpt-5"、"openai/gpt-5"）
+}
+
+// LearnedCapability 是学习到的一项能力
+type LearnedCapability struct {
+	Supported   bool      `json:"supported"`    // 是否支持
+	LastChecked time.Time `json:"last_checked"` // 上次验证时间，超过 CAPABILITY_CACHE_TTL 后重新检测
+}
+
+// ModelCapabilities 跟踪特定模型支持的参数
+// 这是通过自适应重试机制动态学习的
+type ModelCapabilities map[Capability]LearnedCapability
+
+// capabilityCacheFile 是能力缓存文件的内容
+type capabilityCacheFile struct {
+	Version int               `json:"version"`
+	Models  []CapabilityEntry `json:"models"`
+}
+
+// CapabilityEntry 是一个（提供商，模型）组合学习到的能力（缓存文件和状态端点使用）
+type CapabilityEntry struct {
+	BaseURL      string            `json:"base_url"`
+	Model        string            `json:"model"`
+	Capabilities ModelCapabilities `json:"capabilities"`
+}
+
+// 全局能力缓存（(baseURL, model) -> capabilities）
+// 由互斥锁保护，用于跨并发请求的线程安全访问；设置了缓存文件时，能力变化后写入文件，重启后继续使用。
+// 不支持工具调用或图片输入的记录只保存在内存中，不写入文件（见 persistedCapabilities）
+var (
+	modelCapabilityCache = make(map[CacheKey]ModelCapabilities)
+	capabilityCacheMutex sync.RWMutex
+	capabilityCachePath  string    // 缓存文件路径（为空时只保存在内存中）
+	capabilityLastSaved  time.Time // 上次写入缓存文件的时间
+	capabilitySaveMutex  sync.Mutex
+)
+
+// LoadCapabilityCache 从文件加载学习到的模型能力，之后学习到的能力写入同一文件。
+// 文件不存在时从空缓存开始；返回加载的（提供商，模型）组合数量。
+func LoadCapabilityCache(path string) (int, error) {
+	capabilityCacheMutex.Lock()
+	defer capabilityCacheMutex.Unlock()
+	capabilityCachePath = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var file capabilityCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("解析能力缓存文件 %s 失败: %w", path, err)
+	}
+	if file.Version != capabilityCacheVersion {
+		return 0, nil
+	}
+	for _, entry := range file.Models {
+		caps := persistedCapabilities(entry.Capabilities)
+		if len(caps) > 0 {
+			modelCapabilityCache[CacheKey{BaseURL: entry.BaseURL, Model: entry.Model}] = caps
+		}
+	}
+	capabilityLastSaved = time.Now()
+	return len(file.Models), nil
+}
+
+// LearnCapability 记录（提供商，模型）组合的一项能力。
+// 在通过自适应重试或成功的请求确认模型是否支持某个参数后调用。
+// 能力变化（或距上次写入超过 capabilitySaveInterval）时写入缓存文件。
+func LearnCapability(key CacheKey, capability Capability, supported bool) {
+	capabilityCacheMutex.Lock()
+	caps := modelCapabilityCache[key]
+	previous, known := caps[capability]
+	// 复制后替换，使 CapabilitySnapshot 返回的映射不会被并发修改
+	updated := make(ModelCapabilities, len(caps)+1)
+	for k, v := range caps {
+		updated[k] = v
+	}
+	updated[capability] = LearnedCapability{Supported: supported, LastChecked: time.Now()}
+	modelCapabilityCache[key] = updated
+	save := capabilityCachePath != "" &&
+		(!known || previous.Supported != supported || time.Since(capabilityLastSaved) > capabilitySaveInterval)
+	capabilityCacheMutex.Unlock()
+
+	if save {
+		if err := saveCapabilityCache(); err != nil {
+			fmt.Printf("⚠️  写入能力缓存文件失败: %v\n", err)
+		}
+	}
+}
+
+// LookupCapability 返回（提供商，模型）组合学习到的能力；
+// 没有记录或超过有效期（CapabilityCacheTTL，零值表示不过期）时 known 为 false，由调用方重新检测。
+// 不支持工具调用或图片输入的记录最多保留 unsupportedFeatureTTL。
+func (c *Config) LookupCapability(key CacheKey, capability Capability) (supported, known bool) {
+	capabilityCacheMutex.RLock()
+	learned, ok := modelCapabilityCache[key][capability]
+	capabilityCacheMutex.RUnlock()
+	if !ok {
+		return false, false
+	}
+	ttl := c.CapabilityCacheTTL
+	if !learned.Supported && blocksRequests(capability) && (ttl <= 0 || ttl > unsupportedFeatureTTL) {
+		ttl = unsupportedFeatureTTL
+	}
+	if ttl > 0 && time.Since(learned.LastChecked) > ttl {
+		return false, false
+	}
+	return learned.Supported, true
+}
+
+// blocksRequests 如果不支持该能力时请求会在本地被拒绝（而不是删除参数后发送）则返回 true
+func blocksRequests(capability Capability) bool {
+	return capability == CapabilityTools || capability == CapabilityImages
+}
+
+// CapabilitySnapshot 返回所有学习到的能力（按提供商和模型排序），用于状态端点
+func CapabilitySnapshot() []CapabilityEntry {
+	capabilityCacheMutex.RLock()
+	defer capabilityCacheMutex.RUnlock()
+	return capabilityEntries(false)
+}
+
+// capabilityEntries 返回按提供商和模型排序的缓存条目（调用方持有锁）；
+// persisted 为 true 时只返回写入缓存文件的能力
+func capabilityEntries(persisted bool) []CapabilityEntry {
+	entries := make([]CapabilityEntry, 0, len(modelCapabilityCache))
+	for key, caps := range modelCapabilityCache {
+		if persisted {
+			if caps = persistedCapabilities(caps); len(caps) == 0 {
+				continue
+			}
+		}
+		entries = append(entries, CapabilityEntry{BaseURL: key.BaseURL, Model: key.Model, Capabilities: caps})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].BaseURL != entries[j].BaseURL {
+			return entries[i].BaseURL < entries[j].BaseURL
+		}
+		return entries[i].Model < entries[j].Model
+	})
+	return entries
+}
+
+// persistedCapabilities 返回写入缓存文件的能力：不支持工具调用或图片输入的记录会使请求在本地被拒绝，
+// 只在内存中短暂保留，重启后总是重新请求上游验证
+func persistedCapabilities(caps ModelCapabilities) ModelCapabilities {
+	persisted := make(ModelCapabilities, len(caps))
+	for capability, learned := range caps {
+		if learned.Supported || !blocksRequests(capability) {
+			persisted[capability] = learned
+		}
+	}
+	return persisted
+}
+
+// saveCapabilityCache 将能力缓存写入文件（先写临时文件再重命名，避免并发读取到不完整的内容）
+func saveCapabilityCache() error {
+	capabilitySaveMutex.Lock()
+	defer capabilitySaveMutex.Unlock()
+
+	capabilityCacheMutex.Lock()
+	path := capabilityCachePath
+	data, err := json.MarshalIndent(capabilityCacheFile{Version: capabilityCacheVersion, Models: capabilityEntries(true)}, "", "  ")
+	capabilityLastSaved = time.Now()
+	capabilityCacheMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ShouldUseMaxCompletionTokens 根据通过自适应检测学习到的缓存模型能力，
+// 确定是否应发送 max_completion_tokens。
+// 没有硬编码的模型模式 - 首次请求时（以及缓存过期后）对所有模型都尝试 max_completion_tokens。
+func (c *Config) ShouldUseMaxCompletionTokens(modelName string) bool {
+	// 为此（提供商，模型）组合构建缓存键
+	key := CacheKey{
+		BaseURL: c.OpenAIBaseURL,
+		Model:   modelName,
+	}
+
+	// 检查是否有关于此特定模型的缓存知识
+	if supported, known := c.LookupCapability(key, CapabilityMaxCompletionTokens); known {
+		// 缓存命中 - 使用已学习的能力
+		if c.Debug {
+			fmt.Printf("[调试] 缓存命中: %s → max_completion_tokens=%v\n", modelName, supported)
+		}
+		return supported
+	}
+
+	// 缓存未命中 - 默认首先尝试 max_completion_tokens
+	// handlers.go 中的重试机制将检测是否不支持
+	// 并自动回退到 max_tokens，然后缓存结果
+	if c.Debug {
+		fmt.Printf("[调试] 缓存未命中: %s → 将自动检测（尝试 max_completion_tokens）\n", modelName)
+	}
+	return true
+}
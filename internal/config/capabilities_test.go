@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnsupportedFeaturesNotPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capabilities.json")
+	if _, err := LoadCapabilityCache(path); err != nil {
+		t.Fatalf("加载能力缓存失败: %v", err)
+	}
+	defer func() { _, _ = LoadCapabilityCache("") }()
+
+	key := CacheKey{BaseURL: "http://persist.invalid", Model: "test-model"}
+	LearnCapability(key, CapabilityTools, false)
+	LearnCapability(key, CapabilityImages, false)
+	LearnCapability(key, CapabilityTemperature, false)
+
+	cfg := &Config{}
+	if supported, known := cfg.LookupCapability(key, CapabilityTools); !known || supported {
+		t.Fatalf("内存中应记录不支持工具调用: supported=%v known=%v", supported, known)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取能力缓存文件失败: %v", err)
+	}
+	for _, capability := range []Capability{CapabilityTools, CapabilityImages} {
+		if strings.Contains(string(data), `"`+string(capability)+`"`) {
+			t.Errorf("不支持 %s 的记录不应写入缓存文件: %s", capability, data)
+		}
+	}
+	if !strings.Contains(string(data), `"temperature"`) {
+		t.Errorf("不支持的可选参数应写入缓存文件: %s", data)
+	}
+
+	// 重启后（重新加载文件）不再拒绝工具调用
+	delete(modelCapabilityCache, key)
+	if _, err := LoadCapabilityCache(path); err != nil {
+		t.Fatalf("重新加载能力缓存失败: %v", err)
+	}
+	if _, known := cfg.LookupCapability(key, CapabilityTools); known {
+		t.Errorf("重新加载后不应记录工具调用能力")
+	}
+	if supported, known := cfg.LookupCapability(key, CapabilityTemperature); !known || supported {
+		t.Errorf("重新加载后应记录不支持 temperature: supported=%v known=%v", supported, known)
+	}
+}
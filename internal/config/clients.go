@@ -0,0 +1,356 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Client 是密钥文件中定义的入站客户端（如团队成员），使用自己的 API 密钥访问代理
+type Client struct {
+	Name string
+	// Keys 客户端的 API 密钥（可以有多个，便于轮换）
+	Keys []string
+	// Enabled 为 false 时拒绝客户端的所有请求
+	Enabled bool
+	// Routes 允许使用的路由目标，每项为 "后端" 或 "后端:模型"（模型可以使用 * 和 ? 通配符），为空时不限制
+	Routes []string
+	// DefaultModel 请求没有通过 x-proxy-model 或 @ 后缀指定目标时使用的 "后端:模型" 或 "模型"，为空时按路由规则
+	DefaultModel string
+	// DefaultParams 客户端请求参数的默认值，请求和路由规则的参数策略都没有设置时使用
+	DefaultParams map[string]interface{}
+	// Budget 每日和每月的令牌和费用预算，零值表示不限制
+	Budget ClientBudget
+	// Position 客户端在密钥文件中的位置
+	Position Position
+
+	keyHashes [][sha256.Size]byte // 密钥的 SHA-256，用于固定时间比较
+	routeRes  []*regexp.Regexp    // Routes 编译后的正则表达式（匹配 "后端:模型"）
+}
+
+// AllowsRoute 判断客户端是否可以使用路由到后端和模型的请求
+func (c *Client) AllowsRoute(backend, model string) bool {
+	if len(c.routeRes) == 0 {
+		return true
+	}
+	target := backend + ":" + model
+	for _, re := range c.routeRes {
+		if re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileRoutes 编译允许的路由目标：只有后端名称时匹配该后端的所有模型
+func (c *Client) compileRoutes() {
+	c.routeRes = c.routeRes[:0]
+	for _, route := range c.Routes {
+		backend, model, ok := strings.Cut(route, ":")
+		if !ok {
+			model = "*"
+		}
+		var sb strings.Builder
+		sb.WriteString("(?i)^")
+		sb.WriteString(regexp.QuoteMeta(backend))
+		sb.WriteString(":")
+		writeGlob(&sb, model)
+		sb.WriteString("$")
+		c.routeRes = append(c.routeRes, regexp.MustCompile(sb.String()))
+	}
+}
+
+// writeGlob 将通配符模式（* 匹配任意字符，? 匹配单个字符）写为正则表达式
+func writeGlob(sb *strings.Builder, pattern string) {
+	for _, ch := range pattern {
+		switch ch {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+}
+
+// routeBackend 返回允许的路由目标中的后端名称
+func routeBackend(route string) string {
+	backend, _, _ := strings.Cut(route, ":")
+	return backend
+}
+
+// AuthEnabled 如果需要验证入站 API 密钥（设置了 ANTHROPIC_API_KEY 或密钥文件中定义了客户端）则返回 true
+func (c *Config) AuthEnabled() bool {
+	return c.AnthropicAPIKey != "" || len(c.Clients) > 0
+}
+
+// Authenticate 验证入站 API 密钥，返回密钥对应的客户端。
+// 密钥与 ANTHROPIC_API_KEY 匹配时 ok 为 true、client 为 nil（共享密钥不限制路由）。
+// 所有密钥都以固定时间比较 SHA-256，且总是比较全部密钥，响应时间不泄露匹配的位置。
+func (c *Config) Authenticate(key string) (client *Client, ok bool) {
+	presented := sha256.Sum256([]byte(key))
+	if c.AnthropicAPIKey != "" {
+		shared := sha256.Sum256([]byte(c.AnthropicAPIKey))
+		if subtle.ConstantTimeCompare(presented[:], shared[:]) == 1 {
+			ok = true
+		}
+	}
+	for _, name := range c.clientNames() {
+		candidate := c.Clients[name]
+		for _, hash := range candidate.keyHashes {
+			if subtle.ConstantTimeCompare(presented[:], hash[:]) == 1 && client == nil {
+				client, ok = candidate, true
+			}
+		}
+	}
+	return client, ok
+}
+
+// clientNames 返回按名称排序的客户端名称
+func (c *Config) clientNames() []string {
+	names := make([]string, 0, len(c.Clients))
+	for name := range c.Clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadClients 加载 AUTH_KEYS_FILE 指定的密钥文件。文件格式：
+//
+//	clients:
+//	  alice:
+//	    keys: ["${ALICE_PROXY_KEY}"]
+//	    routes: [default, "local:qwen3-*"]
+//	    defaults:
+//	      model: local:qwen3-coder
+//	      params: {temperature: 0.2}
+//	    budget:
+//	      daily: {tokens: 2000000, cost: 5}
+//	      monthly: {cost: 100}
+//	  bob:
+//	    keys: [sk-proxy-bob]
+//	    enabled: false
+//
+// 语法错误、未知设置项和类型错误以带位置的 ValidationErrors 返回。
+func loadClients(path string) (map[string]*Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件 %s 失败: %w", path, err)
+	}
+
+	clients := make(map[string]*Client)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, yamlSyntaxError(path, err)
+	}
+	if len(doc.Content) == 0 {
+		return clients, nil
+	}
+
+	p := &fileParser{file: path}
+	root := resolveAlias(doc.Content[0])
+	if root.Kind != yaml.MappingNode {
+		p.fail(root, "", "密钥文件的顶层必须是映射")
+		return nil, p.errs
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valueNode := root.Content[i], resolveAlias(root.Content[i+1])
+		if keyNode.Value != "clients" {
+			p.fail(keyNode, keyNode.Value, "未知的设置项（支持 clients）")
+			continue
+		}
+		p.walkClients(valueNode, clients)
+	}
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return clients, nil
+}
+
+// walkClients 解析 clients 映射，检查密钥在所有客户端中唯一
+func (p *fileParser) walkClients(node *yaml.Node, clients map[string]*Client) {
+	if isNull(node) {
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, "clients", "应为客户端名称到客户端设置的映射")
+		return
+	}
+
+	keyOwners := make(map[string]string) // 密钥 -> 客户端名称
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		nameNode, clientNode := node.Content[i], resolveAlias(node.Content[i+1])
+		name := strings.TrimSpace(nameNode.Value)
+		path := "clients." + name
+		if name == "" {
+			p.fail(nameNode, "clients", "客户端名称不能为空")
+			continue
+		}
+		if _, seen := clients[name]; seen {
+			p.fail(nameNode, path, "客户端重复定义")
+			continue
+		}
+		client, ok := p.client(clientNode, path)
+		if !ok {
+			continue
+		}
+		client.Name = name
+		for _, key := range client.Keys {
+			if owner, seen := keyOwners[key]; seen {
+				p.fail(clientNode, path+".keys", fmt.Sprintf("密钥已被客户端 %s 使用", owner))
+				ok = false
+			}
+			keyOwners[key] = name
+		}
+		if ok {
+			clients[name] = client
+		}
+	}
+}
+
+// client 解析一个客户端的设置
+func (p *fileParser) client(node *yaml.Node, path string) (*Client, bool) {
+	client := &Client{Enabled: true, Budget: ClientBudget{WarnAt: defaultBudgetWarnAt}, Position: p.position(node)}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射（如 keys: [sk-...]）")
+		return client, false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+
+		switch keyNode.Value {
+		case "keys", "routes":
+			list, valid := p.convert(valueNode, keyPath, fileKey{kind: kindList})
+			if !valid {
+				ok = false
+				continue
+			}
+			if keyNode.Value == "keys" {
+				client.Keys = list.list
+			} else {
+				client.Routes = list.list
+			}
+		case "enabled":
+			text, valid := p.routeString(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			enabled, valid := parseFileBool(text)
+			if !valid {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为 true 或 false，当前为 %q", text))
+				ok = false
+				continue
+			}
+			client.Enabled = enabled
+		case "defaults":
+			ok = p.clientDefaults(valueNode, keyPath, client) && ok
+		case "budget":
+			ok = p.clientBudget(valueNode, keyPath, &client.Budget) && ok
+		default:
+			p.fail(keyNode, keyPath, "未知的设置项（支持 keys、enabled、routes、defaults、budget）")
+			ok = false
+		}
+	}
+
+	if len(client.Keys) == 0 && ok {
+		p.fail(node, path+".keys", "至少需要一个密钥")
+		ok = false
+	}
+	for _, key := range client.Keys {
+		client.keyHashes = append(client.keyHashes, sha256.Sum256([]byte(key)))
+	}
+	client.compileRoutes()
+	return client, ok
+}
+
+// clientDefaults 解析客户端的默认模型和请求参数
+func (p *fileParser) clientDefaults(node *yaml.Node, path string, client *Client) bool {
+	if isNull(node) {
+		return true
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射")
+		return false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+
+		switch keyNode.Value {
+		case "model":
+			text, valid := p.routeString(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			client.DefaultModel = text
+		case "params":
+			checkField := func(fieldNode *yaml.Node, fieldPath, field string) bool {
+				if policyReservedFields[field] {
+					p.fail(fieldNode, fieldPath, fmt.Sprintf("字段 %s 由代理设置，不能作为默认参数", field))
+					return false
+				}
+				return true
+			}
+			fields, valid := p.policyFields(valueNode, keyPath, checkField)
+			if !valid {
+				ok = false
+				continue
+			}
+			client.DefaultParams = fields
+		default:
+			p.fail(keyNode, keyPath, "未知的设置项（支持 model、params）")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// validateClients 验证客户端引用的后端
+func (c *Config) validateClients() ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range c.clientNames() {
+		client := c.Clients[name]
+		for _, route := range client.Routes {
+			if _, ok := c.Backends[routeBackend(route)]; !ok {
+				errs = append(errs, ValidationError{
+					Field:    "clients." + name + ".routes",
+					Position: client.Position,
+					Message:  fmt.Sprintf("后端 %q 未定义", routeBackend(route)),
+				})
+			}
+		}
+		if client.DefaultModel == "" {
+			continue
+		}
+		// 与单个请求覆盖相同：冒号前不是已配置的后端名称时，整个值是默认后端的模型名称
+		backend, model := DefaultBackendName, client.DefaultModel
+		if b, m, found := strings.Cut(client.DefaultModel, ":"); found {
+			if _, ok := c.Backends[b]; ok {
+				backend, model = b, m
+			}
+		}
+		if !client.AllowsRoute(backend, model) {
+			errs = append(errs, ValidationError{
+				Field:    "clients." + name + ".defaults.model",
+				Position: client.Position,
+				Message:  fmt.Sprintf("默认模型 %q 不在允许的路由中", client.DefaultModel),
+			})
+		}
+	}
+	return errs
+}
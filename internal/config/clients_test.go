@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loadTestClients 将密钥文件写入临时目录并加载，返回文件路径
+func loadTestClients(t *testing.T, data string) (map[string]*Client, string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeConfigFile(t, path, data)
+	clients, err := loadClients(path)
+	return clients, path, err
+}
+
+func TestLoadClients(t *testing.T) {
+	t.Setenv("TEST_ALICE_KEY", "sk-alice-env")
+	clients, path, err := loadTestClients(t, `
+clients:
+  alice:
+    keys: ["${TEST_ALICE_KEY}", sk-alice-2]
+    routes: [default, "local:qwen3-*"]
+    defaults:
+      model: local:qwen3-coder
+      params: {temperature: 0.2}
+  bob:
+    keys: sk-bob
+    enabled: false
+`)
+	if err != nil {
+		t.Fatalf("加载密钥文件失败: %v", err)
+	}
+	if len(clients) != 2 {
+		t.Fatalf("客户端 = %v，应有 2 个", clients)
+	}
+
+	alice := clients["alice"]
+	if alice.Name != "alice" || !alice.Enabled {
+		t.Errorf("alice = %+v，应默认启用", alice)
+	}
+	if !reflect.DeepEqual(alice.Keys, []string{"sk-alice-env", "sk-alice-2"}) {
+		t.Errorf("alice 的密钥 = %v，应展开环境变量", alice.Keys)
+	}
+	if !reflect.DeepEqual(alice.Routes, []string{"default", "local:qwen3-*"}) {
+		t.Errorf("alice 的路由 = %v", alice.Routes)
+	}
+	if alice.DefaultModel != "local:qwen3-coder" || alice.DefaultParams["temperature"] != 0.2 {
+		t.Errorf("alice 的默认值 = %q %v", alice.DefaultModel, alice.DefaultParams)
+	}
+	if want := path + ":4:5"; alice.Position.String() != want {
+		t.Errorf("alice 的位置 = %s，应为 %s", alice.Position, want)
+	}
+
+	bob := clients["bob"]
+	if bob.Enabled || !reflect.DeepEqual(bob.Keys, []string{"sk-bob"}) {
+		t.Errorf("bob = %+v，应停用且有一个密钥", bob)
+	}
+}
+
+func TestLoadClientsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantPos string // 相对于密钥文件的 行:列
+		wantMsg string
+	}{
+		{"语法错误", "clients:\n\talice: {}\n", "2", "cannot start any token"},
+		{"未知的顶层设置项", "users: {}\n", "1:1", "未知的设置项"},
+		{"缺少密钥", "clients:\n  alice:\n    routes: [default]\n", "3:5", "至少需要一个密钥"},
+		{"密钥重复", "clients:\n  alice:\n    keys: [k1]\n  bob:\n    keys: [k1]\n", "5:5", "密钥已被客户端 alice 使用"},
+		{"未知的客户端设置项", "clients:\n  alice:\n    keys: [k1]\n    role: admin\n", "4:5", "未知的设置项"},
+		{"无效的 enabled", "clients:\n  alice:\n    keys: [k1]\n    enabled: maybe\n", "4:14", "应为 true 或 false"},
+		{"默认参数不能设置模型", "clients:\n  alice:\n    keys: [k1]\n    defaults:\n      params: {model: x}\n", "5:16", "由代理设置"},
+		{"客户端设置应为映射", "clients:\n  alice: sk-alice\n", "2:10", "应为映射"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, path, err := loadTestClients(t, tt.data)
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != 1 {
+				t.Fatalf("错误 = %v，应为 1 个 ValidationErrors", err)
+			}
+			if got := errs[0].Position.String(); got != path+":"+tt.wantPos || !strings.Contains(errs[0].Message, tt.wantMsg) {
+				t.Errorf("错误 = %s: %s，应为 %s:%s: 包含 %q", got, errs[0].Message, path, tt.wantPos, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	clients, _, err := loadTestClients(t, `
+clients:
+  alice:
+    keys: [sk-alice, sk-alice-2]
+  bob:
+    keys: [sk-bob]
+    enabled: false
+`)
+	if err != nil {
+		t.Fatalf("加载密钥文件失败: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		shared     string // ANTHROPIC_API_KEY
+		key        string
+		wantClient string
+		wantOK     bool
+	}{
+		{"共享密钥", "sk-shared", "sk-shared", "", true},
+		{"客户端密钥", "sk-shared", "sk-alice", "alice", true},
+		{"客户端的第二个密钥", "", "sk-alice-2", "alice", true},
+		{"停用的客户端仍返回客户端（由调用方拒绝）", "", "sk-bob", "bob", true},
+		{"错误的密钥", "sk-shared", "sk-wrong", "", false},
+		{"密钥的前缀", "", "sk-alice-", "", false},
+		{"空密钥", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{AnthropicAPIKey: tt.shared, Clients: clients}
+			client, ok := cfg.Authenticate(tt.key)
+			name := ""
+			if client != nil {
+				name = client.Name
+			}
+			if name != tt.wantClient || ok != tt.wantOK {
+				t.Errorf("Authenticate(%q) = %q, %v，应为 %q, %v", tt.key, name, ok, tt.wantClient, tt.wantOK)
+			}
+		})
+	}
+
+	if (&Config{}).AuthEnabled() {
+		t.Errorf("没有共享密钥和客户端时不应验证")
+	}
+	if !(&Config{Clients: clients}).AuthEnabled() {
+		t.Errorf("定义了客户端时应验证")
+	}
+}
+
+func TestAllowsRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  []string
+		backend string
+		model   string
+		want    bool
+	}{
+		{"没有限制", nil, "local", "any", true},
+		{"只有后端名称时允许该后端的所有模型", []string{"default"}, "default", "gpt-4o", true},
+		{"只有后端名称时不允许其他后端", []string{"default"}, "local", "gpt-4o", false},
+		{"后端名称需要完整匹配", []string{"default"}, "default2", "gpt-4o", false},
+		{"模型通配符", []string{"local:qwen3-*"}, "local", "qwen3-coder", true},
+		{"模型通配符不匹配", []string{"local:qwen3-*"}, "local", "llama3", false},
+		{"通配符只匹配指定后端", []string{"local:qwen3-*"}, "default", "qwen3-coder", false},
+		{"? 匹配单个字符", []string{"local:qwen?"}, "local", "qwen3", true},
+		{"不区分大小写", []string{"Local:Qwen3-*"}, "local", "qwen3-coder", true},
+		{"模型名称中的其他字符按字面匹配", []string{"openrouter:openai/gpt-4.1"}, "openrouter", "openai/gpt-4x1", false},
+		{"任意一项匹配即允许", []string{"default", "local:qwen3-*"}, "local", "qwen3-8b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{Name: "alice", Routes: tt.routes}
+			client.compileRoutes()
+			if got := client.AllowsRoute(tt.backend, tt.model); got != tt.want {
+				t.Errorf("AllowsRoute(%q, %q) = %v，应为 %v（允许 %v）", tt.backend, tt.model, got, tt.want, tt.routes)
+			}
+		})
+	}
+}
+
+func TestValidateClients(t *testing.T) {
+	tests := []struct {
+		name         string
+		routes       []string
+		defaultModel string
+		wantField    string
+		wantMsg      string
+	}{
+		{"有效的设置", []string{"default", "local:qwen3-*"}, "local:qwen3-coder", "", ""},
+		{"未定义的后端", []string{"cloud"}, "", "clients.alice.routes", `后端 "cloud" 未定义`},
+		{"默认模型不在允许的路由中", []string{"local"}, "default:gpt-4o", "clients.alice.defaults.model", "不在允许的路由中"},
+		{"冒号前不是后端名称的默认模型使用默认后端", []string{"default"}, "qwen3:8b", "", ""},
+		{"使用默认后端的默认模型不在允许的路由中", []string{"local"}, "qwen3:8b", "clients.alice.defaults.model", "不在允许的路由中"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{Name: "alice", Routes: tt.routes, DefaultModel: tt.defaultModel, Position: Position{File: "keys.yaml", Line: 2, Column: 3}}
+			client.compileRoutes()
+			cfg := &Config{
+				Backends: map[string]*Backend{DefaultBackendName: {}, "local": {}},
+				Clients:  map[string]*Client{"alice": client},
+			}
+			errs := cfg.validateClients()
+			if tt.wantField == "" {
+				if len(errs) != 0 {
+					t.Errorf("错误 = %v，应没有错误", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || errs[0].Field != tt.wantField || !strings.Contains(errs[0].Message, tt.wantMsg) {
+				t.Fatalf("错误 = %v，应为 %s: 包含 %q", errs, tt.wantField, tt.wantMsg)
+			}
+			if errs[0].Position.String() != "keys.yaml:2:3" {
+				t.Errorf("错误位置 = %s，应为客户端的位置", errs[0].Position)
+			}
+		})
+	}
+}
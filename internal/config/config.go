@@ -1,6 +1,7 @@
-// Package config 处理从环境变量和 .env 文件加载配置。
+// Package config 处理从环境变量、.env 文件和结构化配置文件加载配置。
 //
-// 它支持多个配置文件位置（./.env、~/.claude/proxy.env、~/.claude-code-proxy），
+// 它支持多个 .env 文件位置（./.env、~/.claude/proxy.env、~/.claude-code-proxy）
+// 和分层合并的 YAML 配置文件（~/.claude/proxy.yaml、./.claude/proxy.yaml），
 // 并根据 OPENAI_BASE_URL 检测提供商类型（OpenRouter、OpenAI、Ollama）。
 // 该包还处理模型覆盖，用于将 Claude 模型名称路由到替代提供商。
 package config
@@ -10,6 +11,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -24,30 +27,155 @@ const (
 	ProviderOpenRouter ProviderType = "openrouter"
 	ProviderOpenAI     ProviderType = "openai"
 	ProviderOllama     ProviderType = "ollama"
+	ProviderGemini     ProviderType = "gemini"
+	ProviderAzure      ProviderType = "azure"
+	ProviderBedrock    ProviderType = "bedrock"
+	ProviderAnthropic  ProviderType = "anthropic"
 	ProviderUnknown    ProviderType = "unknown"
 )
 
-// CacheKey 唯一标识用于能力缓存的（提供商，模型）组合
-// 使用结构体作为 map 键提供类型安全性和零冲突风险
-type CacheKey struct {
-	BaseURL string // 提供商基础 URL（例如 "https://openrouter.ai/api/v1"）
-	Model   string // 模型名称（例如 "gpt-5"、"openai/gpt-5"）
+// Protocol 表示与上游通信使用的 API 协议
+type Protocol string
+
+const (
+	// ProtocolChatCompletions OpenAI 聊天完成 API（/chat/completions），所有 OpenAI 兼容后端都支持
+	ProtocolChatCompletions Protocol = "chat_completions"
+	// ProtocolResponses OpenAI Responses API（/responses），支持推理摘要和加密推理项
+	ProtocolResponses Protocol = "responses"
+	// ProtocolGemini Gemini 原生 API（generateContent/streamGenerateContent），保留思考签名
+	ProtocolGemini Protocol = "gemini"
+	// ProtocolOllama Ollama 原生 API（/api/chat），支持 options、NDJSON 流式和真实令牌计数
+	ProtocolOllama Protocol = "ollama"
+	// ProtocolBedrock AWS Bedrock Converse API（/model/{模型}/converse），使用 SigV4 签名
+	ProtocolBedrock Protocol = "bedrock"
+	// ProtocolAnthropic Anthropic Messages API（/v1/messages），Claude 请求不经格式转换直接转发
+	ProtocolAnthropic Protocol = "anthropic"
+)
+
+// DefaultBackendName 是由 OPENAI_* 环境变量定义的默认后端名称
+const DefaultBackendName = "default"
+
+// defaultWatchInterval 未设置 CONFIG_WATCH_INTERVAL 时检查配置文件变化的间隔
+const defaultWatchInterval = 2 * time.Second
+
+// Backend 描述一个上游后端的连接设置。
+// 默认后端由 OPENAI_BASE_URL、OPENAI_API_KEY 等环境变量构建。
+// 超时字段为零值时使用提供商的默认值。
+type Backend struct {
+	Name    string // 后端名称（用于日志和状态端点）
+	BaseURL string // API 基础 URL
+	APIKey  string // API 密钥
+
+	// Provider 显式指定的提供商类型（为空时根据基础 URL 检测）
+	Provider ProviderType
+
+	// Protocol 上游 API 协议（默认为聊天完成）
+	Protocol Protocol
+
+	// DisableStreaming 后端不支持流式传输（配置 streaming: false），请求以非流式调用上游
+	DisableStreaming bool
+
+	// 超时设置
+	RequestTimeout time.Duration // 非流式请求总超时
+	StreamTimeout  time.Duration // 流式请求总超时
+	IdleTimeout    time.Duration // 流式空闲超时：超过该时间未收到任何字节视为上游停滞
+	PingInterval   time.Duration // 上游长时间静默时向客户端发送 ping 事件的间隔
+
+	// 连接池设置（同一后端的所有请求共享一个 http.Transport）
+	HTTP HTTPSettings
+
+	// Ollama 原生协议的模型运行参数
+	Ollama OllamaSettings
+
+	// Azure OpenAI 的 API 版本和部署映射
+	Azure AzureSettings
+
+	// AWS Bedrock 的区域和凭证来源
+	Bedrock BedrockSettings
+
+	// Anthropic 原生协议的认证方式和请求字段过滤
+	Anthropic AnthropicSettings
 }
 
-// ModelCapabilities 跟踪特定模型支持的参数
-// 这是通过自适应重试机制动态学习的
-type ModelCapabilities struct {
-	UsesMaxCompletionTokens bool      // 此模型是否使用 max_completion_tokens？
-	LastChecked             time.Time // 上次验证时间
+// AnthropicSettings 描述 Anthropic 原生协议后端的认证方式和请求字段过滤。
+type AnthropicSettings struct {
+	AuthHeader  string   // 认证头：x-api-key 或 bearer（Authorization: Bearer）
+	Version     string   // anthropic-version 请求头
+	StripFields []string // 转发前从请求体中删除的字段（任意层级，用于不支持这些字段的网关）
 }
 
-// 全局能力缓存（(baseURL, model) -> capabilities）
-// 由互斥锁保护，用于跨并发请求的线程安全访问
-var (
-	modelCapabilityCache = make(map[CacheKey]*ModelCapabilities)
-	capabilityCacheMutex sync.RWMutex
+// Anthropic 原生协议的默认设置
+const (
+	AnthropicAuthXAPIKey    = "x-api-key"
+	AnthropicAuthBearer     = "bearer"
+	DefaultAnthropicVersion = "2023-06-01"
 )
 
+// AzureSettings 描述 Azure OpenAI 后端的 API 版本和部署映射。
+type AzureSettings struct {
+	APIVersion  string            // api-version 查询参数
+	Deployments map[string]string // 后端模型名称（模型路由的结果）-> 部署名称
+}
+
+// DefaultAzureAPIVersion 未设置 AZURE_OPENAI_API_VERSION 时使用的 API 版本
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// OllamaSettings 描述 Ollama 原生 /api/chat 协议的模型运行参数。
+// 零值字段使用默认值。
+type OllamaSettings struct {
+	NumCtx    int    // 固定上下文窗口大小（零值表示按请求估算的令牌数自动调整）
+	MaxNumCtx int    // 自动调整时的上下文窗口上限
+	KeepAlive string // 模型在内存中保留的时间（如 "30m"，空值使用 Ollama 的默认值）
+}
+
+// HTTPSettings 描述后端共享 HTTP 传输层的连接池参数。
+// 零值字段使用默认值；结构体可比较，用于判断设置是否变化。
+type HTTPSettings struct {
+	MaxIdleConns          int           // 所有主机的最大空闲连接数
+	MaxIdleConnsPerHost   int           // 每个主机的最大空闲连接数
+	IdleConnTimeout       time.Duration // 空闲连接保持时间
+	TLSHandshakeTimeout   time.Duration // TLS 握手超时
+	ResponseHeaderTimeout time.Duration // 等待响应头的超时（零值表示不限制）
+	DisableHTTP2          bool          // 禁用 HTTP/2（默认尝试 HTTP/2）
+}
+
+// DetectProvider 返回后端的提供商类型：优先使用显式指定的类型，Bedrock 和 Anthropic 协议固定为对应类型，否则根据基础 URL 识别
+func (b *Backend) DetectProvider() ProviderType {
+	if b.Provider != "" {
+		return b.Provider
+	}
+	switch b.Protocol {
+	case ProtocolBedrock:
+		return ProviderBedrock
+	case ProtocolAnthropic:
+		return ProviderAnthropic
+	}
+	return detectProviderFromURL(b.BaseURL)
+}
+
+// IsLocalhost 如果后端基础 URL 指向 localhost 则返回 true
+func (b *Backend) IsLocalhost() bool {
+	return isLocalhostURL(b.BaseURL)
+}
+
+// AzureDeployment 返回后端模型对应的 Azure 部署名称，未映射时部署名称与模型名称相同
+func (b *Backend) AzureDeployment(model string) string {
+	if deployment, ok := b.Azure.Deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
+// OllamaAPIURL 返回 Ollama 原生 API 的完整 URL（基础 URL 中的 OpenAI 兼容 /v1 后缀会被去掉）
+func (b *Backend) OllamaAPIURL(path string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(b.BaseURL, "/"), "/v1") + path
+}
+
+// UsesResponsesAPI 如果后端使用 OpenAI Responses API 协议则返回 true
+func (b *Backend) UsesResponsesAPI() bool {
+	return b.Protocol == ProtocolResponses
+}
+
 // Config 保存所有代理配置
 type Config struct {
 	// 必需
@@ -57,6 +185,18 @@ type Config struct {
 	OpenAIBaseURL   string
 	AnthropicAPIKey string
 
+	// 入站认证
+	// AuthKeysFile 密钥文件路径，文件中的多个密钥对应命名客户端（与 ANTHROPIC_API_KEY 可以同时使用）
+	AuthKeysFile string
+	// Clients 密钥文件中定义的客户端（名称 -> 客户端）
+	Clients map[string]*Client
+
+	// 客户端用量和预算
+	// UsageStoreFile 客户端用量文件路径（每日和每月用量在重启后继续累计，修改后需要重启）
+	UsageStoreFile string
+	// Pricing 模型价格表，用于计算客户端的费用（只能在配置文件中定义）
+	Pricing []ModelPrice
+
 	// 模型路由（如果未设置则基于模式）
 	OpusModel   string
 	SonnetModel string
@@ -75,68 +215,305 @@ type Config struct {
 	// 直通模式 - 直接代理到 Anthropic 而不进行转换
 	PassthroughMode bool
 
+	// 上游流式设置
+	// DisableUpstreamStreaming 后端不支持流式时，以非流式调用并重放为 Claude SSE
+	DisableUpstreamStreaming bool
+	// NonStreamingModels 禁用上游流式传输的模型列表（后端模型名称）
+	NonStreamingModels []string
+	// StreamAggregation 非流式 Claude 请求改用流式上游调用并聚合结果，避免长生成超时
+	StreamAggregation bool
+
+	// 模型列表端点设置
+	// ModelsIncludeUpstream 在 /v1/models 中合并上游后端的模型列表
+	ModelsIncludeUpstream bool
+	// ModelsCacheTTL 上游模型列表的缓存时间
+	ModelsCacheTTL time.Duration
+
 	// OpenRouter 特定（可选，改善速率限制）
 	OpenRouterAppName string
 	OpenRouterAppURL  string
+
+	// 上游后端（名称 -> 后端），至少包含默认后端
+	Backends map[string]*Backend
+
+	// Routes 模型路由规则（按顺序匹配，只能在配置文件中定义）。
+	// 没有规则匹配时按名称中的 opus/sonnet/haiku 层级路由到默认后端。
+	Routes []ModelRoute
+	// ModelOverrideBackends 单个请求可以通过 x-proxy-model 请求头或模型名称的 @ 后缀指定的后端。
+	// 为空时允许所有已配置的后端，"none" 禁用覆盖。
+	ModelOverrideBackends []string
+
+	// ClampMaxTokens 没有路由策略设置 clamp.max_tokens 时，按已知模型的最大输出令牌数限制 max_tokens
+	ClampMaxTokens bool
+
+	// CapabilityCacheTTL 学习到的模型能力（如是否支持 max_completion_tokens）的有效期，过期后重新检测（零值表示不过期）
+	CapabilityCacheTTL time.Duration
+
+	// 配置重新加载设置
+	// WatchInterval 检查配置文件变化的间隔（零值表示只在收到 SIGHUP 时重新加载）
+	WatchInterval time.Duration
+	// WatchedFiles 需要检查变化的文件（所有 .env 和结构化配置文件位置，包括尚不存在的文件）
+	WatchedFiles []string
+
+	// ConfigFiles 已加载的结构化配置文件（按合并顺序）
+	ConfigFiles []string
+	// Sources 来自配置文件的设置在文件中的位置，用于验证错误。
+	// 顶层设置和默认后端按环境变量名索引，其他后端按点分路径（如 backends.local.base_url）索引。
+	Sources map[string]Position
 }
 
-// Load 从环境变量读取配置
-// 尝试多个位置：./.env、~/.claude/proxy.env、~/.claude-code-proxy
+// Load 从环境变量和配置文件读取配置。
+// .env 文件尝试多个位置：./.env、~/.claude/proxy.env、~/.claude-code-proxy（使用找到的第一个）；
+// 结构化配置文件依次合并 ~/.claude/proxy.yaml、./.claude/proxy.yaml 和 CLAUDE_PROXY_CONFIG 指定的文件，
+// 环境变量（包括 .env 文件中的设置）覆盖配置文件中的对应设置。
 func Load() (*Config, error) {
 	// 按优先级顺序尝试加载 .env 文件
-	locations := []string{
-		".env",
-		filepath.Join(os.Getenv("HOME"), ".claude", "proxy.env"),
-		filepath.Join(os.Getenv("HOME"), ".claude-code-proxy"),
-	}
+	locations := dotenvLocations()
+	loadDotenv(locations)
 
-	for _, loc := range locations {
-		if _, err := os.Stat(loc); err == nil {
-			// 文件存在，加载它（overload 以覆盖现有环境变量）
-			if err := godotenv.Overload(loc); err == nil {
-				fmt.Printf("📁 已从以下位置加载配置: %s\n", loc)
-				break
-			}
-		}
+	// 结构化配置文件（在 .env 之后加载，以便 ${VAR} 引用 .env 中的变量）
+	files, err := loadConfigFiles()
+	if err != nil {
+		return nil, err
 	}
+	for _, file := range files.files {
+		fmt.Printf("📁 已从以下位置加载配置: %s\n", file)
+	}
+	env := settings{useEnv: true, file: files.global}
 
 	// 从环境构建配置
 	cfg := &Config{
-		OpenAIAPIKey:    os.Getenv("OPENAI_API_KEY"),
-		OpenAIBaseURL:   getEnvOrDefault("OPENAI_BASE_URL", "https://api.openai.com/v1"),
-		AnthropicAPIKey: os.Getenv("ANTHROPIC_API_KEY"),
+		AnthropicAPIKey: env.get("ANTHROPIC_API_KEY"),
+		AuthKeysFile:    env.get("AUTH_KEYS_FILE"),
+
+		// 客户端用量和预算
+		UsageStoreFile: env.getOrDefault("USAGE_STORE_FILE", filepath.Join(os.Getenv("HOME"), ".claude", "proxy-usage.json")),
+		Pricing:        files.pricing,
 
 		// 基于模式的路由（可选覆盖）
-		OpusModel:   os.Getenv("ANTHROPIC_DEFAULT_OPUS_MODEL"),
-		SonnetModel: os.Getenv("ANTHROPIC_DEFAULT_SONNET_MODEL"),
-		HaikuModel:  os.Getenv("ANTHROPIC_DEFAULT_HAIKU_MODEL"),
+		OpusModel:   env.get("ANTHROPIC_DEFAULT_OPUS_MODEL"),
+		SonnetModel: env.get("ANTHROPIC_DEFAULT_SONNET_MODEL"),
+		HaikuModel:  env.get("ANTHROPIC_DEFAULT_HAIKU_MODEL"),
 
 		// 服务器设置
-		Host: getEnvOrDefault("HOST", "0.0.0.0"),
-		Port: getEnvOrDefault("PORT", "8082"),
+		Host: env.getOrDefault("HOST", "0.0.0.0"),
+		Port: env.getOrDefault("PORT", "8082"),
 
 		// 直通模式
-		PassthroughMode: getEnvAsBoolOrDefault("PASSTHROUGH_MODE", false),
+		PassthroughMode: env.getBoolOrDefault("PASSTHROUGH_MODE", false),
+
+		// 上游流式设置
+		DisableUpstreamStreaming: env.getBoolOrDefault("DISABLE_UPSTREAM_STREAMING", false),
+		NonStreamingModels:       env.getList("NON_STREAMING_MODELS"),
+		StreamAggregation:        env.getBoolOrDefault("STREAM_AGGREGATION", false),
+
+		// 按已知模型的最大输出令牌数限制 max_tokens
+		ClampMaxTokens: env.getBoolOrDefault("CLAMP_MAX_TOKENS", true),
+
+		// 模型列表端点设置
+		ModelsIncludeUpstream: env.getBoolOrDefault("MODELS_INCLUDE_UPSTREAM", false),
+		ModelsCacheTTL:        env.getDurationOrDefault("MODELS_CACHE_TTL", 10*time.Minute),
 
 		// OpenRouter 特定（可选）
-		OpenRouterAppName: os.Getenv("OPENROUTER_APP_NAME"),
-		OpenRouterAppURL:  os.Getenv("OPENROUTER_APP_URL"),
+		OpenRouterAppName: env.get("OPENROUTER_APP_NAME"),
+		OpenRouterAppURL:  env.get("OPENROUTER_APP_URL"),
+
+		CapabilityCacheTTL: env.getDurationOrDefault("CAPABILITY_CACHE_TTL", defaultCapabilityCacheTTL),
+
+		// 配置重新加载
+		WatchInterval: env.getDurationOrDefault("CONFIG_WATCH_INTERVAL", defaultWatchInterval),
+		WatchedFiles:  append(locations, configFileLocations()...),
+
+		Routes:                files.routes,
+		ModelOverrideBackends: env.getList("MODEL_OVERRIDE_BACKENDS"),
+
+		ConfigFiles: files.files,
+		Sources:     make(map[string]Position),
+	}
+	for key := range files.global {
+		if value, ok := env.fromFile(key); ok {
+			cfg.Sources[key] = value.pos
+		}
 	}
 
-	// 验证必需字段
-	// 允许 Ollama（localhost 端点）缺少 API 密钥
-	if cfg.OpenAIAPIKey == "" {
-		if !strings.Contains(cfg.OpenAIBaseURL, "localhost") &&
-			!strings.Contains(cfg.OpenAIBaseURL, "127.0.0.1") {
-			return nil, fmt.Errorf("OPENAI_API_KEY 是必需的（除非使用 localhost/Ollama）")
+	// 入站客户端密钥文件（修改后与其他配置文件一样重新加载）
+	if cfg.AuthKeysFile != "" {
+		clients, err := loadClients(cfg.AuthKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Clients = clients
+		cfg.WatchedFiles = append(cfg.WatchedFiles, cfg.AuthKeysFile)
+		fmt.Printf("📁 已从 %s 加载 %d 个客户端\n", cfg.AuthKeysFile, len(clients))
+	}
+
+	// 默认后端（由 OPENAI_* 及超时环境变量和配置文件中的 backends.default 构建）
+	defaultSettings := settings{useEnv: true, file: files.backends[DefaultBackendName]}
+	defaultBackend, err := loadBackend(DefaultBackendName, defaultSettings, "https://api.openai.com/v1")
+	if err != nil {
+		return nil, err
+	}
+	cfg.OpenAIBaseURL = defaultBackend.BaseURL
+	cfg.OpenAIAPIKey = defaultBackend.APIKey
+	cfg.Backends = map[string]*Backend{DefaultBackendName: defaultBackend}
+	for key := range defaultSettings.file {
+		if value, ok := defaultSettings.fromFile(key); ok {
+			cfg.Sources[key] = value.pos
+		}
+	}
+
+	// 配置文件中定义的其他后端（只读取文件，可以用 ${VAR} 引用环境变量）
+	var errs ValidationErrors
+	for _, name := range files.backendNames() {
+		if name == DefaultBackendName {
+			continue
+		}
+		backendSettings := settings{file: files.backends[name]}
+		cfg.Sources["backends."+name] = files.backendPos[name]
+		for _, value := range backendSettings.file {
+			cfg.Sources[value.path] = value.pos
 		}
-		// 为 Ollama 设置虚拟密钥
-		cfg.OpenAIAPIKey = "ollama"
+		backend, err := loadBackend(name, backendSettings, "")
+		if err != nil {
+			errs = append(errs, ValidationError{
+				Field:    "backends." + name,
+				Position: files.backendPos[name],
+				Message:  err.Error(),
+			})
+			continue
+		}
+		cfg.Backends[name] = backend
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	return cfg, nil
 }
 
+// dotenvLocations 返回按优先级排列的 .env 文件位置
+func dotenvLocations() []string {
+	return []string{
+		".env",
+		filepath.Join(os.Getenv("HOME"), ".claude", "proxy.env"),
+		filepath.Join(os.Getenv("HOME"), ".claude-code-proxy"),
+	}
+}
+
+// dotenvOriginals 记录被 .env 文件覆盖的环境变量的原值（nil 表示原来未设置）。
+// 重新加载时先恢复原值，使从 .env 中删除的设置不再生效。
+var (
+	dotenvOriginals = make(map[string]*string)
+	dotenvMutex     sync.Mutex
+)
+
+// loadDotenv 加载找到的第一个 .env 文件（覆盖现有环境变量）
+func loadDotenv(locations []string) {
+	dotenvMutex.Lock()
+	defer dotenvMutex.Unlock()
+
+	for key, original := range dotenvOriginals {
+		if original == nil {
+			_ = os.Unsetenv(key)
+		} else {
+			_ = os.Setenv(key, *original)
+		}
+	}
+	dotenvOriginals = make(map[string]*string)
+
+	for _, loc := range locations {
+		if _, err := os.Stat(loc); err != nil {
+			continue
+		}
+		// 文件存在，加载它
+		values, err := godotenv.Read(loc)
+		if err != nil {
+			continue
+		}
+		for key, value := range values {
+			if original, ok := os.LookupEnv(key); ok {
+				dotenvOriginals[key] = &original
+			} else {
+				dotenvOriginals[key] = nil
+			}
+			_ = os.Setenv(key, value)
+		}
+		fmt.Printf("📁 已从以下位置加载配置: %s\n", loc)
+		break
+	}
+}
+
+// loadBackend 根据设置构建后端。
+// defaultBaseURL 为未设置基础 URL 时使用的地址（配置文件中的其他后端没有默认值，由 Validate 报告）。
+func loadBackend(name string, s settings, defaultBaseURL string) (*Backend, error) {
+	protocol := parseProtocol(s.get("OPENAI_PROTOCOL"))
+	baseURL := s.getOrDefault("OPENAI_BASE_URL", defaultBaseURL)
+	apiKey := s.get("OPENAI_API_KEY")
+
+	// Bedrock 使用 AWS 凭证；未设置基础 URL 时使用区域的 Bedrock Runtime 端点
+	var bedrock BedrockSettings
+	if protocol == ProtocolBedrock {
+		bedrock = loadBedrockSettings(s, s.get("OPENAI_BASE_URL"))
+		if bedrock.Region == "" {
+			return nil, fmt.Errorf("Bedrock 需要 AWS_REGION 或 bedrock-runtime 形式的 OPENAI_BASE_URL")
+		}
+		if s.get("OPENAI_BASE_URL") == "" {
+			baseURL = BedrockRuntimeURL(bedrock.Region)
+		}
+	}
+
+	// 验证必需字段
+	// 允许 Ollama（localhost 端点或 Ollama 原生协议）和 Bedrock 缺少 API 密钥
+	if apiKey == "" {
+		if !strings.Contains(baseURL, "localhost") &&
+			!strings.Contains(baseURL, "127.0.0.1") &&
+			protocol != ProtocolOllama && protocol != ProtocolBedrock {
+			if name != DefaultBackendName {
+				return nil, fmt.Errorf("api_key 是必需的（除非使用 localhost/Ollama/Bedrock）")
+			}
+			return nil, fmt.Errorf("OPENAI_API_KEY 是必需的（除非使用 localhost/Ollama/Bedrock）")
+		}
+		// 设置虚拟密钥（Ollama 和 Bedrock 不使用）
+		apiKey = "ollama"
+	}
+
+	return &Backend{
+		Name:           name,
+		BaseURL:        baseURL,
+		APIKey:         apiKey,
+		Provider:       parseProviderType(s.get("OPENAI_PROVIDER")),
+		Protocol:       protocol,
+		RequestTimeout: s.getDurationOrDefault("REQUEST_TIMEOUT", 0),
+		StreamTimeout:  s.getDurationOrDefault("STREAM_TIMEOUT", 0),
+		IdleTimeout:    s.getDurationOrDefault("STREAM_IDLE_TIMEOUT", 0),
+		PingInterval:   s.getDurationOrDefault("STREAM_PING_INTERVAL", 0),
+		HTTP: HTTPSettings{
+			MaxIdleConns:          s.getIntOrDefault("HTTP_MAX_IDLE_CONNS", 0),
+			MaxIdleConnsPerHost:   s.getIntOrDefault("HTTP_MAX_IDLE_CONNS_PER_HOST", 0),
+			IdleConnTimeout:       s.getDurationOrDefault("HTTP_IDLE_CONN_TIMEOUT", 0),
+			TLSHandshakeTimeout:   s.getDurationOrDefault("HTTP_TLS_HANDSHAKE_TIMEOUT", 0),
+			ResponseHeaderTimeout: s.getDurationOrDefault("HTTP_RESPONSE_HEADER_TIMEOUT", 0),
+			DisableHTTP2:          s.getBoolOrDefault("DISABLE_HTTP2", false),
+		},
+		Ollama: OllamaSettings{
+			NumCtx:    s.getIntOrDefault("OLLAMA_NUM_CTX", 0),
+			MaxNumCtx: s.getIntOrDefault("OLLAMA_MAX_NUM_CTX", 0),
+			KeepAlive: s.get("OLLAMA_KEEP_ALIVE"),
+		},
+		Azure: AzureSettings{
+			APIVersion:  s.getOrDefault("AZURE_OPENAI_API_VERSION", DefaultAzureAPIVersion),
+			Deployments: s.getMap("AZURE_OPENAI_DEPLOYMENTS"),
+		},
+		Bedrock: bedrock,
+		Anthropic: AnthropicSettings{
+			AuthHeader:  parseAnthropicAuthHeader(s.get("ANTHROPIC_UPSTREAM_AUTH")),
+			Version:     s.getOrDefault("ANTHROPIC_UPSTREAM_VERSION", DefaultAnthropicVersion),
+			StripFields: s.getList("ANTHROPIC_UPSTREAM_STRIP_FIELDS"),
+		},
+		DisableStreaming: !s.getBoolOrDefault("OPENAI_STREAMING", true),
+	}, nil
+}
+
 // LoadWithDebug 加载配置并设置调试模式
 func LoadWithDebug(debug bool) (*Config, error) {
 	cfg, err := Load()
@@ -147,100 +524,264 @@ func LoadWithDebug(debug bool) (*Config, error) {
 	return cfg, nil
 }
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// parseDuration 解析时长设置：纯数字为秒数，否则为 Go 时长格式（例如 "90s"、"2m"）
+func parseDuration(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, true
 	}
-	return defaultValue
+	return 0, false
 }
 
-func getEnvAsBoolOrDefault(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		return value == "true" || value == "1" || value == "yes"
+// parseProtocol 解析上游协议名称；未设置或无法识别时使用聊天完成协议
+func parseProtocol(value string) Protocol {
+	protocol, ok := lookupProtocol(value)
+	if !ok {
+		fmt.Printf("⚠️  未知的上游协议 %q，使用 chat_completions\n", value)
 	}
-	return defaultValue
+	return protocol
 }
 
-// DetectProvider 根据基础 URL 识别提供商类型
-func (c *Config) DetectProvider() ProviderType {
-	baseURL := strings.ToLower(c.OpenAIBaseURL)
+// lookupProtocol 查找协议名称（包括别名），未设置时为聊天完成协议
+func lookupProtocol(value string) (Protocol, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "responses", "response":
+		return ProtocolResponses, true
+	case "gemini":
+		return ProtocolGemini, true
+	case "ollama":
+		return ProtocolOllama, true
+	case "bedrock", "converse":
+		return ProtocolBedrock, true
+	case "anthropic", "messages", "claude":
+		return ProtocolAnthropic, true
+	case "", "chat_completions", "chat", "completions":
+		return ProtocolChatCompletions, true
+	default:
+		return ProtocolChatCompletions, false
+	}
+}
 
-	if strings.Contains(baseURL, "openrouter.ai") {
-		return ProviderOpenRouter
+// isKnownProtocol 如果协议名称可以识别则返回 true
+func isKnownProtocol(value string) bool {
+	_, ok := lookupProtocol(value)
+	return ok
+}
+
+// parseAnthropicAuthHeader 解析 ANTHROPIC_UPSTREAM_AUTH 设置，默认使用 x-api-key
+func parseAnthropicAuthHeader(value string) string {
+	authHeader, ok := lookupAnthropicAuthHeader(value)
+	if !ok {
+		fmt.Printf("⚠️  未知的 Anthropic 认证方式 %q，使用 x-api-key\n", value)
 	}
-	if strings.Contains(baseURL, "api.openai.com") {
-		return ProviderOpenAI
+	return authHeader
+}
+
+// lookupAnthropicAuthHeader 查找 Anthropic 认证方式，未设置时为 x-api-key
+func lookupAnthropicAuthHeader(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", AnthropicAuthXAPIKey:
+		return AnthropicAuthXAPIKey, true
+	case AnthropicAuthBearer, "authorization":
+		return AnthropicAuthBearer, true
+	default:
+		return AnthropicAuthXAPIKey, false
 	}
-	if strings.Contains(baseURL, "localhost") || strings.Contains(baseURL, "127.0.0.1") {
-		return ProviderOllama
+}
+
+// isKnownAnthropicAuthHeader 如果 Anthropic 认证方式可以识别则返回 true
+func isKnownAnthropicAuthHeader(value string) bool {
+	_, ok := lookupAnthropicAuthHeader(value)
+	return ok
+}
+
+// parseProviderType 解析 OPENAI_PROVIDER 设置，为空或 auto 时返回空值（根据 URL 检测）
+func parseProviderType(value string) ProviderType {
+	providerType, ok := lookupProviderType(value)
+	if !ok {
+		fmt.Printf("⚠️  未知的提供商类型 %q，根据基础 URL 自动检测\n", value)
 	}
-	return ProviderUnknown
+	return providerType
 }
 
-// IsLocalhost 如果基础 URL 指向 localhost 则返回 true
-func (c *Config) IsLocalhost() bool {
-	baseURL := strings.ToLower(c.OpenAIBaseURL)
-	return strings.Contains(baseURL, "localhost") || strings.Contains(baseURL, "127.0.0.1")
+// lookupProviderType 查找提供商类型，为空或 auto 时返回空值
+func lookupProviderType(value string) (ProviderType, bool) {
+	switch providerType := ProviderType(strings.ToLower(strings.TrimSpace(value))); providerType {
+	case "", "auto":
+		return "", true
+	case ProviderOpenRouter, ProviderOpenAI, ProviderOllama, ProviderAzure:
+		return providerType, true
+	case "generic":
+		return ProviderUnknown, true
+	default:
+		return "", false
+	}
 }
 
+// isKnownProviderType 如果提供商类型可以识别则返回 true
+func isKnownProviderType(value string) bool {
+	_, ok := lookupProviderType(value)
+	return ok
+}
 
-// GetModelCapabilities 检索（提供商，模型）组合的缓存能力。
-// 如果尚未缓存任何能力（此模型的首次请求），则返回 nil。
-// 使用读锁保证线程安全。
-func GetModelCapabilities(key CacheKey) *ModelCapabilities {
-	capabilityCacheMutex.RLock()
-	defer capabilityCacheMutex.RUnlock()
-	return modelCapabilityCache[key]
+// splitList 拆分逗号分隔的列表，去除空白和空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
-// SetModelCapabilities 缓存（提供商，模型）组合的能力。
-// 在通过自适应重试检测到特定模型支持哪些参数后调用。
-// 使用写锁保证线程安全。
-func SetModelCapabilities(key CacheKey, capabilities *ModelCapabilities) {
-	capabilityCacheMutex.Lock()
-	defer capabilityCacheMutex.Unlock()
-	capabilities.LastChecked = time.Now()
-	modelCapabilityCache[key] = capabilities
+// parseMap 解析逗号分隔的 key=value 列表（如 "gpt-4o=prod-gpt4o,gpt-4o-mini=mini"）。
+// 格式错误的项会打印警告并跳过。
+func parseMap(key, value string) map[string]string {
+	items := splitList(value)
+	if len(items) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(items))
+	for _, item := range items {
+		k, v, ok := strings.Cut(item, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			fmt.Printf("⚠️  %s 中的无效项 %q（应为 key=value），已忽略\n", key, item)
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// DetectProvider 返回默认后端的提供商类型
+func (c *Config) DetectProvider() ProviderType {
+	return c.DefaultBackend().DetectProvider()
+}
+
+// IsLocalhost 如果基础 URL 指向 localhost 则返回 true
+func (c *Config) IsLocalhost() bool {
+	return isLocalhostURL(c.OpenAIBaseURL)
 }
 
-// ShouldUseMaxCompletionTokens 根据通过自适应检测学习到的缓存模型能力，
-// 确定是否应发送 max_completion_tokens。
-// 没有硬编码的模型模式 - 首次请求时对所有模型都尝试 max_completion_tokens。
-func (c *Config) ShouldUseMaxCompletionTokens(modelName string) bool {
-	// 为此（提供商，模型）组合构建缓存键
-	key := CacheKey{
+// DefaultBackend 返回默认后端。
+// 如果配置不是通过 Load 创建的（没有 Backends），则根据 OpenAI 字段临时构建。
+func (c *Config) DefaultBackend() *Backend {
+	if b, ok := c.Backends[DefaultBackendName]; ok {
+		return b
+	}
+	return &Backend{
+		Name:    DefaultBackendName,
 		BaseURL: c.OpenAIBaseURL,
-		Model:   modelName,
+		APIKey:  c.OpenAIAPIKey,
 	}
+}
+
+// modelOverrideNone 是 MODEL_OVERRIDE_BACKENDS 中禁用单个请求覆盖的值
+const modelOverrideNone = "none"
 
-	// 检查是否有关于此特定模型的缓存知识
-	caps := GetModelCapabilities(key)
-	if caps != nil {
-		// 缓存命中 - 使用已学习的能力
-		if c.Debug {
-			fmt.Printf("[调试] 缓存命中: %s → max_completion_tokens=%v\n",
-				modelName, caps.UsesMaxCompletionTokens)
+// ModelOverrideEnabled 如果允许单个请求覆盖后端和模型则返回 true
+func (c *Config) ModelOverrideEnabled() bool {
+	for _, name := range c.ModelOverrideBackends {
+		if name == modelOverrideNone {
+			return false
 		}
-		return caps.UsesMaxCompletionTokens
 	}
+	return true
+}
 
-	// 缓存未命中 - 默认首先尝试 max_completion_tokens
-	// handlers.go 中的重试机制将检测是否不支持
-	// 并自动回退到 max_tokens，然后缓存结果
-	if c.Debug {
-		fmt.Printf("[调试] 缓存未命中: %s → 将自动检测（尝试 max_completion_tokens）\n", modelName)
+// ModelOverrideAllowed 判断单个请求是否可以覆盖到指定后端：后端必须已配置，
+// 设置了 MODEL_OVERRIDE_BACKENDS 时还必须在列表中
+func (c *Config) ModelOverrideAllowed(backend string) bool {
+	if _, ok := c.Backends[backend]; !ok || !c.ModelOverrideEnabled() {
+		return false
 	}
-	return true
+	if len(c.ModelOverrideBackends) == 0 {
+		return true
+	}
+	for _, name := range c.ModelOverrideBackends {
+		if name == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// WithBackend 返回以指定后端作为默认后端的配置副本，用于按路由规则把请求发送到其他后端。
+// 名称为空、为默认后端或不存在时返回原配置。
+func (c *Config) WithBackend(name string) *Config {
+	backend, ok := c.Backends[name]
+	if !ok || name == DefaultBackendName {
+		return c
+	}
+	routed := *c
+	routed.Backends = make(map[string]*Backend, len(c.Backends))
+	for n, b := range c.Backends {
+		routed.Backends[n] = b
+	}
+	routed.Backends[DefaultBackendName] = backend
+	routed.OpenAIBaseURL = backend.BaseURL
+	routed.OpenAIAPIKey = backend.APIKey
+	return &routed
+}
+
+// detectProviderFromURL 根据 URL 识别提供商类型
+func detectProviderFromURL(rawURL string) ProviderType {
+	baseURL := strings.ToLower(rawURL)
+
+	if strings.Contains(baseURL, "openrouter.ai") {
+		return ProviderOpenRouter
+	}
+	if strings.Contains(baseURL, "api.openai.com") {
+		return ProviderOpenAI
+	}
+	if strings.Contains(baseURL, ".openai.azure.com") || strings.Contains(baseURL, ".cognitiveservices.azure.com") {
+		return ProviderAzure
+	}
+	if isLocalhostURL(baseURL) {
+		return ProviderOllama
+	}
+	return ProviderUnknown
+}
+
+// isLocalhostURL 如果 URL 指向 localhost 则返回 true
+func isLocalhostURL(rawURL string) bool {
+	baseURL := strings.ToLower(rawURL)
+	return strings.Contains(baseURL, "localhost") || strings.Contains(baseURL, "127.0.0.1")
+}
+
+// IsStreamingDisabled 判断指定后端模型是否禁用上游流式传输。
+// 全局禁用或模型在 NON_STREAMING_MODELS 列表中时返回 true。
+func (c *Config) IsStreamingDisabled(modelName string) bool {
+	if c.DisableUpstreamStreaming {
+		return true
+	}
+	for _, m := range c.NonStreamingModels {
+		if strings.EqualFold(m, modelName) {
+			return true
+		}
+	}
+	return false
 }
 
 // ValidationError 表示配置验证错误
 type ValidationError struct {
-	Field   string // 出错的字段名
-	Message string // 错误描述
+	Field    string   // 出错的字段名
+	Position Position // 设置在配置文件中的位置（来自环境变量时为空）
+	Message  string   // 错误描述
 }
 
 // Error 实现 error 接口
 func (e *ValidationError) Error() string {
+	if !e.Position.IsZero() {
+		return fmt.Sprintf("%s: 配置验证错误 [%s]: %s", e.Position, e.Field, e.Message)
+	}
 	return fmt.Sprintf("配置验证错误 [%s]: %s", e.Field, e.Message)
 }
 
@@ -268,36 +809,7 @@ func (c *Config) Validate() error {
 	var errs ValidationErrors
 
 	// 验证 OpenAI Base URL
-	if c.OpenAIBaseURL == "" {
-		errs = append(errs, ValidationError{
-			Field:   "OPENAI_BASE_URL",
-			Message: "不能为空",
-		})
-	} else {
-		// 验证 URL 格式
-		parsedURL, err := url.Parse(c.OpenAIBaseURL)
-		if err != nil {
-			errs = append(errs, ValidationError{
-				Field:   "OPENAI_BASE_URL",
-				Message: fmt.Sprintf("URL 格式无效: %v", err),
-			})
-		} else {
-			// 验证 scheme
-			if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-				errs = append(errs, ValidationError{
-					Field:   "OPENAI_BASE_URL",
-					Message: fmt.Sprintf("URL scheme 必须是 http 或 https，当前为: %s", parsedURL.Scheme),
-				})
-			}
-			// 验证 host
-			if parsedURL.Host == "" {
-				errs = append(errs, ValidationError{
-					Field:   "OPENAI_BASE_URL",
-					Message: "URL 缺少主机名",
-				})
-			}
-		}
-	}
+	errs = append(errs, validateBaseURL("OPENAI_BASE_URL", c.OpenAIBaseURL)...)
 
 	// 验证 API Key（非本地环境必需）
 	if c.OpenAIAPIKey == "" {
@@ -338,15 +850,102 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// 验证配置文件中定义的其他后端
+	names := make([]string, 0, len(c.Backends))
+	for name := range c.Backends {
+		if name != DefaultBackendName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		errs = append(errs, validateBaseURL("backends."+name+".base_url", c.Backends[name].BaseURL)...)
+	}
+
+	// 验证路由规则引用的后端
+	for i, route := range c.Routes {
+		if _, ok := c.Backends[route.Backend]; route.Backend != "" && !ok {
+			errs = append(errs, ValidationError{
+				Field:    fmt.Sprintf("routes[%d].backend", i),
+				Position: route.Position,
+				Message:  fmt.Sprintf("后端 %q 未定义", route.Backend),
+			})
+		}
+	}
+
+	// 验证客户端引用的后端
+	errs = append(errs, c.validateClients()...)
+
+	// 验证允许覆盖的后端
+	for _, name := range c.ModelOverrideBackends {
+		if _, ok := c.Backends[name]; !ok && name != modelOverrideNone {
+			errs = append(errs, ValidationError{
+				Field:   "MODEL_OVERRIDE_BACKENDS",
+				Message: fmt.Sprintf("后端 %q 未定义", name),
+			})
+		}
+	}
+
 	// 验证模型配置（警告级别，不阻止启动）
 	// 这里只做格式检查，不验证模型是否存在
 
 	if len(errs) > 0 {
+		// 标注来自配置文件的设置的位置
+		for i := range errs {
+			if errs[i].Position.IsZero() {
+				errs[i].Position = c.sourceOf(errs[i].Field)
+			}
+		}
 		return errs
 	}
 	return nil
 }
 
+// sourceOf 返回设置在配置文件中的位置。
+// 配置文件中的后端缺少某个设置时，返回其上级设置（最终为后端定义）的位置。
+func (c *Config) sourceOf(field string) Position {
+	for {
+		if pos, ok := c.Sources[field]; ok {
+			return pos
+		}
+		i := strings.LastIndex(field, ".")
+		if i < 0 || !strings.HasPrefix(field[:i], "backends.") {
+			return Position{}
+		}
+		field = field[:i]
+	}
+}
+
+// validateBaseURL 验证后端基础 URL 的格式
+func validateBaseURL(field, baseURL string) ValidationErrors {
+	if baseURL == "" {
+		return ValidationErrors{{Field: field, Message: "不能为空"}}
+	}
+
+	// 验证 URL 格式
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return ValidationErrors{{Field: field, Message: fmt.Sprintf("URL 格式无效: %v", err)}}
+	}
+
+	var errs ValidationErrors
+	// 验证 scheme
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		errs = append(errs, ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("URL scheme 必须是 http 或 https，当前为: %s", parsedURL.Scheme),
+		})
+	}
+	// 验证 host
+	if parsedURL.Host == "" {
+		errs = append(errs, ValidationError{
+			Field:   field,
+			Message: "URL 缺少主机名",
+		})
+	}
+	return errs
+}
+
 // MustValidate 验证配置，如果无效则 panic
 // 用于程序启动时的配置检查
 func (c *Config) MustValidate() {
@@ -368,8 +967,15 @@ func (c *Config) ValidateWithWarnings() (error, []string) {
 	if c.OpenRouterAppURL == "" && c.DetectProvider() == ProviderOpenRouter {
 		warnings = append(warnings, "建议设置 OPENROUTER_APP_URL 以获得更好的速率限制")
 	}
-	if c.AnthropicAPIKey == "" {
-		warnings = append(warnings, "未设置 ANTHROPIC_API_KEY，将不验证入站请求的 API 密钥")
+	if !c.AuthEnabled() {
+		warnings = append(warnings, "未设置 ANTHROPIC_API_KEY 或 AUTH_KEYS_FILE，将不验证入站请求的 API 密钥")
+	}
+	if len(c.Pricing) == 0 {
+		for _, name := range c.clientNames() {
+			if budget := c.Clients[name].Budget; budget.Daily.Cost > 0 || budget.Monthly.Cost > 0 {
+				warnings = append(warnings, fmt.Sprintf("客户端 %s 设置了费用预算，但没有配置 pricing 价格表，费用将按 0 计算", name))
+			}
+		}
 	}
 
 	return err, warnings
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// diffSkipFields 是不参与比较的字段（来源位置和监视列表随配置文件变化，不是设置本身）
+var diffSkipFields = map[string]bool{
+	"Sources":      true,
+	"WatchedFiles": true,
+	"Position":     true,
+}
+
+// diffSecretFields 是只报告“已更改”而不显示值的字段
+var diffSecretFields = map[string]bool{
+	"OpenAIAPIKey":    true,
+	"AnthropicAPIKey": true,
+	"APIKey":          true,
+	"AccessKeyID":     true,
+	"SecretAccessKey": true,
+	"SessionToken":    true,
+	"Keys":            true,
+}
+
+// diffEntryMarker 标记映射中的条目（如一个命名后端），整个条目新增或删除时只报告一行
+const diffEntryMarker = "\x00entry"
+
+// Diff 比较两份配置，返回可读的变化列表（如 "SonnetModel: a → b"），按设置名称排序。
+// 密钥类字段不显示值。
+func Diff(oldCfg, newCfg *Config) []string {
+	oldValues := make(map[string]string)
+	newValues := make(map[string]string)
+	flattenConfig("", reflect.ValueOf(oldCfg), oldValues)
+	flattenConfig("", reflect.ValueOf(newCfg), newValues)
+
+	names := make([]string, 0, len(oldValues)+len(newValues))
+	for name := range oldValues {
+		names = append(names, name)
+	}
+	for name := range newValues {
+		if _, ok := oldValues[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []string
+	var entryPrefixes []string // 新增或删除的映射条目，其下的设置不再逐项报告
+	for _, name := range names {
+		oldValue, hadOld := oldValues[name]
+		newValue, hasNew := newValues[name]
+		if hadOld == hasNew && oldValue == newValue {
+			continue
+		}
+		if underEntry(name, entryPrefixes) {
+			continue
+		}
+
+		field := name[strings.LastIndex(name, ".")+1:]
+		switch {
+		case oldValue == diffEntryMarker || newValue == diffEntryMarker:
+			entryPrefixes = append(entryPrefixes, name+".")
+			if hasNew {
+				changes = append(changes, fmt.Sprintf("%s: 已添加", name))
+			} else {
+				changes = append(changes, fmt.Sprintf("%s: 已删除", name))
+			}
+		case diffSecretFields[field]:
+			changes = append(changes, fmt.Sprintf("%s: 已更改", name))
+		case !hadOld:
+			changes = append(changes, fmt.Sprintf("%s: （未设置）→ %s", name, newValue))
+		case !hasNew:
+			changes = append(changes, fmt.Sprintf("%s: %s → （未设置）", name, oldValue))
+		default:
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", name, oldValue, newValue))
+		}
+	}
+	return changes
+}
+
+// underEntry 判断设置是否属于已整体报告的映射条目
+func underEntry(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenConfig 将配置展开为 点分名称 -> 值 的映射。
+// 映射按键展开（如 Backends.local.BaseURL），零值不记录，使新增和删除的设置显示为（未设置）。
+// 结构体类型的映射条目额外记录一个标记，用于整体报告条目的新增和删除。
+func flattenConfig(prefix string, v reflect.Value, out map[string]string) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			flattenConfig(prefix, v.Elem(), out)
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || diffSkipFields[field.Name] {
+				continue
+			}
+			flattenConfig(join(field.Name), v.Field(i), out)
+		}
+
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			entry := join(fmt.Sprint(key.Interface()))
+			if v.MapIndex(key).Kind() == reflect.Ptr || v.MapIndex(key).Kind() == reflect.Struct {
+				out[entry] = diffEntryMarker
+			}
+			flattenConfig(entry, v.MapIndex(key), out)
+		}
+
+	case reflect.Slice:
+		if v.Len() > 0 {
+			items := make([]string, v.Len())
+			for i := range items {
+				items[i] = fmt.Sprint(v.Index(i).Interface())
+			}
+			out[prefix] = strings.Join(items, ",")
+		}
+
+	default:
+		if v.IsZero() {
+			return
+		}
+		if d, ok := v.Interface().(time.Duration); ok {
+			out[prefix] = d.String()
+			return
+		}
+		out[prefix] = fmt.Sprint(v.Interface())
+	}
+}
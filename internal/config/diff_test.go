@@ -0,0 +1,125 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			OpenAIAPIKey: "sk-old",
+			SonnetModel:  "model-a",
+			Backends: map[string]*Backend{
+				DefaultBackendName: {Name: DefaultBackendName, BaseURL: "http://localhost:8000/v1", APIKey: "sk-old"},
+				"bedrock":          {Name: "bedrock", Bedrock: BedrockSettings{Region: "us-east-1", SecretAccessKey: "aws-old"}},
+			},
+			Clients: map[string]*Client{
+				"alice": {Name: "alice", Keys: []string{"key-old"}, Enabled: true},
+			},
+			WatchedFiles: []string{"a.yaml"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{"相同的配置", func(c *Config) {}, nil},
+		{
+			name: "修改、新增和删除设置",
+			change: func(c *Config) {
+				c.SonnetModel = "model-b"
+				c.HaikuModel = "small"
+				c.Backends[DefaultBackendName].IdleTimeout = 90 * time.Second
+				c.Backends[DefaultBackendName].BaseURL = ""
+			},
+			want: []string{
+				"Backends.default.BaseURL: http://localhost:8000/v1 → （未设置）",
+				"Backends.default.IdleTimeout: （未设置）→ 1m30s",
+				"HaikuModel: （未设置）→ small",
+				"SonnetModel: model-a → model-b",
+			},
+		},
+		{
+			name: "密钥只报告已更改",
+			change: func(c *Config) {
+				c.OpenAIAPIKey = "sk-new"
+				c.Backends[DefaultBackendName].APIKey = "sk-new"
+				c.Backends["bedrock"].Bedrock.SecretAccessKey = "aws-new"
+				c.Clients["alice"].Keys = []string{"key-new", "key-old"}
+			},
+			want: []string{
+				"Backends.bedrock.Bedrock.SecretAccessKey: 已更改",
+				"Backends.default.APIKey: 已更改",
+				"Clients.alice.Keys: 已更改",
+				"OpenAIAPIKey: 已更改",
+			},
+		},
+		{
+			name: "新增和删除的条目整体报告一行",
+			change: func(c *Config) {
+				delete(c.Backends, "bedrock")
+				c.Backends["local"] = &Backend{Name: "local", BaseURL: "http://localhost:11434", APIKey: "sk-local"}
+				c.Clients["bob"] = &Client{Name: "bob", Keys: []string{"key-bob"}}
+			},
+			want: []string{
+				"Backends.bedrock: 已删除",
+				"Backends.local: 已添加",
+				"Clients.bob: 已添加",
+			},
+		},
+		{
+			name: "来源和监视列表不参与比较",
+			change: func(c *Config) {
+				c.WatchedFiles = []string{"a.yaml", "b.yaml"}
+				c.Clients["alice"].Position = Position{File: "keys.yaml", Line: 3}
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newCfg := base()
+			tt.change(newCfg)
+			got := Diff(base(), newCfg)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %q，应为 %q", got, tt.want)
+			}
+			for _, change := range got {
+				for _, secret := range []string{"sk-", "aws-", "key-"} {
+					if strings.Contains(change, secret) {
+						t.Errorf("变化 %q 泄露了密钥", change)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFlattenConfig(t *testing.T) {
+	cfg := &Config{
+		SonnetModel:        "model-a",
+		NonStreamingModels: []string{"a", "b"},
+		Backends: map[string]*Backend{
+			"local": {Name: "local", PingInterval: 15 * time.Second},
+		},
+	}
+	got := make(map[string]string)
+	flattenConfig("", reflect.ValueOf(cfg), got)
+
+	want := map[string]string{
+		"SonnetModel":                 "model-a",
+		"NonStreamingModels":          "a,b",
+		"Backends.local":              diffEntryMarker,
+		"Backends.local.Name":         "local",
+		"Backends.local.PingInterval": "15s",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenConfig = %q，应为 %q（零值不记录）", got, want)
+	}
+}
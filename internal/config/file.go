@@ -0,0 +1,587 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv 指定额外配置文件路径的环境变量（在全局和项目配置文件之后合并）
+const ConfigFileEnv = "CLAUDE_PROXY_CONFIG"
+
+// Position 表示设置项在配置文件中的位置
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String 返回 文件:行:列 形式的位置（未知的行或列省略）
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// IsZero 如果位置未设置则返回 true
+func (p Position) IsZero() bool {
+	return p.File == ""
+}
+
+// fileKind 表示配置文件中设置项的值类型
+type fileKind int
+
+const (
+	kindString   fileKind = iota
+	kindInt               // 整数
+	kindBool              // 布尔值
+	kindDuration          // 秒数或 Go 时长格式
+	kindList              // 字符串列表（也接受逗号分隔的字符串）
+	kindMap               // 字符串到字符串的映射
+)
+
+// fileKey 描述配置文件中的一个设置项：对应的环境变量名、值类型和可选的取值校验
+type fileKey struct {
+	env   string
+	kind  fileKind
+	check func(value string) bool // 字符串取值校验（如协议名称），为 nil 时不校验
+}
+
+// globalFileKeys 是配置文件顶层设置项（点分路径）到环境变量的映射
+var globalFileKeys = map[string]fileKey{
+	"host":                           {env: "HOST"},
+	"port":                           {env: "PORT", kind: kindInt},
+	"auth.api_key":                   {env: "ANTHROPIC_API_KEY"},
+	"auth.keys_file":                 {env: "AUTH_KEYS_FILE"},
+	"usage.store_file":               {env: "USAGE_STORE_FILE"},
+	"passthrough_mode":               {env: "PASSTHROUGH_MODE", kind: kindBool},
+	"models.opus":                    {env: "ANTHROPIC_DEFAULT_OPUS_MODEL"},
+	"models.sonnet":                  {env: "ANTHROPIC_DEFAULT_SONNET_MODEL"},
+	"models.haiku":                   {env: "ANTHROPIC_DEFAULT_HAIKU_MODEL"},
+	"streaming.disable_upstream":     {env: "DISABLE_UPSTREAM_STREAMING", kind: kindBool},
+	"streaming.non_streaming_models": {env: "NON_STREAMING_MODELS", kind: kindList},
+	"streaming.aggregation":          {env: "STREAM_AGGREGATION", kind: kindBool},
+	"model_list.include_upstream":    {env: "MODELS_INCLUDE_UPSTREAM", kind: kindBool},
+	"model_list.cache_ttl":           {env: "MODELS_CACHE_TTL", kind: kindDuration},
+	"openrouter.app_name":            {env: "OPENROUTER_APP_NAME"},
+	"openrouter.app_url":             {env: "OPENROUTER_APP_URL"},
+	"overrides.backends":             {env: "MODEL_OVERRIDE_BACKENDS", kind: kindList},
+	"capabilities.cache_ttl":         {env: "CAPABILITY_CACHE_TTL", kind: kindDuration},
+	"clamp_max_tokens":               {env: "CLAMP_MAX_TOKENS", kind: kindBool},
+	"reload.watch_interval":          {env: "CONFIG_WATCH_INTERVAL", kind: kindDuration},
+}
+
+// backendFileKeys 是 backends.<名称> 下的设置项到环境变量的映射（default 后端的环境变量会覆盖文件中的值）
+var backendFileKeys = map[string]fileKey{
+	"base_url":                     {env: "OPENAI_BASE_URL"},
+	"api_key":                      {env: "OPENAI_API_KEY"},
+	"provider":                     {env: "OPENAI_PROVIDER", check: isKnownProviderType},
+	"protocol":                     {env: "OPENAI_PROTOCOL", check: isKnownProtocol},
+	"streaming":                    {env: "OPENAI_STREAMING", kind: kindBool},
+	"timeouts.request":             {env: "REQUEST_TIMEOUT", kind: kindDuration},
+	"timeouts.stream":              {env: "STREAM_TIMEOUT", kind: kindDuration},
+	"timeouts.idle":                {env: "STREAM_IDLE_TIMEOUT", kind: kindDuration},
+	"timeouts.ping_interval":       {env: "STREAM_PING_INTERVAL", kind: kindDuration},
+	"http.max_idle_conns":          {env: "HTTP_MAX_IDLE_CONNS", kind: kindInt},
+	"http.max_idle_conns_per_host": {env: "HTTP_MAX_IDLE_CONNS_PER_HOST", kind: kindInt},
+	"http.idle_conn_timeout":       {env: "HTTP_IDLE_CONN_TIMEOUT", kind: kindDuration},
+	"http.tls_handshake_timeout":   {env: "HTTP_TLS_HANDSHAKE_TIMEOUT", kind: kindDuration},
+	"http.response_header_timeout": {env: "HTTP_RESPONSE_HEADER_TIMEOUT", kind: kindDuration},
+	"http.disable_http2":           {env: "DISABLE_HTTP2", kind: kindBool},
+	"ollama.num_ctx":               {env: "OLLAMA_NUM_CTX", kind: kindInt},
+	"ollama.max_num_ctx":           {env: "OLLAMA_MAX_NUM_CTX", kind: kindInt},
+	"ollama.keep_alive":            {env: "OLLAMA_KEEP_ALIVE"},
+	"azure.api_version":            {env: "AZURE_OPENAI_API_VERSION"},
+	"azure.deployments":            {env: "AZURE_OPENAI_DEPLOYMENTS", kind: kindMap},
+	"bedrock.region":               {env: "AWS_REGION"},
+	"bedrock.access_key_id":        {env: "AWS_ACCESS_KEY_ID"},
+	"bedrock.secret_access_key":    {env: "AWS_SECRET_ACCESS_KEY"},
+	"bedrock.session_token":        {env: "AWS_SESSION_TOKEN"},
+	"bedrock.profile":              {env: "AWS_PROFILE"},
+	"bedrock.credentials_file":     {env: "AWS_SHARED_CREDENTIALS_FILE"},
+	"anthropic.auth":               {env: "ANTHROPIC_UPSTREAM_AUTH", check: isKnownAnthropicAuthHeader},
+	"anthropic.version":            {env: "ANTHROPIC_UPSTREAM_VERSION"},
+	"anthropic.strip_fields":       {env: "ANTHROPIC_UPSTREAM_STRIP_FIELDS", kind: kindList},
+}
+
+// fileValue 是配置文件中一个设置项的值和位置
+type fileValue struct {
+	path    string            // 点分路径（如 backends.default.base_url）
+	value   string            // 标量值（列表和映射为空）
+	list    []string          // 列表值
+	mapping map[string]string // 映射值
+	pos     Position
+}
+
+// fileSettings 是合并后的配置文件内容，按环境变量名索引。
+// 后加载的文件覆盖先加载文件中的同名设置项（列表和映射整体替换）。
+type fileSettings struct {
+	files      []string                        // 已加载的配置文件（按合并顺序）
+	global     map[string]fileValue            // 顶层设置
+	backends   map[string]map[string]fileValue // 后端名称 -> 后端设置
+	backendPos map[string]Position             // 后端名称 -> 定义位置（取最先定义的位置）
+	routes     []ModelRoute                    // 模型路由规则（取最后定义 routes 的文件）
+	pricing    []ModelPrice                    // 模型价格表（取最后定义 pricing 的文件）
+}
+
+// configFileLocations 返回按合并顺序排列的配置文件路径：
+// 全局 ~/.claude/proxy.yaml、项目 ./.claude/proxy.yaml，最后是 CLAUDE_PROXY_CONFIG 指定的文件
+func configFileLocations() []string {
+	locations := []string{
+		filepath.Join(os.Getenv("HOME"), ".claude", "proxy.yaml"),
+		filepath.Join(".claude", "proxy.yaml"),
+	}
+	if explicit := os.Getenv(ConfigFileEnv); explicit != "" {
+		locations = append(locations, explicit)
+	}
+	return locations
+}
+
+// loadConfigFiles 按顺序加载并合并存在的配置文件。
+// CLAUDE_PROXY_CONFIG 指定的文件必须存在；语法错误、未知设置项和类型错误以带位置的 ValidationErrors 返回。
+func loadConfigFiles() (*fileSettings, error) {
+	fs := &fileSettings{
+		files:      []string{},
+		global:     make(map[string]fileValue),
+		backends:   make(map[string]map[string]fileValue),
+		backendPos: make(map[string]Position),
+	}
+
+	locations := configFileLocations()
+	explicit := os.Getenv(ConfigFileEnv)
+
+	var errs ValidationErrors
+	seen := make(map[string]bool)
+	for i, loc := range locations {
+		abs, err := filepath.Abs(loc)
+		if err != nil {
+			abs = loc
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		data, err := os.ReadFile(loc)
+		if err != nil {
+			if os.IsNotExist(err) && !(explicit != "" && i == len(locations)-1) {
+				continue
+			}
+			return nil, fmt.Errorf("读取配置文件 %s 失败: %w", loc, err)
+		}
+
+		errs = append(errs, fs.parse(loc, data)...)
+		fs.files = append(fs.files, loc)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return fs, nil
+}
+
+// yamlErrorLine 匹配 yaml 语法错误中的行号
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// parse 解析一个配置文件并合并到已有设置中
+func (fs *fileSettings) parse(file string, data []byte) ValidationErrors {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return yamlSyntaxError(file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil // 空文件
+	}
+
+	p := &fileParser{file: file, settings: fs}
+	root := resolveAlias(doc.Content[0])
+	if root.Kind != yaml.MappingNode {
+		p.fail(root, "", "配置文件的顶层必须是映射")
+		return p.errs
+	}
+	p.walk(root, "", "", globalFileKeys, fs.global)
+	return p.errs
+}
+
+// yamlSyntaxError 将 yaml 语法错误转换为带行号的 ValidationErrors
+func yamlSyntaxError(file string, err error) ValidationErrors {
+	pos := Position{File: file}
+	message := err.Error()
+	if m := yamlErrorLine.FindStringSubmatch(message); m != nil {
+		pos.Line, _ = strconv.Atoi(m[1])
+		message = m[2]
+	}
+	return ValidationErrors{{Field: "yaml", Position: pos, Message: message}}
+}
+
+// fileParser 遍历一个配置文件的节点树，按设置项表校验并转换值
+type fileParser struct {
+	file     string
+	settings *fileSettings
+	errs     ValidationErrors
+}
+
+// fail 记录一个带位置的错误
+func (p *fileParser) fail(node *yaml.Node, path, message string) {
+	p.errs = append(p.errs, ValidationError{
+		Field:    path,
+		Position: p.position(node),
+		Message:  message,
+	})
+}
+
+// position 返回节点在当前文件中的位置
+func (p *fileParser) position(node *yaml.Node) Position {
+	return Position{File: p.file, Line: node.Line, Column: node.Column}
+}
+
+// walk 遍历映射节点。base 为后端设置的路径前缀（顶层设置为空），prefix 为相对于设置项表的路径，
+// 结果按环境变量名写入 out
+func (p *fileParser) walk(node *yaml.Node, base, prefix string, keys map[string]fileKey, out map[string]fileValue) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		path := keyNode.Value
+		if prefix != "" {
+			path = prefix + "." + keyNode.Value
+		}
+		fullPath := path
+		if base != "" {
+			fullPath = base + "." + path
+		}
+
+		if base == "" && path == "backends" {
+			p.walkBackends(valueNode)
+			continue
+		}
+		if base == "" && path == "routes" {
+			p.walkRoutes(valueNode)
+			continue
+		}
+		if base == "" && path == "pricing" {
+			p.walkPricing(valueNode)
+			continue
+		}
+		if isNull(valueNode) {
+			continue // 空值视为未设置
+		}
+		if key, ok := keys[path]; ok {
+			if value, ok := p.convert(valueNode, fullPath, key); ok {
+				out[key.env] = value
+			}
+			continue
+		}
+		if !isSection(keys, path) {
+			p.fail(keyNode, fullPath, "未知的设置项")
+			continue
+		}
+		if valueNode.Kind != yaml.MappingNode {
+			p.fail(valueNode, fullPath, "应为映射")
+			continue
+		}
+		p.walk(valueNode, base, path, keys, out)
+	}
+}
+
+// walkBackends 遍历 backends 映射，每个后端的设置按后端名称分别合并
+func (p *fileParser) walkBackends(node *yaml.Node) {
+	if isNull(node) {
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, "backends", "应为后端名称到后端设置的映射")
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		nameNode, backendNode := node.Content[i], resolveAlias(node.Content[i+1])
+		name := strings.TrimSpace(nameNode.Value)
+		path := "backends." + name
+		if name == "" {
+			p.fail(nameNode, "backends", "后端名称不能为空")
+			continue
+		}
+		if isNull(backendNode) {
+			continue
+		}
+		if backendNode.Kind != yaml.MappingNode {
+			p.fail(backendNode, path, "应为映射")
+			continue
+		}
+
+		backend := p.settings.backends[name]
+		if backend == nil {
+			backend = make(map[string]fileValue)
+			p.settings.backends[name] = backend
+			p.settings.backendPos[name] = p.position(nameNode)
+		}
+		p.walk(backendNode, path, "", backendFileKeys, backend)
+	}
+}
+
+// convert 按设置项类型校验并转换节点的值，标量中的 ${VAR} 在转换前展开
+func (p *fileParser) convert(node *yaml.Node, path string, key fileKey) (fileValue, bool) {
+	value := fileValue{path: path, pos: p.position(node)}
+
+	switch key.kind {
+	case kindList:
+		var items []*yaml.Node
+		switch node.Kind {
+		case yaml.SequenceNode:
+			items = node.Content
+		case yaml.ScalarNode:
+			text, ok := p.scalar(node, path)
+			if !ok {
+				return value, false
+			}
+			value.list = splitList(text)
+			return value, true
+		default:
+			p.fail(node, path, "应为字符串列表")
+			return value, false
+		}
+		for _, item := range items {
+			item = resolveAlias(item)
+			if item.Kind != yaml.ScalarNode {
+				p.fail(item, path, "列表项应为字符串")
+				return value, false
+			}
+			text, ok := p.scalar(item, path)
+			if !ok {
+				return value, false
+			}
+			if text = strings.TrimSpace(text); text != "" {
+				value.list = append(value.list, text)
+			}
+		}
+		return value, true
+
+	case kindMap:
+		if node.Kind != yaml.MappingNode {
+			p.fail(node, path, "应为字符串到字符串的映射")
+			return value, false
+		}
+		value.mapping = make(map[string]string, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], resolveAlias(node.Content[i+1])
+			if v.Kind != yaml.ScalarNode {
+				p.fail(v, path+"."+k.Value, "映射值应为字符串")
+				return value, false
+			}
+			text, ok := p.scalar(v, path+"."+k.Value)
+			if !ok {
+				return value, false
+			}
+			value.mapping[k.Value] = text
+		}
+		return value, true
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		p.fail(node, path, "应为单个值")
+		return value, false
+	}
+	text, ok := p.scalar(node, path)
+	if !ok {
+		return value, false
+	}
+	text = strings.TrimSpace(text)
+
+	switch key.kind {
+	case kindInt:
+		if _, err := strconv.Atoi(text); err != nil {
+			p.fail(node, path, fmt.Sprintf("应为整数，当前为 %q", text))
+			return value, false
+		}
+	case kindBool:
+		b, ok := parseFileBool(text)
+		if !ok {
+			p.fail(node, path, fmt.Sprintf("应为 true 或 false，当前为 %q", text))
+			return value, false
+		}
+		text = strconv.FormatBool(b)
+	case kindDuration:
+		if _, ok := parseDuration(text); !ok {
+			p.fail(node, path, fmt.Sprintf("应为时长（秒数或如 90s、2m 的格式），当前为 %q", text))
+			return value, false
+		}
+	default:
+		if key.check != nil && !key.check(text) {
+			p.fail(node, path, fmt.Sprintf("无效的取值 %q", text))
+			return value, false
+		}
+	}
+	value.value = text
+	return value, true
+}
+
+// scalar 返回标量节点展开环境变量后的值
+func (p *fileParser) scalar(node *yaml.Node, path string) (string, bool) {
+	text, err := interpolateEnv(node.Value)
+	if err != nil {
+		p.fail(node, path, err.Error())
+		return "", false
+	}
+	return text, true
+}
+
+// envReference 匹配 ${VAR}、${VAR:-默认值} 和转义的 $$
+var envReference = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolateEnv 展开字符串中的环境变量引用。
+// ${VAR} 要求变量已设置（可以来自 .env 文件），${VAR:-默认值} 在变量未设置或为空时使用默认值，$$ 表示字面的 $。
+func interpolateEnv(text string) (string, error) {
+	var missing []string
+	result := envReference.ReplaceAllStringFunc(text, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		m := envReference.FindStringSubmatch(ref)
+		if value := os.Getenv(m[1]); value != "" {
+			return value
+		}
+		if m[2] == "" {
+			missing = append(missing, m[1])
+		}
+		return m[3]
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("环境变量 %s 未设置（可用 ${%s:-默认值} 提供默认值）", strings.Join(missing, "、"), missing[0])
+	}
+	return result, nil
+}
+
+// parseFileBool 解析配置文件中的布尔值
+func parseFileBool(text string) (bool, bool) {
+	switch strings.ToLower(text) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// resolveAlias 返回别名节点指向的节点
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// isNull 如果节点为空值（key: 或 key: ~）则返回 true
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// isSection 如果 path 是设置项表中某些设置项的上级路径则返回 true
+func isSection(keys map[string]fileKey, path string) bool {
+	for key := range keys {
+		if strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// backendNames 返回配置文件中定义的后端名称（已排序）
+func (fs *fileSettings) backendNames() []string {
+	names := make([]string, 0, len(fs.backends))
+	for name := range fs.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// settings 按环境变量名读取设置。
+// useEnv 为 true 时环境变量优先，未设置时使用配置文件中的值；配置文件中定义的其他后端只读取文件。
+type settings struct {
+	useEnv bool
+	file   map[string]fileValue
+}
+
+// get 返回设置的字符串值
+func (s settings) get(key string) string {
+	if s.useEnv {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return s.file[key].value
+}
+
+// fromFile 如果设置的值来自配置文件则返回其位置
+func (s settings) fromFile(key string) (fileValue, bool) {
+	if s.useEnv && os.Getenv(key) != "" {
+		return fileValue{}, false
+	}
+	value, ok := s.file[key]
+	return value, ok
+}
+
+// getOrDefault 返回设置的值，未设置时返回默认值
+func (s settings) getOrDefault(key, defaultValue string) string {
+	if value := s.get(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getBoolOrDefault 读取布尔设置（true、1、yes 为真）
+func (s settings) getBoolOrDefault(key string, defaultValue bool) bool {
+	if value := s.get(key); value != "" {
+		return value == "true" || value == "1" || value == "yes"
+	}
+	return defaultValue
+}
+
+// getIntOrDefault 读取整数设置；无法解析时返回默认值
+func (s settings) getIntOrDefault(key string, defaultValue int) int {
+	if value := s.get(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// getDurationOrDefault 读取时长设置。
+// 接受纯数字（秒）或 Go 时长格式（例如 "90s"、"2m"）；无法解析时返回默认值。
+func (s settings) getDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if d, ok := parseDuration(s.get(key)); ok {
+		return d
+	}
+	return defaultValue
+}
+
+// getList 读取列表设置：环境变量为逗号分隔的字符串，配置文件中为列表
+func (s settings) getList(key string) []string {
+	if s.useEnv {
+		if value := os.Getenv(key); value != "" {
+			return splitList(value)
+		}
+	}
+	return s.file[key].list
+}
+
+// getMap 读取映射设置：环境变量为逗号分隔的 key=value，配置文件中为映射。
+// 环境变量中格式错误的项会打印警告并跳过。
+func (s settings) getMap(key string) map[string]string {
+	if s.useEnv {
+		if value := os.Getenv(key); value != "" {
+			return parseMap(key, value)
+		}
+	}
+	return s.file[key].mapping
+}
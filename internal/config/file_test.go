@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestFileSettings 返回空的配置文件内容，用于直接调用 parse
+func newTestFileSettings() *fileSettings {
+	return &fileSettings{
+		global:     make(map[string]fileValue),
+		backends:   make(map[string]map[string]fileValue),
+		backendPos: make(map[string]Position),
+	}
+}
+
+func TestFileParseLayers(t *testing.T) {
+	fs := newTestFileSettings()
+	first := `
+port: 8080
+models:
+  sonnet: model-a
+streaming:
+  non_streaming_models: [a, b]
+backends:
+  default:
+    base_url: https://a.example.com/v1
+    api_key: key-a
+    timeouts:
+      idle: 90s
+`
+	second := `
+port: 9090
+streaming:
+  non_streaming_models: c
+backends:
+  default:
+    base_url: https://b.example.com/v1
+  local:
+    base_url: http://localhost:11434
+    streaming: off
+`
+	if errs := fs.parse("global.yaml", []byte(first)); len(errs) > 0 {
+		t.Fatalf("解析第一个文件失败: %v", errs)
+	}
+	if errs := fs.parse("project.yaml", []byte(second)); len(errs) > 0 {
+		t.Fatalf("解析第二个文件失败: %v", errs)
+	}
+
+	// 后加载的文件覆盖同名设置项，未覆盖的设置项保留
+	port := fs.global["PORT"]
+	if port.value != "9090" || port.pos.String() != "project.yaml:2:7" {
+		t.Errorf("PORT = %q（%s），应为 9090（project.yaml:2:7）", port.value, port.pos)
+	}
+	if got := fs.global["ANTHROPIC_DEFAULT_SONNET_MODEL"].value; got != "model-a" {
+		t.Errorf("sonnet 模型 = %q，应保留第一个文件的值", got)
+	}
+	// 列表整体替换，字符串形式按逗号分隔
+	if got := fs.global["NON_STREAMING_MODELS"].list; !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("NON_STREAMING_MODELS = %v，应被整体替换为 [c]", got)
+	}
+
+	// 后端设置按后端名称逐项合并
+	def := fs.backends["default"]
+	if def["OPENAI_BASE_URL"].value != "https://b.example.com/v1" || def["OPENAI_API_KEY"].value != "key-a" {
+		t.Errorf("default 后端 = %+v", def)
+	}
+	if got := def["STREAM_IDLE_TIMEOUT"]; got.value != "90s" || got.path != "backends.default.timeouts.idle" {
+		t.Errorf("STREAM_IDLE_TIMEOUT = %+v", got)
+	}
+	if got := fs.backendPos["default"].String(); got != "global.yaml:8:3" {
+		t.Errorf("default 后端位置 = %s，应取最先定义的位置 global.yaml:8:3", got)
+	}
+	if got := fs.backends["local"]["OPENAI_STREAMING"].value; got != "false" {
+		t.Errorf("local 后端 streaming = %q，布尔值应规范化为 false", got)
+	}
+	if got := fs.backendNames(); !reflect.DeepEqual(got, []string{"default", "local"}) {
+		t.Errorf("后端名称 = %v", got)
+	}
+}
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("PROXY_TEST_KEY", "secret")
+	t.Setenv("PROXY_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"无引用", "plain", "plain", ""},
+		{"已设置的变量", "Bearer ${PROXY_TEST_KEY}", "Bearer secret", ""},
+		{"默认值不覆盖已设置的变量", "${PROXY_TEST_KEY:-fallback}", "secret", ""},
+		{"未设置时使用默认值", "${PROXY_TEST_UNSET:-fallback}", "fallback", ""},
+		{"空值时使用默认值", "${PROXY_TEST_EMPTY:-fallback}", "fallback", ""},
+		{"空默认值", "a${PROXY_TEST_UNSET:-}b", "ab", ""},
+		{"$$ 表示字面的 $", "cost $$5", "cost $5", ""},
+		{"$${...} 不展开", "$${PROXY_TEST_KEY}", "${PROXY_TEST_KEY}", ""},
+		{"未设置的变量", "${PROXY_TEST_UNSET}", "", "环境变量 PROXY_TEST_UNSET 未设置"},
+		{"空值视为未设置", "${PROXY_TEST_EMPTY}", "", "环境变量 PROXY_TEST_EMPTY 未设置"},
+		{"列出所有未设置的变量", "${PROXY_TEST_A}/${PROXY_TEST_B}", "", "PROXY_TEST_A、PROXY_TEST_B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := interpolateEnv(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("interpolateEnv(%q) 错误 = %v，应包含 %q", tt.text, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("interpolateEnv(%q) = %q, %v，应为 %q", tt.text, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileParseInterpolation(t *testing.T) {
+	t.Setenv("PROXY_TEST_KEY", "secret")
+	fs := newTestFileSettings()
+	data := `
+backends:
+  default:
+    api_key: ${PROXY_TEST_KEY}
+    base_url: ${PROXY_TEST_UNSET:-http://localhost:8000/v1}
+    azure:
+      deployments:
+        gpt-4o: $${literal}
+`
+	if errs := fs.parse("proxy.yaml", []byte(data)); len(errs) > 0 {
+		t.Fatalf("解析失败: %v", errs)
+	}
+	def := fs.backends["default"]
+	if def["OPENAI_API_KEY"].value != "secret" || def["OPENAI_BASE_URL"].value != "http://localhost:8000/v1" {
+		t.Errorf("default 后端 = %+v", def)
+	}
+	if got := def["AZURE_OPENAI_DEPLOYMENTS"].mapping["gpt-4o"]; got != "${literal}" {
+		t.Errorf("映射值 = %q，应为 ${literal}", got)
+	}
+}
+
+func TestFileParseErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantField string
+		wantPos   string
+		wantMsg   string
+	}{
+		{"未知的顶层设置项", "prot: 8080\n", "prot", "proxy.yaml:1:1", "未知的设置项"},
+		{"未知的后端设置项", "backends:\n  default:\n    base_ur: x\n", "backends.default.base_ur", "proxy.yaml:3:5", "未知的设置项"},
+		{"整数类型错误", "port: abc\n", "port", "proxy.yaml:1:7", "应为整数"},
+		{"布尔类型错误", "passthrough_mode: maybe\n", "passthrough_mode", "proxy.yaml:1:19", "应为 true 或 false"},
+		{"时长类型错误", "backends:\n  default:\n    timeouts:\n      idle: soon\n", "backends.default.timeouts.idle", "proxy.yaml:4:13", "应为时长"},
+		{"取值校验", "backends:\n  default:\n    protocol: grpc\n", "backends.default.protocol", "proxy.yaml:3:15", "无效的取值"},
+		{"分组不是映射", "models: gpt-4o\n", "models", "proxy.yaml:1:9", "应为映射"},
+		{"标量设置项不是单个值", "host: [a, b]\n", "host", "proxy.yaml:1:7", "应为单个值"},
+		{"列表项不是字符串", "streaming:\n  non_streaming_models:\n    - {a: 1}\n", "streaming.non_streaming_models", "proxy.yaml:3:7", "列表项应为字符串"},
+		{"映射值不是字符串", "backends:\n  default:\n    azure:\n      deployments:\n        gpt-4o: [a]\n", "backends.default.azure.deployments.gpt-4o", "proxy.yaml:5:17", "映射值应为字符串"},
+		{"后端不是映射", "backends:\n  default: x\n", "backends.default", "proxy.yaml:2:12", "应为映射"},
+		{"未设置的环境变量", "host: ${PROXY_TEST_UNSET}\n", "host", "proxy.yaml:1:7", "环境变量 PROXY_TEST_UNSET 未设置"},
+		{"顶层不是映射", "- a\n", "", "proxy.yaml:1:1", "顶层必须是映射"},
+		{"语法错误", "port: 8080\n\thost: x\n", "yaml", "proxy.yaml:2", "tab character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := newTestFileSettings().parse("proxy.yaml", []byte(tt.data))
+			if len(errs) != 1 {
+				t.Fatalf("错误 = %v，应有 1 个错误", errs)
+			}
+			e := errs[0]
+			if e.Field != tt.wantField || e.Position.String() != tt.wantPos || !strings.Contains(e.Message, tt.wantMsg) {
+				t.Errorf("错误 = [%s] %s: %s，应为 [%s] %s: 包含 %q", e.Field, e.Position, e.Message, tt.wantField, tt.wantPos, tt.wantMsg)
+			}
+			if !strings.HasPrefix(e.Error(), tt.wantPos+": ") {
+				t.Errorf("错误信息 %q 应以位置 %s 开头", e.Error(), tt.wantPos)
+			}
+		})
+	}
+}
+
+func TestFileParseCollectsAllErrors(t *testing.T) {
+	data := "port: abc\nunknown: 1\nbackends:\n  default:\n    streaming: maybe\n"
+	errs := newTestFileSettings().parse("proxy.yaml", []byte(data))
+	var positions []string
+	for _, e := range errs {
+		positions = append(positions, e.Position.String())
+	}
+	want := []string{"proxy.yaml:1:7", "proxy.yaml:2:1", "proxy.yaml:5:16"}
+	if !reflect.DeepEqual(positions, want) {
+		t.Errorf("错误位置 = %v，应为 %v", positions, want)
+	}
+}
+
+// writeConfigFile 写入配置文件（自动创建目录）并返回路径
+func writeConfigFile(t *testing.T, path, data string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(project)
+
+	global := writeConfigFile(t, filepath.Join(home, ".claude", "proxy.yaml"), "port: 1\nmodels:\n  haiku: small\n")
+	writeConfigFile(t, filepath.Join(project, ".claude", "proxy.yaml"), "port: 2\nmodels:\n  sonnet: medium\n")
+	explicit := writeConfigFile(t, filepath.Join(t.TempDir(), "extra.yaml"), "port: 3\n")
+	t.Setenv(ConfigFileEnv, explicit)
+
+	fs, err := loadConfigFiles()
+	if err != nil {
+		t.Fatalf("加载配置文件失败: %v", err)
+	}
+	wantFiles := []string{global, filepath.Join(".claude", "proxy.yaml"), explicit}
+	if !reflect.DeepEqual(fs.files, wantFiles) {
+		t.Errorf("已加载的文件 = %v，应为 %v", fs.files, wantFiles)
+	}
+	// 合并顺序：全局 < 项目 < CLAUDE_PROXY_CONFIG
+	if got := fs.global["PORT"]; got.value != "3" || got.pos.File != explicit {
+		t.Errorf("PORT = %q（%s），应为 CLAUDE_PROXY_CONFIG 中的 3", got.value, got.pos)
+	}
+	if fs.global["ANTHROPIC_DEFAULT_HAIKU_MODEL"].value != "small" || fs.global["ANTHROPIC_DEFAULT_SONNET_MODEL"].value != "medium" {
+		t.Errorf("未被覆盖的设置项应保留: %+v", fs.global)
+	}
+}
+
+func TestLoadConfigFilesErrors(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(project)
+
+	// CLAUDE_PROXY_CONFIG 指定的文件必须存在
+	t.Setenv(ConfigFileEnv, filepath.Join(project, "missing.yaml"))
+	if _, err := loadConfigFiles(); err == nil || !strings.Contains(err.Error(), "missing.yaml") {
+		t.Errorf("错误 = %v，应报告指定的文件不存在", err)
+	}
+	t.Setenv(ConfigFileEnv, "")
+
+	// 所有文件的错误一起返回，每个错误带有所在文件
+	global := writeConfigFile(t, filepath.Join(home, ".claude", "proxy.yaml"), "port: abc\n")
+	writeConfigFile(t, filepath.Join(project, ".claude", "proxy.yaml"), "\nbogus: 1\n")
+	_, err := loadConfigFiles()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("错误 = %v，应为 2 个验证错误", err)
+	}
+	want := []string{global + ":1:7", filepath.Join(".claude", "proxy.yaml") + ":2:1"}
+	for i, e := range errs {
+		if e.Position.String() != want[i] {
+			t.Errorf("错误 %d 的位置 = %s，应为 %s", i, e.Position, want[i])
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"gopkg.in/yaml.v3"
+)
+
+// ParamPolicy 是路由规则的请求参数策略，在请求转换为上游格式之后应用。
+// 与 params（转换前覆盖 Claude 请求参数）不同，策略可以删除参数、设置任意上游字段和限制数值范围。
+// 应用顺序：drop → default → set → clamp → extra_body。
+type ParamPolicy struct {
+	Set       map[string]interface{} // 总是设置的字段
+	Default   map[string]interface{} // 请求中没有时才设置的字段
+	Drop      []string               // 删除的字段（如拒绝 top_p 的模型）
+	Clamp     ParamClamp             // 数值参数的上限
+	ExtraBody map[string]interface{} // 深度合并到上游请求体的字段（如 OpenRouter 的 provider.order、transforms）
+}
+
+// ParamClamp 是数值参数的上限，零值表示不限制
+type ParamClamp struct {
+	MaxTokens     int      // max_tokens 的上限
+	MaxTokensAuto bool     // 按已知模型的最大输出令牌数限制 max_tokens（未知模型不限制）
+	MaxTokensOff  bool     // 不限制 max_tokens（关闭 CLAMP_MAX_TOKENS 的默认限制）
+	Temperature   *float64 // temperature 的上限
+	TopP          *float64 // top_p 的上限
+}
+
+// IsZero 如果没有设置任何限制则返回 true
+func (c ParamClamp) IsZero() bool {
+	return c.MaxTokens == 0 && !c.MaxTokensAuto && !c.MaxTokensOff && c.Temperature == nil && c.TopP == nil
+}
+
+// IsZero 如果策略为空则返回 true
+func (p ParamPolicy) IsZero() bool {
+	return len(p.Set) == 0 && len(p.Default) == 0 && len(p.Drop) == 0 && p.Clamp.IsZero() && len(p.ExtraBody) == 0
+}
+
+// String 返回策略的可读形式（如 "drop=top_p clamp.max_tokens=auto extra_body.transforms=[middle-out]"）
+func (p ParamPolicy) String() string {
+	var parts []string
+	if len(p.Drop) > 0 {
+		parts = append(parts, "drop="+strings.Join(p.Drop, ","))
+	}
+	parts = append(parts, policyFieldStrings("default.", p.Default)...)
+	parts = append(parts, policyFieldStrings("set.", p.Set)...)
+	if p.Clamp.MaxTokensAuto {
+		parts = append(parts, "clamp.max_tokens=auto")
+	} else if p.Clamp.MaxTokensOff {
+		parts = append(parts, "clamp.max_tokens=off")
+	} else if p.Clamp.MaxTokens > 0 {
+		parts = append(parts, "clamp.max_tokens="+strconv.Itoa(p.Clamp.MaxTokens))
+	}
+	if p.Clamp.Temperature != nil {
+		parts = append(parts, "clamp.temperature="+strconv.FormatFloat(*p.Clamp.Temperature, 'g', -1, 64))
+	}
+	if p.Clamp.TopP != nil {
+		parts = append(parts, "clamp.top_p="+strconv.FormatFloat(*p.Clamp.TopP, 'g', -1, 64))
+	}
+	parts = append(parts, policyFieldStrings("extra_body.", p.ExtraBody)...)
+	return strings.Join(parts, " ")
+}
+
+// WithMaxTokensAuto 返回按已知模型的最大输出令牌数限制 max_tokens 的策略副本（CLAMP_MAX_TOKENS 的默认限制）；
+// 策略已设置 clamp.max_tokens（数字、auto 或 off）时保持不变
+func (p ParamPolicy) WithMaxTokensAuto() ParamPolicy {
+	if p.Clamp.MaxTokens == 0 && !p.Clamp.MaxTokensOff {
+		p.Clamp.MaxTokensAuto = true
+	}
+	return p
+}
+
+// WithDefaults 返回加入默认值后的策略副本（用于客户端的默认参数）：
+// defaults 覆盖策略 default 中的同名字段，策略 set 或 drop 的字段保持不变
+func (p ParamPolicy) WithDefaults(defaults map[string]interface{}) ParamPolicy {
+	merged := make(map[string]interface{}, len(p.Default)+len(defaults))
+	for field, value := range p.Default {
+		merged[field] = value
+	}
+	for field, value := range defaults {
+		if _, set := p.Set[field]; set || containsField(p.Drop, field) {
+			continue
+		}
+		merged[field] = value
+	}
+	p.Default = merged
+	return p
+}
+
+// containsField 如果字段列表中包含字段则返回 true
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// policyFieldStrings 返回按名称排序的 前缀字段=值 列表，值为 JSON 形式
+func policyFieldStrings(prefix string, fields map[string]interface{}) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		value, _ := json.Marshal(fields[name])
+		parts[i] = prefix + name + "=" + string(value)
+	}
+	return parts
+}
+
+// policyReservedFields 是策略不能修改的字段（由代理根据请求和路由设置）
+var policyReservedFields = map[string]bool{"model": true, "messages": true, "stream": true}
+
+// normalizePolicyValue 检查已知参数的取值类型，并转换为转换器使用的类型；
+// 其他字段原样写入上游请求体
+func normalizePolicyValue(field string, value interface{}) (interface{}, error) {
+	switch field {
+	case "temperature", "top_p":
+		number, ok := policyNumber(value)
+		if !ok || number < 0 {
+			return nil, fmt.Errorf("应为非负数，当前为 %v", value)
+		}
+		return number, nil
+	case "max_tokens":
+		number, ok := policyNumber(value)
+		if !ok || number <= 0 || number != float64(int(number)) {
+			return nil, fmt.Errorf("应为正整数，当前为 %v", value)
+		}
+		return int(number), nil
+	case "reasoning_effort":
+		text, ok := value.(string)
+		if !ok || text == "" {
+			return nil, fmt.Errorf("应为字符串（如 low、medium、high），当前为 %v", value)
+		}
+		return text, nil
+	case "stop":
+		switch v := value.(type) {
+		case string:
+			return []string{v}, nil
+		case []interface{}:
+			stops := make([]string, 0, len(v))
+			for _, item := range v {
+				text, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("应为字符串或字符串列表，当前为 %v", value)
+				}
+				stops = append(stops, text)
+			}
+			return stops, nil
+		}
+		return nil, fmt.Errorf("应为字符串或字符串列表，当前为 %v", value)
+	case "stream_options", "reasoning", "usage":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("应为映射，当前为 %v", value)
+		}
+	}
+	return value, nil
+}
+
+// policyNumber 将 YAML 中的整数、浮点数或数字字符串（如展开的 ${VAR}）转换为 float64
+func policyNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// routePolicy 解析路由规则的参数策略
+func (p *fileParser) routePolicy(node *yaml.Node, path string, policy *ParamPolicy) bool {
+	if isNull(node) {
+		return true
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射")
+		return false
+	}
+
+	ok := true
+	fieldNodes := make(map[string]*yaml.Node) // 字段在 set、default、drop 中第一次出现的位置
+	checkField := func(fieldNode *yaml.Node, fieldPath, field string) bool {
+		if policyReservedFields[field] {
+			p.fail(fieldNode, fieldPath, fmt.Sprintf("字段 %s 由代理设置，不能在策略中修改", field))
+			return false
+		}
+		if previous, seen := fieldNodes[field]; seen {
+			p.fail(fieldNode, fieldPath, fmt.Sprintf("字段 %s 已在第 %d 行的策略中出现，每个字段只能使用 set、default、drop 之一", field, previous.Line))
+			return false
+		}
+		fieldNodes[field] = fieldNode
+		return true
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+
+		switch keyNode.Value {
+		case "set", "default":
+			fields, valid := p.policyFields(valueNode, keyPath, checkField)
+			if !valid {
+				ok = false
+				continue
+			}
+			if keyNode.Value == "set" {
+				policy.Set = fields
+			} else {
+				policy.Default = fields
+			}
+		case "drop":
+			if valueNode.Kind != yaml.SequenceNode {
+				p.fail(valueNode, keyPath, "应为字段名称列表（如 [top_p, temperature]）")
+				ok = false
+				continue
+			}
+			for _, item := range valueNode.Content {
+				item = resolveAlias(item)
+				field, valid := p.routeString(item, keyPath)
+				if !valid {
+					ok = false
+					continue
+				}
+				if !checkField(item, keyPath, field) {
+					ok = false
+					continue
+				}
+				policy.Drop = append(policy.Drop, field)
+			}
+		case "clamp":
+			ok = p.policyClamp(valueNode, keyPath, &policy.Clamp) && ok
+		case "extra_body":
+			if valueNode.Kind != yaml.MappingNode {
+				p.fail(valueNode, keyPath, "应为映射")
+				ok = false
+				continue
+			}
+			value, valid := p.anyValue(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			policy.ExtraBody = value.(map[string]interface{})
+		default:
+			p.fail(keyNode, keyPath, "未知的策略（支持 set、default、drop、clamp、extra_body）")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// policyFields 解析 set 或 default 中的 字段: 值 映射
+func (p *fileParser) policyFields(node *yaml.Node, path string, checkField func(*yaml.Node, string, string) bool) (map[string]interface{}, bool) {
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为字段到值的映射")
+		return nil, false
+	}
+
+	ok := true
+	fields := make(map[string]interface{}, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+		if !checkField(keyNode, keyPath, keyNode.Value) {
+			ok = false
+			continue
+		}
+		value, valid := p.anyValue(valueNode, keyPath)
+		if !valid {
+			ok = false
+			continue
+		}
+		value, err := normalizePolicyValue(keyNode.Value, value)
+		if err != nil {
+			p.fail(valueNode, keyPath, err.Error())
+			ok = false
+			continue
+		}
+		fields[keyNode.Value] = value
+	}
+	return fields, ok
+}
+
+// policyClamp 解析数值参数的上限
+func (p *fileParser) policyClamp(node *yaml.Node, path string, clamp *ParamClamp) bool {
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射（如 max_tokens: auto）")
+		return false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+		text, valid := p.routeString(valueNode, keyPath)
+		if !valid {
+			ok = false
+			continue
+		}
+
+		switch keyNode.Value {
+		case "max_tokens":
+			if strings.EqualFold(text, "auto") {
+				clamp.MaxTokensAuto = true
+				continue
+			}
+			if strings.EqualFold(text, "off") {
+				clamp.MaxTokensOff = true
+				continue
+			}
+			n, err := strconv.Atoi(text)
+			if err != nil || n <= 0 {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为 auto（按已知模型的最大输出令牌数）、off（不限制）或正整数，当前为 %q", text))
+				ok = false
+				continue
+			}
+			clamp.MaxTokens = n
+		case "temperature", "top_p":
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil || value < 0 {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为非负数，当前为 %q", text))
+				ok = false
+				continue
+			}
+			if keyNode.Value == "temperature" {
+				clamp.Temperature = &value
+			} else {
+				clamp.TopP = &value
+			}
+		default:
+			p.fail(keyNode, keyPath, "未知的参数（支持 max_tokens、temperature、top_p）")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// anyValue 将 YAML 节点转换为 JSON 兼容的值（映射、列表、字符串、整数、浮点数、布尔值或 nil），
+// 字符串中的 ${VAR} 会展开
+func (p *fileParser) anyValue(node *yaml.Node, path string) (interface{}, bool) {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yaml.MappingNode:
+		ok := true
+		mapping := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode := node.Content[i]
+			value, valid := p.anyValue(node.Content[i+1], path+"."+keyNode.Value)
+			if !valid {
+				ok = false
+				continue
+			}
+			mapping[keyNode.Value] = value
+		}
+		return mapping, ok
+	case yaml.SequenceNode:
+		ok := true
+		items := make([]interface{}, 0, len(node.Content))
+		for i, item := range node.Content {
+			value, valid := p.anyValue(item, fmt.Sprintf("%s[%d]", path, i))
+			if !valid {
+				ok = false
+				continue
+			}
+			items = append(items, value)
+		}
+		return items, ok
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!null":
+			return nil, true
+		case "!!bool", "!!int", "!!float":
+			var value interface{}
+			if err := node.Decode(&value); err != nil {
+				p.fail(node, path, err.Error())
+				return nil, false
+			}
+			return value, true
+		}
+		text, ok := p.scalar(node, path)
+		return text, ok
+	}
+	p.fail(node, path, "不支持的值")
+	return nil, false
+}
@@ -0,0 +1,451 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RouteMatch 表示模型路由规则的匹配方式
+type RouteMatch string
+
+const (
+	// RouteExact 完整名称匹配（不区分大小写），用于 fast、cheap 等自定义别名
+	RouteExact RouteMatch = "exact"
+	// RouteGlob 通配符匹配（不区分大小写）：* 匹配任意字符，? 匹配单个字符，每个通配符是一个捕获组
+	RouteGlob RouteMatch = "glob"
+	// RouteRegex 正则表达式匹配（必须匹配完整名称，区分大小写，可用 (?i) 忽略大小写）
+	RouteRegex RouteMatch = "regex"
+)
+
+// RouteParams 是路由规则覆盖的请求参数，零值表示不覆盖
+type RouteParams struct {
+	Temperature     *float64
+	TopP            *float64
+	MaxTokens       int
+	ReasoningEffort string
+}
+
+// IsZero 如果没有覆盖任何参数则返回 true
+func (p RouteParams) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == 0 && p.ReasoningEffort == ""
+}
+
+// String 返回覆盖参数的可读形式（如 "temperature=0.2 max_tokens=4096"）
+func (p RouteParams) String() string {
+	var parts []string
+	if p.Temperature != nil {
+		parts = append(parts, "temperature="+strconv.FormatFloat(*p.Temperature, 'g', -1, 64))
+	}
+	if p.TopP != nil {
+		parts = append(parts, "top_p="+strconv.FormatFloat(*p.TopP, 'g', -1, 64))
+	}
+	if p.MaxTokens > 0 {
+		parts = append(parts, "max_tokens="+strconv.Itoa(p.MaxTokens))
+	}
+	if p.ReasoningEffort != "" {
+		parts = append(parts, "reasoning_effort="+p.ReasoningEffort)
+	}
+	return strings.Join(parts, " ")
+}
+
+// RequestFeatures 提供路由条件使用的请求特征，实现可以按需计算（如只在需要时估算令牌数）
+type RequestFeatures interface {
+	HasImages() bool      // 消息（包括工具结果）中是否包含图片
+	HasTools() bool       // 请求是否定义了工具
+	InputTokens() int     // 估算的输入令牌数
+	SystemPrompt() string // 系统提示文本
+}
+
+// RouteConditions 是路由规则的请求内容条件，所有设置的条件都满足时才匹配，零值表示没有条件
+type RouteConditions struct {
+	Images         *bool  // 是否包含图片
+	Tools          *bool  // 是否定义了工具（Claude Code 生成标题、摘要等辅助请求不带工具）
+	MinInputTokens int    // 估算输入令牌数的下限（包含）
+	MaxInputTokens int    // 估算输入令牌数的上限（包含）
+	System         string // 系统提示需要包含的正则表达式匹配（如子代理的提示词）
+
+	systemRe *regexp.Regexp
+}
+
+// IsZero 如果没有设置任何条件则返回 true
+func (c RouteConditions) IsZero() bool {
+	return c.Images == nil && c.Tools == nil && c.MinInputTokens == 0 && c.MaxInputTokens == 0 && c.System == ""
+}
+
+// Matches 判断请求是否满足所有条件。令牌数和系统提示只在设置了对应条件时才读取。
+func (c RouteConditions) Matches(f RequestFeatures) bool {
+	if c.Images != nil && f.HasImages() != *c.Images {
+		return false
+	}
+	if c.Tools != nil && f.HasTools() != *c.Tools {
+		return false
+	}
+	if c.MinInputTokens > 0 || c.MaxInputTokens > 0 {
+		tokens := f.InputTokens()
+		if c.MinInputTokens > 0 && tokens < c.MinInputTokens {
+			return false
+		}
+		if c.MaxInputTokens > 0 && tokens > c.MaxInputTokens {
+			return false
+		}
+	}
+	if c.systemRe != nil && !c.systemRe.MatchString(f.SystemPrompt()) {
+		return false
+	}
+	return true
+}
+
+// String 返回条件的可读形式（如 "images=true input_tokens>=100000"）
+func (c RouteConditions) String() string {
+	var parts []string
+	if c.Images != nil {
+		parts = append(parts, "images="+strconv.FormatBool(*c.Images))
+	}
+	if c.Tools != nil {
+		parts = append(parts, "tools="+strconv.FormatBool(*c.Tools))
+	}
+	if c.MinInputTokens > 0 {
+		parts = append(parts, "input_tokens>="+strconv.Itoa(c.MinInputTokens))
+	}
+	if c.MaxInputTokens > 0 {
+		parts = append(parts, "input_tokens<="+strconv.Itoa(c.MaxInputTokens))
+	}
+	if c.System != "" {
+		parts = append(parts, fmt.Sprintf("system~%q", c.System))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ModelRoute 是一条模型路由规则：请求的模型名称和内容匹配时，路由到指定后端和模型并覆盖请求参数。
+// 规则按配置文件中的顺序检查，使用第一条匹配的规则。
+type ModelRoute struct {
+	// Match 和 Pattern 匹配请求的模型名称，Match 为空时匹配任意名称（此时必须设置 When）
+	Match   RouteMatch
+	Pattern string
+	// When 请求内容条件
+	When RouteConditions
+	// Backend 目标后端名称，为空时使用默认后端
+	Backend string
+	// Model 目标模型名称，为空时使用请求的模型名称；
+	// 可以用 $1、${1} 引用通配符或正则表达式的捕获组（配置文件中的 ${name} 是环境变量，命名捕获组写作 $${name}）
+	Model  string
+	Params RouteParams
+	// Policy 转换为上游格式之后应用的参数策略
+	Policy ParamPolicy
+	// Position 规则在配置文件中的位置
+	Position Position
+
+	re *regexp.Regexp // glob 和 regex 规则编译后的正则表达式
+}
+
+// compile 编译规则的匹配模式
+func (r *ModelRoute) compile() error {
+	switch r.Match {
+	case "", RouteExact:
+		r.re = nil
+	case RouteGlob:
+		var sb strings.Builder
+		sb.WriteString("(?i)^")
+		for _, ch := range r.Pattern {
+			switch ch {
+			case '*':
+				sb.WriteString("(.*)")
+			case '?':
+				sb.WriteString("(.)")
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(ch)))
+			}
+		}
+		sb.WriteString("$")
+		r.re = regexp.MustCompile(sb.String())
+	case RouteRegex:
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		r.re = regexp.MustCompile("^(?:" + r.Pattern + ")$")
+	default:
+		return fmt.Errorf("未知的匹配方式 %q", r.Match)
+	}
+	return nil
+}
+
+// Resolve 如果模型名称和请求内容匹配规则，返回目标模型名称（已替换捕获组引用）。
+// features 为 nil 时（只知道模型名称）带有请求内容条件的规则不匹配。
+func (r *ModelRoute) Resolve(model string, features RequestFeatures) (string, bool) {
+	if !r.When.IsZero() && (features == nil || !r.When.Matches(features)) {
+		return "", false
+	}
+
+	target := r.Model
+	switch {
+	case r.re != nil:
+		match := r.re.FindStringSubmatchIndex(model)
+		if match == nil {
+			return "", false
+		}
+		if target != "" {
+			target = string(r.re.ExpandString(nil, target, model, match))
+		}
+	case r.Match == RouteExact:
+		if !strings.EqualFold(r.Pattern, model) {
+			return "", false
+		}
+	}
+
+	if target == "" {
+		return model, true
+	}
+	return target, true
+}
+
+// String 返回规则的可读形式（如 glob "claude-sonnet-4-5*" → local:qwen3-coder）
+func (r ModelRoute) String() string {
+	target := r.Model
+	if target == "" {
+		target = "（原模型名称）"
+	}
+	if r.Backend != "" {
+		target = r.Backend + ":" + target
+	}
+	s := "任意模型"
+	if r.Match != "" {
+		s = fmt.Sprintf("%s %q", r.Match, r.Pattern)
+	}
+	if !r.When.IsZero() {
+		s += "，条件 " + r.When.String()
+	}
+	s += " → " + target
+	var params []string
+	if !r.Params.IsZero() {
+		params = append(params, r.Params.String())
+	}
+	if !r.Policy.IsZero() {
+		params = append(params, "策略 "+r.Policy.String())
+	}
+	if len(params) > 0 {
+		s += "（" + strings.Join(params, "；") + "）"
+	}
+	return s
+}
+
+// walkRoutes 解析 routes 列表。后加载的配置文件中的 routes 整体替换之前的规则。
+func (p *fileParser) walkRoutes(node *yaml.Node) {
+	if isNull(node) {
+		return
+	}
+	if node.Kind != yaml.SequenceNode {
+		p.fail(node, "routes", "应为路由规则列表")
+		return
+	}
+
+	routes := make([]ModelRoute, 0, len(node.Content))
+	for i, item := range node.Content {
+		if route, ok := p.route(resolveAlias(item), fmt.Sprintf("routes[%d]", i)); ok {
+			routes = append(routes, route)
+		}
+	}
+	p.settings.routes = routes
+}
+
+// route 解析一条路由规则
+func (p *fileParser) route(node *yaml.Node, path string) (ModelRoute, bool) {
+	route := ModelRoute{Position: p.position(node)}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射（如 glob: claude-sonnet-*）")
+		return route, false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+
+		switch keyNode.Value {
+		case string(RouteExact), string(RouteGlob), string(RouteRegex):
+			if route.Match != "" {
+				p.fail(keyNode, keyPath, fmt.Sprintf("每条规则只能有一种匹配方式（已设置 %s）", route.Match))
+				ok = false
+				continue
+			}
+			pattern, valid := p.routeString(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			route.Match, route.Pattern = RouteMatch(keyNode.Value), pattern
+			if err := route.compile(); err != nil {
+				p.fail(valueNode, keyPath, err.Error())
+				ok = false
+			}
+		case "backend", "model":
+			text, valid := p.routeString(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			if keyNode.Value == "backend" {
+				route.Backend = text
+			} else {
+				route.Model = text
+			}
+		case "when":
+			ok = p.routeConditions(valueNode, keyPath, &route.When) && ok
+		case "params":
+			ok = p.routeParams(valueNode, keyPath, &route.Params) && ok
+		case "policy":
+			ok = p.routePolicy(valueNode, keyPath, &route.Policy) && ok
+		default:
+			p.fail(keyNode, keyPath, "未知的设置项")
+			ok = false
+		}
+	}
+
+	if route.Match == "" && route.When.IsZero() && ok {
+		p.fail(node, path, "缺少匹配方式（exact、glob、regex 或 when）")
+		ok = false
+	}
+	return route, ok
+}
+
+// routeString 返回路由规则中的非空字符串值
+func (p *fileParser) routeString(node *yaml.Node, path string) (string, bool) {
+	if node.Kind != yaml.ScalarNode || isNull(node) {
+		p.fail(node, path, "应为字符串")
+		return "", false
+	}
+	text, ok := p.scalar(node, path)
+	if !ok {
+		return "", false
+	}
+	if text = strings.TrimSpace(text); text == "" {
+		p.fail(node, path, "不能为空")
+		return "", false
+	}
+	return text, true
+}
+
+// routeParams 解析路由规则覆盖的请求参数
+func (p *fileParser) routeParams(node *yaml.Node, path string, params *RouteParams) bool {
+	if isNull(node) {
+		return true
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射")
+		return false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+
+		switch keyNode.Value {
+		case "temperature", "top_p":
+			text, valid := p.routeString(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil || value < 0 {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为非负数，当前为 %q", text))
+				ok = false
+				continue
+			}
+			if keyNode.Value == "temperature" {
+				params.Temperature = &value
+			} else {
+				params.TopP = &value
+			}
+		case "max_tokens":
+			text, valid := p.routeString(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			value, err := strconv.Atoi(text)
+			if err != nil || value <= 0 {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为正整数，当前为 %q", text))
+				ok = false
+				continue
+			}
+			params.MaxTokens = value
+		case "reasoning_effort":
+			text, valid := p.routeString(valueNode, keyPath)
+			if !valid {
+				ok = false
+				continue
+			}
+			params.ReasoningEffort = text
+		default:
+			p.fail(keyNode, keyPath, "未知的参数（支持 temperature、top_p、max_tokens、reasoning_effort）")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// routeConditions 解析路由规则的请求内容条件
+func (p *fileParser) routeConditions(node *yaml.Node, path string, when *RouteConditions) bool {
+	if isNull(node) {
+		return true
+	}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node, path, "应为映射")
+		return false
+	}
+
+	ok := true
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], resolveAlias(node.Content[i+1])
+		keyPath := path + "." + keyNode.Value
+		text, valid := p.routeString(valueNode, keyPath)
+		if !valid {
+			ok = false
+			continue
+		}
+
+		switch keyNode.Value {
+		case "images", "tools":
+			b, valid := parseFileBool(text)
+			if !valid {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为 true 或 false，当前为 %q", text))
+				ok = false
+				continue
+			}
+			if keyNode.Value == "images" {
+				when.Images = &b
+			} else {
+				when.Tools = &b
+			}
+		case "min_input_tokens", "max_input_tokens":
+			n, err := strconv.Atoi(text)
+			if err != nil || n <= 0 {
+				p.fail(valueNode, keyPath, fmt.Sprintf("应为正整数，当前为 %q", text))
+				ok = false
+				continue
+			}
+			if keyNode.Value == "min_input_tokens" {
+				when.MinInputTokens = n
+			} else {
+				when.MaxInputTokens = n
+			}
+		case "system":
+			re, err := regexp.Compile(text)
+			if err != nil {
+				p.fail(valueNode, keyPath, fmt.Sprintf("无效的正则表达式: %v", err))
+				ok = false
+				continue
+			}
+			when.System, when.systemRe = text, re
+		default:
+			p.fail(keyNode, keyPath, "未知的条件（支持 images、tools、min_input_tokens、max_input_tokens、system）")
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseRoutes 解析配置文件中的 routes 列表
+func parseRoutes(t *testing.T, data string) []ModelRoute {
+	t.Helper()
+	fs := newTestFileSettings()
+	if errs := fs.parse("proxy.yaml", []byte(data)); len(errs) > 0 {
+		t.Fatalf("解析路由规则失败: %v", errs)
+	}
+	return fs.routes
+}
+
+func TestModelRouteResolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		model  string
+		want   string
+		wantOK bool
+	}{
+		{"exact 不区分大小写", "exact: fast\n    model: flash", "FAST", "flash", true},
+		{"exact 需要完整名称", "exact: fast\n    model: flash", "fast-2", "", false},
+		{"省略 model 时使用请求的名称", "exact: fast", "Fast", "Fast", true},
+		{"glob 不区分大小写", "glob: claude-sonnet-4-5*\n    model: qwen3-coder", "Claude-Sonnet-4-5-20250929", "qwen3-coder", true},
+		{"glob 需要匹配完整名称", "glob: sonnet*\n    model: x", "claude-sonnet-4", "", false},
+		{"glob 的 * 和 ? 依次为捕获组 $1 $2", "glob: claude-*-4-?\n    model: x-$1-$2", "claude-opus-4-5", "x-opus-5", true},
+		{"${1} 形式的捕获组引用", "glob: claude-*-4\n    model: m-${1}x", "claude-haiku-4", "m-haikux", true},
+		{"glob 中的其他字符按字面匹配", "glob: gpt-4.1*\n    model: x", "gpt-4x1-mini", "", false},
+		{"regex 捕获组", `regex: claude-(opus|sonnet)-4-(\d)-.*` + "\n    model: anthropic/claude-${1}-4.${2}", "claude-opus-4-5-20251101", "anthropic/claude-opus-4.5", true},
+		{"regex 区分大小写", `regex: claude-(opus|sonnet)-.*` + "\n    model: x", "Claude-opus-4", "", false},
+		{"regex 可用 (?i) 忽略大小写", `regex: (?i)claude-(opus|sonnet)-.*` + "\n    model: $1", "Claude-Opus-4", "Opus", true},
+		{"regex 必须匹配完整名称", "regex: sonnet\n    model: x", "claude-sonnet-4", "", false},
+		{"regex 中的 | 不突破完整名称匹配", "regex: a|b\n    model: x", "abc", "", false},
+		{"命名捕获组 $${name}", `regex: claude-(?P<family>opus|sonnet)-(?P<ver>\d).*` + "\n    model: $${family}-v$${ver}", "claude-sonnet-4-5", "sonnet-v4", true},
+		{"只知道模型名称时带条件的规则不匹配", "glob: '*'\n    when: {tools: true}\n    model: x", "claude-sonnet-4", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := parseRoutes(t, "routes:\n  - "+tt.rule+"\n")
+			if len(routes) != 1 {
+				t.Fatalf("路由规则 = %+v", routes)
+			}
+			got, ok := routes[0].Resolve(tt.model, nil)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Resolve(%q) = %q, %v，应为 %q, %v", tt.model, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes := parseRoutes(t, `
+routes:
+  - exact: fast
+    model: flash
+  - glob: claude-sonnet-*
+    backend: local
+    model: qwen3-coder
+    params:
+      temperature: 0.2
+      max_tokens: 8192
+`)
+	if len(routes) != 2 {
+		t.Fatalf("路由规则 = %+v", routes)
+	}
+	if got := routes[0].Position.String(); got != "proxy.yaml:3:5" {
+		t.Errorf("第一条规则的位置 = %s，应为 proxy.yaml:3:5", got)
+	}
+	want := `glob "claude-sonnet-*" → local:qwen3-coder（temperature=0.2 max_tokens=8192）`
+	if got := routes[1].String(); got != want {
+		t.Errorf("规则 = %s，应为 %s", got, want)
+	}
+
+	// 后加载的文件中的 routes 整体替换之前的规则
+	fs := newTestFileSettings()
+	_ = fs.parse("global.yaml", []byte("routes:\n  - exact: a\n  - exact: b\n"))
+	_ = fs.parse("project.yaml", []byte("routes:\n  - exact: c\n"))
+	if len(fs.routes) != 1 || fs.routes[0].Pattern != "c" {
+		t.Errorf("合并后的路由规则 = %+v，应只有 project.yaml 中的规则", fs.routes)
+	}
+}
+
+func TestParseRoutesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		wantPos string
+		wantMsg string
+	}{
+		{"无效的正则表达式", "regex: claude-(\n    model: x", "proxy.yaml:2:12", "无效的正则表达式"},
+		{"多种匹配方式", "exact: a\n    glob: b*", "proxy.yaml:3:5", "只能有一种匹配方式"},
+		{"缺少匹配方式", "model: x", "proxy.yaml:2:5", "缺少匹配方式"},
+		{"未知的设置项", "exact: a\n    target: x", "proxy.yaml:3:5", "未知的设置项"},
+		{"空的模型名称", "exact: a\n    model: ''", "proxy.yaml:3:12", "不能为空"},
+		{"无效的参数值", "exact: a\n    params: {temperature: hot}", "proxy.yaml:3:27", "应为非负数"},
+		{"未知的参数", "exact: a\n    params: {seed: 1}", "proxy.yaml:3:14", "未知的参数"},
+		{"未知的条件", "when: {vision: true}", "proxy.yaml:2:12", "未知的条件"},
+		{"无效的条件值", "when: {images: maybe}", "proxy.yaml:2:20", "应为 true 或 false"},
+		{"令牌数应为正整数", "when: {min_input_tokens: -1}", "proxy.yaml:2:30", "应为正整数"},
+		{"无效的系统提示正则表达式", "when: {system: '('}", "proxy.yaml:2:20", "无效的正则表达式"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := newTestFileSettings().parse("proxy.yaml", []byte("routes:\n  - "+tt.rule+"\n"))
+			if len(errs) != 1 {
+				t.Fatalf("错误 = %v，应有 1 个错误", errs)
+			}
+			if got := errs[0].Position.String(); got != tt.wantPos || !strings.Contains(errs[0].Message, tt.wantMsg) {
+				t.Errorf("错误 = %s: %s，应为 %s: 包含 %q", got, errs[0].Message, tt.wantPos, tt.wantMsg)
+			}
+		})
+	}
+}
+
+// fakeFeatures 是测试用的请求特征，记录令牌数被读取的次数
+type fakeFeatures struct {
+	images, tools bool
+	tokens        int
+	system        string
+	tokenCalls    int
+}
+
+func (f *fakeFeatures) HasImages() bool      { return f.images }
+func (f *fakeFeatures) HasTools() bool       { return f.tools }
+func (f *fakeFeatures) SystemPrompt() string { return f.system }
+func (f *fakeFeatures) InputTokens() int {
+	f.tokenCalls++
+	return f.tokens
+}
+
+func TestRouteConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		when       string
+		features   fakeFeatures
+		want       bool
+		wantTokens bool // 是否应读取令牌数
+	}{
+		{"images 满足", "{images: true}", fakeFeatures{images: true}, true, false},
+		{"images 不满足", "{images: true}", fakeFeatures{}, false, false},
+		{"images: false 匹配不含图片的请求", "{images: false}", fakeFeatures{}, true, false},
+		{"tools: false 匹配辅助请求", "{tools: false}", fakeFeatures{}, true, false},
+		{"tools: false 不匹配带工具的请求", "{tools: false}", fakeFeatures{tools: true}, false, false},
+		{"min_input_tokens 包含下限", "{min_input_tokens: 1000}", fakeFeatures{tokens: 1000}, true, true},
+		{"min_input_tokens 不满足", "{min_input_tokens: 1000}", fakeFeatures{tokens: 999}, false, true},
+		{"max_input_tokens 包含上限", "{max_input_tokens: 1000}", fakeFeatures{tokens: 1000}, true, true},
+		{"max_input_tokens 不满足", "{max_input_tokens: 1000}", fakeFeatures{tokens: 1001}, false, true},
+		{"令牌数范围", "{min_input_tokens: 10, max_input_tokens: 20}", fakeFeatures{tokens: 15}, true, true},
+		{"system 正则表达式部分匹配", "{system: 'sub-?agent'}", fakeFeatures{system: "You are a subagent for ..."}, true, false},
+		{"system 不满足", "{system: 'sub-?agent'}", fakeFeatures{system: "You are Claude Code"}, false, false},
+		{"所有条件都需要满足", "{images: true, tools: true}", fakeFeatures{images: true}, false, false},
+		{"前面的条件不满足时不估算令牌数", "{tools: true, min_input_tokens: 10}", fakeFeatures{tokens: 100}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := parseRoutes(t, "routes:\n  - when: "+tt.when+"\n    model: target\n")
+			features := tt.features
+			if got := routes[0].When.Matches(&features); got != tt.want {
+				t.Errorf("Matches = %v，应为 %v（条件 %s）", got, tt.want, routes[0].When)
+			}
+			if got := features.tokenCalls > 0; got != tt.wantTokens {
+				t.Errorf("InputTokens 调用了 %d 次，应读取令牌数 = %v", features.tokenCalls, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestConditionalRouteResolve(t *testing.T) {
+	routes := parseRoutes(t, `
+routes:
+  - when: {images: true}
+    model: vision
+  - glob: claude-haiku-*
+    when: {tools: false}
+    model: small
+`)
+	tests := []struct {
+		name     string
+		route    int
+		model    string
+		features *fakeFeatures
+		want     string
+		wantOK   bool
+	}{
+		{"只有条件的规则匹配任意模型名称", 0, "claude-sonnet-4", &fakeFeatures{images: true}, "vision", true},
+		{"只有条件的规则在条件不满足时不匹配", 0, "claude-sonnet-4", &fakeFeatures{}, "", false},
+		{"名称和条件都满足", 1, "claude-haiku-4-5", &fakeFeatures{}, "small", true},
+		{"名称满足但条件不满足", 1, "claude-haiku-4-5", &fakeFeatures{tools: true}, "", false},
+		{"条件满足但名称不满足", 1, "claude-sonnet-4", &fakeFeatures{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := routes[tt.route].Resolve(tt.model, tt.features)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Resolve(%q) = %q, %v，应为 %q, %v", tt.model, got, ok, tt.want, tt.wantOK)
+			}
+			if tt.features.tokenCalls != 0 {
+				t.Errorf("没有令牌数条件时不应调用 InputTokens")
+			}
+		})
+	}
+	if got := routes[1].String(); !strings.Contains(got, "tools=false") {
+		t.Errorf("规则 = %s，应包含条件", got)
+	}
+}
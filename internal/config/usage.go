@@ -0,0 +1,174 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+)
+
+// usageStoreVersion 是用量文件的格式版本，版本不同时忽略文件
+const usageStoreVersion = 1
+
+// usageSaveInterval 记录用量后写入用量文件的延迟，期间的其他请求合并为一次写入
+const usageSaveInterval = 5 * time.Second
+
+// UsageTotals 是一个周期内的累计用量
+type UsageTotals struct {
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Cost         float64 `json:"cost"` // 美元
+}
+
+// Tokens 返回输入和输出令牌总数
+func (t UsageTotals) Tokens() int64 {
+	return t.InputTokens + t.OutputTokens
+}
+
+// ClientUsage 是客户端当前每日和每月周期的用量（周期按本地时间计算）
+type ClientUsage struct {
+	Day     string      `json:"day"` // 每日周期（如 2026-10-18）
+	Daily   UsageTotals `json:"daily"`
+	Month   string      `json:"month"` // 每月周期（如 2026-10）
+	Monthly UsageTotals `json:"monthly"`
+}
+
+// at 返回 now 所在周期的用量（记录的周期已经过去时该周期从零开始）
+func (u ClientUsage) at(now time.Time) ClientUsage {
+	if day := now.Format("2006-01-02"); u.Day != day {
+		u.Day, u.Daily = day, UsageTotals{}
+	}
+	if month := now.Format("2006-01"); u.Month != month {
+		u.Month, u.Monthly = month, UsageTotals{}
+	}
+	return u
+}
+
+// usageStoreFile 是用量文件的内容
+type usageStoreFile struct {
+	Version int                    `json:"version"`
+	Clients map[string]ClientUsage `json:"clients"`
+}
+
+// 全局客户端用量（客户端名称 -> 用量）
+// 由互斥锁保护；设置了用量文件时，记录后在 usageSaveInterval 内写入文件（关闭时立即写入），重启后预算继续累计
+var (
+	clientUsage    = make(map[string]ClientUsage)
+	usageMutex     sync.Mutex
+	usageStorePath string // 用量文件路径（为空时只保存在内存中）
+	usageDirty     bool   // 有尚未写入文件的用量（已安排写入）
+	usageSaveMutex sync.Mutex
+)
+
+// LoadUsageStore 从文件加载客户端用量，之后记录的用量写入同一文件。
+// 文件不存在时从零开始；返回加载的客户端数量。
+func LoadUsageStore(path string) (int, error) {
+	usageMutex.Lock()
+	defer usageMutex.Unlock()
+	usageStorePath = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var file usageStoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("解析用量文件 %s 失败: %w", path, err)
+	}
+	if file.Version != usageStoreVersion {
+		return 0, nil
+	}
+	for name, usage := range file.Clients {
+		clientUsage[name] = usage
+	}
+	return len(file.Clients), nil
+}
+
+// RecordUsage 累加客户端一个请求的用量，返回累加后当前周期的用量。
+// 用量文件在请求之外延迟写入，不阻塞请求。
+func RecordUsage(client string, inputTokens, outputTokens int, cost float64) ClientUsage {
+	usageMutex.Lock()
+	usage := clientUsage[client].at(time.Now())
+	for _, totals := range []*UsageTotals{&usage.Daily, &usage.Monthly} {
+		totals.Requests++
+		totals.InputTokens += int64(inputTokens)
+		totals.OutputTokens += int64(outputTokens)
+		totals.Cost += cost
+	}
+	clientUsage[client] = usage
+	schedule := usageStorePath != "" && !usageDirty
+	usageDirty = usageDirty || usageStorePath != ""
+	usageMutex.Unlock()
+
+	if schedule {
+		time.AfterFunc(usageSaveInterval, func() {
+			if err := FlushUsageStore(); err != nil {
+				fmt.Printf("⚠️  写入用量文件失败: %v\n", err)
+			}
+		})
+	}
+	return usage
+}
+
+// UsageFor 返回客户端当前周期的用量
+func UsageFor(client string) ClientUsage {
+	usageMutex.Lock()
+	defer usageMutex.Unlock()
+	return clientUsage[client].at(time.Now())
+}
+
+// UsageSnapshot 返回所有客户端当前周期的用量（按客户端名称排序），用于状态端点
+func UsageSnapshot() []UsageEntry {
+	usageMutex.Lock()
+	defer usageMutex.Unlock()
+	now := time.Now()
+	entries := make([]UsageEntry, 0, len(clientUsage))
+	for name, usage := range clientUsage {
+		entries = append(entries, UsageEntry{Client: name, ClientUsage: usage.at(now)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Client < entries[j].Client })
+	return entries
+}
+
+// UsageEntry 是一个客户端的用量（状态端点使用）
+type UsageEntry struct {
+	Client string `json:"client"`
+	ClientUsage
+}
+
+// FlushUsageStore 立即将尚未写入的客户端用量写入文件（关闭代理时调用）。
+// 先写临时文件再重命名，避免并发读取到不完整的内容；写入失败时用量保留在内存中，下次记录后重试。
+func FlushUsageStore() error {
+	usageSaveMutex.Lock()
+	defer usageSaveMutex.Unlock()
+
+	usageMutex.Lock()
+	if !usageDirty {
+		usageMutex.Unlock()
+		return nil
+	}
+	usageDirty = false
+	path := usageStorePath
+	data, err := json.MarshalIndent(usageStoreFile{Version: usageStoreVersion, Clients: clientUsage}, "", "  ")
+	usageMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+)
+
+// resetUsageStore 清空全局用量并在测试结束后恢复为只保存在内存中
+func resetUsageStore(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		usageMutex.Lock()
+		defer usageMutex.Unlock()
+		clientUsage = make(map[string]ClientUsage)
+		usageStorePath, usageDirty = "", false
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestPriceFor(t *testing.T) {
+	fs := newTestFileSettings()
+	if errs := fs.parse("proxy.yaml", []byte(`
+pricing:
+  "*": {input: 1, output: 1}
+  gpt-4o*: {input: 2.5, output: 10}
+  gpt-4o-mini*: {input: 0.15, output: 0.6}
+  deepseek/deepseek-?1: {input: 0.5, output: 2}
+`)); len(errs) > 0 {
+		t.Fatalf("解析价格表失败: %v", errs)
+	}
+	cfg := &Config{Pricing: fs.pricing}
+
+	tests := []struct {
+		model       string
+		wantPattern string
+	}{
+		{"gpt-4o-mini-2024-07-18", "gpt-4o-mini*"},
+		{"gpt-4o", "gpt-4o*"},
+		{"GPT-4o-2024-08-06", "gpt-4o*"},
+		{"deepseek/deepseek-r1", "deepseek/deepseek-?1"},
+		{"qwen3-coder", "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			price, ok := cfg.PriceFor(tt.model)
+			if !ok || price.Pattern != tt.wantPattern {
+				t.Errorf("PriceFor(%q) = %q, %v，应使用最长的匹配模式 %q", tt.model, price.Pattern, ok, tt.wantPattern)
+			}
+		})
+	}
+
+	if _, ok := (&Config{}).PriceFor("gpt-4o"); ok {
+		t.Errorf("没有价格表时不应有价格")
+	}
+	price, _ := cfg.PriceFor("gpt-4o")
+	if got := price.Cost(1_000_000, 100_000); math.Abs(got-3.5) > 1e-9 {
+		t.Errorf("Cost = %v，应为 3.5", got)
+	}
+	if got := price.String(); got != "gpt-4o*（输入 $2.5 / 输出 $10 每百万令牌）" {
+		t.Errorf("String = %s", got)
+	}
+}
+
+func TestUsageStoreRoundTrip(t *testing.T) {
+	resetUsageStore(t)
+	path := filepath.Join(t.TempDir(), "state", "usage.json")
+
+	if n, err := LoadUsageStore(path); n != 0 || err != nil {
+		t.Fatalf("LoadUsageStore（文件不存在）= %d, %v，应从零开始", n, err)
+	}
+	RecordUsage("alice", 100, 20, 0.5)
+	total := RecordUsage("alice", 50, 10, 0.25)
+	if total.Daily.Requests != 2 || total.Daily.Tokens() != 180 || total.Monthly.Cost != 0.75 {
+		t.Errorf("累计用量 = %+v", total)
+	}
+	if err := FlushUsageStore(); err != nil {
+		t.Fatalf("写入用量文件失败: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取用量文件失败: %v", err)
+	}
+	var file usageStoreFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != usageStoreVersion {
+		t.Fatalf("用量文件 = %s（%v），版本应为 %d", data, err, usageStoreVersion)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("临时文件应已重命名")
+	}
+
+	// 重启后从文件恢复
+	resetUsageStore(t)
+	if n, err := LoadUsageStore(path); n != 1 || err != nil {
+		t.Fatalf("LoadUsageStore = %d, %v，应加载 1 个客户端", n, err)
+	}
+	if got := UsageFor("alice"); got != total {
+		t.Errorf("恢复的用量 = %+v，应为 %+v", got, total)
+	}
+	if got := UsageSnapshot(); len(got) != 1 || got[0].Client != "alice" {
+		t.Errorf("UsageSnapshot = %+v", got)
+	}
+}
+
+func TestUsageStorePeriods(t *testing.T) {
+	resetUsageStore(t)
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "usage.json")
+	writeConfigFile(t, path, `{"version": 1, "clients": {
+  "old": {"day": "2000-01-01", "daily": {"requests": 5, "input_tokens": 500}, "month": "2000-01", "monthly": {"requests": 5, "cost": 9}},
+  "today": {"day": "`+now.Format("2006-01-02")+`", "daily": {"input_tokens": 10}, "month": "`+now.Format("2006-01")+`", "monthly": {"input_tokens": 30}}
+}}`)
+	if _, err := LoadUsageStore(path); err != nil {
+		t.Fatalf("加载用量文件失败: %v", err)
+	}
+
+	// 过去的周期从零开始，当前周期继续累计
+	if got := UsageFor("old"); got.Daily.Requests != 0 || got.Monthly.Cost != 0 {
+		t.Errorf("过去周期的用量 = %+v，应从零开始", got)
+	}
+	if got := UsageFor("today"); got.Daily.InputTokens != 10 || got.Monthly.InputTokens != 30 {
+		t.Errorf("当前周期的用量 = %+v", got)
+	}
+
+	// 版本不同的文件被忽略，无效的文件返回错误
+	resetUsageStore(t)
+	writeConfigFile(t, path, `{"version": 99, "clients": {"alice": {}}}`)
+	if n, err := LoadUsageStore(path); n != 0 || err != nil {
+		t.Errorf("LoadUsageStore（版本不同）= %d, %v，应忽略文件", n, err)
+	}
+	writeConfigFile(t, path, `{"version": `)
+	if _, err := LoadUsageStore(path); err == nil || !strings.Contains(err.Error(), "解析用量文件") {
+		t.Errorf("LoadUsageStore（无效的文件）错误 = %v", err)
+	}
+}
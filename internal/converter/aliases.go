@@ -0,0 +1,29 @@
+package converter
+
+import (
+	"time"
+)
+
+// ClaudeModel 描述代理对外公布的 Claude 模型别名
+type ClaudeModel struct {
+	ID          string
+	DisplayName string
+	CreatedAt   time.Time
+}
+
+// KnownClaudeModels 是 /v1/models 端点列出的 Claude 模型别名（按发布时间从新到旧）。
+// 实际路由由 ResolveRoute 根据路由规则和名称中的 opus/sonnet/haiku 层级决定。
+var KnownClaudeModels = []ClaudeModel{
+	{ID: "claude-opus-4-5-20251101", DisplayName: "Claude Opus 4.5", CreatedAt: modelDate(2025, 11, 1)},
+	{ID: "claude-haiku-4-5-20251001", DisplayName: "Claude Haiku 4.5", CreatedAt: modelDate(2025, 10, 1)},
+	{ID: "claude-sonnet-4-5-20250929", DisplayName: "Claude Sonnet 4.5", CreatedAt: modelDate(2025, 9, 29)},
+	{ID: "claude-opus-4-1-20250805", DisplayName: "Claude Opus 4.1", CreatedAt: modelDate(2025, 8, 5)},
+	{ID: "claude-opus-4-20250514", DisplayName: "Claude Opus 4", CreatedAt: modelDate(2025, 5, 14)},
+	{ID: "claude-sonnet-4-20250514", DisplayName: "Claude Sonnet 4", CreatedAt: modelDate(2025, 5, 14)},
+	{ID: "claude-3-7-sonnet-20250219", DisplayName: "Claude Sonnet 3.7", CreatedAt: modelDate(2025, 2, 19)},
+	{ID: "claude-3-5-haiku-20241022", DisplayName: "Claude Haiku 3.5", CreatedAt: modelDate(2024, 10, 22)},
+}
+
+func modelDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,283 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// bedrockSignaturePrefix 标记思考块签名中保存的是 Bedrock 推理签名
+const bedrockSignaturePrefix = "bedrock:"
+
+// EncodeBedrockSignature 将 Bedrock 推理块的签名编码为 Claude 思考块签名。
+// Claude Code 在后续请求中原样发回思考块，转换时还原为带签名的 reasoningContent 块。
+func EncodeBedrockSignature(signature string) string {
+	return bedrockSignaturePrefix + signature
+}
+
+// decodeBedrockSignature 从思考块签名中还原 Bedrock 推理签名
+func decodeBedrockSignature(signature string) (string, bool) {
+	bedrockSignature, ok := strings.CutPrefix(signature, bedrockSignaturePrefix)
+	return bedrockSignature, ok && bedrockSignature != ""
+}
+
+// ConvertToBedrockRequest 将已转换的聊天完成请求改写为 Bedrock Converse 请求。
+// 模型映射、令牌限制和工具描述增强等逻辑沿用 ConvertRequest 的结果（模型 ID 放在 URL 中）；
+// 对话内容由原始 Claude 消息直接转换，以保留思考签名和图片。
+func ConvertToBedrockRequest(openaiReq *models.OpenAIRequest) (*models.BedrockConverseRequest, error) {
+	if openaiReq.Original == nil {
+		return nil, fmt.Errorf("Bedrock 协议需要原始 Claude 请求")
+	}
+	claudeReq := openaiReq.Original
+
+	bedrockReq := &models.BedrockConverseRequest{
+		Messages: convertMessagesToBedrock(claudeReq.Messages),
+		InferenceConfig: &models.BedrockInferenceConfig{
+			MaxTokens:     openaiReq.MaxCompletionTokens,
+			Temperature:   openaiReq.Temperature,
+			TopP:          openaiReq.TopP,
+			StopSequences: openaiReq.Stop,
+		},
+	}
+	if bedrockReq.InferenceConfig.MaxTokens == 0 {
+		bedrockReq.InferenceConfig.MaxTokens = openaiReq.MaxTokens
+	}
+
+	// 系统消息（包括注入的工具参数说明）成为 system 块
+	for _, msg := range openaiReq.Messages {
+		if msg.Role == constants.RoleSystem {
+			if text, ok := msg.Content.(string); ok && text != "" {
+				bedrockReq.System = append(bedrockReq.System, models.BedrockSystemBlock{Text: text})
+			}
+		}
+	}
+
+	// 对话中包含 toolUse 块时 Bedrock 要求提供 toolConfig，因此工具定义始终发送
+	if len(openaiReq.Tools) > 0 {
+		tools := make([]models.BedrockTool, 0, len(openaiReq.Tools))
+		for _, tool := range openaiReq.Tools {
+			spec := models.BedrockToolSpec{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+			}
+			spec.InputSchema.JSON = tool.Function.Parameters
+			tools = append(tools, models.BedrockTool{ToolSpec: spec})
+		}
+		bedrockReq.ToolConfig = &models.BedrockToolConfig{
+			Tools:      tools,
+			ToolChoice: bedrockToolChoice(openaiReq.ToolChoice),
+		}
+	}
+
+	// Claude 的扩展思考通过 additionalModelRequestFields 原样传给 Bedrock 上的 Claude 模型
+	if claudeReq.Thinking != nil && claudeReq.Thinking.Type == "enabled" {
+		bedrockReq.AdditionalModelRequestFields = map[string]interface{}{
+			"thinking": map[string]interface{}{
+				"type":          "enabled",
+				"budget_tokens": claudeReq.Thinking.BudgetTokens,
+			},
+		}
+	}
+
+	return bedrockReq, nil
+}
+
+// convertMessagesToBedrock 将 Claude 消息转换为 Converse 消息。
+//
+// 转换规则：
+//   - text 块转换为 text 块，base64 图片转换为 image 块（URL 图片无法传递）
+//   - 带 Bedrock 签名的 thinking 块转换为 reasoningContent 块，其他思考块无法验证而被丢弃
+//   - tool_use 块转换为 toolUse 块，tool_result 块转换为 toolResult 块
+func convertMessagesToBedrock(messages []models.ClaudeMessage) []models.BedrockMessage {
+	result := []models.BedrockMessage{}
+
+	for _, msg := range messages {
+		var blocks []models.BedrockContentBlock
+
+		switch content := msg.Content.(type) {
+		case string:
+			if content != "" {
+				blocks = append(blocks, models.BedrockContentBlock{Text: content})
+			}
+
+		case []interface{}:
+			for _, block := range content {
+				blockMap, ok := block.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				switch blockMap["type"] {
+				case constants.ContentTypeText:
+					if text, _ := blockMap["text"].(string); text != "" {
+						blocks = append(blocks, models.BedrockContentBlock{Text: text})
+					}
+
+				case constants.ContentTypeImage:
+					source, _ := blockMap["source"].(map[string]interface{})
+					if source["type"] == "base64" {
+						mediaType, _ := source["media_type"].(string)
+						data, _ := source["data"].(string)
+						image := &models.BedrockImage{Format: bedrockImageFormat(mediaType)}
+						image.Source.Bytes = data
+						blocks = append(blocks, models.BedrockContentBlock{Image: image})
+					}
+
+				case constants.ContentTypeThinking:
+					signature, _ := blockMap["signature"].(string)
+					if sig, ok := decodeBedrockSignature(signature); ok {
+						thinking, _ := blockMap["thinking"].(string)
+						blocks = append(blocks, models.BedrockContentBlock{
+							ReasoningContent: &models.BedrockReasoningContent{
+								ReasoningText: &models.BedrockReasoningText{Text: thinking, Signature: sig},
+							},
+						})
+					}
+
+				case constants.ContentTypeToolUse:
+					id, _ := blockMap["id"].(string)
+					name, _ := blockMap["name"].(string)
+					input, _ := blockMap["input"].(map[string]interface{})
+					if input == nil {
+						input = map[string]interface{}{}
+					}
+					blocks = append(blocks, models.BedrockContentBlock{
+						ToolUse: &models.BedrockToolUse{ToolUseID: id, Name: name, Input: input},
+					})
+
+				case constants.ContentTypeToolResult:
+					id, _ := blockMap["tool_use_id"].(string)
+					toolResult := &models.BedrockToolResult{
+						ToolUseID: id,
+						Content:   []models.BedrockToolResultContent{{Text: extractSystemText(blockMap["content"])}},
+					}
+					if isError, _ := blockMap["is_error"].(bool); isError {
+						toolResult.Status = "error"
+					}
+					blocks = append(blocks, models.BedrockContentBlock{ToolResult: toolResult})
+				}
+			}
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		result = append(result, models.BedrockMessage{Role: msg.Role, Content: blocks})
+	}
+
+	return result
+}
+
+// bedrockImageFormat 将 MIME 类型转换为 Converse 图片格式（image/jpg 视为 jpeg）
+func bedrockImageFormat(mediaType string) string {
+	format := strings.TrimPrefix(strings.ToLower(mediaType), "image/")
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
+// bedrockToolChoice 将聊天完成的 tool_choice 转换为 Converse 的 toolChoice。
+// Converse 没有 none 选项，此时与未指定一样由模型自行决定。
+func bedrockToolChoice(toolChoice interface{}) map[string]interface{} {
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "required":
+			return map[string]interface{}{"any": map[string]interface{}{}}
+		case "auto":
+			return map[string]interface{}{"auto": map[string]interface{}{}}
+		}
+	case map[string]interface{}:
+		if function, ok := choice["function"].(map[string]interface{}); ok {
+			return map[string]interface{}{"tool": map[string]interface{}{"name": function["name"]}}
+		}
+	}
+	return nil
+}
+
+// ConvertBedrockResponse 将 Bedrock Converse 响应转换为聊天完成响应，以便复用 ConvertResponse。
+// 推理块转换为 reasoning.text，第一个推理签名转换为 reasoning.encrypted（data 为编码后的思考块签名）。
+func ConvertBedrockResponse(bedrockResp *models.BedrockConverseResponse, model string) *models.OpenAIResponse {
+	message := models.OpenAIMessage{Role: constants.RoleAssistant}
+	var textParts []string
+	var signature string
+
+	for _, block := range bedrockResp.Output.Message.Content {
+		switch {
+		case block.ToolUse != nil:
+			argsJSON, err := json.Marshal(block.ToolUse.Input)
+			if err != nil || block.ToolUse.Input == nil {
+				argsJSON = []byte("{}")
+			}
+			toolCall := models.OpenAIToolCall{ID: block.ToolUse.ToolUseID, Type: constants.ToolTypeFunction}
+			toolCall.Function.Name = block.ToolUse.Name
+			toolCall.Function.Arguments = string(argsJSON)
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+
+		case block.ReasoningContent != nil && block.ReasoningContent.ReasoningText != nil:
+			reasoning := block.ReasoningContent.ReasoningText
+			if reasoning.Text != "" {
+				message.ReasoningDetails = append(message.ReasoningDetails, map[string]interface{}{
+					"type": constants.ReasoningTypeText,
+					"text": reasoning.Text,
+				})
+			}
+			if reasoning.Signature != "" && signature == "" {
+				signature = reasoning.Signature
+			}
+
+		default:
+			textParts = append(textParts, block.Text)
+		}
+	}
+	message.Content = strings.Join(textParts, "")
+
+	if signature != "" {
+		message.ReasoningDetails = append(message.ReasoningDetails, map[string]interface{}{
+			"type": constants.ReasoningTypeEncrypted,
+			"data": EncodeBedrockSignature(signature),
+		})
+	}
+
+	finishReason := BedrockFinishReason(bedrockResp.StopReason)
+
+	// Converse 响应没有 ID，与流式响应一样按时间生成
+	return &models.OpenAIResponse{
+		ID:     fmt.Sprintf("msg_%d", time.Now().UnixNano()),
+		Object: "chat.completion",
+		Model:  model,
+		Choices: []models.OpenAIChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: &finishReason,
+		}},
+		Usage: bedrockUsage(bedrockResp.Usage),
+	}
+}
+
+// bedrockUsage 将 Bedrock 的令牌使用量转换为聊天完成格式（缓存读写的令牌计入输入）
+func bedrockUsage(usage models.BedrockUsage) models.OpenAIUsage {
+	return models.OpenAIUsage{
+		PromptTokens:     usage.InputTokens + usage.CacheReadInputTokens + usage.CacheWriteInputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// BedrockFinishReason 将 Converse 的 stopReason 映射为聊天完成的完成原因
+func BedrockFinishReason(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return constants.FinishReasonToolCalls
+	case "max_tokens", "model_context_window_exceeded":
+		return constants.FinishReasonLength
+	case "content_filtered", "guardrail_intervened":
+		return constants.FinishReasonContentFilter
+	}
+	return constants.FinishReasonStop
+}
@@ -92,10 +92,10 @@ func ExtractReasoningText(detail map[string]interface{}) string {
 	return ""
 }
 
-// ConvertRequest 将 Claude API 请求转换为 OpenAI 格式
-func ConvertRequest(claudeReq models.ClaudeRequest, cfg *config.Config) (*models.OpenAIRequest, error) {
-	// 使用基于模式的路由映射模型
-	openaiModel := mapModel(claudeReq.Model, cfg)
+// ConvertRequest 将 Claude API 请求转换为 OpenAI 格式，使用路由结果（见 RouteRequest）的模型并覆盖请求参数
+func ConvertRequest(claudeReq models.ClaudeRequest, route Route, cfg *config.Config) (*models.OpenAIRequest, error) {
+	openaiModel := route.Model
+	applyRouteParams(&claudeReq, route.Params)
 
 	// 提取系统消息（可以是字符串或内容块数组）
 	systemText := extractSystemText(claudeReq.System)
@@ -112,40 +112,9 @@ func ConvertRequest(claudeReq models.ClaudeRequest, cfg *config.Config) (*models
 		Stream:      claudeReq.Stream,
 	}
 
-	// 启用使用量跟踪和推理 - 针对不同提供商的特定设置
-	if claudeReq.Stream != nil && *claudeReq.Stream {
-		provider := cfg.DetectProvider()
-
-		switch provider {
-		case config.ProviderOpenRouter:
-			// OpenRouter 需要启用推理块和使用量跟踪
-			// - reasoning.enabled: 在响应中启用思考块
-			// - usage.include: 即使在流式模式下也跟踪令牌使用量
-			openaiReq.StreamOptions = map[string]interface{}{
-				"include_usage": true,
-			}
-			openaiReq.Usage = map[string]interface{}{
-				"include": true,
-			}
-			openaiReq.Reasoning = map[string]interface{}{
-				"enabled": true,
-			}
-
-		case config.ProviderOpenAI:
-			// OpenAI GPT-5 模型支持 reasoning_effort 参数
-			// 此参数控制模型在响应前花多少时间思考
-			openaiReq.StreamOptions = map[string]interface{}{
-				"include_usage": true,
-			}
-			openaiReq.ReasoningEffort = "medium" // minimal | low | medium | high
-
-		case config.ProviderOllama:
-			// Ollama 需要在有工具时显式设置 tool_choice
-			// 否则 Ollama 模型可能不会自然选择使用工具
-			if len(claudeReq.Tools) > 0 {
-				openaiReq.ToolChoice = "required"
-			}
-		}
+	// 流式专用的提供商参数（使用量跟踪、推理等）由 SetUpstreamStreaming 在确定上游流式模式后添加
+	if route.Params.ReasoningEffort != "" {
+		openaiReq.ReasoningEffort = route.Params.ReasoningEffort
 	}
 
 	// 使用自适应的单模型检测设置令牌限制
@@ -172,42 +141,59 @@ func ConvertRequest(claudeReq models.ClaudeRequest, cfg *config.Config) (*models
 		openaiReq.Tools = convertTools(claudeReq.Tools)
 	}
 
+	// 保留原始请求，供 Responses API、Gemini 等需要完整内容块的上游协议使用
+	openaiReq.Original = &claudeReq
+
 	return openaiReq, nil
 }
 
-// mapModel 使用模式匹配将 Claude 模型名称映射到特定提供商的模型。
-// 将 haiku/sonnet/opus 层级路由到适当的 Gemini 模型，并允许
-// 通过环境变量覆盖以路由到 Grok、Gemini 或 DeepSeek 等替代提供商。
-// 非 Claude 模型名称将原样传递。
-func mapModel(claudeModel string, cfg *config.Config) string {
-	modelLower := strings.ToLower(claudeModel)
+// applyStreamOptions 为流式上游请求添加提供商特定的参数（使用量跟踪、推理等）
+func applyStreamOptions(openaiReq *models.OpenAIRequest, cfg *config.Config) {
+	provider := cfg.DetectProvider()
 
-	// Haiku 层级
-	if strings.Contains(modelLower, "haiku") {
-		if cfg.HaikuModel != "" {
-			return cfg.HaikuModel
+	switch provider {
+	case config.ProviderOpenRouter:
+		// OpenRouter 需要启用推理块和使用量跟踪
+		// - reasoning.enabled: 在响应中启用思考块
+		// - usage.include: 即使在流式模式下也跟踪令牌使用量
+		openaiReq.StreamOptions = map[string]interface{}{
+			"include_usage": true,
+		}
+		openaiReq.Usage = map[string]interface{}{
+			"include": true,
+		}
+		openaiReq.Reasoning = map[string]interface{}{
+			"enabled": true,
 		}
-		return DefaultHaikuModel
-	}
 
-	// Sonnet 层级
-	if strings.Contains(modelLower, "sonnet") {
-		if cfg.SonnetModel != "" {
-			return cfg.SonnetModel
+	case config.ProviderAzure:
+		// Azure OpenAI 需要显式请求流式使用量（api-version 2024-10-21 起支持）
+		openaiReq.StreamOptions = map[string]interface{}{
+			"include_usage": true,
 		}
-		return DefaultSonnetModel
-	}
 
-	// Opus 层级
-	if strings.Contains(modelLower, "opus") {
-		if cfg.OpusModel != "" {
-			return cfg.OpusModel
+	case config.ProviderOpenAI:
+		// OpenAI GPT-5 模型支持 reasoning_effort 参数
+		// 此参数控制模型在响应前花多少时间思考
+		openaiReq.StreamOptions = map[string]interface{}{
+			"include_usage": true,
+		}
+		if openaiReq.ReasoningEffort == "" {
+			openaiReq.ReasoningEffort = "medium" // minimal | low | medium | high
 		}
-		return DefaultOpusModel
 	}
+}
 
-	// 非 Claude 模型直接传递（OpenAI、OpenRouter 等）
-	return claudeModel
+// SetUpstreamStreaming 设置已转换请求的上游流式模式。
+// ConvertRequest 不添加流式专用参数，调用方确定上游是否流式后（伪流式、流式聚合）、应用参数策略之前调用，
+// 使策略可以覆盖这些参数。启用时添加提供商特定的流式参数；禁用时清除 stream 字段，请求与非流式请求相同。
+func SetUpstreamStreaming(openaiReq *models.OpenAIRequest, stream bool, cfg *config.Config) {
+	if stream {
+		openaiReq.Stream = &stream
+		applyStreamOptions(openaiReq, cfg)
+		return
+	}
+	openaiReq.Stream = nil
 }
 
 // convertMessages 将 Claude 消息转换为 OpenAI 格式。
@@ -434,6 +420,21 @@ func ConvertResponse(openaiResp *models.OpenAIResponse, requestedModel string) (
 						Signature: &emptySignature, // Claude Code 正确隐藏/显示思考块所必需
 					})
 				}
+
+				// Responses API 的加密推理或 Gemini 思考签名：作为签名附加到前一个思考块（没有摘要时单独创建）
+				if data, ok := detailMap["data"].(string); ok && detailMap["type"] == constants.ReasoningTypeEncrypted {
+					if isRoundTripSignature(data) {
+						signature := data
+						if n := len(contentBlocks); n > 0 && contentBlocks[n-1].Type == "thinking" && *contentBlocks[n-1].Signature == "" {
+							contentBlocks[n-1].Signature = &signature
+						} else {
+							contentBlocks = append(contentBlocks, models.ContentBlock{
+								Type:      "thinking",
+								Signature: &signature,
+							})
+						}
+					}
+				}
 			}
 		}
 	}
@@ -0,0 +1,335 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// geminiSignaturePrefix 标记思考块签名中保存的是 Gemini 思考签名
+const geminiSignaturePrefix = "gemini:"
+
+// Gemini 内容角色
+const (
+	geminiRoleUser  = "user"
+	geminiRoleModel = "model"
+)
+
+// EncodeGeminiSignature 将 Gemini 的 thoughtSignature 编码为 Claude 思考块签名。
+// Claude Code 在后续请求中原样发回思考块，转换时再附加到对应的函数调用部分。
+func EncodeGeminiSignature(thoughtSignature string) string {
+	return geminiSignaturePrefix + thoughtSignature
+}
+
+// decodeGeminiSignature 从思考块签名中还原 Gemini 的 thoughtSignature
+func decodeGeminiSignature(signature string) (string, bool) {
+	thoughtSignature, ok := strings.CutPrefix(signature, geminiSignaturePrefix)
+	return thoughtSignature, ok && thoughtSignature != ""
+}
+
+// isRoundTripSignature 判断签名是否由代理编码（Responses API 推理项、Gemini 思考签名或 Bedrock 推理签名），
+// 这类签名需要保存在思考块中并在下一轮发回上游
+func isRoundTripSignature(signature string) bool {
+	if _, _, ok := decodeReasoningSignature(signature); ok {
+		return true
+	}
+	if _, ok := decodeBedrockSignature(signature); ok {
+		return true
+	}
+	_, ok := decodeGeminiSignature(signature)
+	return ok
+}
+
+// ConvertToGeminiRequest 将已转换的聊天完成请求改写为 Gemini generateContent 请求。
+// 模型映射、令牌限制和工具描述增强等逻辑沿用 ConvertRequest 的结果；
+// 对话内容由原始 Claude 消息直接转换，以保留思考签名和图片。
+func ConvertToGeminiRequest(openaiReq *models.OpenAIRequest) (*models.GeminiRequest, error) {
+	if openaiReq.Original == nil {
+		return nil, fmt.Errorf("Gemini 协议需要原始 Claude 请求")
+	}
+	claudeReq := openaiReq.Original
+
+	geminiReq := &models.GeminiRequest{
+		Contents: convertMessagesToGeminiContents(claudeReq.Messages),
+		GenerationConfig: &models.GeminiGenerationConfig{
+			MaxOutputTokens: openaiReq.MaxCompletionTokens,
+			Temperature:     openaiReq.Temperature,
+			TopP:            openaiReq.TopP,
+			StopSequences:   openaiReq.Stop,
+		},
+	}
+	if geminiReq.GenerationConfig.MaxOutputTokens == 0 {
+		geminiReq.GenerationConfig.MaxOutputTokens = openaiReq.MaxTokens
+	}
+
+	// 系统消息（包括注入的工具参数说明）成为 systemInstruction
+	var instructions []string
+	for _, msg := range openaiReq.Messages {
+		if msg.Role == constants.RoleSystem {
+			if text, ok := msg.Content.(string); ok && text != "" {
+				instructions = append(instructions, text)
+			}
+		}
+	}
+	if len(instructions) > 0 {
+		geminiReq.SystemInstruction = &models.GeminiContent{
+			Parts: []models.GeminiPart{{Text: strings.Join(instructions, "\n\n")}},
+		}
+	}
+
+	if len(openaiReq.Tools) > 0 {
+		declarations := make([]models.GeminiFunctionDeclaration, 0, len(openaiReq.Tools))
+		for _, tool := range openaiReq.Tools {
+			declarations = append(declarations, models.GeminiFunctionDeclaration{
+				Name:                 tool.Function.Name,
+				Description:          tool.Function.Description,
+				ParametersJSONSchema: tool.Function.Parameters,
+			})
+		}
+		geminiReq.Tools = []models.GeminiTool{{FunctionDeclarations: declarations}}
+		geminiReq.ToolConfig = geminiToolConfig(openaiReq.ToolChoice)
+	}
+
+	// Claude 的扩展思考映射到 thinkingConfig：返回思考摘要，预算沿用 budget_tokens
+	if claudeReq.Thinking != nil && claudeReq.Thinking.Type == "enabled" {
+		thinkingConfig := &models.GeminiThinkingConfig{IncludeThoughts: true}
+		if budget := claudeReq.Thinking.BudgetTokens; budget > 0 {
+			thinkingConfig.ThinkingBudget = &budget
+		}
+		geminiReq.GenerationConfig.ThinkingConfig = thinkingConfig
+	}
+
+	return geminiReq, nil
+}
+
+// convertMessagesToGeminiContents 将 Claude 消息转换为 Gemini 的 contents。
+//
+// 转换规则：
+//   - assistant 角色映射为 model，其他为 user
+//   - text 块转换为 text 部分，base64 图片转换为 inlineData 部分
+//   - tool_use 块转换为 functionCall 部分，tool_result 块转换为 functionResponse 部分（按 ID 查找函数名）
+//   - 思考块签名中的 thoughtSignature 附加到该轮第一个 functionCall 部分（没有函数调用时附加到最后一个部分）
+func convertMessagesToGeminiContents(messages []models.ClaudeMessage) []models.GeminiContent {
+	contents := []models.GeminiContent{}
+	toolNames := make(map[string]string) // tool_use ID -> 函数名称
+
+	for _, msg := range messages {
+		role := geminiRoleUser
+		if msg.Role == constants.RoleAssistant {
+			role = geminiRoleModel
+		}
+
+		var parts []models.GeminiPart
+		var thoughtSignature string
+
+		switch content := msg.Content.(type) {
+		case string:
+			if content != "" {
+				parts = append(parts, models.GeminiPart{Text: content})
+			}
+
+		case []interface{}:
+			for _, block := range content {
+				blockMap, ok := block.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				switch blockMap["type"] {
+				case constants.ContentTypeText:
+					if text, _ := blockMap["text"].(string); text != "" {
+						parts = append(parts, models.GeminiPart{Text: text})
+					}
+
+				case constants.ContentTypeImage:
+					// Gemini 只接受内联数据或已上传的文件，URL 图片无法直接传递
+					source, _ := blockMap["source"].(map[string]interface{})
+					if source["type"] == "base64" {
+						mediaType, _ := source["media_type"].(string)
+						data, _ := source["data"].(string)
+						parts = append(parts, models.GeminiPart{
+							InlineData: &models.GeminiInlineData{MimeType: mediaType, Data: data},
+						})
+					}
+
+				case constants.ContentTypeThinking:
+					signature, _ := blockMap["signature"].(string)
+					if sig, ok := decodeGeminiSignature(signature); ok && thoughtSignature == "" {
+						thoughtSignature = sig
+					}
+
+				case constants.ContentTypeToolUse:
+					id, _ := blockMap["id"].(string)
+					name, _ := blockMap["name"].(string)
+					toolNames[id] = name
+					args, _ := blockMap["input"].(map[string]interface{})
+					if args == nil {
+						args = map[string]interface{}{}
+					}
+					parts = append(parts, models.GeminiPart{
+						FunctionCall: &models.GeminiFunctionCall{Name: name, Args: args},
+					})
+
+				case constants.ContentTypeToolResult:
+					id, _ := blockMap["tool_use_id"].(string)
+					resultKey := "content"
+					if isError, _ := blockMap["is_error"].(bool); isError {
+						resultKey = "error"
+					}
+					parts = append(parts, models.GeminiPart{
+						FunctionResponse: &models.GeminiFunctionResponse{
+							Name:     toolNames[id],
+							Response: map[string]interface{}{resultKey: extractSystemText(blockMap["content"])},
+						},
+					})
+				}
+			}
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+		if thoughtSignature != "" {
+			attachThoughtSignature(parts, thoughtSignature)
+		}
+		contents = append(contents, models.GeminiContent{Role: role, Parts: parts})
+	}
+
+	return contents
+}
+
+// attachThoughtSignature 将思考签名附加到第一个 functionCall 部分，没有函数调用时附加到最后一个部分
+func attachThoughtSignature(parts []models.GeminiPart, thoughtSignature string) {
+	for i := range parts {
+		if parts[i].FunctionCall != nil {
+			parts[i].ThoughtSignature = thoughtSignature
+			return
+		}
+	}
+	parts[len(parts)-1].ThoughtSignature = thoughtSignature
+}
+
+// geminiToolConfig 将聊天完成的 tool_choice 转换为 Gemini 的 functionCallingConfig
+func geminiToolConfig(toolChoice interface{}) map[string]interface{} {
+	mode := ""
+	var allowed []interface{}
+
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case constants.ToolChoiceNone:
+			mode = "NONE"
+		case "required":
+			mode = "ANY"
+		case "auto":
+			mode = "AUTO"
+		}
+	case map[string]interface{}:
+		if function, ok := choice["function"].(map[string]interface{}); ok {
+			mode = "ANY"
+			allowed = append(allowed, function["name"])
+		}
+	}
+	if mode == "" {
+		return nil
+	}
+
+	functionCallingConfig := map[string]interface{}{"mode": mode}
+	if len(allowed) > 0 {
+		functionCallingConfig["allowedFunctionNames"] = allowed
+	}
+	return map[string]interface{}{"functionCallingConfig": functionCallingConfig}
+}
+
+// ConvertGeminiResponse 将 Gemini 响应转换为聊天完成响应，以便复用 ConvertResponse。
+// 思考部分转换为 reasoning.text，第一个 thoughtSignature 转换为 reasoning.encrypted（data 为编码后的思考块签名）。
+func ConvertGeminiResponse(geminiResp *models.GeminiResponse) (*models.OpenAIResponse, error) {
+	if len(geminiResp.Candidates) == 0 {
+		return nil, fmt.Errorf("Gemini 响应中没有候选结果")
+	}
+	candidate := geminiResp.Candidates[0]
+
+	message := models.OpenAIMessage{Role: constants.RoleAssistant}
+	var textParts []string
+	var thoughtSignature string
+
+	for i, part := range candidate.Content.Parts {
+		if part.ThoughtSignature != "" && thoughtSignature == "" {
+			thoughtSignature = part.ThoughtSignature
+		}
+
+		switch {
+		case part.FunctionCall != nil:
+			argsJSON, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil || part.FunctionCall.Args == nil {
+				argsJSON = []byte("{}")
+			}
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = GenerateToolID(i)
+			}
+			toolCall := models.OpenAIToolCall{ID: id, Type: constants.ToolTypeFunction}
+			toolCall.Function.Name = part.FunctionCall.Name
+			toolCall.Function.Arguments = string(argsJSON)
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+
+		case part.Thought:
+			if part.Text != "" {
+				message.ReasoningDetails = append(message.ReasoningDetails, map[string]interface{}{
+					"type": constants.ReasoningTypeText,
+					"text": part.Text,
+				})
+			}
+
+		default:
+			textParts = append(textParts, part.Text)
+		}
+	}
+	message.Content = strings.Join(textParts, "")
+
+	if thoughtSignature != "" {
+		message.ReasoningDetails = append(message.ReasoningDetails, map[string]interface{}{
+			"type": constants.ReasoningTypeEncrypted,
+			"data": EncodeGeminiSignature(thoughtSignature),
+		})
+	}
+
+	finishReason := GeminiFinishReason(candidate.FinishReason, len(message.ToolCalls) > 0)
+
+	openaiResp := &models.OpenAIResponse{
+		ID:     geminiResp.ResponseID,
+		Object: "chat.completion",
+		Model:  geminiResp.ModelVersion,
+		Choices: []models.OpenAIChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: &finishReason,
+		}},
+	}
+	if usage := geminiResp.UsageMetadata; usage != nil {
+		openaiResp.Usage = models.OpenAIUsage{
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+		}
+	}
+	return openaiResp, nil
+}
+
+// GeminiFinishReason 将 Gemini 的 finishReason 映射为聊天完成的完成原因。
+// Gemini 在函数调用时同样返回 STOP，因此需要根据是否有函数调用判断。
+func GeminiFinishReason(finishReason string, hasToolCalls bool) string {
+	switch finishReason {
+	case "MAX_TOKENS":
+		return constants.FinishReasonLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return constants.FinishReasonContentFilter
+	}
+	if hasToolCalls {
+		return constants.FinishReasonToolCalls
+	}
+	return constants.FinishReasonStop
+}
@@ -0,0 +1,195 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+func TestConvertToGeminiRequest(t *testing.T) {
+	schema := map[string]interface{}{"type": "object", "properties": map[string]interface{}{"path": map[string]interface{}{"type": "string"}}}
+	tool := models.OpenAITool{Type: "function"}
+	tool.Function.Name = "read"
+	tool.Function.Description = "读取文件"
+	tool.Function.Parameters = schema
+
+	openaiReq := &models.OpenAIRequest{
+		Model:     "gemini-2.5-pro",
+		MaxTokens: 4096,
+		Stop:      []string{"END"},
+		Messages: []models.OpenAIMessage{
+			{Role: "system", Content: "你是助手"},
+			{Role: "user", Content: "读取 a.txt"},
+		},
+		Tools:      []models.OpenAITool{tool},
+		ToolChoice: "required",
+		Original: &models.ClaudeRequest{
+			Thinking: &models.ThinkingConfig{Type: "enabled", BudgetTokens: 2048},
+			Messages: []models.ClaudeMessage{
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "text", "text": "读取 a.txt"},
+					map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "aW1n"}},
+					map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "url", "url": "https://example.com/a.png"}},
+				}},
+				{Role: "assistant", Content: []interface{}{
+					map[string]interface{}{"type": "thinking", "thinking": "需要读取", "signature": "gemini:U0lH"},
+					map[string]interface{}{"type": "text", "text": "好的"},
+					map[string]interface{}{"type": "tool_use", "id": "call_1", "name": "read", "input": map[string]interface{}{"path": "a.txt"}},
+				}},
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "call_1", "content": "内容"},
+				}},
+				{Role: "assistant", Content: []interface{}{
+					map[string]interface{}{"type": "thinking", "thinking": "", "signature": "gemini:VEFJTA=="},
+					map[string]interface{}{"type": "text", "text": "文件内容是“内容”"},
+				}},
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "call_2", "is_error": true, "content": "失败"},
+				}},
+			},
+		},
+	}
+
+	got, err := ConvertToGeminiRequest(openaiReq)
+	if err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+
+	wantContents := []models.GeminiContent{
+		{Role: "user", Parts: []models.GeminiPart{
+			{Text: "读取 a.txt"},
+			{InlineData: &models.GeminiInlineData{MimeType: "image/png", Data: "aW1n"}},
+		}},
+		{Role: "model", Parts: []models.GeminiPart{
+			{Text: "好的"},
+			{FunctionCall: &models.GeminiFunctionCall{Name: "read", Args: map[string]interface{}{"path": "a.txt"}}, ThoughtSignature: "U0lH"},
+		}},
+		{Role: "user", Parts: []models.GeminiPart{
+			{FunctionResponse: &models.GeminiFunctionResponse{Name: "read", Response: map[string]interface{}{"content": "内容"}}},
+		}},
+		{Role: "model", Parts: []models.GeminiPart{
+			{Text: "文件内容是“内容”", ThoughtSignature: "VEFJTA=="},
+		}},
+		{Role: "user", Parts: []models.GeminiPart{
+			{FunctionResponse: &models.GeminiFunctionResponse{Name: "", Response: map[string]interface{}{"error": "失败"}}},
+		}},
+	}
+	if !reflect.DeepEqual(got.Contents, wantContents) {
+		t.Errorf("contents = %#v\n应为 %#v", got.Contents, wantContents)
+	}
+
+	if got.SystemInstruction == nil || got.SystemInstruction.Parts[0].Text != "你是助手" {
+		t.Errorf("systemInstruction = %#v", got.SystemInstruction)
+	}
+	wantTools := []models.GeminiTool{{FunctionDeclarations: []models.GeminiFunctionDeclaration{
+		{Name: "read", Description: "读取文件", ParametersJSONSchema: schema},
+	}}}
+	if !reflect.DeepEqual(got.Tools, wantTools) {
+		t.Errorf("tools = %#v", got.Tools)
+	}
+	wantToolConfig := map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "ANY"}}
+	if !reflect.DeepEqual(got.ToolConfig, wantToolConfig) {
+		t.Errorf("toolConfig = %#v", got.ToolConfig)
+	}
+
+	gen := got.GenerationConfig
+	if gen.MaxOutputTokens != 4096 || !reflect.DeepEqual(gen.StopSequences, []string{"END"}) {
+		t.Errorf("generationConfig = %#v", gen)
+	}
+	if gen.ThinkingConfig == nil || !gen.ThinkingConfig.IncludeThoughts ||
+		gen.ThinkingConfig.ThinkingBudget == nil || *gen.ThinkingConfig.ThinkingBudget != 2048 {
+		t.Errorf("thinkingConfig = %#v", gen.ThinkingConfig)
+	}
+}
+
+func TestConvertToGeminiRequestRequiresOriginal(t *testing.T) {
+	if _, err := ConvertToGeminiRequest(&models.OpenAIRequest{Model: "gemini-2.5-pro"}); err == nil {
+		t.Errorf("没有原始 Claude 请求时应返回错误")
+	}
+}
+
+func TestGeminiToolConfig(t *testing.T) {
+	tests := []struct {
+		choice interface{}
+		want   map[string]interface{}
+	}{
+		{nil, nil},
+		{"auto", map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "AUTO"}}},
+		{"none", map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "NONE"}}},
+		{
+			map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "read"}},
+			map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "ANY", "allowedFunctionNames": []interface{}{"read"}}},
+		},
+	}
+	for _, tt := range tests {
+		if got := geminiToolConfig(tt.choice); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("geminiToolConfig(%v) = %#v，应为 %#v", tt.choice, got, tt.want)
+		}
+	}
+}
+
+func TestConvertGeminiResponse(t *testing.T) {
+	resp := &models.GeminiResponse{
+		ResponseID:   "resp_1",
+		ModelVersion: "gemini-2.5-pro",
+		Candidates: []models.GeminiCandidate{{
+			FinishReason: "STOP",
+			Content: models.GeminiContent{Role: "model", Parts: []models.GeminiPart{
+				{Text: "想一想", Thought: true},
+				{Text: "我来读取。"},
+				{FunctionCall: &models.GeminiFunctionCall{Name: "read", Args: map[string]interface{}{"path": "a.txt"}}, ThoughtSignature: "U0lH"},
+			}},
+		}},
+		UsageMetadata: &models.GeminiUsage{PromptTokenCount: 10, CandidatesTokenCount: 5, ThoughtsTokenCount: 3, TotalTokenCount: 18},
+	}
+
+	got, err := ConvertGeminiResponse(resp)
+	if err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+	msg := got.Choices[0].Message
+	if msg.Content != "我来读取。" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Name != "read" || msg.ToolCalls[0].Function.Arguments != `{"path":"a.txt"}` {
+		t.Errorf("tool_calls = %#v", msg.ToolCalls)
+	}
+	wantDetails := []map[string]interface{}{
+		{"type": constants.ReasoningTypeText, "text": "想一想"},
+		{"type": constants.ReasoningTypeEncrypted, "data": "gemini:U0lH"},
+	}
+	if len(msg.ReasoningDetails) != len(wantDetails) {
+		t.Fatalf("reasoning_details = %#v", msg.ReasoningDetails)
+	}
+	for i, want := range wantDetails {
+		if !reflect.DeepEqual(msg.ReasoningDetails[i], want) {
+			t.Errorf("reasoning_details[%d] = %#v，应为 %#v", i, msg.ReasoningDetails[i], want)
+		}
+	}
+	if *got.Choices[0].FinishReason != constants.FinishReasonToolCalls {
+		t.Errorf("finish_reason = %q，函数调用时应为 tool_calls", *got.Choices[0].FinishReason)
+	}
+	if got.Usage.CompletionTokens != 8 {
+		t.Errorf("completion_tokens = %d，应包括思考令牌", got.Usage.CompletionTokens)
+	}
+}
+
+func TestGeminiSignatureRoundTrip(t *testing.T) {
+	signature := EncodeGeminiSignature("U0lH")
+	if signature != "gemini:U0lH" {
+		t.Fatalf("签名 = %q", signature)
+	}
+	if sig, ok := decodeGeminiSignature(signature); !ok || sig != "U0lH" {
+		t.Errorf("解码 = %q, %v", sig, ok)
+	}
+	for _, other := range []string{"", "gemini:", "rs_1:ZW5j", "U0lH"} {
+		if _, ok := decodeGeminiSignature(other); ok {
+			t.Errorf("%q 不应解码为 Gemini 签名", other)
+		}
+	}
+	if !isRoundTripSignature(signature) {
+		t.Errorf("Gemini 签名应保存在思考块中")
+	}
+}
@@ -0,0 +1,248 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/tokenizer"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+const (
+	// ollamaMinNumCtx 自动调整时的最小上下文窗口
+	ollamaMinNumCtx = 8192
+	// ollamaDefaultMaxNumCtx 自动调整时默认的上下文窗口上限
+	ollamaDefaultMaxNumCtx = 32768
+	// ollamaOutputReserve 自动调整时为输出预留的最大令牌数。
+	// Claude Code 的 max_tokens 通常很大，按其全额预留会使上下文窗口总是达到上限。
+	ollamaOutputReserve = 8192
+)
+
+// ConvertToOllamaRequest 将已转换的聊天完成请求改写为 Ollama 原生 /api/chat 请求。
+// 模型映射和令牌限制沿用 ConvertRequest 的结果；对话内容由原始 Claude 消息直接转换，以保留图片和思考内容。
+// num_ctx 未固定时按请求估算的令牌数自动调整（向上取 2 的幂，减少 Ollama 因上下文大小变化而重新加载模型）。
+func ConvertToOllamaRequest(openaiReq *models.OpenAIRequest, settings config.OllamaSettings) (*models.OllamaChatRequest, error) {
+	if openaiReq.Original == nil {
+		return nil, fmt.Errorf("Ollama 原生协议需要原始 Claude 请求")
+	}
+	claudeReq := openaiReq.Original
+
+	maxTokens := openaiReq.MaxCompletionTokens
+	if maxTokens == 0 {
+		maxTokens = openaiReq.MaxTokens
+	}
+
+	ollamaReq := &models.OllamaChatRequest{
+		Model:  openaiReq.Model,
+		Tools:  openaiReq.Tools,
+		Stream: openaiReq.Stream != nil && *openaiReq.Stream,
+		Options: map[string]interface{}{
+			"num_ctx": OllamaNumCtx(tokenizer.EstimateOpenAIRequest(openaiReq), maxTokens, settings),
+		},
+		KeepAlive: ollamaKeepAlive(settings.KeepAlive),
+	}
+	if maxTokens > 0 {
+		ollamaReq.Options["num_predict"] = maxTokens
+	}
+	if openaiReq.Temperature != nil {
+		ollamaReq.Options["temperature"] = *openaiReq.Temperature
+	}
+	if openaiReq.TopP != nil {
+		ollamaReq.Options["top_p"] = *openaiReq.TopP
+	}
+	if len(openaiReq.Stop) > 0 {
+		ollamaReq.Options["stop"] = openaiReq.Stop
+	}
+
+	// Claude 的扩展思考映射到 think 参数，思考模型的推理内容在 thinking 字段中返回
+	if claudeReq.Thinking != nil && claudeReq.Thinking.Type == "enabled" {
+		think := true
+		ollamaReq.Think = &think
+	}
+
+	// 系统消息（包括注入的工具参数说明）保持为 system 角色
+	for _, msg := range openaiReq.Messages {
+		if msg.Role == constants.RoleSystem {
+			if text, ok := msg.Content.(string); ok && text != "" {
+				ollamaReq.Messages = append(ollamaReq.Messages, models.OllamaMessage{Role: constants.RoleSystem, Content: text})
+			}
+		}
+	}
+	ollamaReq.Messages = append(ollamaReq.Messages, convertMessagesToOllama(claudeReq.Messages)...)
+
+	return ollamaReq, nil
+}
+
+// convertMessagesToOllama 将 Claude 消息转换为 Ollama 消息。
+//
+// 转换规则：
+//   - text 块合并为 content，base64 图片放入 images（URL 图片无法传递）
+//   - assistant 的 thinking 块放入 thinking 字段，tool_use 块转换为 tool_calls
+//   - user 的 tool_result 块转换为独立的 tool 消息（按 ID 查找函数名称），排在同一条消息的文本之前
+func convertMessagesToOllama(messages []models.ClaudeMessage) []models.OllamaMessage {
+	var result []models.OllamaMessage
+	toolNames := make(map[string]string) // tool_use ID -> 函数名称
+
+	for _, msg := range messages {
+		ollamaMsg := models.OllamaMessage{Role: msg.Role}
+		var textParts, thinkingParts []string
+
+		switch content := msg.Content.(type) {
+		case string:
+			textParts = append(textParts, content)
+
+		case []interface{}:
+			for _, block := range content {
+				blockMap, ok := block.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				switch blockMap["type"] {
+				case constants.ContentTypeText:
+					if text, _ := blockMap["text"].(string); text != "" {
+						textParts = append(textParts, text)
+					}
+
+				case constants.ContentTypeImage:
+					source, _ := blockMap["source"].(map[string]interface{})
+					if source["type"] == "base64" {
+						if data, _ := source["data"].(string); data != "" {
+							ollamaMsg.Images = append(ollamaMsg.Images, data)
+						}
+					}
+
+				case constants.ContentTypeThinking:
+					if thinking, _ := blockMap["thinking"].(string); thinking != "" {
+						thinkingParts = append(thinkingParts, thinking)
+					}
+
+				case constants.ContentTypeToolUse:
+					id, _ := blockMap["id"].(string)
+					name, _ := blockMap["name"].(string)
+					toolNames[id] = name
+					args, _ := blockMap["input"].(map[string]interface{})
+					if args == nil {
+						args = map[string]interface{}{}
+					}
+					ollamaMsg.ToolCalls = append(ollamaMsg.ToolCalls, models.OllamaToolCall{
+						Function: models.OllamaFunctionCall{Name: name, Arguments: args},
+					})
+
+				case constants.ContentTypeToolResult:
+					id, _ := blockMap["tool_use_id"].(string)
+					result = append(result, models.OllamaMessage{
+						Role:     constants.RoleTool,
+						Content:  extractSystemText(blockMap["content"]),
+						ToolName: toolNames[id],
+					})
+				}
+			}
+		}
+
+		ollamaMsg.Content = strings.Join(textParts, "\n")
+		ollamaMsg.Thinking = strings.Join(thinkingParts, "\n")
+		if ollamaMsg.Content == "" && len(ollamaMsg.Images) == 0 && len(ollamaMsg.ToolCalls) == 0 {
+			continue
+		}
+		result = append(result, ollamaMsg)
+	}
+
+	return result
+}
+
+// OllamaNumCtx 返回请求使用的上下文窗口大小。
+// 配置了固定值时直接使用；否则为估算的输入令牌数加上输出预留，向上取 2 的幂并限制在上限以内。
+func OllamaNumCtx(estimatedInputTokens, maxTokens int, settings config.OllamaSettings) int {
+	if settings.NumCtx > 0 {
+		return settings.NumCtx
+	}
+	maxNumCtx := settings.MaxNumCtx
+	if maxNumCtx <= 0 {
+		maxNumCtx = ollamaDefaultMaxNumCtx
+	}
+
+	needed := estimatedInputTokens + min(maxTokens, ollamaOutputReserve)
+	numCtx := ollamaMinNumCtx
+	for numCtx < needed && numCtx < maxNumCtx {
+		numCtx *= 2
+	}
+	return min(numCtx, maxNumCtx)
+}
+
+// ollamaKeepAlive 转换 keep_alive 设置：纯数字按秒数发送，其他值作为时长字符串发送
+func ollamaKeepAlive(keepAlive string) interface{} {
+	if keepAlive == "" {
+		return nil
+	}
+	if seconds, err := strconv.Atoi(keepAlive); err == nil {
+		return seconds
+	}
+	return keepAlive
+}
+
+// ConvertOllamaResponse 将 Ollama 响应转换为聊天完成响应，以便复用 ConvertResponse。
+// thinking 字段转换为 reasoning.text，令牌计数取自 prompt_eval_count 和 eval_count。
+func ConvertOllamaResponse(ollamaResp *models.OllamaChatResponse) *models.OpenAIResponse {
+	message := models.OpenAIMessage{
+		Role:    constants.RoleAssistant,
+		Content: ollamaResp.Message.Content,
+	}
+	if ollamaResp.Message.Thinking != "" {
+		message.ReasoningDetails = []interface{}{map[string]interface{}{
+			"type": constants.ReasoningTypeText,
+			"text": ollamaResp.Message.Thinking,
+		}}
+	}
+	for i, tc := range ollamaResp.Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, ollamaToolCall(tc, GenerateToolID(i)))
+	}
+
+	finishReason := OllamaFinishReason(ollamaResp.DoneReason, len(message.ToolCalls) > 0)
+
+	// Ollama 响应没有 ID，与流式响应一样按时间生成
+	return &models.OpenAIResponse{
+		ID:     fmt.Sprintf("msg_%d", time.Now().UnixNano()),
+		Object: "chat.completion",
+		Model:  ollamaResp.Model,
+		Choices: []models.OpenAIChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: &finishReason,
+		}},
+		Usage: models.OpenAIUsage{
+			PromptTokens:     ollamaResp.PromptEvalCount,
+			CompletionTokens: ollamaResp.EvalCount,
+			TotalTokens:      ollamaResp.PromptEvalCount + ollamaResp.EvalCount,
+		},
+	}
+}
+
+// ollamaToolCall 将 Ollama 工具调用转换为聊天完成格式（参数序列化为 JSON 字符串）
+func ollamaToolCall(tc models.OllamaToolCall, id string) models.OpenAIToolCall {
+	argsJSON, err := json.Marshal(tc.Function.Arguments)
+	if err != nil || tc.Function.Arguments == nil {
+		argsJSON = []byte("{}")
+	}
+	toolCall := models.OpenAIToolCall{ID: id, Type: constants.ToolTypeFunction}
+	toolCall.Function.Name = tc.Function.Name
+	toolCall.Function.Arguments = string(argsJSON)
+	return toolCall
+}
+
+// OllamaFinishReason 将 Ollama 的 done_reason 映射为聊天完成的完成原因。
+// Ollama 在工具调用时同样返回 stop，因此需要根据是否有工具调用判断。
+func OllamaFinishReason(doneReason string, hasToolCalls bool) string {
+	if doneReason == "length" {
+		return constants.FinishReasonLength
+	}
+	if hasToolCalls {
+		return constants.FinishReasonToolCalls
+	}
+	return constants.FinishReasonStop
+}
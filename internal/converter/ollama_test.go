@@ -0,0 +1,161 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+func TestOllamaNumCtx(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     int
+		maxTokens int
+		settings  config.OllamaSettings
+		want      int
+	}{
+		{"小请求使用最小值", 100, 1000, config.OllamaSettings{}, 8192},
+		{"恰好等于最小值", 8192 - 1000, 1000, config.OllamaSettings{}, 8192},
+		{"超过最小值时翻倍", 8192 - 1000 + 1, 1000, config.OllamaSettings{}, 16384},
+		{"输出预留最多 8192", 5000, 32000, config.OllamaSettings{}, 16384},
+		{"没有 max_tokens", 9000, 0, config.OllamaSettings{}, 16384},
+		{"限制在默认上限以内", 100000, 32000, config.OllamaSettings{}, 32768},
+		{"自定义上限", 40000, 32000, config.OllamaSettings{MaxNumCtx: 131072}, 65536},
+		{"上限不是 2 的幂", 40000, 32000, config.OllamaSettings{MaxNumCtx: 40000}, 40000},
+		{"上限小于最小值", 100, 1000, config.OllamaSettings{MaxNumCtx: 4096}, 4096},
+		{"固定值优先", 100000, 32000, config.OllamaSettings{NumCtx: 4096, MaxNumCtx: 131072}, 4096},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OllamaNumCtx(tt.input, tt.maxTokens, tt.settings); got != tt.want {
+				t.Errorf("OllamaNumCtx(%d, %d, %+v) = %d，应为 %d", tt.input, tt.maxTokens, tt.settings, got, tt.want)
+			}
+		})
+	}
+}
+
+// ollamaTestRequest 返回只有一条用户消息的已转换请求（ASCII 文本约 4 个字符一个令牌）
+func ollamaTestRequest(prompt string, maxTokens int) *models.OpenAIRequest {
+	return &models.OpenAIRequest{
+		Model:     "qwen3:8b",
+		MaxTokens: maxTokens,
+		Messages:  []models.OpenAIMessage{{Role: "user", Content: prompt}},
+		Original: &models.ClaudeRequest{
+			Messages: []models.ClaudeMessage{{Role: "user", Content: prompt}},
+		},
+	}
+}
+
+func TestConvertToOllamaRequestNumCtxFromPromptSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompt   string
+		settings config.OllamaSettings
+		want     int
+	}{
+		{"短提示", "hi", config.OllamaSettings{}, 8192},
+		{"约 10000 令牌", strings.Repeat("abcd", 10000), config.OllamaSettings{}, 16384},
+		{"约 25000 令牌", strings.Repeat("abcd", 25000), config.OllamaSettings{}, 32768},
+		{"约 40000 令牌且上限更高", strings.Repeat("abcd", 40000), config.OllamaSettings{MaxNumCtx: 65536}, 65536},
+		{"固定 num_ctx", strings.Repeat("abcd", 25000), config.OllamaSettings{NumCtx: 12000}, 12000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertToOllamaRequest(ollamaTestRequest(tt.prompt, 1000), tt.settings)
+			if err != nil {
+				t.Fatalf("转换失败: %v", err)
+			}
+			if got.Options["num_ctx"] != tt.want {
+				t.Errorf("num_ctx = %v，应为 %d", got.Options["num_ctx"], tt.want)
+			}
+			if got.Options["num_predict"] != 1000 {
+				t.Errorf("num_predict = %v", got.Options["num_predict"])
+			}
+		})
+	}
+}
+
+func TestConvertToOllamaRequest(t *testing.T) {
+	temperature := 0.2
+	stream := true
+	tool := models.OpenAITool{Type: "function"}
+	tool.Function.Name = "read"
+
+	openaiReq := &models.OpenAIRequest{
+		Model:       "qwen3:8b",
+		MaxTokens:   1000,
+		Temperature: &temperature,
+		Stream:      &stream,
+		Messages:    []models.OpenAIMessage{{Role: "system", Content: "你是助手"}},
+		Tools:       []models.OpenAITool{tool},
+		Original: &models.ClaudeRequest{
+			Thinking: &models.ThinkingConfig{Type: "enabled", BudgetTokens: 1024},
+			Messages: []models.ClaudeMessage{
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "text", "text": "看看这张图"},
+					map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "aW1n"}},
+				}},
+				{Role: "assistant", Content: []interface{}{
+					map[string]interface{}{"type": "thinking", "thinking": "需要读取"},
+					map[string]interface{}{"type": "tool_use", "id": "call_1", "name": "read", "input": map[string]interface{}{"path": "a.txt"}},
+				}},
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "call_1", "content": "内容"},
+					map[string]interface{}{"type": "text", "text": "继续"},
+				}},
+			},
+		},
+	}
+
+	got, err := ConvertToOllamaRequest(openaiReq, config.OllamaSettings{KeepAlive: "300"})
+	if err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+	if !got.Stream || got.Think == nil || !*got.Think || got.KeepAlive != 300 {
+		t.Errorf("stream = %v, think = %v, keep_alive = %#v", got.Stream, got.Think, got.KeepAlive)
+	}
+	if got.Options["temperature"] != 0.2 || got.Options["num_predict"] != 1000 {
+		t.Errorf("options = %v", got.Options)
+	}
+	wantMessages := []models.OllamaMessage{
+		{Role: "system", Content: "你是助手"},
+		{Role: "user", Content: "看看这张图", Images: []string{"aW1n"}},
+		{Role: "assistant", Thinking: "需要读取", ToolCalls: []models.OllamaToolCall{
+			{Function: models.OllamaFunctionCall{Name: "read", Arguments: map[string]interface{}{"path": "a.txt"}}},
+		}},
+		{Role: "tool", Content: "内容", ToolName: "read"},
+		{Role: "user", Content: "继续"},
+	}
+	if !reflect.DeepEqual(got.Messages, wantMessages) {
+		t.Errorf("messages = %#v\n应为 %#v", got.Messages, wantMessages)
+	}
+}
+
+func TestSetUpstreamStreamingKeepsToolChoice(t *testing.T) {
+	// 通过 OpenAI 兼容层调用 Ollama 时不强制 tool_choice: required（会让模型反复调用工具）
+	cfg := &config.Config{Backends: map[string]*config.Backend{
+		config.DefaultBackendName: {Name: config.DefaultBackendName, BaseURL: "http://localhost:11434/v1", Provider: config.ProviderOllama},
+	}}
+	tool := models.OpenAITool{Type: "function"}
+	tool.Function.Name = "read"
+
+	tests := []struct {
+		name       string
+		toolChoice interface{}
+	}{
+		{"客户端未指定", nil},
+		{"客户端指定 auto", "auto"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &models.OpenAIRequest{Model: "qwen3", Tools: []models.OpenAITool{tool}, ToolChoice: tt.toolChoice}
+			SetUpstreamStreaming(req, true, cfg)
+			if req.ToolChoice != tt.toolChoice {
+				t.Errorf("tool_choice = %#v，应保持为 %#v", req.ToolChoice, tt.toolChoice)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// knownMaxOutputTokens 是已知模型的最大输出令牌数，按模型名称前缀匹配（忽略大小写和 供应商/ 前缀，使用最长的匹配前缀）。
+// Claude Code 默认请求 32000 个输出令牌，超过模型上限时许多后端直接返回 400。
+var knownMaxOutputTokens = map[string]int{
+	// OpenAI
+	"gpt-3.5-turbo": 4096,
+	"gpt-4-turbo":   4096,
+	"gpt-4o":        16384,
+	"gpt-4.1":       32768,
+	"gpt-5":         128000,
+	"o1":            100000,
+	"o1-mini":       65536,
+	"o3":            100000,
+	"o4-mini":       100000,
+	// Google
+	"gemini-1.5": 8192,
+	"gemini-2.0": 8192,
+	"gemini-2.5": 65536,
+	"gemini-3":   65536,
+	// Anthropic
+	"claude-3-5-haiku":  8192,
+	"claude-3-5-sonnet": 8192,
+	"claude-3-7-sonnet": 64000,
+	"claude-haiku-4":    64000,
+	"claude-sonnet-4":   64000,
+	"claude-opus-4":     32000,
+	"claude-opus-4-5":   64000,
+	// DeepSeek
+	"deepseek-chat":     8192,
+	"deepseek-coder":    8192,
+	"deepseek-reasoner": 65536,
+	// Qwen
+	"qwen-max":    8192,
+	"qwen-plus":   32768,
+	"qwen3-coder": 65536,
+}
+
+// MaxOutputTokens 返回已知模型的最大输出令牌数
+func MaxOutputTokens(model string) (int, bool) {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	best, limit := "", 0
+	for prefix, tokens := range knownMaxOutputTokens {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(best) {
+			best, limit = prefix, tokens
+		}
+	}
+	return limit, best != ""
+}
+
+// ApplyParamPolicy 对转换后的上游请求应用路由规则的参数策略，返回可读的变化列表（用于调试日志）。
+// 已知参数（temperature、max_tokens、reasoning_effort 等）直接修改请求，对所有上游协议生效；
+// 其他字段和 extra_body 记录在请求中，序列化时合并到请求体（见 MergeRequestBody）。
+func ApplyParamPolicy(req *models.OpenAIRequest, policy config.ParamPolicy) []string {
+	if policy.IsZero() {
+		return nil
+	}
+
+	var changes []string
+	for _, field := range policy.Drop {
+		if _, ok := RequestField(req, field); ok {
+			changes = append(changes, "删除 "+field)
+		}
+		setRequestField(req, field, nil)
+		req.Drop = append(req.Drop, field)
+	}
+	for _, field := range sortedFields(policy.Default) {
+		if _, ok := RequestField(req, field); !ok {
+			setRequestField(req, field, policy.Default[field])
+			changes = append(changes, fmt.Sprintf("%s: （未设置）→ %v", field, policy.Default[field]))
+		}
+	}
+	for _, field := range sortedFields(policy.Set) {
+		old, _ := RequestField(req, field)
+		setRequestField(req, field, policy.Set[field])
+		changes = append(changes, fmt.Sprintf("%s: %v → %v", field, describeField(old), policy.Set[field]))
+	}
+
+	if limit, reason := maxTokensLimit(req.Model, policy.Clamp); limit > 0 {
+		if req.MaxCompletionTokens > limit {
+			changes = append(changes, fmt.Sprintf("max_completion_tokens: %d → %d（%s）", req.MaxCompletionTokens, limit, reason))
+			req.MaxCompletionTokens = limit
+		}
+		if req.MaxTokens > limit {
+			changes = append(changes, fmt.Sprintf("max_tokens: %d → %d（%s）", req.MaxTokens, limit, reason))
+			req.MaxTokens = limit
+		}
+	}
+	if policy.Clamp.Temperature != nil && req.Temperature != nil && *req.Temperature > *policy.Clamp.Temperature {
+		changes = append(changes, fmt.Sprintf("temperature: %v → %v（上限）", *req.Temperature, *policy.Clamp.Temperature))
+		limit := *policy.Clamp.Temperature
+		req.Temperature = &limit
+	}
+	if policy.Clamp.TopP != nil && req.TopP != nil && *req.TopP > *policy.Clamp.TopP {
+		changes = append(changes, fmt.Sprintf("top_p: %v → %v（上限）", *req.TopP, *policy.Clamp.TopP))
+		limit := *policy.Clamp.TopP
+		req.TopP = &limit
+	}
+
+	if len(policy.ExtraBody) > 0 {
+		if req.Extra == nil {
+			req.Extra = make(map[string]interface{}, len(policy.ExtraBody))
+		}
+		mergeJSONObject(req.Extra, policy.ExtraBody)
+		for _, field := range sortedFields(policy.ExtraBody) {
+			changes = append(changes, fmt.Sprintf("合并 extra_body.%s", field))
+		}
+	}
+	return changes
+}
+
+// ApplyBodyPolicy 对 JSON 请求体（Anthropic 原生协议）应用参数策略，字段名称与请求体的顶层键对应。
+// model 为上游模型名称，用于按已知模型限制 max_tokens。
+func ApplyBodyPolicy(body map[string]interface{}, policy config.ParamPolicy, model string) {
+	for _, field := range policy.Drop {
+		delete(body, field)
+	}
+	for field, value := range policy.Default {
+		if _, ok := body[field]; !ok {
+			body[field] = value
+		}
+	}
+	for field, value := range policy.Set {
+		body[field] = value
+	}
+
+	if limit, _ := maxTokensLimit(model, policy.Clamp); limit > 0 {
+		if n, ok := jsonNumber(body["max_tokens"]); ok && n > float64(limit) {
+			body["max_tokens"] = limit
+		}
+	}
+	if policy.Clamp.Temperature != nil {
+		if t, ok := jsonNumber(body["temperature"]); ok && t > *policy.Clamp.Temperature {
+			body["temperature"] = *policy.Clamp.Temperature
+		}
+	}
+	if policy.Clamp.TopP != nil {
+		if p, ok := jsonNumber(body["top_p"]); ok && p > *policy.Clamp.TopP {
+			body["top_p"] = *policy.Clamp.TopP
+		}
+	}
+
+	mergeJSONObject(body, policy.ExtraBody)
+}
+
+// MergeRequestBody 在序列化的上游请求体中删除策略删除的顶层字段并合并额外字段；没有时原样返回。
+// 在序列化时处理可以覆盖转换之后才添加的字段（如流式聚合时添加的 stream_options）。
+func MergeRequestBody(body []byte, req *models.OpenAIRequest) ([]byte, error) {
+	if len(req.Extra) == 0 && len(req.Drop) == 0 {
+		return body, nil
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil, err
+	}
+	for _, field := range req.Drop {
+		delete(object, field)
+		if field == "max_tokens" {
+			delete(object, "max_completion_tokens")
+		}
+	}
+	mergeJSONObject(object, req.Extra)
+	return json.Marshal(object)
+}
+
+// jsonNumber 返回请求体中的数值（解析的 JSON 为 float64，路由参数覆盖的值可能是 int）
+func jsonNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// maxTokensLimit 返回 max_tokens 的上限和说明，没有限制时返回 0
+func maxTokensLimit(model string, clamp config.ParamClamp) (int, string) {
+	if clamp.MaxTokensOff {
+		return 0, ""
+	}
+	if clamp.MaxTokens > 0 {
+		return clamp.MaxTokens, "上限"
+	}
+	if clamp.MaxTokensAuto {
+		if limit, ok := MaxOutputTokens(model); ok {
+			return limit, "模型最大输出"
+		}
+	}
+	return 0, ""
+}
+
+// RequestField 返回上游请求中字段的当前值，未设置时第二个返回值为 false
+func RequestField(req *models.OpenAIRequest, field string) (interface{}, bool) {
+	switch field {
+	case "temperature":
+		if req.Temperature != nil {
+			return *req.Temperature, true
+		}
+	case "top_p":
+		if req.TopP != nil {
+			return *req.TopP, true
+		}
+	case "max_tokens":
+		if req.MaxCompletionTokens > 0 {
+			return req.MaxCompletionTokens, true
+		}
+		if req.MaxTokens > 0 {
+			return req.MaxTokens, true
+		}
+	case "reasoning_effort":
+		if req.ReasoningEffort != "" {
+			return req.ReasoningEffort, true
+		}
+	case "stop":
+		if len(req.Stop) > 0 {
+			return req.Stop, true
+		}
+	case "tool_choice":
+		if req.ToolChoice != nil {
+			return req.ToolChoice, true
+		}
+	case "stream_options":
+		if req.StreamOptions != nil {
+			return req.StreamOptions, true
+		}
+	case "reasoning":
+		if req.Reasoning != nil {
+			return req.Reasoning, true
+		}
+	case "usage":
+		if req.Usage != nil {
+			return req.Usage, true
+		}
+	default:
+		value, ok := req.Extra[field]
+		return value, ok
+	}
+	return nil, false
+}
+
+// setRequestField 设置上游请求中的字段，value 为 nil 时删除字段。
+// 已知参数的值已在加载配置时转换为对应类型（见 config.ParamPolicy）。
+func setRequestField(req *models.OpenAIRequest, field string, value interface{}) {
+	switch field {
+	case "temperature", "top_p":
+		var number *float64
+		if v, ok := value.(float64); ok {
+			number = &v
+		}
+		if field == "temperature" {
+			req.Temperature = number
+		} else {
+			req.TopP = number
+		}
+	case "max_tokens":
+		n, _ := value.(int)
+		if req.MaxCompletionTokens > 0 && n > 0 {
+			req.MaxCompletionTokens = n
+		} else {
+			req.MaxCompletionTokens, req.MaxTokens = 0, n
+		}
+	case "reasoning_effort":
+		req.ReasoningEffort, _ = value.(string)
+	case "stop":
+		req.Stop, _ = value.([]string)
+	case "tool_choice":
+		req.ToolChoice = value
+	case "stream_options", "reasoning", "usage":
+		// 复制配置中的映射，避免请求处理修改共享的配置
+		var object map[string]interface{}
+		if v, ok := value.(map[string]interface{}); ok {
+			object = make(map[string]interface{}, len(v))
+			mergeJSONObject(object, v)
+		}
+		switch field {
+		case "stream_options":
+			req.StreamOptions = object
+		case "reasoning":
+			req.Reasoning = object
+		default:
+			req.Usage = object
+		}
+	default:
+		if value == nil {
+			delete(req.Extra, field)
+			return
+		}
+		if req.Extra == nil {
+			req.Extra = make(map[string]interface{})
+		}
+		req.Extra[field] = value
+	}
+}
+
+// DropRequestField 删除上游请求中的字段，序列化时也从请求体中删除（用于删除上游不支持的参数后重试）。
+// 请求可能是浅拷贝，Extra 和 Drop 重新分配，不修改原请求共享的映射和切片。
+func DropRequestField(req *models.OpenAIRequest, field string) {
+	if _, ok := req.Extra[field]; ok {
+		extra := make(map[string]interface{}, len(req.Extra))
+		for k, v := range req.Extra {
+			if k != field {
+				extra[k] = v
+			}
+		}
+		req.Extra = extra
+	}
+	setRequestField(req, field, nil)
+	req.Drop = append(append([]string(nil), req.Drop...), field)
+}
+
+// describeField 返回字段值的可读形式，未设置时为（未设置）
+func describeField(value interface{}) interface{} {
+	if value == nil {
+		return "（未设置）"
+	}
+	return value
+}
+
+// sortedFields 返回按名称排序的字段名称，使变化列表的顺序固定
+func sortedFields(fields map[string]interface{}) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeJSONObject 将 src 深度合并到 dst：两边都是对象的键递归合并，其他值（包括列表）由 src 替换
+func mergeJSONObject(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcObject, srcIsObject := value.(map[string]interface{})
+		dstObject, dstIsObject := dst[key].(map[string]interface{})
+		if srcIsObject && dstIsObject {
+			merged := make(map[string]interface{}, len(dstObject)+len(srcObject))
+			for k, v := range dstObject {
+				merged[k] = v
+			}
+			mergeJSONObject(merged, srcObject)
+			dst[key] = merged
+			continue
+		}
+		if srcIsObject {
+			copied := make(map[string]interface{}, len(srcObject))
+			mergeJSONObject(copied, srcObject)
+			value = copied
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+func TestApplyParamPolicyClampsMaxTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  string
+		policy config.ParamPolicy
+		want   int
+	}{
+		{"默认限制已知模型", "gpt-4o", config.ParamPolicy{}.WithMaxTokensAuto(), 16384},
+		{"忽略供应商前缀", "openai/gpt-4o-mini", config.ParamPolicy{}.WithMaxTokensAuto(), 16384},
+		{"未知模型不限制", "my-local-model", config.ParamPolicy{}.WithMaxTokensAuto(), 32000},
+		{"没有策略不限制", "gpt-4o", config.ParamPolicy{}, 32000},
+		{"off 关闭默认限制", "gpt-4o", config.ParamPolicy{Clamp: config.ParamClamp{MaxTokensOff: true}}.WithMaxTokensAuto(), 32000},
+		{"数字上限优先", "gpt-4o", config.ParamPolicy{Clamp: config.ParamClamp{MaxTokens: 4096}}.WithMaxTokensAuto(), 4096},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &models.OpenAIRequest{Model: tt.model, MaxCompletionTokens: 32000}
+			ApplyParamPolicy(req, tt.policy)
+			if req.MaxCompletionTokens != tt.want {
+				t.Errorf("max_completion_tokens = %d，应为 %d", req.MaxCompletionTokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyBodyPolicyClampsMaxTokens(t *testing.T) {
+	body := map[string]interface{}{"max_tokens": float64(32000)}
+	ApplyBodyPolicy(body, config.ParamPolicy{}.WithMaxTokensAuto(), "claude-3-5-haiku-20241022")
+	if body["max_tokens"] != 8192 {
+		t.Errorf("max_tokens = %v，应为 8192", body["max_tokens"])
+	}
+}
@@ -0,0 +1,356 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// Responses API 输入/输出项类型
+const (
+	responsesItemMessage            = "message"
+	responsesItemReasoning          = "reasoning"
+	responsesItemFunctionCall       = "function_call"
+	responsesItemFunctionCallOutput = "function_call_output"
+)
+
+// reasoningSignatureSeparator 分隔思考块签名中的推理项 ID 和加密内容。
+// 推理项 ID（rs_...）和 base64 编码的加密内容都不包含冒号。
+const reasoningSignatureSeparator = ":"
+
+// EncodeReasoningSignature 将 Responses API 推理项的 ID 和加密内容编码为 Claude 思考块签名。
+// Claude Code 在后续请求中原样发回思考块，从而可以还原推理项并继续无状态的多轮推理。
+func EncodeReasoningSignature(id, encryptedContent string) string {
+	return id + reasoningSignatureSeparator + encryptedContent
+}
+
+// decodeReasoningSignature 从思考块签名中还原推理项 ID 和加密内容。
+// 其他提供商产生的签名（包括空签名）返回 ok=false。
+func decodeReasoningSignature(signature string) (id, encryptedContent string, ok bool) {
+	if !strings.HasPrefix(signature, "rs_") {
+		return "", "", false
+	}
+	id, encryptedContent, ok = strings.Cut(signature, reasoningSignatureSeparator)
+	if !ok || encryptedContent == "" {
+		return "", "", false
+	}
+	return id, encryptedContent, true
+}
+
+// ConvertMessagesToResponsesInput 将 Claude 消息转换为 Responses API 的输入项。
+//
+// 转换规则：
+//   - text/image 块转换为对应角色的消息（保持与其他项的相对顺序）
+//   - 带有 Responses 签名的 thinking 块转换为 reasoning 项（携带加密内容）
+//   - tool_use 块转换为 function_call 项
+//   - tool_result 块转换为 function_call_output 项
+func ConvertMessagesToResponsesInput(messages []models.ClaudeMessage) []interface{} {
+	input := []interface{}{}
+
+	for _, msg := range messages {
+		blocks, ok := msg.Content.([]interface{})
+		if !ok {
+			if text, ok := msg.Content.(string); ok {
+				input = append(input, map[string]interface{}{"role": msg.Role, "content": text})
+			}
+			continue
+		}
+
+		// 累积的消息内容，遇到非消息项时先输出以保持顺序
+		var parts []interface{}
+		var textParts []string
+		flush := func() {
+			if msg.Role == constants.RoleAssistant && len(textParts) > 0 {
+				input = append(input, map[string]interface{}{
+					"role":    constants.RoleAssistant,
+					"content": strings.Join(textParts, "\n"),
+				})
+			} else if len(parts) > 0 {
+				input = append(input, map[string]interface{}{
+					"type":    responsesItemMessage,
+					"role":    msg.Role,
+					"content": parts,
+				})
+			}
+			parts, textParts = nil, nil
+		}
+
+		for _, block := range blocks {
+			blockMap, ok := block.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			switch blockMap["type"] {
+			case constants.ContentTypeText:
+				text, _ := blockMap["text"].(string)
+				textParts = append(textParts, text)
+				parts = append(parts, map[string]interface{}{"type": "input_text", "text": text})
+
+			case constants.ContentTypeImage:
+				if imageURL := claudeImageURL(blockMap["source"]); imageURL != "" {
+					parts = append(parts, map[string]interface{}{"type": "input_image", "image_url": imageURL})
+				}
+
+			case constants.ContentTypeThinking:
+				signature, _ := blockMap["signature"].(string)
+				id, encryptedContent, ok := decodeReasoningSignature(signature)
+				if !ok {
+					continue
+				}
+				flush()
+				summary := []interface{}{}
+				if thinking, _ := blockMap["thinking"].(string); thinking != "" {
+					summary = append(summary, map[string]interface{}{"type": "summary_text", "text": thinking})
+				}
+				input = append(input, map[string]interface{}{
+					"type":              responsesItemReasoning,
+					"id":                id,
+					"summary":           summary,
+					"encrypted_content": encryptedContent,
+				})
+
+			case constants.ContentTypeToolUse:
+				flush()
+				argsJSON, err := json.Marshal(blockMap["input"])
+				if err != nil || blockMap["input"] == nil {
+					argsJSON = []byte("{}")
+				}
+				callID, _ := blockMap["id"].(string)
+				name, _ := blockMap["name"].(string)
+				input = append(input, map[string]interface{}{
+					"type":      responsesItemFunctionCall,
+					"call_id":   callID,
+					"name":      name,
+					"arguments": string(argsJSON),
+				})
+
+			case constants.ContentTypeToolResult:
+				flush()
+				callID, _ := blockMap["tool_use_id"].(string)
+				input = append(input, map[string]interface{}{
+					"type":    responsesItemFunctionCallOutput,
+					"call_id": callID,
+					"output":  extractSystemText(blockMap["content"]),
+				})
+			}
+		}
+		flush()
+	}
+
+	return input
+}
+
+// claudeImageURL 将 Claude 图片来源转换为 Responses API 接受的图片 URL（base64 来源转为 data URL）
+func claudeImageURL(source interface{}) string {
+	sourceMap, ok := source.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	switch sourceMap["type"] {
+	case "base64":
+		mediaType, _ := sourceMap["media_type"].(string)
+		data, _ := sourceMap["data"].(string)
+		return fmt.Sprintf("data:%s;base64,%s", mediaType, data)
+	case "url":
+		url, _ := sourceMap["url"].(string)
+		return url
+	}
+	return ""
+}
+
+// ConvertToResponsesRequest 将已转换的聊天完成请求改写为 Responses API 请求。
+// 模型映射、令牌限制和工具描述增强等逻辑沿用 ConvertRequest 的结果；
+// 系统消息成为 instructions，输入项优先由原始 Claude 消息直接转换（保留推理项和图片）。
+func ConvertToResponsesRequest(openaiReq *models.OpenAIRequest) *models.ResponsesRequest {
+	store := false // 无状态调用：推理项通过加密内容在客户端往返
+	respReq := &models.ResponsesRequest{
+		Model:           openaiReq.Model,
+		MaxOutputTokens: openaiReq.MaxCompletionTokens,
+		Temperature:     openaiReq.Temperature,
+		TopP:            openaiReq.TopP,
+		Stream:          openaiReq.Stream != nil && *openaiReq.Stream,
+		Store:           &store,
+	}
+	if respReq.MaxOutputTokens == 0 {
+		respReq.MaxOutputTokens = openaiReq.MaxTokens
+	}
+
+	var instructions []string
+	var messages []models.OpenAIMessage
+	for _, msg := range openaiReq.Messages {
+		if msg.Role == constants.RoleSystem {
+			if text, ok := msg.Content.(string); ok && text != "" {
+				instructions = append(instructions, text)
+			}
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	respReq.Instructions = strings.Join(instructions, "\n\n")
+	if openaiReq.Original != nil {
+		respReq.Input = ConvertMessagesToResponsesInput(openaiReq.Original.Messages)
+	} else {
+		respReq.Input = openAIMessagesToResponsesInput(messages)
+	}
+
+	for _, tool := range openaiReq.Tools {
+		respReq.Tools = append(respReq.Tools, models.ResponsesTool{
+			Type:        constants.ToolTypeFunction,
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
+	respReq.ToolChoice = responsesToolChoice(openaiReq.ToolChoice)
+
+	// 推理：请求摘要，并要求返回加密推理内容以便在下一轮发回
+	if openaiReq.ReasoningEffort != "" {
+		respReq.Reasoning = map[string]interface{}{
+			"effort":  openaiReq.ReasoningEffort,
+			"summary": "auto",
+		}
+		respReq.Include = []string{"reasoning.encrypted_content"}
+	}
+
+	return respReq
+}
+
+// openAIMessagesToResponsesInput 将聊天完成消息转换为 Responses API 输入项。
+// 仅在请求没有原始 Claude 请求时使用（无法还原推理项）。
+func openAIMessagesToResponsesInput(messages []models.OpenAIMessage) []interface{} {
+	input := []interface{}{}
+	for _, msg := range messages {
+		switch msg.Role {
+		case constants.RoleTool:
+			input = append(input, map[string]interface{}{
+				"type":    responsesItemFunctionCallOutput,
+				"call_id": msg.ToolCallID,
+				"output":  openAIContentText(msg.Content),
+			})
+		default:
+			if text := openAIContentText(msg.Content); text != "" {
+				input = append(input, map[string]interface{}{"role": msg.Role, "content": text})
+			}
+			for _, tc := range msg.ToolCalls {
+				input = append(input, map[string]interface{}{
+					"type":      responsesItemFunctionCall,
+					"call_id":   tc.ID,
+					"name":      tc.Function.Name,
+					"arguments": tc.Function.Arguments,
+				})
+			}
+		}
+	}
+	return input
+}
+
+// responsesToolChoice 将聊天完成的 tool_choice 转换为 Responses API 格式。
+// 字符串值（auto、none、required）保持不变；指定函数时去掉 function 嵌套。
+func responsesToolChoice(toolChoice interface{}) interface{} {
+	choice, ok := toolChoice.(map[string]interface{})
+	if !ok {
+		return toolChoice
+	}
+	if function, ok := choice["function"].(map[string]interface{}); ok {
+		return map[string]interface{}{
+			"type": constants.ToolTypeFunction,
+			"name": function["name"],
+		}
+	}
+	return toolChoice
+}
+
+// ConvertResponsesResponse 将 Responses API 响应转换为聊天完成响应，以便复用 ConvertResponse。
+// 推理项转换为 OpenRouter 格式的 reasoning_details：摘要为 reasoning.summary，
+// 加密内容为 reasoning.encrypted（data 为编码后的思考块签名）。
+func ConvertResponsesResponse(respResp *models.ResponsesResponse) *models.OpenAIResponse {
+	message := models.OpenAIMessage{Role: constants.RoleAssistant}
+
+	var textParts []string
+	for _, item := range respResp.Output {
+		switch item.Type {
+		case responsesItemReasoning:
+			var summaryParts []string
+			for _, part := range item.Summary {
+				summaryParts = append(summaryParts, part.Text)
+			}
+			if len(summaryParts) > 0 {
+				message.ReasoningDetails = append(message.ReasoningDetails, map[string]interface{}{
+					"type":    constants.ReasoningTypeSummary,
+					"summary": strings.Join(summaryParts, "\n\n"),
+				})
+			}
+			if item.EncryptedContent != "" {
+				message.ReasoningDetails = append(message.ReasoningDetails, map[string]interface{}{
+					"type": constants.ReasoningTypeEncrypted,
+					"id":   item.ID,
+					"data": EncodeReasoningSignature(item.ID, item.EncryptedContent),
+				})
+			}
+
+		case responsesItemMessage:
+			for _, part := range item.Content {
+				if part.Type == "refusal" {
+					textParts = append(textParts, part.Refusal)
+				} else {
+					textParts = append(textParts, part.Text)
+				}
+			}
+
+		case responsesItemFunctionCall:
+			toolCall := models.OpenAIToolCall{
+				ID:   item.CallID,
+				Type: constants.ToolTypeFunction,
+			}
+			toolCall.Function.Name = item.Name
+			toolCall.Function.Arguments = item.Arguments
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		}
+	}
+	message.Content = strings.Join(textParts, "")
+
+	finishReason := ResponsesFinishReason(respResp.Status, incompleteReason(respResp), len(message.ToolCalls) > 0)
+
+	return &models.OpenAIResponse{
+		ID:     respResp.ID,
+		Object: "chat.completion",
+		Model:  respResp.Model,
+		Choices: []models.OpenAIChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: &finishReason,
+		}},
+		Usage: models.OpenAIUsage{
+			PromptTokens:     respResp.Usage.InputTokens,
+			CompletionTokens: respResp.Usage.OutputTokens,
+			TotalTokens:      respResp.Usage.TotalTokens,
+		},
+	}
+}
+
+// incompleteReason 返回未完成响应的原因（响应已完成时为空）
+func incompleteReason(respResp *models.ResponsesResponse) string {
+	if respResp.IncompleteDetails == nil {
+		return ""
+	}
+	return respResp.IncompleteDetails.Reason
+}
+
+// ResponsesFinishReason 根据 Responses API 的响应状态推导聊天完成的完成原因。
+// 流式和非流式转换共用。
+func ResponsesFinishReason(status, incompleteReason string, hasToolCalls bool) string {
+	switch {
+	case status == "incomplete" && incompleteReason == "max_output_tokens":
+		return constants.FinishReasonLength
+	case status == "incomplete" && incompleteReason == "content_filter":
+		return constants.FinishReasonContentFilter
+	case hasToolCalls:
+		return constants.FinishReasonToolCalls
+	default:
+		return constants.FinishReasonStop
+	}
+}
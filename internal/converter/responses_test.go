@@ -0,0 +1,221 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+func TestConvertToResponsesRequest(t *testing.T) {
+	temperature := 0.3
+	tool := models.OpenAITool{Type: "function"}
+	tool.Function.Name = "read"
+	tool.Function.Description = "读取文件"
+	tool.Function.Parameters = map[string]interface{}{"type": "object"}
+
+	tests := []struct {
+		name  string
+		req   *models.OpenAIRequest
+		check func(t *testing.T, got *models.ResponsesRequest)
+	}{
+		{
+			name: "系统消息成为 instructions，max_tokens 回退为 max_output_tokens",
+			req: &models.OpenAIRequest{
+				Model:       "gpt-5",
+				MaxTokens:   1024,
+				Temperature: &temperature,
+				Messages: []models.OpenAIMessage{
+					{Role: "system", Content: "你是助手"},
+					{Role: "system", Content: "回答要简短"},
+					{Role: "user", Content: "hi"},
+				},
+			},
+			check: func(t *testing.T, got *models.ResponsesRequest) {
+				if got.Instructions != "你是助手\n\n回答要简短" {
+					t.Errorf("instructions = %q", got.Instructions)
+				}
+				if got.MaxOutputTokens != 1024 || got.Temperature == nil || *got.Temperature != 0.3 {
+					t.Errorf("max_output_tokens = %d，temperature = %v", got.MaxOutputTokens, got.Temperature)
+				}
+				if got.Store == nil || *got.Store {
+					t.Errorf("store 应为 false（无状态调用）")
+				}
+				want := []interface{}{map[string]interface{}{"role": "user", "content": "hi"}}
+				if !reflect.DeepEqual(got.Input, want) {
+					t.Errorf("input = %#v", got.Input)
+				}
+			},
+		},
+		{
+			name: "工具去掉 function 嵌套，指定函数的 tool_choice 改写",
+			req: &models.OpenAIRequest{
+				Model:               "gpt-5",
+				MaxCompletionTokens: 2048,
+				Tools:               []models.OpenAITool{tool},
+				ToolChoice: map[string]interface{}{
+					"type":     "function",
+					"function": map[string]interface{}{"name": "read"},
+				},
+				Messages: []models.OpenAIMessage{{Role: "user", Content: "读取 a.txt"}},
+			},
+			check: func(t *testing.T, got *models.ResponsesRequest) {
+				if got.MaxOutputTokens != 2048 {
+					t.Errorf("max_output_tokens = %d", got.MaxOutputTokens)
+				}
+				if len(got.Tools) != 1 || got.Tools[0].Name != "read" || got.Tools[0].Type != "function" {
+					t.Errorf("tools = %#v", got.Tools)
+				}
+				want := map[string]interface{}{"type": "function", "name": "read"}
+				if !reflect.DeepEqual(got.ToolChoice, want) {
+					t.Errorf("tool_choice = %#v", got.ToolChoice)
+				}
+			},
+		},
+		{
+			name: "推理请求摘要和加密内容",
+			req: &models.OpenAIRequest{
+				Model:           "o3",
+				ReasoningEffort: "high",
+				Messages:        []models.OpenAIMessage{{Role: "user", Content: "hi"}},
+			},
+			check: func(t *testing.T, got *models.ResponsesRequest) {
+				want := map[string]interface{}{"effort": "high", "summary": "auto"}
+				if !reflect.DeepEqual(got.Reasoning, want) {
+					t.Errorf("reasoning = %#v", got.Reasoning)
+				}
+				if !reflect.DeepEqual(got.Include, []string{"reasoning.encrypted_content"}) {
+					t.Errorf("include = %#v", got.Include)
+				}
+			},
+		},
+		{
+			name: "原始 Claude 消息转换为推理、函数调用和函数输出项",
+			req: &models.OpenAIRequest{
+				Model: "gpt-5",
+				Original: &models.ClaudeRequest{Messages: []models.ClaudeMessage{
+					{Role: "user", Content: "读取 a.txt"},
+					{Role: "assistant", Content: []interface{}{
+						map[string]interface{}{"type": "thinking", "thinking": "需要读取文件", "signature": "rs_1:ZW5j"},
+						map[string]interface{}{"type": "thinking", "thinking": "其他提供商", "signature": "sig"},
+						map[string]interface{}{"type": "text", "text": "好的"},
+						map[string]interface{}{"type": "tool_use", "id": "call_1", "name": "read", "input": map[string]interface{}{"path": "a.txt"}},
+					}},
+					{Role: "user", Content: []interface{}{
+						map[string]interface{}{"type": "tool_result", "tool_use_id": "call_1", "content": "内容"},
+						map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "aW1n"}},
+					}},
+				}},
+			},
+			check: func(t *testing.T, got *models.ResponsesRequest) {
+				want := []interface{}{
+					map[string]interface{}{"role": "user", "content": "读取 a.txt"},
+					map[string]interface{}{
+						"type":              "reasoning",
+						"id":                "rs_1",
+						"summary":           []interface{}{map[string]interface{}{"type": "summary_text", "text": "需要读取文件"}},
+						"encrypted_content": "ZW5j",
+					},
+					map[string]interface{}{"role": "assistant", "content": "好的"},
+					map[string]interface{}{"type": "function_call", "call_id": "call_1", "name": "read", "arguments": `{"path":"a.txt"}`},
+					map[string]interface{}{"type": "function_call_output", "call_id": "call_1", "output": "内容"},
+					map[string]interface{}{"type": "message", "role": "user", "content": []interface{}{
+						map[string]interface{}{"type": "input_image", "image_url": "data:image/png;base64,aW1n"},
+					}},
+				}
+				if !reflect.DeepEqual(got.Input, want) {
+					t.Errorf("input = %#v\n应为 %#v", got.Input, want)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, ConvertToResponsesRequest(tt.req))
+		})
+	}
+}
+
+func TestConvertResponsesResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		resp         models.ResponsesResponse
+		wantContent  string
+		wantFinish   string
+		wantTools    []string
+		wantDetails  []map[string]interface{}
+		wantUsageOut int
+	}{
+		{
+			name: "文本",
+			resp: models.ResponsesResponse{ID: "resp_1", Status: "completed", Output: []models.ResponsesOutputItem{
+				{Type: "message", Content: []models.ResponsesContentPart{{Type: "output_text", Text: "你好"}, {Type: "output_text", Text: "！"}}},
+			}, Usage: models.ResponsesUsage{InputTokens: 5, OutputTokens: 3, TotalTokens: 8}},
+			wantContent: "你好！", wantFinish: constants.FinishReasonStop, wantUsageOut: 3,
+		},
+		{
+			name: "拒绝",
+			resp: models.ResponsesResponse{Status: "completed", Output: []models.ResponsesOutputItem{
+				{Type: "message", Content: []models.ResponsesContentPart{{Type: "refusal", Refusal: "无法协助"}}},
+			}},
+			wantContent: "无法协助", wantFinish: constants.FinishReasonStop,
+		},
+		{
+			name: "推理和函数调用",
+			resp: models.ResponsesResponse{Status: "completed", Output: []models.ResponsesOutputItem{
+				{Type: "reasoning", ID: "rs_1", Summary: []models.ResponsesContentPart{{Text: "第一段"}, {Text: "第二段"}}, EncryptedContent: "ZW5j"},
+				{Type: "function_call", CallID: "call_1", Name: "read", Arguments: `{"path":"a.txt"}`},
+			}},
+			wantFinish: constants.FinishReasonToolCalls,
+			wantTools:  []string{"call_1:read"},
+			wantDetails: []map[string]interface{}{
+				{"type": constants.ReasoningTypeSummary, "summary": "第一段\n\n第二段"},
+				{"type": constants.ReasoningTypeEncrypted, "id": "rs_1", "data": "rs_1:ZW5j"},
+			},
+		},
+		{
+			name: "达到输出上限",
+			resp: func() models.ResponsesResponse {
+				r := models.ResponsesResponse{Status: "incomplete", Output: []models.ResponsesOutputItem{
+					{Type: "message", Content: []models.ResponsesContentPart{{Type: "output_text", Text: "截断"}}},
+				}}
+				r.IncompleteDetails = &struct {
+					Reason string `json:"reason"`
+				}{Reason: "max_output_tokens"}
+				return r
+			}(),
+			wantContent: "截断", wantFinish: constants.FinishReasonLength,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConvertResponsesResponse(&tt.resp)
+			msg := got.Choices[0].Message
+			if msg.Content != tt.wantContent {
+				t.Errorf("content = %q，应为 %q", msg.Content, tt.wantContent)
+			}
+			if *got.Choices[0].FinishReason != tt.wantFinish {
+				t.Errorf("finish_reason = %q，应为 %q", *got.Choices[0].FinishReason, tt.wantFinish)
+			}
+			var tools []string
+			for _, tc := range msg.ToolCalls {
+				tools = append(tools, tc.ID+":"+tc.Function.Name)
+			}
+			if !reflect.DeepEqual(tools, tt.wantTools) {
+				t.Errorf("tool_calls = %v，应为 %v", tools, tt.wantTools)
+			}
+			if len(msg.ReasoningDetails) != len(tt.wantDetails) {
+				t.Fatalf("reasoning_details = %#v", msg.ReasoningDetails)
+			}
+			for i, want := range tt.wantDetails {
+				if !reflect.DeepEqual(msg.ReasoningDetails[i], want) {
+					t.Errorf("reasoning_details[%d] = %#v，应为 %#v", i, msg.ReasoningDetails[i], want)
+				}
+			}
+			if got.Usage.CompletionTokens != tt.wantUsageOut {
+				t.Errorf("completion_tokens = %d，应为 %d", got.Usage.CompletionTokens, tt.wantUsageOut)
+			}
+		})
+	}
+}
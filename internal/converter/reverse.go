@@ -0,0 +1,264 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// defaultReverseMaxTokens OpenAI 请求未指定 max_tokens 时使用的值（Claude API 要求该字段）
+const defaultReverseMaxTokens = 8192
+
+// ConvertOpenAIRequest 将入站 OpenAI 聊天完成请求转换为 Claude 请求（反向转换）。
+// 用于 /v1/chat/completions 端点：转换后的请求与 /v1/messages 共用同一条路由和上游处理流程。
+//
+// 转换规则：
+//   - system/developer 消息合并为 Claude 的 system 字段
+//   - assistant 的 tool_calls 转换为 tool_use 块
+//   - 连续的 tool 消息合并为一条包含 tool_result 块的 user 消息
+//   - image_url 内容转换为 image 块（data URL 转为 base64 来源）
+func ConvertOpenAIRequest(openaiReq *models.OpenAIRequest) (*models.ClaudeRequest, error) {
+	claudeReq := &models.ClaudeRequest{
+		Model:         openaiReq.Model,
+		MaxTokens:     openaiReq.MaxCompletionTokens,
+		Temperature:   openaiReq.Temperature,
+		TopP:          openaiReq.TopP,
+		StopSequences: openaiReq.Stop,
+		Stream:        openaiReq.Stream,
+	}
+	if claudeReq.MaxTokens == 0 {
+		claudeReq.MaxTokens = openaiReq.MaxTokens
+	}
+	if claudeReq.MaxTokens == 0 {
+		claudeReq.MaxTokens = defaultReverseMaxTokens
+	}
+
+	var systemParts []string
+	for i, msg := range openaiReq.Messages {
+		switch msg.Role {
+		case constants.RoleSystem, "developer":
+			if text := openAIContentText(msg.Content); text != "" {
+				systemParts = append(systemParts, text)
+			}
+
+		case constants.RoleUser:
+			claudeReq.Messages = append(claudeReq.Messages, models.ClaudeMessage{
+				Role:    constants.RoleUser,
+				Content: openAIContentToClaude(msg.Content),
+			})
+
+		case constants.RoleAssistant:
+			var blocks []interface{}
+			if text := openAIContentText(msg.Content); text != "" {
+				blocks = append(blocks, map[string]interface{}{
+					"type": constants.ContentTypeText,
+					"text": text,
+				})
+			}
+			for _, tc := range msg.ToolCalls {
+				var input map[string]interface{}
+				if err := json.Unmarshal([]byte(tc.Function.Arguments), &input); err != nil || input == nil {
+					input = map[string]interface{}{}
+				}
+				blocks = append(blocks, map[string]interface{}{
+					"type":  constants.ContentTypeToolUse,
+					"id":    tc.ID,
+					"name":  tc.Function.Name,
+					"input": input,
+				})
+			}
+			if len(blocks) == 0 {
+				continue
+			}
+			claudeReq.Messages = append(claudeReq.Messages, models.ClaudeMessage{
+				Role:    constants.RoleAssistant,
+				Content: blocks,
+			})
+
+		case constants.RoleTool:
+			result := map[string]interface{}{
+				"type":        constants.ContentTypeToolResult,
+				"tool_use_id": msg.ToolCallID,
+				"content":     openAIContentText(msg.Content),
+			}
+			// 紧跟在另一条 tool 消息之后时合并到同一条 user 消息
+			if i > 0 && openaiReq.Messages[i-1].Role == constants.RoleTool && len(claudeReq.Messages) > 0 {
+				last := &claudeReq.Messages[len(claudeReq.Messages)-1]
+				if blocks, ok := last.Content.([]interface{}); ok {
+					last.Content = append(blocks, result)
+					continue
+				}
+			}
+			claudeReq.Messages = append(claudeReq.Messages, models.ClaudeMessage{
+				Role:    constants.RoleUser,
+				Content: []interface{}{result},
+			})
+
+		default:
+			return nil, fmt.Errorf("不支持的消息角色: %s", msg.Role)
+		}
+	}
+	if len(systemParts) > 0 {
+		claudeReq.System = strings.Join(systemParts, "\n\n")
+	}
+
+	// tool_choice 为 "none" 时不发送工具定义
+	if choice, _ := openaiReq.ToolChoice.(string); choice != constants.ToolChoiceNone {
+		for _, tool := range openaiReq.Tools {
+			claudeReq.Tools = append(claudeReq.Tools, models.Tool{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				InputSchema: tool.Function.Parameters,
+			})
+		}
+	}
+
+	return claudeReq, nil
+}
+
+// ConvertClaudeResponseToOpenAI 将 Claude 响应转换为 OpenAI 聊天完成响应（反向转换）。
+// 思考块转换为 reasoning_content，tool_use 块转换为 tool_calls。
+func ConvertClaudeResponseToOpenAI(claudeResp *models.ClaudeResponse, requestedModel string) *models.OpenAIResponse {
+	message := models.OpenAIMessage{Role: constants.RoleAssistant}
+
+	var textParts, thinkingParts []string
+	for _, block := range claudeResp.Content {
+		switch block.Type {
+		case constants.ContentTypeText:
+			textParts = append(textParts, block.Text)
+		case constants.ContentTypeThinking:
+			thinkingParts = append(thinkingParts, block.Thinking)
+		case constants.ContentTypeToolUse:
+			argsJSON, err := json.Marshal(block.Input)
+			if err != nil || block.Input == nil {
+				argsJSON = []byte("{}")
+			}
+			toolCall := models.OpenAIToolCall{
+				ID:   block.ID,
+				Type: constants.ToolTypeFunction,
+			}
+			toolCall.Function.Name = block.Name
+			toolCall.Function.Arguments = string(argsJSON)
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		}
+	}
+	message.Content = strings.Join(textParts, "")
+	message.ReasoningContent = strings.Join(thinkingParts, "")
+
+	stopReason := constants.StopReasonEndTurn
+	if claudeResp.StopReason != nil {
+		stopReason = *claudeResp.StopReason
+	}
+	finishReason := ConvertStopReason(stopReason)
+
+	return &models.OpenAIResponse{
+		ID:      "chatcmpl-" + strings.TrimPrefix(claudeResp.ID, "msg_"),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   requestedModel,
+		Choices: []models.OpenAIChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: &finishReason,
+		}},
+		Usage: models.OpenAIUsage{
+			PromptTokens:     claudeResp.Usage.InputTokens,
+			CompletionTokens: claudeResp.Usage.OutputTokens,
+			TotalTokens:      claudeResp.Usage.InputTokens + claudeResp.Usage.OutputTokens,
+		},
+	}
+}
+
+// ConvertStopReason 将 Claude 停止原因映射为 OpenAI 完成原因（convertFinishReason 的反向）
+func ConvertStopReason(stopReason string) string {
+	switch stopReason {
+	case constants.StopReasonToolUse:
+		return constants.FinishReasonToolCalls
+	case constants.StopReasonMaxTokens:
+		return constants.FinishReasonLength
+	default:
+		return constants.FinishReasonStop
+	}
+}
+
+// openAIContentText 提取 OpenAI 消息内容中的文本（字符串或内容部分数组）
+func openAIContentText(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var parts []string
+		for _, part := range v {
+			if partMap, ok := part.(map[string]interface{}); ok && partMap["type"] == constants.ContentTypeText {
+				if text, ok := partMap["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return ""
+	}
+}
+
+// openAIContentToClaude 将 OpenAI 用户消息内容转换为 Claude 内容。
+// 纯文本保持为字符串；内容部分数组转换为 text 和 image 块。
+func openAIContentToClaude(content interface{}) interface{} {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return openAIContentText(content)
+	}
+
+	blocks := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		partMap, ok := part.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch partMap["type"] {
+		case constants.ContentTypeText:
+			text, _ := partMap["text"].(string)
+			blocks = append(blocks, map[string]interface{}{
+				"type": constants.ContentTypeText,
+				"text": text,
+			})
+		case "image_url":
+			imageURL, _ := partMap["image_url"].(map[string]interface{})
+			url, _ := imageURL["url"].(string)
+			if source := imageSourceFromURL(url); source != nil {
+				blocks = append(blocks, map[string]interface{}{
+					"type":   constants.ContentTypeImage,
+					"source": source,
+				})
+			}
+		}
+	}
+	return blocks
+}
+
+// imageSourceFromURL 将图片 URL 转换为 Claude 图片来源。
+// data URL（data:image/png;base64,...）转为 base64 来源，其他 URL 转为 url 来源。
+func imageSourceFromURL(url string) map[string]interface{} {
+	if url == "" {
+		return nil
+	}
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		meta, data, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return nil
+		}
+		return map[string]interface{}{
+			"type":       "base64",
+			"media_type": strings.TrimSuffix(meta, ";base64"),
+			"data":       data,
+		}
+	}
+	return map[string]interface{}{
+		"type": "url",
+		"url":  url,
+	}
+}
@@ -0,0 +1,201 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+func TestConvertOpenAIRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(t *testing.T, got *models.ClaudeRequest)
+	}{
+		{
+			name: "system 和 developer 消息合并，未指定 max_tokens 时使用默认值",
+			body: `{"model":"claude-sonnet-4-5","messages":[` +
+				`{"role":"system","content":"你是助手"},` +
+				`{"role":"developer","content":[{"type":"text","text":"回答要简短"}]},` +
+				`{"role":"user","content":"hi"}]}`,
+			check: func(t *testing.T, got *models.ClaudeRequest) {
+				if got.System != "你是助手\n\n回答要简短" {
+					t.Errorf("system = %#v", got.System)
+				}
+				if got.MaxTokens != defaultReverseMaxTokens {
+					t.Errorf("max_tokens = %d，应为 %d", got.MaxTokens, defaultReverseMaxTokens)
+				}
+				want := []models.ClaudeMessage{{Role: "user", Content: "hi"}}
+				if !reflect.DeepEqual(got.Messages, want) {
+					t.Errorf("messages = %#v", got.Messages)
+				}
+			},
+		},
+		{
+			name: "max_completion_tokens 优先于 max_tokens",
+			body: `{"model":"m","max_tokens":100,"max_completion_tokens":200,"messages":[{"role":"user","content":"hi"}]}`,
+			check: func(t *testing.T, got *models.ClaudeRequest) {
+				if got.MaxTokens != 200 {
+					t.Errorf("max_tokens = %d，应为 200", got.MaxTokens)
+				}
+			},
+		},
+		{
+			name: "tool_calls 转为 tool_use，连续的 tool 消息合并为一条 user 消息",
+			body: `{"model":"m","messages":[` +
+				`{"role":"user","content":"读取两个文件"},` +
+				`{"role":"assistant","content":"好的","tool_calls":[` +
+				`{"id":"call_1","type":"function","function":{"name":"read","arguments":"{\"path\":\"a.txt\"}"}},` +
+				`{"id":"call_2","type":"function","function":{"name":"read","arguments":"不是 JSON"}}]},` +
+				`{"role":"tool","tool_call_id":"call_1","content":"A"},` +
+				`{"role":"tool","tool_call_id":"call_2","content":"B"}]}`,
+			check: func(t *testing.T, got *models.ClaudeRequest) {
+				want := []models.ClaudeMessage{
+					{Role: "user", Content: "读取两个文件"},
+					{Role: "assistant", Content: []interface{}{
+						map[string]interface{}{"type": "text", "text": "好的"},
+						map[string]interface{}{"type": "tool_use", "id": "call_1", "name": "read",
+							"input": map[string]interface{}{"path": "a.txt"}},
+						map[string]interface{}{"type": "tool_use", "id": "call_2", "name": "read",
+							"input": map[string]interface{}{}},
+					}},
+					{Role: "user", Content: []interface{}{
+						map[string]interface{}{"type": "tool_result", "tool_use_id": "call_1", "content": "A"},
+						map[string]interface{}{"type": "tool_result", "tool_use_id": "call_2", "content": "B"},
+					}},
+				}
+				if !reflect.DeepEqual(got.Messages, want) {
+					t.Errorf("messages = %#v", got.Messages)
+				}
+			},
+		},
+		{
+			name: "image_url 转为 image 块",
+			body: `{"model":"m","messages":[{"role":"user","content":[` +
+				`{"type":"text","text":"这是什么"},` +
+				`{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}},` +
+				`{"type":"image_url","image_url":{"url":"https://example.com/a.jpg"}},` +
+				`{"type":"image_url","image_url":{"url":"data:image/png,raw"}}]}]}`,
+			check: func(t *testing.T, got *models.ClaudeRequest) {
+				want := []interface{}{
+					map[string]interface{}{"type": "text", "text": "这是什么"},
+					map[string]interface{}{"type": "image", "source": map[string]interface{}{
+						"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}},
+					map[string]interface{}{"type": "image", "source": map[string]interface{}{
+						"type": "url", "url": "https://example.com/a.jpg"}},
+				}
+				if len(got.Messages) != 1 || !reflect.DeepEqual(got.Messages[0].Content, want) {
+					t.Errorf("messages = %#v", got.Messages)
+				}
+			},
+		},
+		{
+			name: "转换工具定义",
+			body: `{"model":"m","messages":[{"role":"user","content":"hi"}],` +
+				`"tools":[{"type":"function","function":{"name":"read","description":"读取文件","parameters":{"type":"object"}}}]}`,
+			check: func(t *testing.T, got *models.ClaudeRequest) {
+				want := []models.Tool{{Name: "read", Description: "读取文件", InputSchema: map[string]interface{}{"type": "object"}}}
+				if !reflect.DeepEqual(got.Tools, want) {
+					t.Errorf("tools = %#v", got.Tools)
+				}
+			},
+		},
+		{
+			name: "tool_choice 为 none 时不发送工具",
+			body: `{"model":"m","messages":[{"role":"user","content":"hi"}],"tool_choice":"none",` +
+				`"tools":[{"type":"function","function":{"name":"read","parameters":{"type":"object"}}}]}`,
+			check: func(t *testing.T, got *models.ClaudeRequest) {
+				if len(got.Tools) != 0 {
+					t.Errorf("tools = %#v，应为空", got.Tools)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req models.OpenAIRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatalf("解析请求失败: %v", err)
+			}
+			got, err := ConvertOpenAIRequest(&req)
+			if err != nil {
+				t.Fatalf("ConvertOpenAIRequest 返回错误: %v", err)
+			}
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestConvertOpenAIRequestUnknownRole(t *testing.T) {
+	req := &models.OpenAIRequest{
+		Model:    "m",
+		Messages: []models.OpenAIMessage{{Role: "function", Content: "x"}},
+	}
+	if _, err := ConvertOpenAIRequest(req); err == nil || !strings.Contains(err.Error(), "function") {
+		t.Errorf("错误 = %v，应报告不支持的角色", err)
+	}
+}
+
+func TestConvertClaudeResponseToOpenAI(t *testing.T) {
+	stopReason := "tool_use"
+	resp := &models.ClaudeResponse{
+		ID:    "msg_abc",
+		Model: "test-model",
+		Content: []models.ContentBlock{
+			{Type: "thinking", Thinking: "先读文件"},
+			{Type: "text", Text: "好的，"},
+			{Type: "text", Text: "我来读取"},
+			{Type: "tool_use", ID: "toolu_1", Name: "read", Input: map[string]interface{}{"path": "a.txt"}},
+			{Type: "tool_use", ID: "toolu_2", Name: "list"},
+		},
+		StopReason: &stopReason,
+		Usage:      models.Usage{InputTokens: 10, OutputTokens: 5},
+	}
+
+	got := ConvertClaudeResponseToOpenAI(resp, "claude-sonnet-4-5")
+	if got.ID != "chatcmpl-abc" || got.Object != "chat.completion" || got.Model != "claude-sonnet-4-5" {
+		t.Errorf("id = %q，object = %q，model = %q", got.ID, got.Object, got.Model)
+	}
+	if len(got.Choices) != 1 {
+		t.Fatalf("choices = %#v", got.Choices)
+	}
+	choice := got.Choices[0]
+	if choice.FinishReason == nil || *choice.FinishReason != "tool_calls" {
+		t.Errorf("finish_reason = %v，应为 tool_calls", choice.FinishReason)
+	}
+	if choice.Message.Content != "好的，我来读取" || choice.Message.ReasoningContent != "先读文件" {
+		t.Errorf("content = %#v，reasoning_content = %q", choice.Message.Content, choice.Message.ReasoningContent)
+	}
+	calls := choice.Message.ToolCalls
+	if len(calls) != 2 {
+		t.Fatalf("tool_calls = %#v", calls)
+	}
+	if calls[0].ID != "toolu_1" || calls[0].Type != "function" || calls[0].Function.Name != "read" ||
+		calls[0].Function.Arguments != `{"path":"a.txt"}` {
+		t.Errorf("tool_calls[0] = %#v", calls[0])
+	}
+	if calls[1].Function.Arguments != "{}" {
+		t.Errorf("无输入的工具调用参数 = %q，应为 {}", calls[1].Function.Arguments)
+	}
+	if got.Usage.PromptTokens != 10 || got.Usage.CompletionTokens != 5 || got.Usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v", got.Usage)
+	}
+}
+
+func TestConvertStopReason(t *testing.T) {
+	tests := map[string]string{
+		"end_turn":      "stop",
+		"stop_sequence": "stop",
+		"tool_use":      "tool_calls",
+		"max_tokens":    "length",
+	}
+	for stopReason, want := range tests {
+		if got := ConvertStopReason(stopReason); got != want {
+			t.Errorf("ConvertStopReason(%q) = %q，应为 %q", stopReason, got, want)
+		}
+	}
+}
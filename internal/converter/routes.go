@@ -0,0 +1,187 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/tokenizer"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// Route 是请求的路由结果
+type Route struct {
+	Backend string             // 目标后端名称
+	Model   string             // 发送给后端的模型名称
+	Params  config.RouteParams // 覆盖的请求参数
+	Policy  config.ParamPolicy // 转换为上游格式之后应用的参数策略
+	Rule    string             // 匹配的规则（可读形式）
+	Source  string             // 规则的来源（配置文件位置、环境变量或内置默认值）
+}
+
+// modelTier 是内置的层级规则：名称包含层级名称时路由到对应的模型
+type modelTier struct {
+	name         string
+	env          string
+	configured   func(cfg *config.Config) string
+	defaultModel string
+}
+
+// modelTiers 是内置层级规则，在配置文件中的路由规则都不匹配时按顺序检查
+var modelTiers = []modelTier{
+	{"haiku", "ANTHROPIC_DEFAULT_HAIKU_MODEL", func(cfg *config.Config) string { return cfg.HaikuModel }, DefaultHaikuModel},
+	{"sonnet", "ANTHROPIC_DEFAULT_SONNET_MODEL", func(cfg *config.Config) string { return cfg.SonnetModel }, DefaultSonnetModel},
+	{"opus", "ANTHROPIC_DEFAULT_OPUS_MODEL", func(cfg *config.Config) string { return cfg.OpusModel }, DefaultOpusModel},
+}
+
+// RouteRequest 返回 Claude 请求的路由结果，带有请求内容条件（图片、工具、令牌数、系统提示）的规则按请求内容评估
+func RouteRequest(claudeReq *models.ClaudeRequest, cfg *config.Config) Route {
+	return resolveRoute(claudeReq.Model, &requestFeatures{req: claudeReq, inputTokens: -1}, cfg)
+}
+
+// ResolveRoute 返回只根据模型名称的路由结果（用于模型列表和 route 命令），带有请求内容条件的规则不匹配
+func ResolveRoute(model string, cfg *config.Config) Route {
+	return resolveRoute(model, nil, cfg)
+}
+
+// OverrideRoute 返回单个请求指定的路由（不应用路由规则）。
+// target 为 "后端:模型" 或 "模型"（默认后端）；冒号前不是已配置的后端名称时，整个值作为模型名称（如 Ollama 的 qwen3:8b）。
+// source 说明覆盖的来源（请求头或模型名称后缀）。
+func OverrideRoute(target, source string, cfg *config.Config) Route {
+	route := Route{
+		Backend: config.DefaultBackendName,
+		Model:   strings.TrimSpace(target),
+		Rule:    "单个请求覆盖（" + source + "）",
+	}
+	if backend, model, ok := strings.Cut(route.Model, ":"); ok {
+		if _, known := cfg.Backends[backend]; known {
+			route.Backend, route.Model = backend, strings.TrimSpace(model)
+		}
+	}
+	return route
+}
+
+// resolveRoute 依次检查配置文件中的路由规则（使用第一条匹配的规则），然后是内置的 haiku/sonnet/opus 层级规则；
+// 都不匹配时原样发送到默认后端
+func resolveRoute(model string, features config.RequestFeatures, cfg *config.Config) Route {
+	for i := range cfg.Routes {
+		rule := &cfg.Routes[i]
+		target, ok := rule.Resolve(model, features)
+		if !ok {
+			continue
+		}
+		backend := rule.Backend
+		if backend == "" {
+			backend = config.DefaultBackendName
+		}
+		return Route{
+			Backend: backend,
+			Model:   target,
+			Params:  rule.Params,
+			Policy:  rule.Policy,
+			Rule:    fmt.Sprintf("routes[%d]: %s", i, rule),
+			Source:  rule.Position.String(),
+		}
+	}
+
+	modelLower := strings.ToLower(model)
+	for _, tier := range modelTiers {
+		if !strings.Contains(modelLower, tier.name) {
+			continue
+		}
+		route := Route{
+			Backend: config.DefaultBackendName,
+			Model:   tier.defaultModel,
+			Rule:    fmt.Sprintf("内置层级规则: 名称包含 %q", tier.name),
+			Source:  "内置默认值",
+		}
+		if configured := tier.configured(cfg); configured != "" {
+			route.Model = configured
+			route.Source = tier.env
+			if pos, ok := cfg.Sources[tier.env]; ok {
+				route.Source = pos.String()
+			}
+		}
+		return route
+	}
+
+	// 非 Claude 模型直接传递（OpenAI、OpenRouter 等）
+	return Route{
+		Backend: config.DefaultBackendName,
+		Model:   model,
+		Rule:    "没有匹配的规则，原样传递",
+	}
+}
+
+// applyRouteParams 用路由规则的参数覆盖 Claude 请求中的对应参数
+func applyRouteParams(claudeReq *models.ClaudeRequest, params config.RouteParams) {
+	if params.Temperature != nil {
+		temperature := *params.Temperature
+		claudeReq.Temperature = &temperature
+	}
+	if params.TopP != nil {
+		topP := *params.TopP
+		claudeReq.TopP = &topP
+	}
+	if params.MaxTokens > 0 {
+		claudeReq.MaxTokens = params.MaxTokens
+	}
+}
+
+// requestFeatures 按需计算 Claude 请求的路由特征，令牌数只在规则需要时估算一次
+type requestFeatures struct {
+	req         *models.ClaudeRequest
+	inputTokens int // -1 表示尚未估算
+}
+
+// HasImages 如果消息（包括工具结果）中包含图片则返回 true
+func (f *requestFeatures) HasImages() bool {
+	for _, msg := range f.req.Messages {
+		if containsImage(msg.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTools 如果请求定义了工具则返回 true
+func (f *requestFeatures) HasTools() bool {
+	return len(f.req.Tools) > 0
+}
+
+// InputTokens 返回估算的输入令牌数
+func (f *requestFeatures) InputTokens() int {
+	if f.inputTokens < 0 {
+		f.inputTokens = tokenizer.EstimateClaudeRequest(f.req)
+	}
+	return f.inputTokens
+}
+
+// SystemPrompt 返回系统提示文本
+func (f *requestFeatures) SystemPrompt() string {
+	return extractSystemText(f.req.System)
+}
+
+// HasImages 如果 Claude 请求的消息（包括工具结果）中包含图片则返回 true
+func HasImages(claudeReq *models.ClaudeRequest) bool {
+	return (&requestFeatures{req: claudeReq}).HasImages()
+}
+
+// containsImage 判断内容块数组（包括嵌套的工具结果内容）中是否有图片
+func containsImage(content interface{}) bool {
+	blocks, ok := content.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, block := range blocks {
+		blockMap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if blockMap["type"] == constants.ContentTypeImage || containsImage(blockMap["content"]) {
+			return true
+		}
+	}
+	return false
+}
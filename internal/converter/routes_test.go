@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+// loadTestConfig 在隔离的环境中从配置文件加载配置（路由规则只能通过配置文件编译）
+func loadTestConfig(t *testing.T, data string) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	for _, name := range []string{
+		"OPENAI_BASE_URL", "OPENAI_API_KEY", "OPENAI_PROVIDER", "OPENAI_PROTOCOL",
+		"ANTHROPIC_DEFAULT_OPUS_MODEL", "ANTHROPIC_DEFAULT_SONNET_MODEL", "ANTHROPIC_DEFAULT_HAIKU_MODEL",
+	} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(dir, "proxy.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	t.Setenv(config.ConfigFileEnv, path)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	return cfg
+}
+
+func TestResolveRoute(t *testing.T) {
+	cfg := loadTestConfig(t, `models:
+  sonnet: configured-sonnet
+backends:
+  default:
+    base_url: http://localhost:8000/v1
+  local:
+    base_url: http://localhost:11434
+routes:
+  - when: {tools: false}
+    model: helper
+  - exact: fast
+    model: flash
+  - glob: claude-sonnet-4-5*
+    backend: local
+    model: qwen3-coder
+  - regex: claude-(opus|sonnet)-4-(\d).*
+    model: generic-${1}-${2}
+  - glob: "fast*"
+    model: never
+`)
+
+	tests := []struct {
+		name        string
+		model       string
+		wantBackend string
+		wantModel   string
+		wantRule    string
+		wantSource  string
+	}{
+		{"exact 规则", "fast", "default", "flash", `routes[1]: exact "fast" → flash`, "proxy.yaml:11:5"},
+		{"第一条匹配的规则优先（glob 在 regex 之前）", "claude-sonnet-4-5-20250929", "local", "qwen3-coder",
+			`routes[2]: glob "claude-sonnet-4-5*" → local:qwen3-coder`, "proxy.yaml:13:5"},
+		{"regex 规则", "claude-opus-4-1-20250805", "default", "generic-opus-1",
+			`routes[3]: regex "claude-(opus|sonnet)-4-(\\d).*" → generic-${1}-${2}`, "proxy.yaml:16:5"},
+		{"路由规则优先于层级规则", "claude-sonnet-4-20250514", "default", "generic-sonnet-2", "", ""},
+		{"没有匹配的规则时使用层级规则", "claude-3-5-sonnet", "default", "configured-sonnet",
+			`内置层级规则: 名称包含 "sonnet"`, "proxy.yaml:2:11"},
+		{"未配置的层级使用内置默认值", "claude-3-5-haiku", "default", DefaultHaikuModel,
+			`内置层级规则: 名称包含 "haiku"`, "内置默认值"},
+		{"其他名称原样传递", "gpt-4o", "default", "gpt-4o", "没有匹配的规则，原样传递", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := ResolveRoute(tt.model, cfg)
+			if route.Backend != tt.wantBackend || route.Model != tt.wantModel {
+				t.Errorf("ResolveRoute(%q) = %s:%s，应为 %s:%s（规则 %s）",
+					tt.model, route.Backend, route.Model, tt.wantBackend, tt.wantModel, route.Rule)
+			}
+			if tt.wantRule != "" && route.Rule != tt.wantRule {
+				t.Errorf("规则 = %s，应为 %s", route.Rule, tt.wantRule)
+			}
+			if tt.wantSource != "" && filepath.Base(route.Source) != tt.wantSource {
+				t.Errorf("规则来源 = %s，应为 %s", route.Source, tt.wantSource)
+			}
+		})
+	}
+}
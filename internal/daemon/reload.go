@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+)
+
+// reloadWaitTimeout 是 reload 命令等待守护进程报告结果的最长时间
+const reloadWaitTimeout = 10 * time.Second
+
+// ReloadResult 是守护进程最近一次重新加载配置的结果，写入临时目录供 reload 命令读取
+type ReloadResult struct {
+	Time    time.Time `json:"time"`              // 重新加载时间
+	Trigger string    `json:"trigger"`           // 触发原因（SIGHUP 或变化的文件）
+	Changes []string  `json:"changes,omitempty"` // 配置变化（已应用时）
+	Error   string    `json:"error,omitempty"`   // 加载或验证失败的原因（此时继续使用旧配置）
+}
+
+// reloadResultFile 返回重新加载结果文件的路径
+func reloadResultFile() string {
+	return filepath.Join(GetTempDir(), "claude-code-proxy.reload.json")
+}
+
+// CapabilityCacheFile 返回学习到的模型能力缓存文件的路径
+func CapabilityCacheFile() string {
+	return filepath.Join(GetTempDir(), "claude-code-proxy.capabilities.json")
+}
+
+// WriteReloadResult 记录重新加载的结果
+func WriteReloadResult(result *ReloadResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免 reload 命令读到不完整的内容
+	tmp := reloadResultFile() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, reloadResultFile())
+}
+
+// readReloadResult 读取最近一次重新加载的结果，文件不存在时返回 nil
+func readReloadResult() *ReloadResult {
+	data, err := os.ReadFile(reloadResultFile())
+	if err != nil {
+		return nil
+	}
+	var result ReloadResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return &result
+}
+
+// Reload 向运行中的守护进程发送 SIGHUP，等待其重新加载配置并打印配置变化
+func Reload() {
+	if !IsRunning() {
+		fmt.Println("代理未在运行")
+		return
+	}
+
+	pid, err := readPID()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 PID 失败: %v\n", err)
+		return
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "查找进程失败: %v\n", err)
+		return
+	}
+
+	sentAt := time.Now()
+	if err := process.Signal(syscall.SIGHUP); err != nil {
+		fmt.Fprintf(os.Stderr, "发送 SIGHUP 失败: %v（守护进程会自动检测配置文件的变化）\n", err)
+		return
+	}
+
+	deadline := time.Now().Add(reloadWaitTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		result := readReloadResult()
+		if result == nil || result.Time.Before(sentAt) {
+			continue
+		}
+
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "❌ 重新加载失败，继续使用当前配置:\n%s\n", result.Error)
+			return
+		}
+		if len(result.Changes) == 0 {
+			fmt.Println("✅ 配置已重新加载，没有变化")
+			return
+		}
+		fmt.Printf("✅ 配置已重新加载，%d 项变化:\n", len(result.Changes))
+		for _, change := range result.Changes {
+			fmt.Printf("   %s\n", change)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "等待守护进程重新加载超时（%v），请查看日志\n", reloadWaitTimeout)
+}
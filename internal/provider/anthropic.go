@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// AnthropicProvider 实现 Anthropic Messages API 兼容的提供商。
+// 适用于原生支持 /v1/messages 的网关（OpenRouter 的 Anthropic 端点、DeepSeek/Kimi/GLM 的 Anthropic 兼容端点等），
+// 基础 URL 与 Claude Code 的 ANTHROPIC_BASE_URL 相同（不含 /v1/messages）。
+type AnthropicProvider struct {
+	*BaseProvider
+}
+
+// NewAnthropicProvider 创建 Anthropic 原生提供商
+func NewAnthropicProvider(cfg *config.Config, backend *config.Backend) *AnthropicProvider {
+	return &AnthropicProvider{
+		BaseProvider: NewBaseProvider(cfg, backend),
+	}
+}
+
+// Name 返回提供商名称
+func (p *AnthropicProvider) Name() string {
+	return "Anthropic"
+}
+
+// Type 返回提供商类型
+func (p *AnthropicProvider) Type() config.ProviderType {
+	return config.ProviderAnthropic
+}
+
+// GetEndpoint 返回 /v1/messages 端点 URL（基础 URL 已以 /v1 结尾时不重复添加）
+func (p *AnthropicProvider) GetEndpoint() string {
+	baseURL := strings.TrimSuffix(p.GetBaseURL(), "/")
+	return strings.TrimSuffix(baseURL, "/v1") + "/v1/messages"
+}
+
+// RequestEndpoint 返回请求的端点 URL（流式与非流式相同）
+func (p *AnthropicProvider) RequestEndpoint(req *models.OpenAIRequest) string {
+	return p.GetEndpoint()
+}
+
+// AddHeaders 添加 anthropic-version 和认证头（x-api-key 或 Authorization: Bearer）
+func (p *AnthropicProvider) AddHeaders(httpReq *http.Request) {
+	settings := p.Backend().Anthropic
+	if settings.AuthHeader == config.AnthropicAuthBearer {
+		httpReq.Header.Set("Authorization", "Bearer "+p.GetAPIKey())
+	} else {
+		httpReq.Header.Set("x-api-key", p.GetAPIKey())
+	}
+	httpReq.Header.Set("anthropic-version", settings.Version)
+	httpReq.Header.Set("Content-Type", "application/json")
+}
+
+// RequiresAuth 返回是否需要认证
+func (p *AnthropicProvider) RequiresAuth() bool {
+	return true
+}
+
+// HandleError 处理 Claude 格式的错误（{"type": "error", "error": {"type", "message"}}），
+// 错误类型原样保留；其他格式按 HTTP 状态码推断。
+func (p *AnthropicProvider) HandleError(statusCode int, body []byte) *errors.ProxyError {
+	var errorBody struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorBody); err == nil && errorBody.Error.Type != "" {
+		pe := errors.FromClaudeError(errorBody.Error.Type, errorBody.Error.Message)
+		pe.StatusCode = statusCode
+		return pe.WithProvider(p.Name())
+	}
+	return errors.FromHTTPStatus(statusCode, string(body)).WithProvider(p.Name())
+}
+
+// SupportsReasoning 返回是否支持推理（思考块原样转发）
+func (p *AnthropicProvider) SupportsReasoning() bool {
+	return true
+}
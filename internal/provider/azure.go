@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// AzureProvider 实现 Azure OpenAI 提供商。
+// 基础 URL 为资源端点（如 https://my-resource.openai.azure.com），
+// 请求发送到 /openai/deployments/{部署}/chat/completions?api-version=...，部署名称由模型路由结果映射得到。
+type AzureProvider struct {
+	*BaseProvider
+}
+
+// NewAzureProvider 创建 Azure OpenAI 提供商
+func NewAzureProvider(cfg *config.Config, backend *config.Backend) *AzureProvider {
+	return &AzureProvider{
+		BaseProvider: NewBaseProvider(cfg, backend),
+	}
+}
+
+// Name 返回提供商名称
+func (p *AzureProvider) Name() string {
+	return "Azure OpenAI"
+}
+
+// Type 返回提供商类型
+func (p *AzureProvider) Type() config.ProviderType {
+	return config.ProviderAzure
+}
+
+// GetEndpoint 返回部署无关的资源端点（具体部署的 URL 见 RequestEndpoint）
+func (p *AzureProvider) GetEndpoint() string {
+	return p.resourceURL() + "/openai/deployments"
+}
+
+// RequestEndpoint 返回请求模型对应部署的聊天完成端点 URL
+func (p *AzureProvider) RequestEndpoint(req *models.OpenAIRequest) string {
+	deployment := p.Backend().AzureDeployment(req.Model)
+	return fmt.Sprintf("%s/openai/deployments/%s%s?api-version=%s",
+		p.resourceURL(), url.PathEscape(deployment), constants.EndpointChatCompletions,
+		url.QueryEscape(p.Backend().Azure.APIVersion))
+}
+
+// resourceURL 返回去掉尾部斜杠和 /openai 后缀的资源端点
+func (p *AzureProvider) resourceURL() string {
+	return strings.TrimSuffix(strings.TrimSuffix(p.GetBaseURL(), "/"), "/openai")
+}
+
+// AddHeaders 添加 Azure 特定的 HTTP 头（使用 api-key 认证）
+func (p *AzureProvider) AddHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("api-key", p.GetAPIKey())
+	httpReq.Header.Set("Content-Type", "application/json")
+}
+
+// RequiresAuth 返回是否需要认证
+func (p *AzureProvider) RequiresAuth() bool {
+	return true
+}
+
+// HandleError 处理 Azure OpenAI 返回的错误。
+// 除 OpenAI 格式外还处理 Azure 特有的错误：内容过滤（code 为 content_filter，
+// innererror 中给出触发的类别）、部署不存在，以及网关返回的 {"statusCode", "message"} 格式。
+func (p *AzureProvider) HandleError(statusCode int, body []byte) *errors.ProxyError {
+	var errorBody map[string]interface{}
+	if err := json.Unmarshal(body, &errorBody); err != nil {
+		return errors.FromHTTPStatus(statusCode, string(body)).WithProvider(p.Name())
+	}
+
+	errObj, ok := errorBody["error"].(map[string]interface{})
+	if !ok {
+		// 网关层错误（例如 401 密钥无效）：{"statusCode": 401, "message": "..."}
+		if message, ok := errorBody["message"].(string); ok {
+			return errors.FromHTTPStatus(statusCode, message).WithProvider(p.Name())
+		}
+		return errors.FromOpenAIError(statusCode, errorBody).WithProvider(p.Name())
+	}
+
+	message, _ := errObj["message"].(string)
+	code, _ := errObj["code"].(string)
+	innerError, _ := errObj["innererror"].(map[string]interface{})
+	innerCode, _ := innerError["code"].(string)
+
+	switch {
+	case code == "content_filter" || innerCode == "ResponsibleAIPolicyViolation":
+		if categories := azureFilteredCategories(innerError); len(categories) > 0 {
+			message = fmt.Sprintf("Azure 内容过滤拦截了请求（类别: %s）: %s", strings.Join(categories, ", "), message)
+		}
+		return errors.NewInvalidRequestError(message).WithProvider(p.Name())
+
+	case code == "DeploymentNotFound":
+		return errors.NewNotFoundError(message + "（请检查 AZURE_OPENAI_DEPLOYMENTS 中的部署映射）").WithProvider(p.Name())
+	}
+
+	return errors.FromOpenAIError(statusCode, errorBody).WithProvider(p.Name())
+}
+
+// azureFilteredCategories 返回内容过滤结果中被拦截的类别（按名称排序）
+func azureFilteredCategories(innerError map[string]interface{}) []string {
+	results, _ := innerError["content_filter_result"].(map[string]interface{})
+	var categories []string
+	for category, result := range results {
+		if resultMap, ok := result.(map[string]interface{}); ok {
+			if filtered, _ := resultMap["filtered"].(bool); filtered {
+				categories = append(categories, category)
+			}
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// SupportsReasoning 返回是否支持推理
+func (p *AzureProvider) SupportsReasoning() bool {
+	return true
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// bedrockService 是 Bedrock Runtime 的 SigV4 服务名称
+const bedrockService = "bedrock"
+
+// BedrockProvider 实现 AWS Bedrock Converse API 提供商。
+// 基础 URL 为 Bedrock Runtime 端点（如 https://bedrock-runtime.us-east-1.amazonaws.com），
+// 请求使用 SigV4 签名，模型 ID（或推理配置文件 ID）是路径的一部分。
+type BedrockProvider struct {
+	*BaseProvider
+}
+
+// NewBedrockProvider 创建 Bedrock 提供商
+func NewBedrockProvider(cfg *config.Config, backend *config.Backend) *BedrockProvider {
+	return &BedrockProvider{
+		BaseProvider: NewBaseProvider(cfg, backend),
+	}
+}
+
+// Name 返回提供商名称
+func (p *BedrockProvider) Name() string {
+	return "Bedrock"
+}
+
+// Type 返回提供商类型
+func (p *BedrockProvider) Type() config.ProviderType {
+	return config.ProviderBedrock
+}
+
+// GetEndpoint 返回模型无关的端点前缀（具体模型的 URL 见 RequestEndpoint）
+func (p *BedrockProvider) GetEndpoint() string {
+	return strings.TrimSuffix(p.GetBaseURL(), "/") + "/model"
+}
+
+// RequestEndpoint 返回请求的端点 URL：流式使用 converse-stream。
+// 模型 ID 中的 : 与 AWS SDK 一样转义为 %3A。
+func (p *BedrockProvider) RequestEndpoint(req *models.OpenAIRequest) string {
+	action := "/converse"
+	if req.Stream != nil && *req.Stream {
+		action = "/converse-stream"
+	}
+	modelID := strings.ReplaceAll(url.PathEscape(req.Model), ":", "%3A")
+	return p.GetEndpoint() + "/" + modelID + action
+}
+
+// AddHeaders 添加 Content-Type 并使用 AWS 凭证进行 SigV4 签名。
+// 凭证不可用时无法签名，打印原因后发送未签名的请求（上游返回 403）。
+func (p *BedrockProvider) AddHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	settings := p.Backend().Bedrock
+	creds, err := settings.Credentials()
+	if err != nil {
+		fmt.Printf("⚠️  Bedrock 请求签名失败: %v\n", err)
+		return
+	}
+
+	var body []byte
+	if httpReq.GetBody != nil {
+		if reader, err := httpReq.GetBody(); err == nil {
+			body, _ = io.ReadAll(reader)
+		}
+	}
+	signSigV4(httpReq, body, creds, settings.Region, bedrockService, time.Now())
+}
+
+// RequiresAuth 返回是否需要认证（使用 AWS 凭证而不是 API 密钥）
+func (p *BedrockProvider) RequiresAuth() bool {
+	return true
+}
+
+// HandleError 处理 Bedrock 返回的错误（{"message": "..."}，错误类型在 x-amzn-ErrorType 头中）。
+// 424 表示模型本身出错（ModelErrorException），按上游服务器错误处理。
+func (p *BedrockProvider) HandleError(statusCode int, body []byte) *errors.ProxyError {
+	var errorBody map[string]interface{}
+	if err := json.Unmarshal(body, &errorBody); err != nil {
+		return errors.FromHTTPStatus(statusCode, string(body)).WithProvider(p.Name())
+	}
+
+	message, _ := errorBody["message"].(string)
+	if message == "" {
+		message, _ = errorBody["Message"].(string)
+	}
+	if message == "" {
+		return errors.FromOpenAIError(statusCode, errorBody).WithProvider(p.Name())
+	}
+	if statusCode == http.StatusFailedDependency {
+		return errors.NewAPIError(message).WithProvider(p.Name())
+	}
+	return errors.FromHTTPStatus(statusCode, message).WithProvider(p.Name())
+}
+
+// SupportsReasoning 返回是否支持推理（Claude 模型的扩展思考）
+func (p *BedrockProvider) SupportsReasoning() bool {
+	return true
+}
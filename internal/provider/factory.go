@@ -4,23 +4,43 @@ import (
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
 )
 
-// New 根据配置创建适当的提供商实例
+// New 根据配置为默认后端创建适当的提供商实例
 // 这是创建提供商的推荐方式
 func New(cfg *config.Config) Provider {
-	return FromType(cfg.DetectProvider(), cfg)
+	return ForBackend(cfg, cfg.DefaultBackend())
 }
 
-// FromType 根据提供商类型创建提供商实例
-func FromType(providerType config.ProviderType, cfg *config.Config) Provider {
+// ForBackend 为指定后端创建提供商实例。
+// 使用 Gemini、Ollama、Bedrock 或 Anthropic 原生协议的后端始终使用对应的提供商，其他后端的提供商类型根据 URL 检测。
+func ForBackend(cfg *config.Config, backend *config.Backend) Provider {
+	switch backend.Protocol {
+	case config.ProtocolGemini:
+		return NewGeminiProvider(cfg, backend)
+	case config.ProtocolOllama:
+		return NewOllamaProvider(cfg, backend)
+	case config.ProtocolBedrock:
+		return NewBedrockProvider(cfg, backend)
+	case config.ProtocolAnthropic:
+		return NewAnthropicProvider(cfg, backend)
+	}
+	return FromType(backend.DetectProvider(), cfg, backend)
+}
+
+// FromType 根据提供商类型为指定后端创建提供商实例
+func FromType(providerType config.ProviderType, cfg *config.Config, backend *config.Backend) Provider {
 	switch providerType {
 	case config.ProviderOpenRouter:
-		return NewOpenRouterProvider(cfg)
+		return NewOpenRouterProvider(cfg, backend)
 	case config.ProviderOpenAI:
-		return NewOpenAIProvider(cfg)
+		return NewOpenAIProvider(cfg, backend)
 	case config.ProviderOllama:
-		return NewOllamaProvider(cfg)
+		return NewOllamaProvider(cfg, backend)
+	case config.ProviderGemini:
+		return NewGeminiProvider(cfg, backend)
+	case config.ProviderAzure:
+		return NewAzureProvider(cfg, backend)
 	default:
-		return NewGenericProvider(cfg)
+		return NewGenericProvider(cfg, backend)
 	}
 }
 
@@ -44,7 +64,7 @@ func (r *Registry) Get(providerType config.ProviderType) Provider {
 		return p
 	}
 
-	p := FromType(providerType, r.cfg)
+	p := FromType(providerType, r.cfg, r.cfg.DefaultBackend())
 	r.providers[providerType] = p
 	return p
 }
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// GeminiProvider 实现 Gemini 原生 API 提供商（generateContent/streamGenerateContent）
+// 基础 URL 通常为 https://generativelanguage.googleapis.com/v1beta
+type GeminiProvider struct {
+	*BaseProvider
+}
+
+// NewGeminiProvider 创建 Gemini 原生提供商
+func NewGeminiProvider(cfg *config.Config, backend *config.Backend) *GeminiProvider {
+	return &GeminiProvider{
+		BaseProvider: NewBaseProvider(cfg, backend),
+	}
+}
+
+// Name 返回提供商名称
+func (p *GeminiProvider) Name() string {
+	return "Gemini"
+}
+
+// Type 返回提供商类型
+func (p *GeminiProvider) Type() config.ProviderType {
+	return config.ProviderGemini
+}
+
+// AddHeaders 添加 Gemini 特定的 HTTP 头（使用 x-goog-api-key 认证）
+func (p *GeminiProvider) AddHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("x-goog-api-key", p.GetAPIKey())
+	httpReq.Header.Set("Content-Type", "application/json")
+}
+
+// RequiresAuth 返回是否需要认证
+func (p *GeminiProvider) RequiresAuth() bool {
+	return true
+}
+
+// RequestEndpoint 返回请求的端点 URL：模型名称是路径的一部分，流式使用 SSE 格式
+func (p *GeminiProvider) RequestEndpoint(req *models.OpenAIRequest) string {
+	action := ":generateContent"
+	if req.Stream != nil && *req.Stream {
+		action = ":streamGenerateContent?alt=sse"
+	}
+	return strings.TrimSuffix(p.GetBaseURL(), "/") + "/models/" + geminiModelName(req.Model) + action
+}
+
+// HandleError 处理 Gemini 返回的错误（{"error": {"code", "message", "status"}}）
+func (p *GeminiProvider) HandleError(statusCode int, body []byte) *errors.ProxyError {
+	var errorBody struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorBody); err == nil && errorBody.Error.Message != "" {
+		return errors.FromHTTPStatus(statusCode, errorBody.Error.Message).WithProvider(p.Name())
+	}
+	return errors.FromHTTPStatus(statusCode, string(body)).WithProvider(p.Name())
+}
+
+// SupportsReasoning 返回是否支持推理
+func (p *GeminiProvider) SupportsReasoning() bool {
+	return true
+}
+
+// geminiModelName 去掉 OpenRouter 风格的 "google/" 前缀和 API 资源名的 "models/" 前缀，
+// 使默认的 google/gemini-* 映射可以直接用于原生 API
+func geminiModelName(model string) string {
+	model = strings.TrimPrefix(model, "google/")
+	return strings.TrimPrefix(model, "models/")
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
-	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
 )
 
 // GenericProvider 实现通用/未知提供商
@@ -16,9 +15,9 @@ type GenericProvider struct {
 }
 
 // NewGenericProvider 创建通用提供商
-func NewGenericProvider(cfg *config.Config) *GenericProvider {
+func NewGenericProvider(cfg *config.Config, backend *config.Backend) *GenericProvider {
 	return &GenericProvider{
-		BaseProvider: NewBaseProvider(cfg),
+		BaseProvider: NewBaseProvider(cfg, backend),
 	}
 }
 
@@ -32,29 +31,20 @@ func (p *GenericProvider) Type() config.ProviderType {
 	return config.ProviderUnknown
 }
 
-// PrepareRequest 准备通用格式的请求
-func (p *GenericProvider) PrepareRequest(req *models.OpenAIRequest) error {
-	// 通用提供商不添加任何特定参数
-	// 依赖自适应能力检测机制
-	return nil
-}
-
 // AddHeaders 添加通用 HTTP 头
 func (p *GenericProvider) AddHeaders(httpReq *http.Request) {
-	cfg := p.Config()
-
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 如果配置了 API 密钥且不是本地服务，添加认证头
-	if cfg.OpenAIAPIKey != "" && !cfg.IsLocalhost() {
-		httpReq.Header.Set("Authorization", "Bearer "+cfg.OpenAIAPIKey)
+	if p.GetAPIKey() != "" && !p.Backend().IsLocalhost() {
+		httpReq.Header.Set("Authorization", "Bearer "+p.GetAPIKey())
 	}
 }
 
 // RequiresAuth 返回是否需要认证
 func (p *GenericProvider) RequiresAuth() bool {
 	// 根据是否是本地服务决定
-	return !p.Config().IsLocalhost()
+	return !p.Backend().IsLocalhost()
 }
 
 // HandleError 处理通用错误
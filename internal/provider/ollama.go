@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
@@ -15,9 +16,9 @@ type OllamaProvider struct {
 }
 
 // NewOllamaProvider 创建 Ollama 提供商
-func NewOllamaProvider(cfg *config.Config) *OllamaProvider {
+func NewOllamaProvider(cfg *config.Config, backend *config.Backend) *OllamaProvider {
 	return &OllamaProvider{
-		BaseProvider: NewBaseProvider(cfg),
+		BaseProvider: NewBaseProvider(cfg, backend),
 	}
 }
 
@@ -31,15 +32,22 @@ func (p *OllamaProvider) Type() config.ProviderType {
 	return config.ProviderOllama
 }
 
-// PrepareRequest 准备 Ollama 格式的请求
-func (p *OllamaProvider) PrepareRequest(req *models.OpenAIRequest) error {
-	// Ollama 特定：存在工具时设置 tool_choice 为 required
-	// 这强制模型使用工具而不是忽略它们
-	if len(req.Tools) > 0 && req.ToolChoice == nil {
-		req.ToolChoice = "required"
+// usesNativeAPI 如果后端使用 Ollama 原生 /api/chat 协议则返回 true
+func (p *OllamaProvider) usesNativeAPI() bool {
+	return p.Backend().Protocol == config.ProtocolOllama
+}
+
+// GetEndpoint 返回完整的 API 端点 URL（原生协议使用 /api/chat）
+func (p *OllamaProvider) GetEndpoint() string {
+	if p.usesNativeAPI() {
+		return p.Backend().OllamaAPIURL(constants.EndpointOllamaChat)
 	}
+	return p.BaseProvider.GetEndpoint()
+}
 
-	return nil
+// RequestEndpoint 返回请求的端点 URL
+func (p *OllamaProvider) RequestEndpoint(req *models.OpenAIRequest) string {
+	return p.GetEndpoint()
 }
 
 // AddHeaders 添加 Ollama 特定的 HTTP 头
@@ -61,7 +69,7 @@ func (p *OllamaProvider) HandleError(statusCode int, body []byte) *errors.ProxyE
 	if err := json.Unmarshal(body, &errorBody); err == nil {
 		// Ollama 可能有自己的错误格式
 		if errMsg, ok := errorBody["error"].(string); ok {
-			return errors.NewAPIError(errMsg).WithProvider(p.Name())
+			return errors.FromHTTPStatus(statusCode, errMsg).WithProvider(p.Name())
 		}
 		return errors.FromOpenAIError(statusCode, errorBody).WithProvider(p.Name())
 	}
@@ -70,16 +78,21 @@ func (p *OllamaProvider) HandleError(statusCode int, body []byte) *errors.ProxyE
 
 // SupportsReasoning 返回是否支持推理
 func (p *OllamaProvider) SupportsReasoning() bool {
-	// Ollama 本地模型通常不支持推理功能
-	return false
+	// 原生协议通过 think 参数和 thinking 字段支持思考模型；OpenAI 兼容层不返回推理内容
+	return p.usesNativeAPI()
 }
 
 // GetTimeout 返回请求超时时间（Ollama 本地模型可能较慢）
 func (p *OllamaProvider) GetTimeout() int {
-	return 180 // 3 分钟
+	return secondsOr(p.Backend().RequestTimeout, 180) // 默认 3 分钟
 }
 
 // GetStreamTimeout 返回流式请求超时时间
 func (p *OllamaProvider) GetStreamTimeout() int {
-	return 600 // 10 分钟
+	return secondsOr(p.Backend().StreamTimeout, 600) // 默认 10 分钟
+}
+
+// GetIdleTimeout 返回流式空闲超时时间（首次加载模型到显存可能需要较长时间）
+func (p *OllamaProvider) GetIdleTimeout() int {
+	return secondsOr(p.Backend().IdleTimeout, 300) // 默认 5 分钟
 }
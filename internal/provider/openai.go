@@ -6,7 +6,6 @@ import (
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
-	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
 )
 
 // OpenAIProvider 实现 OpenAI Direct 提供商
@@ -15,9 +14,9 @@ type OpenAIProvider struct {
 }
 
 // NewOpenAIProvider 创建 OpenAI Direct 提供商
-func NewOpenAIProvider(cfg *config.Config) *OpenAIProvider {
+func NewOpenAIProvider(cfg *config.Config, backend *config.Backend) *OpenAIProvider {
 	return &OpenAIProvider{
-		BaseProvider: NewBaseProvider(cfg),
+		BaseProvider: NewBaseProvider(cfg, backend),
 	}
 }
 
@@ -31,22 +30,9 @@ func (p *OpenAIProvider) Type() config.ProviderType {
 	return config.ProviderOpenAI
 }
 
-// PrepareRequest 准备 OpenAI 格式的请求
-func (p *OpenAIProvider) PrepareRequest(req *models.OpenAIRequest) error {
-	// OpenAI 特定：为推理模型添加 reasoning_effort 参数
-	// GPT-5、o1、o3 等模型支持此参数
-	if req.ReasoningEffort == "" {
-		req.ReasoningEffort = "medium"
-	}
-
-	return nil
-}
-
 // AddHeaders 添加 OpenAI 特定的 HTTP 头
 func (p *OpenAIProvider) AddHeaders(httpReq *http.Request) {
-	cfg := p.Config()
-
-	httpReq.Header.Set("Authorization", "Bearer "+cfg.OpenAIAPIKey)
+	httpReq.Header.Set("Authorization", "Bearer "+p.GetAPIKey())
 	httpReq.Header.Set("Content-Type", "application/json")
 }
 
@@ -6,7 +6,6 @@ import (
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
-	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
 )
 
 // OpenRouterProvider 实现 OpenRouter 提供商
@@ -15,9 +14,9 @@ type OpenRouterProvider struct {
 }
 
 // NewOpenRouterProvider 创建 OpenRouter 提供商
-func NewOpenRouterProvider(cfg *config.Config) *OpenRouterProvider {
+func NewOpenRouterProvider(cfg *config.Config, backend *config.Backend) *OpenRouterProvider {
 	return &OpenRouterProvider{
-		BaseProvider: NewBaseProvider(cfg),
+		BaseProvider: NewBaseProvider(cfg, backend),
 	}
 }
 
@@ -31,31 +30,12 @@ func (p *OpenRouterProvider) Type() config.ProviderType {
 	return config.ProviderOpenRouter
 }
 
-// PrepareRequest 准备 OpenRouter 格式的请求
-func (p *OpenRouterProvider) PrepareRequest(req *models.OpenAIRequest) error {
-	// OpenRouter 特定：添加 reasoning 参数以支持思考功能
-	if req.Reasoning == nil {
-		req.Reasoning = map[string]interface{}{
-			"enabled": true,
-		}
-	}
-
-	// OpenRouter 特定：添加 usage 参数以获取 token 统计
-	if req.Usage == nil {
-		req.Usage = map[string]interface{}{
-			"include": true,
-		}
-	}
-
-	return nil
-}
-
 // AddHeaders 添加 OpenRouter 特定的 HTTP 头
 func (p *OpenRouterProvider) AddHeaders(httpReq *http.Request) {
 	cfg := p.Config()
 
 	// 设置认证头
-	httpReq.Header.Set("Authorization", "Bearer "+cfg.OpenAIAPIKey)
+	httpReq.Header.Set("Authorization", "Bearer "+p.GetAPIKey())
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// OpenRouter 特定头
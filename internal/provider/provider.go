@@ -4,8 +4,10 @@ package provider
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
 )
@@ -18,10 +20,6 @@ type Provider interface {
 	// Type 返回提供商类型
 	Type() config.ProviderType
 
-	// PrepareRequest 准备 OpenAI 格式的请求
-	// 根据提供商特性添加特定参数（如 reasoning、tool_choice 等）
-	PrepareRequest(req *models.OpenAIRequest) error
-
 	// AddHeaders 添加提供商特定的 HTTP 头
 	AddHeaders(httpReq *http.Request)
 
@@ -37,6 +35,10 @@ type Provider interface {
 	// GetEndpoint 返回完整的 API 端点 URL
 	GetEndpoint() string
 
+	// RequestEndpoint 返回指定请求的端点 URL
+	// 大多数协议与 GetEndpoint 相同；Gemini 的端点取决于模型和是否流式
+	RequestEndpoint(req *models.OpenAIRequest) string
+
 	// HandleError 处理提供商返回的错误
 	HandleError(statusCode int, body []byte) *errors.ProxyError
 
@@ -54,36 +56,55 @@ type Provider interface {
 
 	// GetStreamTimeout 返回流式请求超时时间（秒）
 	GetStreamTimeout() int
+
+	// GetIdleTimeout 返回流式空闲超时时间（秒）
+	// 超过该时间未从上游收到任何字节则视为停滞
+	GetIdleTimeout() int
+
+	// GetPingInterval 返回上游静默期间向客户端发送 ping 的间隔（秒）
+	GetPingInterval() int
+
+	// Backend 返回提供商所连接的后端配置
+	Backend() *config.Backend
 }
 
 // BaseProvider 提供通用的基础实现
 type BaseProvider struct {
-	cfg *config.Config
+	cfg     *config.Config
+	backend *config.Backend
 }
 
 // NewBaseProvider 创建基础提供商
-func NewBaseProvider(cfg *config.Config) *BaseProvider {
-	return &BaseProvider{cfg: cfg}
+func NewBaseProvider(cfg *config.Config, backend *config.Backend) *BaseProvider {
+	return &BaseProvider{cfg: cfg, backend: backend}
 }
 
 // GetAPIKey 返回 API 密钥
 func (p *BaseProvider) GetAPIKey() string {
-	return p.cfg.OpenAIAPIKey
+	return p.backend.APIKey
 }
 
 // GetBaseURL 返回基础 URL
 func (p *BaseProvider) GetBaseURL() string {
-	return p.cfg.OpenAIBaseURL
+	return p.backend.BaseURL
 }
 
-// GetEndpoint 返回完整的 API 端点 URL
+// GetEndpoint 返回完整的 API 端点 URL（根据后端协议选择聊天完成或 Responses 端点）
 func (p *BaseProvider) GetEndpoint() string {
-	return p.cfg.OpenAIBaseURL + "/chat/completions"
+	if p.backend.UsesResponsesAPI() {
+		return p.backend.BaseURL + constants.EndpointResponses
+	}
+	return p.backend.BaseURL + constants.EndpointChatCompletions
+}
+
+// RequestEndpoint 默认返回 GetEndpoint
+func (p *BaseProvider) RequestEndpoint(req *models.OpenAIRequest) string {
+	return p.GetEndpoint()
 }
 
-// SupportsStreaming 默认支持流式传输
+// SupportsStreaming 返回后端是否支持流式传输（后端配置 streaming: false 时不支持；全局或按模型禁用见 Config.IsStreamingDisabled）
 func (p *BaseProvider) SupportsStreaming() bool {
-	return true
+	return !p.backend.DisableStreaming
 }
 
 // SupportsToolCalls 默认支持工具调用
@@ -96,17 +117,43 @@ func (p *BaseProvider) SupportsReasoning() bool {
 	return false
 }
 
-// GetTimeout 返回默认请求超时时间（90秒）
+// GetTimeout 返回请求超时时间（默认 90 秒）
 func (p *BaseProvider) GetTimeout() int {
-	return 90
+	return secondsOr(p.backend.RequestTimeout, 90)
 }
 
-// GetStreamTimeout 返回默认流式请求超时时间（300秒）
+// GetStreamTimeout 返回流式请求超时时间（默认 300 秒）
 func (p *BaseProvider) GetStreamTimeout() int {
-	return 300
+	return secondsOr(p.backend.StreamTimeout, 300)
+}
+
+// GetIdleTimeout 返回流式空闲超时时间（默认 120 秒）
+func (p *BaseProvider) GetIdleTimeout() int {
+	return secondsOr(p.backend.IdleTimeout, 120)
+}
+
+// GetPingInterval 返回 ping 间隔（默认 15 秒）
+func (p *BaseProvider) GetPingInterval() int {
+	return secondsOr(p.backend.PingInterval, 15)
 }
 
 // Config 返回配置（供子类使用）
 func (p *BaseProvider) Config() *config.Config {
 	return p.cfg
 }
+
+// Backend 返回提供商所属的后端
+func (p *BaseProvider) Backend() *config.Backend {
+	return p.backend
+}
+
+// secondsOr 将后端配置的时长转换为秒；未配置（零值）时返回默认值
+func secondsOr(d time.Duration, defaultSeconds int) int {
+	if d <= 0 {
+		return defaultSeconds
+	}
+	if seconds := int(d / time.Second); seconds > 0 {
+		return seconds
+	}
+	return 1
+}
@@ -0,0 +1,352 @@
+// anthropic.go 实现 Anthropic 原生协议后端的请求转发：
+// Claude 请求只替换模型名称、删除配置的字段后原样发送到上游 /v1/messages，
+// 响应（JSON 或 Claude SSE 事件流）直接返回给客户端，不经过聊天完成格式的中间转换。
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// anthropicOpaqueFields 是字段过滤时不进入的键：工具参数 schema 和工具调用参数由用户定义，
+// 其中的同名属性不是请求字段
+var anthropicOpaqueFields = map[string]bool{"input_schema": true, "input": true}
+
+// handleAnthropicMessages 将 /v1/messages 请求转发到 Anthropic 原生后端。
+// 请求体保留客户端发送的所有字段（cache_control、tool_choice、metadata 等），
+// 客户端的 anthropic-beta 头一并转发。
+func handleAnthropicMessages(c *fiber.Ctx, prov provider.Provider, claudeReq models.ClaudeRequest, route converter.Route, cfg *config.Config) error {
+	// 记录计时用于简单日志
+	startTime := time.Now()
+
+	model := route.Model
+	body, err := anthropicRequestBody(c.Body(), route, prov.Backend().Anthropic.StripFields)
+	if err != nil {
+		return sendProxyError(c, errors.NewInvalidRequestError(fmt.Sprintf("Invalid request body: %v", err)))
+	}
+
+	if cfg.Debug {
+		fmt.Printf("\n=== Anthropic 上游请求 ===\n%s\n===================\n", string(body))
+	}
+
+	// 客户端断开连接时取消上游请求
+	ctx, cancel := requestContext(c)
+
+	stream := claudeReq.Stream != nil && *claudeReq.Stream
+	resp, err := callAnthropic(ctx, prov, body, c.Get("anthropic-beta"), stream)
+	if err != nil {
+		clientGone := ctx.Err() != nil
+		cancel()
+		if clientGone {
+			logClientDisconnected(cfg, model)
+			return nil
+		}
+		return sendProxyError(c, toProxyError(err, "Anthropic API 错误"))
+	}
+	applyRateLimitHeaders(c, prov, resp.Header)
+
+	if stream {
+		setSSEHeaders(c)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer cancel()
+			defer func() { _ = resp.Body.Close() }()
+
+			relayAnthropicStream(ctx, w, resp.Body, prov, model, cfg, startTime)
+			if ctx.Err() != nil {
+				logClientDisconnected(cfg, model)
+			}
+		})
+		return nil
+	}
+
+	defer cancel()
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return sendProxyError(c, errors.NewAPIError(fmt.Sprintf("读取响应失败: %v", err)).WithCause(err))
+	}
+
+	// 与其他协议一样，响应中的模型名称使用客户端请求的模型
+	var claudeResp map[string]interface{}
+	if err := json.Unmarshal(respBody, &claudeResp); err != nil {
+		return sendProxyError(c, errors.NewAPIError(fmt.Sprintf("解析响应失败: %v", err)).WithCause(err))
+	}
+	claudeResp["model"] = claudeReq.Model
+
+	if cfg.Debug {
+		fmt.Printf("\n=== Anthropic 上游响应 ===\n%s\n====================\n\n", string(respBody))
+	}
+
+	var parsed models.ClaudeResponse
+	_ = json.Unmarshal(respBody, &parsed)
+	logRequestSummary(cfg, model, parsed.Usage, startTime, false)
+	recordUsage(ctx, cfg, model, parsed.Usage)
+
+	return c.JSON(claudeResp)
+}
+
+// handleAnthropicChatCompletions 将 /v1/chat/completions 请求（已转换为 Claude 请求）转发到 Anthropic 原生后端，
+// 并把 Claude 响应或 SSE 事件流转换回 OpenAI 格式
+func handleAnthropicChatCompletions(c *fiber.Ctx, prov provider.Provider, claudeReq *models.ClaudeRequest, route converter.Route, requestedModel string, includeUsage bool, cfg *config.Config) error {
+	// 记录计时用于简单日志
+	startTime := time.Now()
+
+	claudeBody, err := json.Marshal(claudeReq)
+	if err != nil {
+		return sendOpenAIError(c, errors.NewInvalidRequestError(err.Error()))
+	}
+	model := route.Model
+	body, err := anthropicRequestBody(claudeBody, route, prov.Backend().Anthropic.StripFields)
+	if err != nil {
+		return sendOpenAIError(c, errors.NewInvalidRequestError(err.Error()))
+	}
+
+	ctx, cancel := requestContext(c)
+
+	stream := claudeReq.Stream != nil && *claudeReq.Stream
+	resp, err := callAnthropic(ctx, prov, body, "", stream)
+	if err != nil {
+		clientGone := ctx.Err() != nil
+		cancel()
+		if clientGone {
+			logClientDisconnected(cfg, model)
+			return nil
+		}
+		return sendOpenAIError(c, toProxyError(err, "Anthropic API 错误"))
+	}
+	applyRateLimitHeaders(c, prov, resp.Header)
+
+	if stream {
+		setSSEHeaders(c)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer cancel()
+			defer func() { _ = resp.Body.Close() }()
+
+			pr, pw := io.Pipe()
+			go func() {
+				claudeWriter := bufio.NewWriter(pw)
+				relayAnthropicStream(ctx, claudeWriter, resp.Body, prov, model, cfg, startTime)
+				_ = claudeWriter.Flush()
+				_ = pw.Close()
+			}()
+
+			if err := writeClaudeSSEAsOpenAI(w, pr, requestedModel, includeUsage); err != nil {
+				// 客户端已断开 - 关闭管道使转发停止，并取消上游请求
+				_ = pr.CloseWithError(err)
+				cancel()
+				logClientDisconnected(cfg, model)
+			}
+		})
+		return nil
+	}
+
+	defer cancel()
+	defer func() { _ = resp.Body.Close() }()
+
+	var claudeResp models.ClaudeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&claudeResp); err != nil {
+		return sendOpenAIError(c, errors.NewAPIError(fmt.Sprintf("解析响应失败: %v", err)).WithCause(err))
+	}
+	logRequestSummary(cfg, model, claudeResp.Usage, startTime, false)
+	recordUsage(ctx, cfg, model, claudeResp.Usage)
+
+	return c.JSON(converter.ConvertClaudeResponseToOpenAI(&claudeResp, requestedModel))
+}
+
+// anthropicRequestBody 构建转发给 Anthropic 原生后端的请求体：
+// 模型替换为路由后的后端模型，应用路由规则覆盖的参数（reasoning_effort 不适用于 Anthropic 协议）和参数策略，
+// 并删除配置的字段（请求顶层、系统提示、消息内容块和工具定义中的同名字段）
+func anthropicRequestBody(body []byte, route converter.Route, stripFields []string) ([]byte, error) {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	req["model"] = route.Model
+	if route.Params.Temperature != nil {
+		req["temperature"] = *route.Params.Temperature
+	}
+	if route.Params.TopP != nil {
+		req["top_p"] = *route.Params.TopP
+	}
+	if route.Params.MaxTokens > 0 {
+		req["max_tokens"] = route.Params.MaxTokens
+	}
+	converter.ApplyBodyPolicy(req, route.Policy, route.Model)
+	for _, field := range stripFields {
+		stripJSONField(req, field)
+	}
+	return json.Marshal(req)
+}
+
+// stripJSONField 递归删除 JSON 值中名为 field 的键（不进入 anthropicOpaqueFields 中的键）
+func stripJSONField(value interface{}, field string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		delete(v, field)
+		for key, child := range v {
+			if !anthropicOpaqueFields[key] {
+				stripJSONField(child, field)
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			stripJSONField(child, field)
+		}
+	}
+}
+
+// callAnthropic 向 Anthropic 原生后端发送请求，非 200 响应转换为 ProxyError。
+// 流式请求使用流式总超时，上游停滞由 relayAnthropicStream 的空闲超时检测。
+func callAnthropic(ctx context.Context, prov provider.Provider, body []byte, beta string, stream bool) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", prov.GetEndpoint(), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	prov.AddHeaders(httpReq)
+	if beta != "" {
+		httpReq.Header.Set("anthropic-beta", beta)
+	}
+
+	timeout := prov.GetTimeout()
+	if stream {
+		timeout = prov.GetStreamTimeout()
+	}
+	resp, err := newHTTPClient(prov, time.Duration(timeout)*time.Second).Do(httpReq)
+	if err != nil {
+		return nil, transportError(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, upstreamError(prov, resp, respBody)
+	}
+	return resp, nil
+}
+
+// relayAnthropicStream 将上游的 Claude SSE 事件流逐行转发给客户端。
+// 每个事件结束（空行）时刷新；从 message_start 和 message_delta 中记录令牌用量用于简单日志。
+// 上游静默期间在事件边界发送 ping，超过空闲超时或流在 message_stop 之前结束时发送错误事件。
+func relayAnthropicStream(ctx context.Context, w *bufio.Writer, reader io.Reader, prov provider.Provider, model string, cfg *config.Config, startTime time.Time) {
+	scanCtx, stopScan := context.WithCancel(ctx)
+	defer stopScan()
+	lines, readErr := scanLines(scanCtx, reader)
+
+	idleTimeout := time.Duration(prov.GetIdleTimeout()) * time.Second
+	pingInterval := time.Duration(prov.GetPingInterval()) * time.Second
+	idleTimer := time.NewTimer(idleTimeout)
+	defer idleTimer.Stop()
+	pingTicker := time.NewTicker(pingInterval)
+	defer pingTicker.Stop()
+
+	var usage models.Usage
+	// 流结束（包括提前结束）时记录客户端用量
+	defer func() { recordUsage(ctx, cfg, model, usage) }()
+	lastFlush := time.Now()
+	atBoundary := true // 当前位置在两个事件之间，可以插入 ping
+	stopped := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-pingTicker.C:
+			if atBoundary && time.Since(lastFlush) >= pingInterval {
+				writeSSEEvent(w, constants.EventPing, map[string]interface{}{"type": constants.EventPing})
+				if w.Flush() != nil {
+					return
+				}
+				lastFlush = time.Now()
+			}
+
+		case <-idleTimer.C:
+			if cfg.Debug || cfg.SimpleLog {
+				fmt.Printf("[%s] [超时] 上游流停滞：%v 内未收到任何数据 模型=%s\n",
+					time.Now().Format("15:04:05"), idleTimeout, model)
+			}
+			writeSSEProxyError(w, errors.NewTimeoutError(fmt.Sprintf("上游流停滞：%v 内未收到任何数据", idleTimeout)))
+			return
+
+		case line, ok := <-lines:
+			if !ok {
+				if err := <-readErr; err != nil && ctx.Err() == nil {
+					writeSSEProxyError(w, errors.NewStreamProcessingError(fmt.Sprintf("流读取错误: %v", err)).WithCause(err))
+					return
+				}
+				if !stopped && ctx.Err() == nil {
+					writeSSEProxyError(w, errors.NewStreamProcessingError("上游流在 message_stop 之前结束"))
+					return
+				}
+				logRequestSummary(cfg, model, usage, startTime, false)
+				return
+			}
+			if !idleTimer.Stop() {
+				<-idleTimer.C
+			}
+			idleTimer.Reset(idleTimeout)
+
+			if cfg.Debug {
+				fmt.Printf("[调试] 来自提供商的 Anthropic 数据: %s\n", line)
+			}
+			if dataJSON, ok := strings.CutPrefix(line, "data:"); ok {
+				stopped = recordAnthropicStreamEvent(strings.TrimSpace(dataJSON), &usage) || stopped
+			}
+
+			_, _ = w.WriteString(line + "\n")
+			atBoundary = line == ""
+			if atBoundary {
+				if w.Flush() != nil {
+					return
+				}
+				lastFlush = time.Now()
+			}
+		}
+	}
+}
+
+// recordAnthropicStreamEvent 从 SSE 数据中记录令牌用量，返回是否为 message_stop 事件
+func recordAnthropicStreamEvent(dataJSON string, usage *models.Usage) bool {
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
+			Usage models.Usage `json:"usage"`
+		} `json:"message"`
+		Usage *models.Usage `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(dataJSON), &event); err != nil {
+		return false
+	}
+
+	switch event.Type {
+	case constants.EventMessageStart:
+		*usage = event.Message.Usage
+	case constants.EventMessageDelta:
+		if event.Usage != nil {
+			usage.OutputTokens = event.Usage.OutputTokens
+			if event.Usage.InputTokens > 0 {
+				usage.InputTokens = event.Usage.InputTokens
+			}
+		}
+	case constants.EventMessageStop:
+		return true
+	}
+	return false
+}
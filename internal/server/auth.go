@@ -0,0 +1,91 @@
+// auth.go 验证入站 API 密钥：ANTHROPIC_API_KEY 共享密钥或 AUTH_KEYS_FILE 中命名客户端的密钥。
+// 认证作为中间件在解析请求体之前运行；客户端的路由限制和默认参数在选择路由后应用（见 requestRoute）。
+
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/gofiber/fiber/v2"
+)
+
+// clientLocalsKey 是认证的客户端在请求上下文中的键
+const clientLocalsKey = "proxy.client"
+
+// authMiddleware 返回验证入站 API 密钥的中间件（未设置 ANTHROPIC_API_KEY 和 AUTH_KEYS_FILE 时不验证）。
+// 接受 x-api-key 头（Anthropic SDK）或 Authorization: Bearer 头（OpenAI SDK），
+// 错误按端点的格式返回（/v1/chat/completions 为 OpenAI 格式）。
+func authMiddleware(store *configStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg := store.Load()
+		if !cfg.AuthEnabled() {
+			return c.Next()
+		}
+
+		client, pe := authenticate(c, cfg)
+		if pe != nil {
+			if c.Path() == "/v1/chat/completions" {
+				return sendOpenAIError(c, pe)
+			}
+			return sendProxyError(c, pe)
+		}
+		if client != nil {
+			c.Locals(clientLocalsKey, client)
+			if cfg.Debug {
+				fmt.Printf("[调试] 客户端: %s\n", client.Name)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// authenticate 验证请求的 API 密钥，返回密钥对应的客户端（共享密钥为 nil）
+func authenticate(c *fiber.Ctx, cfg *config.Config) (*config.Client, *errors.ProxyError) {
+	apiKey := c.Get("x-api-key")
+	if apiKey == "" {
+		if auth := c.Get("Authorization"); len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+			apiKey = strings.TrimSpace(auth[len("Bearer "):])
+		}
+	}
+	if apiKey == "" {
+		return nil, errors.NewAuthenticationError("缺少 API 密钥（x-api-key 或 Authorization: Bearer 请求头）")
+	}
+
+	client, ok := cfg.Authenticate(apiKey)
+	if !ok {
+		return nil, errors.NewAuthenticationError("API 密钥无效")
+	}
+	if client != nil && !client.Enabled {
+		return nil, errors.NewPermissionError(fmt.Sprintf("客户端 %s 已停用", client.Name))
+	}
+	return client, nil
+}
+
+// requestClient 返回请求认证的客户端，未认证或使用共享密钥时返回 nil
+func requestClient(c *fiber.Ctx) *config.Client {
+	client, _ := c.Locals(clientLocalsKey).(*config.Client)
+	return client
+}
+
+// applyClientRoute 检查路由是否在客户端允许的路由中，并将客户端的默认参数加入路由的参数策略
+// （请求和路由规则的策略没有设置的参数使用客户端的默认值）
+func applyClientRoute(route *converter.Route, client *config.Client, cfg *config.Config) *errors.ProxyError {
+	if client == nil {
+		return nil
+	}
+	if !client.AllowsRoute(route.Backend, route.Model) {
+		return errors.NewPermissionError(fmt.Sprintf("客户端 %s 不允许使用 %s:%s", client.Name, route.Backend, route.Model)).
+			WithModel(route.Model)
+	}
+	if len(client.DefaultParams) > 0 {
+		route.Policy = route.Policy.WithDefaults(client.DefaultParams)
+		if cfg.Debug {
+			fmt.Printf("[调试] 客户端 %s 的默认参数: %s\n", client.Name, config.ParamPolicy{Default: client.DefaultParams})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+// loadClientsConfig 加载配置文件和密钥文件（共享密钥为 sk-shared）
+func loadClientsConfig(t *testing.T, configData, keysData string) *config.Config {
+	t.Helper()
+	path := setupConfigFile(t)
+	writeFile(t, path, configData)
+	keysFile := filepath.Join(filepath.Dir(path), "keys.yaml")
+	writeFile(t, keysFile, keysData)
+	t.Setenv("ANTHROPIC_API_KEY", "sk-shared")
+	t.Setenv("AUTH_KEYS_FILE", keysFile)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	return cfg
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          string
+		header        map[string]string
+		model         string
+		wantStatus    int
+		wantErrorType string
+		wantBackend   string
+	}{
+		{"缺少密钥", "/v1/messages", nil, "claude-sonnet-4", http.StatusUnauthorized, "authentication_error", ""},
+		{"x-api-key 共享密钥", "/v1/messages", map[string]string{"x-api-key": "sk-shared"}, "claude-sonnet-4", http.StatusOK, "", "default"},
+		{"Bearer 共享密钥", "/v1/messages", map[string]string{"Authorization": "Bearer sk-shared"}, "claude-sonnet-4", http.StatusOK, "", "default"},
+		{"Bearer 不区分大小写", "/v1/messages", map[string]string{"Authorization": "bearer sk-shared"}, "claude-sonnet-4", http.StatusOK, "", "default"},
+		{"x-api-key 优先于 Bearer", "/v1/messages", map[string]string{"x-api-key": "sk-wrong", "Authorization": "Bearer sk-shared"}, "claude-sonnet-4", http.StatusUnauthorized, "authentication_error", ""},
+		{"不是 Bearer 的 Authorization", "/v1/messages", map[string]string{"Authorization": "Basic sk-shared"}, "claude-sonnet-4", http.StatusUnauthorized, "authentication_error", ""},
+		{"错误的密钥", "/v1/messages", map[string]string{"x-api-key": "sk-wrong"}, "claude-sonnet-4", http.StatusUnauthorized, "authentication_error", ""},
+		{"停用的客户端", "/v1/messages", map[string]string{"x-api-key": "sk-carol"}, "claude-sonnet-4", http.StatusForbidden, "permission_error", ""},
+		{"共享密钥不限制路由", "/v1/messages", map[string]string{"x-api-key": "sk-shared"}, "claude-sonnet-4@local:llama3", http.StatusOK, "", "local"},
+		{"允许的模型通配符", "/v1/messages", map[string]string{"x-api-key": "sk-alice"}, "claude-sonnet-4@local:qwen3-coder", http.StatusOK, "", "local"},
+		{"模型通配符不匹配", "/v1/messages", map[string]string{"x-api-key": "sk-alice"}, "claude-sonnet-4@local:llama3", http.StatusForbidden, "permission_error", ""},
+		{"不允许的后端", "/v1/messages", map[string]string{"x-api-key": "sk-alice"}, "claude-sonnet-4", http.StatusForbidden, "permission_error", ""},
+		{"只有后端名称时允许该后端的所有模型", "/v1/messages", map[string]string{"Authorization": "Bearer sk-bob"}, "claude-sonnet-4", http.StatusOK, "", "default"},
+		{"只有后端名称时不允许其他后端", "/v1/messages", map[string]string{"x-api-key": "sk-bob"}, "claude-sonnet-4@local:qwen3-coder", http.StatusForbidden, "permission_error", ""},
+		{"OpenAI 端点的认证错误", "/v1/chat/completions", map[string]string{"Authorization": "Bearer sk-wrong"}, "claude-sonnet-4", http.StatusUnauthorized, "invalid_api_key", ""},
+		{"OpenAI 端点的客户端密钥", "/v1/chat/completions", map[string]string{"Authorization": "Bearer sk-alice"}, "claude-sonnet-4@local:qwen3-coder", http.StatusOK, "", "local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreams := map[string]*fakeUpstream{}
+			for _, name := range []string{"default", "local"} {
+				upstreams[name] = newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+					return jsonResponse(http.StatusOK, chatCompletionResponse)
+				})
+			}
+			cfg := loadClientsConfig(t, `
+models:
+  sonnet: `+testModel+`
+backends:
+  default:
+    base_url: `+upstreams["default"].URL+`
+  local:
+    base_url: `+upstreams["local"].URL+`
+`, `
+clients:
+  alice:
+    keys: [sk-alice]
+    routes: ["local:qwen3-*"]
+  bob:
+    keys: [sk-bob]
+    routes: [default]
+  carol:
+    keys: [sk-carol]
+    enabled: false
+`)
+
+			resp := postWithHeaders(t, newTestApp(cfg), tt.path,
+				`{"model":"`+tt.model+`","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`, tt.header)
+			defer func() { _ = resp.Body.Close() }()
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("状态码 = %d，应为 %d，响应: %s", resp.StatusCode, tt.wantStatus, data)
+			}
+			if tt.wantErrorType != "" && !strings.Contains(string(data), `"`+tt.wantErrorType+`"`) {
+				t.Errorf("响应 = %s，应包含 %s", data, tt.wantErrorType)
+			}
+			for name, upstream := range upstreams {
+				if calls := len(upstream.calls()); (name == tt.wantBackend) != (calls == 1) {
+					t.Errorf("后端 %s 收到 %d 个请求，应发送到后端 %q", name, calls, tt.wantBackend)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+		return jsonResponse(http.StatusOK, chatCompletionResponse)
+	})
+	status, resp := postJSON(t, newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL})), "/v1/messages",
+		`{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	if status != http.StatusOK {
+		t.Errorf("没有共享密钥和密钥文件时不应验证，状态码 = %d，响应: %s", status, resp)
+	}
+}
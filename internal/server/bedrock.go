@@ -0,0 +1,132 @@
+// bedrock.go 实现 Bedrock ConverseStream 上游流的解析：
+// 事件流消息由 eventStreamLineReader 解码为 {"事件类型": 载荷} 形式的 JSON 行，流以 metadata 事件（令牌用量）结束。
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// handleBedrockStreamLine 处理解码后的一条 ConverseStream 事件。
+// 文本和推理增量分别转换为文本和思考增量，推理签名作为思考块签名发送，
+// 工具调用块的 start 事件启动工具调用、增量事件累积参数；收到异常事件或错误消息时返回对应的错误。
+func handleBedrockStreamLine(processor *StreamProcessor, w *bufio.Writer, line string, cfg *config.Config) (done bool, streamErr *errors.ProxyError) {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return false, nil
+	}
+
+	if cfg.Debug {
+		fmt.Printf("[调试] 来自提供商的 Bedrock 事件: %s\n", line)
+	}
+
+	for eventType, payload := range event {
+		switch eventType {
+		case "contentBlockStart":
+			var start models.BedrockContentBlockStart
+			if err := json.Unmarshal(payload, &start); err != nil || start.Start.ToolUse == nil {
+				continue
+			}
+			processor.HandleToolCallsDelta([]interface{}{map[string]interface{}{
+				"index":    float64(len(processor.state.CurrentToolCalls)),
+				"id":       start.Start.ToolUse.ToolUseID,
+				"function": map[string]interface{}{"name": start.Start.ToolUse.Name},
+			}})
+
+		case "contentBlockDelta":
+			var delta models.BedrockContentBlockDelta
+			if err := json.Unmarshal(payload, &delta); err != nil {
+				continue
+			}
+			switch {
+			case delta.Delta.ToolUse != nil:
+				// 内容块按顺序逐个发送，参数增量属于最近启动的工具调用
+				processor.HandleToolCallsDelta([]interface{}{map[string]interface{}{
+					"index":    float64(len(processor.state.CurrentToolCalls) - 1),
+					"function": map[string]interface{}{"arguments": delta.Delta.ToolUse.Input},
+				}})
+			case delta.Delta.ReasoningContent != nil:
+				if text := delta.Delta.ReasoningContent.Text; text != "" {
+					processor.HandleThinkingDelta(map[string]interface{}{"reasoning_content": text})
+				}
+				if signature := delta.Delta.ReasoningContent.Signature; signature != "" {
+					processor.HandleThinkingSignature(converter.EncodeBedrockSignature(signature))
+				}
+			case delta.Delta.Text != "":
+				processor.HandleTextDelta(delta.Delta.Text)
+			}
+
+		case "messageStop":
+			var stop models.BedrockMessageStop
+			if err := json.Unmarshal(payload, &stop); err == nil {
+				processor.HandleFinishReason(converter.BedrockFinishReason(stop.StopReason))
+			}
+
+		case "metadata":
+			var metadata models.BedrockStreamMetadata
+			if err := json.Unmarshal(payload, &metadata); err != nil {
+				return true, nil
+			}
+			usage := metadata.Usage
+			processor.HandleUsageData(map[string]interface{}{
+				"prompt_tokens":     float64(usage.InputTokens + usage.CacheReadInputTokens + usage.CacheWriteInputTokens),
+				"completion_tokens": float64(usage.OutputTokens),
+				"prompt_tokens_details": map[string]interface{}{
+					"cached_tokens": float64(usage.CacheReadInputTokens),
+				},
+			})
+			return true, nil
+
+		case "messageStart", "contentBlockStop":
+			// 无需处理
+
+		case "error":
+			// 事件流的错误消息（:message-type 为 error），错误代码不一定以 Exception 结尾
+			var body struct {
+				Code string `json:"code"`
+			}
+			_ = json.Unmarshal(payload, &body)
+			return false, bedrockStreamError(body.Code, payload)
+
+		default:
+			if strings.HasSuffix(strings.ToLower(eventType), "exception") {
+				return false, bedrockStreamError(eventType, payload)
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// bedrockStreamError 将 ConverseStream 的异常事件转换为代理错误
+func bedrockStreamError(exceptionType string, payload []byte) *errors.ProxyError {
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(payload, &body)
+	message := body.Message
+	if message == "" {
+		message = exceptionType
+	}
+
+	switch strings.ToLower(exceptionType) {
+	case "throttlingexception":
+		return errors.NewRateLimitError(message).WithProvider("Bedrock")
+	case "serviceunavailableexception":
+		return errors.NewOverloadedError(message).WithProvider("Bedrock")
+	case "validationexception":
+		return errors.NewInvalidRequestError(message).WithProvider("Bedrock")
+	case "modeltimeoutexception":
+		return errors.NewTimeoutError(message).WithProvider("Bedrock")
+	default:
+		return errors.NewAPIError(fmt.Sprintf("%s: %s", exceptionType, message)).WithProvider("Bedrock")
+	}
+}
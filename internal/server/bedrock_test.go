@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+// checkBedrockRequest 检查发送到 ConverseStream 的请求路径和 SigV4 签名头
+func checkBedrockRequest(t *testing.T, req upstreamRequest) {
+	t.Helper()
+	if req.Path != "/model/"+testModel+"/converse-stream" {
+		t.Errorf("上游路径 = %q", req.Path)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+		t.Errorf("Authorization = %q，应为 SigV4 签名", auth)
+	}
+}
+
+// bedrockBackend 返回使用指定端点和静态凭证的 Bedrock 后端
+func bedrockBackend(baseURL string) *config.Backend {
+	return &config.Backend{
+		BaseURL:  baseURL,
+		Protocol: config.ProtocolBedrock,
+		Bedrock:  config.BedrockSettings{Region: "us-east-1", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"},
+	}
+}
+
+func TestBedrockConverseStream(t *testing.T) {
+	// 事件分成两次写出，并把一条消息拆开，验证解码器跨读取边界组装消息
+	var stream bytes.Buffer
+	for _, frame := range [][]byte{
+		bedrockEvent("messageStart", `{"role":"assistant"}`),
+		bedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"reasoningContent":{"text":"需要读取"}}}`),
+		bedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"reasoningContent":{"signature":"c2ln"}}}`),
+		bedrockEvent("contentBlockStop", `{"contentBlockIndex":0}`),
+		bedrockEvent("contentBlockDelta", `{"contentBlockIndex":1,"delta":{"text":"我来"}}`),
+		bedrockEvent("contentBlockDelta", `{"contentBlockIndex":1,"delta":{"text":"读取。"}}`),
+		bedrockEvent("contentBlockStop", `{"contentBlockIndex":1}`),
+		bedrockEvent("contentBlockStart", `{"contentBlockIndex":2,"start":{"toolUse":{"toolUseId":"tooluse_1","name":"read"}}}`),
+		bedrockEvent("contentBlockDelta", `{"contentBlockIndex":2,"delta":{"toolUse":{"input":"{\"path\":"}}}`),
+		bedrockEvent("contentBlockDelta", `{"contentBlockIndex":2,"delta":{"toolUse":{"input":"\"a.txt\"}"}}}`),
+		bedrockEvent("contentBlockStop", `{"contentBlockIndex":2}`),
+		bedrockEvent("messageStop", `{"stopReason":"tool_use"}`),
+		bedrockEvent("metadata", `{"usage":{"inputTokens":10,"outputTokens":7,"totalTokens":17,"cacheReadInputTokens":5},"metrics":{"latencyMs":100}}`),
+	} {
+		stream.Write(frame)
+	}
+	data := stream.Bytes()
+	split := len(data)/2 + 3
+	upstream := streamingUpstream(t, "application/vnd.amazon.eventstream", string(data[:split]), string(data[split:]))
+	app := newTestApp(newTestConfig(bedrockBackend(upstream.URL)))
+
+	status, body := postJSON(t, app, "/v1/messages", streamRequest)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, body)
+	}
+	checkBedrockRequest(t, upstream.last(t))
+	events := parseSSE(t, body)
+	messageDelta := checkClaudeStream(t, events, "thinking", "text", "tool_use")
+
+	if got := deltaText(events, "thinking"); got != "需要读取" {
+		t.Errorf("思考内容 = %q", got)
+	}
+	if got := deltaText(events, "signature"); got != "bedrock:c2ln" {
+		t.Errorf("思考签名 = %q", got)
+	}
+	if got := deltaText(events, "text"); got != "我来读取。" {
+		t.Errorf("文本内容 = %q", got)
+	}
+	if got := deltaText(events, "partial_json"); got != `{"path":"a.txt"}` {
+		t.Errorf("工具参数 = %q", got)
+	}
+	for _, e := range events {
+		if block, ok := e.Data["content_block"].(map[string]interface{}); ok && block["type"] == "tool_use" {
+			if block["id"] != "tooluse_1" || block["name"] != "read" {
+				t.Errorf("工具调用块 = %v", block)
+			}
+		}
+	}
+	delta := messageDelta["delta"].(map[string]interface{})
+	if delta["stop_reason"] != "tool_use" {
+		t.Errorf("stop_reason = %v", delta["stop_reason"])
+	}
+	usage := messageDelta["usage"].(map[string]interface{})
+	if usage["output_tokens"] != float64(7) {
+		t.Errorf("用量 = %v", usage)
+	}
+}
+
+func TestBedrockConverseStreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   []byte
+		errType string
+		message string
+	}{
+		{
+			"异常消息",
+			encodeEventStreamMessage(encodeEventStreamHeaders(
+				":message-type", "exception", ":exception-type", "throttlingException", ":content-type", "application/json"),
+				`{"message":"Too many requests"}`),
+			"rate_limit_error", "Too many requests",
+		},
+		{
+			"错误消息",
+			encodeEventStreamMessage(encodeEventStreamHeaders(
+				":message-type", "error", ":error-code", "InternalFailure", ":error-message", "内部错误"), ""),
+			"api_error", "InternalFailure: 内部错误",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := streamingUpstream(t, "application/vnd.amazon.eventstream",
+				string(bedrockEvent("messageStart", `{"role":"assistant"}`)),
+				string(bedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"部分"}}`)),
+				string(tt.frame))
+			app := newTestApp(newTestConfig(bedrockBackend(upstream.URL)))
+
+			_, body := postJSON(t, app, "/v1/messages", streamRequest)
+			checkBedrockRequest(t, upstream.last(t))
+			events := parseSSE(t, body)
+			last := events[len(events)-1]
+			if last.Event != "error" {
+				t.Fatalf("最后一个事件 = %s，应为 error；事件: %v", last.Event, eventTypes(events))
+			}
+			errObj := last.Data["error"].(map[string]interface{})
+			if errObj["type"] != tt.errType || !strings.Contains(errObj["message"].(string), tt.message) {
+				t.Errorf("错误 = %v", errObj)
+			}
+		})
+	}
+}
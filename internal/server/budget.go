@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// budgetWarningHeader 是用量达到软限制（warn_at）时的警告响应头
+const budgetWarningHeader = "x-proxy-budget-warning"
+
+// clientContextKey 是认证的客户端在请求 context.Context 中的键（流式响应在处理器返回后才记录用量）
+type clientContextKey struct{}
+
+// requestContext 创建在客户端断开连接时取消的请求上下文，并带上认证的客户端用于记录用量
+func requestContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	ctx, cancel := newClientContext(c.Context().Conn())
+	if client := requestClient(c); client != nil {
+		ctx = context.WithValue(ctx, clientContextKey{}, client)
+	}
+	return ctx, cancel
+}
+
+// recordUsage 按价格表计算请求的费用，累加到认证客户端的用量（共享密钥和未认证的请求不记录）
+func recordUsage(ctx context.Context, cfg *config.Config, model string, usage models.Usage) {
+	client, _ := ctx.Value(clientContextKey{}).(*config.Client)
+	if client == nil || (usage.InputTokens == 0 && usage.OutputTokens == 0) {
+		return
+	}
+
+	cost := 0.0
+	if price, ok := cfg.PriceFor(model); ok {
+		cost = price.Cost(usage.InputTokens, usage.OutputTokens)
+	} else if cfg.Debug && len(cfg.Pricing) > 0 {
+		fmt.Printf("[调试] 模型 %s 不在价格表中，费用按 0 计算\n", model)
+	}
+
+	total := config.RecordUsage(client.Name, usage.InputTokens, usage.OutputTokens, cost)
+	if cfg.Debug {
+		fmt.Printf("[调试] 客户端 %s 用量: 输入=%d 输出=%d 费用=$%.4f（今日 %d 令牌 $%.4f，本月 %d 令牌 $%.4f）\n",
+			client.Name, usage.InputTokens, usage.OutputTokens, cost,
+			total.Daily.Tokens(), total.Daily.Cost, total.Monthly.Tokens(), total.Monthly.Cost)
+	}
+}
+
+// budgetPeriod 是预算检查中的一个周期
+type budgetPeriod struct {
+	name  string // 响应头中的名称
+	label string // 错误消息中的名称
+	limit config.BudgetLimit
+	used  config.UsageTotals
+	reset time.Time // 周期结束（用量重置）的时间
+}
+
+// checkClientBudget 检查客户端当前周期的用量是否超出预算，并在响应头中返回剩余额度。
+// 每日预算用完时返回 rate_limit_error（retry-after 为到次日零点的秒数），每月预算用完时返回 permission_error；
+// 用量达到 warn_at 比例时设置 x-proxy-budget-warning 响应头。
+// 检查使用请求开始前的用量，并发请求可能使用量略微超出预算。
+func checkClientBudget(c *fiber.Ctx, client *config.Client, cfg *config.Config) *errors.ProxyError {
+	if client == nil || client.Budget.IsZero() {
+		return nil
+	}
+
+	now := time.Now()
+	usage := config.UsageFor(client.Name)
+	periods := []budgetPeriod{
+		{"daily", "今日", client.Budget.Daily, usage.Daily, time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())},
+		{"monthly", "本月", client.Budget.Monthly, usage.Monthly, time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())},
+	}
+
+	var warnings []string
+	for _, period := range periods {
+		if period.limit.Tokens > 0 {
+			used, limit := period.used.Tokens(), period.limit.Tokens
+			c.Set("x-proxy-budget-"+period.name+"-tokens-remaining", strconv.FormatInt(max(limit-used, 0), 10))
+			if used >= limit {
+				return budgetExceeded(client, period, fmt.Sprintf("令牌预算已用完（%d / %d）", used, limit), now)
+			}
+			if float64(used) >= float64(limit)*client.Budget.WarnAt {
+				warnings = append(warnings, fmt.Sprintf("%s tokens %.0f%% (%d of %d)", period.name, float64(used)*100/float64(limit), used, limit))
+			}
+		}
+		if period.limit.Cost > 0 {
+			used, limit := period.used.Cost, period.limit.Cost
+			c.Set("x-proxy-budget-"+period.name+"-cost-remaining", strconv.FormatFloat(max(limit-used, 0), 'f', 4, 64))
+			if used >= limit {
+				return budgetExceeded(client, period, fmt.Sprintf("费用预算已用完（$%.2f / $%.2f）", used, limit), now)
+			}
+			if used >= limit*client.Budget.WarnAt {
+				warnings = append(warnings, fmt.Sprintf("%s cost %.0f%% ($%.2f of $%.2f)", period.name, used*100/limit, used, limit))
+			}
+		}
+	}
+
+	if len(warnings) > 0 {
+		c.Set(budgetWarningHeader, strings.Join(warnings, ", "))
+		if cfg.Debug || cfg.SimpleLog {
+			fmt.Printf("⚠️  客户端 %s 的预算即将用完: %s\n", client.Name, strings.Join(warnings, ", "))
+		}
+	}
+	return nil
+}
+
+// budgetExceeded 返回预算用完的错误：每日预算为速率限制（次日零点后可以重试），每月预算为权限错误
+func budgetExceeded(client *config.Client, period budgetPeriod, reason string, now time.Time) *errors.ProxyError {
+	message := fmt.Sprintf("客户端 %s %s的%s，将于 %s 重置", client.Name, period.label, reason, period.reset.Format("2006-01-02 15:04"))
+	if period.name == "daily" {
+		retryAfter := int(period.reset.Sub(now).Seconds()) + 1
+		return errors.NewRateLimitError(message).WithRetryAfter(strconv.Itoa(retryAfter))
+	}
+	return errors.NewPermissionError(message)
+}
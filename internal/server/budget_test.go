@@ -0,0 +1,167 @@
+package server
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+// budgetRequest 是预算测试使用的请求（上游模型为 gpt-4o）
+const budgetRequest = `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`
+
+// uniqueClient 返回带有唯一后缀的客户端名称（用量是全局的，重复运行测试时不累计）
+func uniqueClient(name string) string {
+	return name + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// loadBudgetConfig 加载带有价格表和客户端预算的配置
+func loadBudgetConfig(t *testing.T, upstreamURL, client, budget string) *config.Config {
+	t.Helper()
+	return loadClientsConfig(t, `
+models:
+  sonnet: gpt-4o
+backends:
+  default:
+    base_url: `+upstreamURL+`
+pricing:
+  gpt-4o*: {input: 2.5, output: 10}
+`, `
+clients:
+  `+client+`:
+    keys: [sk-`+client+`]
+    budget: `+budget+`
+`)
+}
+
+func TestClientBudget(t *testing.T) {
+	tests := []struct {
+		name          string
+		client        string
+		budget        string
+		usedTokens    int
+		usedCost      float64
+		wantStatus    int
+		wantErrorType string
+		wantHeader    map[string]string // 响应头（值为空时只检查存在）
+	}{
+		{
+			name: "每日令牌预算用完", client: "budget-daily-tokens", budget: "{daily: {tokens: 1000}}",
+			usedTokens: 1000, wantStatus: http.StatusTooManyRequests, wantErrorType: "rate_limit_error",
+			wantHeader: map[string]string{"retry-after": ""},
+		},
+		{
+			name: "每日费用预算用完", client: "budget-daily-cost", budget: "{daily: {cost: 1}}",
+			usedCost: 1.2, wantStatus: http.StatusTooManyRequests, wantErrorType: "rate_limit_error",
+			wantHeader: map[string]string{"retry-after": ""},
+		},
+		{
+			name: "每月令牌预算用完", client: "budget-monthly-tokens", budget: "{monthly: {tokens: 500}}",
+			usedTokens: 600, wantStatus: http.StatusForbidden, wantErrorType: "permission_error",
+		},
+		{
+			name: "每月费用预算用完", client: "budget-monthly-cost", budget: "{daily: {cost: 10}, monthly: {cost: 5}}",
+			usedCost: 5, wantStatus: http.StatusForbidden, wantErrorType: "permission_error",
+		},
+		{
+			name: "预算内返回剩余额度", client: "budget-remaining", budget: "{daily: {tokens: 1000}, monthly: {cost: 10}}",
+			usedTokens: 100, usedCost: 1, wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"x-proxy-budget-daily-tokens-remaining": "900",
+				"x-proxy-budget-monthly-cost-remaining": "9.0000",
+			},
+		},
+		{
+			name: "达到 warn_at 时警告", client: "budget-warning", budget: "{daily: {tokens: 1000}, warn_at: 0.5}",
+			usedTokens: 600, wantStatus: http.StatusOK,
+			wantHeader: map[string]string{budgetWarningHeader: "daily tokens 60% (600 of 1000)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+				return jsonResponse(http.StatusOK, chatCompletionResponse)
+			})
+			client := uniqueClient(tt.client)
+			cfg := loadBudgetConfig(t, upstream.URL, client, tt.budget)
+			config.RecordUsage(client, tt.usedTokens, 0, tt.usedCost)
+
+			resp := postWithHeaders(t, newTestApp(cfg), "/v1/messages", budgetRequest, map[string]string{"x-api-key": "sk-" + client})
+			defer func() { _ = resp.Body.Close() }()
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("状态码 = %d，应为 %d，响应: %s", resp.StatusCode, tt.wantStatus, data)
+			}
+			if tt.wantErrorType != "" {
+				if !strings.Contains(string(data), `"`+tt.wantErrorType+`"`) {
+					t.Errorf("响应 = %s，应为 %s", data, tt.wantErrorType)
+				}
+				if calls := upstream.calls(); len(calls) != 0 {
+					t.Errorf("超出预算的请求不应发送到上游")
+				}
+			}
+			for name, want := range tt.wantHeader {
+				got := resp.Header.Get(name)
+				if got == "" || (want != "" && got != want) {
+					t.Errorf("响应头 %s = %q，应为 %q", name, got, want)
+				}
+			}
+			if retryAfter := resp.Header.Get("retry-after"); retryAfter != "" {
+				if seconds, err := strconv.Atoi(retryAfter); err != nil || seconds <= 0 || seconds > 24*60*60+1 {
+					t.Errorf("retry-after = %q，应为到次日零点的秒数", retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordUsage(t *testing.T) {
+	upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+		return jsonResponse(http.StatusOK, chatCompletionResponse)
+	})
+	client := uniqueClient("usage-recorded")
+	cfg := loadBudgetConfig(t, upstream.URL, client, "{monthly: {cost: 100}}")
+	app := newTestApp(cfg)
+
+	// 认证的客户端：按价格表记录令牌和费用（chatCompletionResponse 为 10 个输入令牌、2 个输出令牌）
+	before := config.UsageFor(client)
+	resp := postWithHeaders(t, app, "/v1/messages", budgetRequest, map[string]string{"x-api-key": "sk-" + client})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("状态码 = %d", resp.StatusCode)
+	}
+	after := config.UsageFor(client)
+	if got := after.Daily.Requests - before.Daily.Requests; got != 1 {
+		t.Errorf("记录的请求数 = %d，应为 1", got)
+	}
+	if got := after.Monthly.Tokens() - before.Monthly.Tokens(); got != 12 {
+		t.Errorf("记录的令牌数 = %d，应为 12", got)
+	}
+	if got, want := after.Monthly.Cost-before.Monthly.Cost, (10*2.5+2*10)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("记录的费用 = %v，应为 %v", got, want)
+	}
+
+	// 共享密钥和未认证的请求不记录用量
+	snapshot := config.UsageSnapshot()
+	resp = postWithHeaders(t, app, "/v1/messages", budgetRequest, map[string]string{"x-api-key": "sk-shared"})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("共享密钥的状态码 = %d", resp.StatusCode)
+	}
+	noAuth := newTestConfig(&config.Backend{BaseURL: upstream.URL})
+	if status, body := postJSON(t, newTestApp(noAuth), "/v1/messages", budgetRequest); status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, body)
+	}
+	if got := config.UsageSnapshot(); !reflect.DeepEqual(got, snapshot) {
+		t.Errorf("共享密钥和未认证的请求不应记录用量: %+v → %+v", snapshot, got)
+	}
+	if calls := upstream.calls(); len(calls) != 3 {
+		t.Errorf("上游调用次数 = %d，应为 3", len(calls))
+	}
+}
@@ -0,0 +1,284 @@
+// capabilities.go 根据上游响应学习（提供商，模型）组合支持的功能：请求成功时记录请求中使用的功能，
+// 上游错误表明不支持工具调用或图片输入时记录为不支持，短时间内（最多 10 分钟）同类请求直接返回错误而不再请求上游；
+// 上游拒绝 reasoning_effort、temperature 等可选参数时删除参数后重试，之后的请求直接删除该参数。
+// 学习结果写入临时目录中的缓存文件，超过 CAPABILITY_CACHE_TTL 后重新检测；不支持工具调用或图片输入的记录只保存在内存中。
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// unsupportedToolsMessages 是上游表示模型不支持工具调用的错误消息片段（小写）
+var unsupportedToolsMessages = []string{
+	"does not support tools",
+	"tool use is not supported",
+	"tools are not supported",
+	"tool calling is not supported",
+	"no endpoints found that support tool use",
+}
+
+// unsupportedImagesMessages 是上游表示模型不支持图片输入的错误消息片段（小写）
+var unsupportedImagesMessages = []string{
+	"does not support image",
+	"does not support vision",
+	"image input is not supported",
+	"images are not supported",
+	"image_url is only supported",
+	"no endpoints found that support image input",
+}
+
+// maxParameterRetries 是一个请求因参数错误（包括 max_completion_tokens）删除参数后重试的最大次数
+const maxParameterRetries = 4
+
+// strippableParameters 是上游拒绝时可以删除后重试的可选参数，同一能力的字段一起删除
+var strippableParameters = []struct {
+	capability config.Capability
+	fields     []string
+}{
+	{config.CapabilityReasoning, []string{"reasoning_effort", "reasoning"}},
+	{config.CapabilityStreamOptions, []string{"stream_options"}},
+	{config.CapabilityParallelToolCalls, []string{"parallel_tool_calls"}},
+	{config.CapabilityTemperature, []string{"temperature"}},
+	{config.CapabilityTopP, []string{"top_p"}},
+	{config.CapabilityToolChoiceRequired, []string{"tool_choice"}},
+}
+
+// unsupportedParameterMessages 是上游表示参数不被支持的错误消息片段（小写），需要同时提到请求中的参数名称。
+// 例如 OpenAI 的 "Unsupported parameter: 'reasoning_effort'"、Azure 的 "Unrecognized request argument supplied"、
+// Groq 的 "property 'x' is unsupported"、Gemini 的 "Unknown name" 和 vLLM 的 "Extra inputs are not permitted"。
+var unsupportedParameterMessages = []string{
+	"unsupported",
+	"not supported",
+	"does not support",
+	"unrecognized",
+	"unknown name",
+	"unknown parameter",
+	"unexpected keyword",
+	"extra inputs are not permitted",
+	"not permitted",
+	"not allowed",
+}
+
+// capabilityKey 返回请求的能力缓存键
+func capabilityKey(prov provider.Provider, req *models.OpenAIRequest) config.CacheKey {
+	return config.CacheKey{BaseURL: prov.GetBaseURL(), Model: req.Model}
+}
+
+// requestUsesImages 如果请求的消息中包含图片则返回 true
+func requestUsesImages(req *models.OpenAIRequest) bool {
+	return req.Original != nil && converter.HasImages(req.Original)
+}
+
+// requestSends 如果字段会出现在上游请求体中（没有被参数策略删除）则返回 true
+func requestSends(req *models.OpenAIRequest, field string) bool {
+	for _, dropped := range req.Drop {
+		if dropped == field {
+			return false
+		}
+	}
+	return true
+}
+
+// sendsParameter 如果请求会向上游发送可删除的参数则返回 true（tool_choice 只在值为 "required" 时计入）
+func sendsParameter(req *models.OpenAIRequest, field string) bool {
+	value, ok := converter.RequestField(req, field)
+	if !ok || !requestSends(req, field) {
+		return false
+	}
+	if field == "tool_choice" {
+		return value == "required"
+	}
+	return true
+}
+
+// mentionsField 如果错误消息中出现完整的字段名称则返回 true（reasoning 不匹配 reasoning_effort）
+func mentionsField(message, field string) bool {
+	isNameChar := func(b byte) bool {
+		return b == '_' || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9')
+	}
+	for offset := 0; ; {
+		i := strings.Index(message[offset:], field)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(field)
+		if (start == 0 || !isNameChar(message[start-1])) && (end == len(message) || !isNameChar(message[end])) {
+			return true
+		}
+		offset = end
+	}
+}
+
+// isClientError 如果是请求本身导致的上游错误（4xx，不包括速率限制）则返回 true
+func isClientError(err error) (*errors.ProxyError, bool) {
+	pe, ok := errors.AsProxyError(err)
+	if !ok || pe.StatusCode < http.StatusBadRequest || pe.StatusCode >= http.StatusInternalServerError ||
+		pe.StatusCode == http.StatusTooManyRequests {
+		return nil, false
+	}
+	return pe, true
+}
+
+// withoutUnsupportedParameter 在上游错误表明模型不支持请求中的可选参数时，记录为不支持并返回删除该参数后的请求副本；
+// 错误与可删除的参数无关时返回 nil
+func withoutUnsupportedParameter(prov provider.Provider, req *models.OpenAIRequest, err error, cfg *config.Config) *models.OpenAIRequest {
+	pe, ok := isClientError(err)
+	if !ok {
+		return nil
+	}
+	message := strings.ToLower(pe.Message)
+	indicated := false
+	for _, pattern := range unsupportedParameterMessages {
+		indicated = indicated || strings.Contains(message, pattern)
+	}
+	if !indicated {
+		return nil
+	}
+
+	for _, param := range strippableParameters {
+		var fields []string
+		mentioned := false
+		for _, field := range param.fields {
+			if sendsParameter(req, field) {
+				fields = append(fields, field)
+				mentioned = mentioned || mentionsField(message, field)
+			}
+		}
+		if !mentioned {
+			continue
+		}
+
+		retryReq := *req
+		for _, field := range fields {
+			converter.DropRequestField(&retryReq, field)
+		}
+		config.LearnCapability(capabilityKey(prov, req), param.capability, false)
+		if cfg.Debug {
+			fmt.Printf("[调试] 上游不支持模型 %s 的参数 %s，已缓存，删除后重试\n", req.Model, strings.Join(fields, "、"))
+		}
+		return &retryReq
+	}
+	return nil
+}
+
+// stripLearnedParameters 删除已知模型不支持的可选参数，返回请求副本；没有需要删除的参数时返回原请求
+func stripLearnedParameters(prov provider.Provider, req *models.OpenAIRequest, cfg *config.Config) *models.OpenAIRequest {
+	key := capabilityKey(prov, req)
+	stripped := req
+	var dropped []string
+	for _, param := range strippableParameters {
+		if supported, known := cfg.LookupCapability(key, param.capability); !known || supported {
+			continue
+		}
+		for _, field := range param.fields {
+			if !sendsParameter(stripped, field) {
+				continue
+			}
+			if stripped == req {
+				copied := *req
+				stripped = &copied
+			}
+			converter.DropRequestField(stripped, field)
+			dropped = append(dropped, field)
+		}
+	}
+
+	if cfg.Debug && len(dropped) > 0 {
+		fmt.Printf("[调试] 缓存命中：模型 %s 不支持 %s，已从请求中删除\n", req.Model, strings.Join(dropped, "、"))
+	}
+	return stripped
+}
+
+// learnRequestCapabilities 在上游请求成功后记录请求中使用的功能为支持。
+// max_completion_tokens、stream_options 和推理等可选参数只在聊天完成协议中按原样发送，其他协议不记录这些参数。
+func learnRequestCapabilities(prov provider.Provider, req *models.OpenAIRequest, cfg *config.Config) {
+	key := capabilityKey(prov, req)
+	var learned []string
+	learn := func(capability config.Capability) {
+		config.LearnCapability(key, capability, true)
+		learned = append(learned, string(capability))
+	}
+
+	if req.MaxCompletionTokens > 0 {
+		learn(config.CapabilityMaxCompletionTokens)
+	}
+	if len(req.Tools) > 0 && requestSends(req, "tools") {
+		learn(config.CapabilityTools)
+	}
+	if requestUsesImages(req) {
+		learn(config.CapabilityImages)
+	}
+	if prov.Backend().Protocol == config.ProtocolChatCompletions {
+		for _, param := range strippableParameters {
+			for _, field := range param.fields {
+				if sendsParameter(req, field) {
+					learn(param.capability)
+					break
+				}
+			}
+		}
+	}
+
+	if cfg.Debug && len(learned) > 0 {
+		fmt.Printf("[调试] 已缓存：模型 %s 支持 %s\n", req.Model, strings.Join(learned, "、"))
+	}
+}
+
+// learnFromUpstreamError 在上游错误表明模型不支持请求中的工具调用或图片输入时记录为不支持
+func learnFromUpstreamError(prov provider.Provider, req *models.OpenAIRequest, err error, cfg *config.Config) {
+	pe, ok := isClientError(err)
+	if !ok {
+		return
+	}
+
+	message := strings.ToLower(pe.Message)
+	learn := func(capability config.Capability, patterns []string) {
+		for _, pattern := range patterns {
+			if strings.Contains(message, pattern) {
+				config.LearnCapability(capabilityKey(prov, req), capability, false)
+				if cfg.Debug {
+					fmt.Printf("[调试] 已缓存：模型 %s 不支持 %s\n", req.Model, capability)
+				}
+				return
+			}
+		}
+	}
+	if len(req.Tools) > 0 {
+		learn(config.CapabilityTools, unsupportedToolsMessages)
+	}
+	if requestUsesImages(req) {
+		learn(config.CapabilityImages, unsupportedImagesMessages)
+	}
+}
+
+// checkLearnedCapabilities 如果请求使用了已知模型不支持的工具调用或图片输入，返回说明原因的错误
+func checkLearnedCapabilities(prov provider.Provider, req *models.OpenAIRequest, cfg *config.Config) *errors.ProxyError {
+	key := capabilityKey(prov, req)
+	unsupported := func(capability config.Capability) bool {
+		supported, known := cfg.LookupCapability(key, capability)
+		return known && !supported
+	}
+
+	var features []string
+	if len(req.Tools) > 0 && requestSends(req, "tools") && unsupported(config.CapabilityTools) {
+		features = append(features, "工具调用")
+	}
+	if requestUsesImages(req) && unsupported(config.CapabilityImages) {
+		features = append(features, "图片输入")
+	}
+	if len(features) == 0 {
+		return nil
+	}
+	return errors.NewInvalidRequestError(fmt.Sprintf(
+		"模型 %s 不支持%s（根据最近的上游错误判断，10 分钟后重新检测），请在路由规则中为此类请求选择其他模型",
+		req.Model, strings.Join(features, "和"))).WithModel(req.Model)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+// chatCompletionResponse 是测试上游返回的最小聊天完成响应
+const chatCompletionResponse = `{"id":"chatcmpl-1","object":"chat.completion","model":"test-model",` +
+	`"choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],` +
+	`"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`
+
+// toolRequest 是带工具和 temperature 的 Claude 请求（Claude Code 的每个请求都带工具）
+const toolRequest = `{"model":"claude-sonnet-4-5","max_tokens":100,"temperature":0.5,` +
+	`"messages":[{"role":"user","content":"hi"}],` +
+	`"tools":[{"name":"read","description":"读取文件","input_schema":{"type":"object","properties":{"path":{"type":"string"}}}}]}`
+
+func TestParameterRejectionStripsAndRetries(t *testing.T) {
+	upstream := newFakeUpstream(t, func(req upstreamRequest) upstreamResponse {
+		if _, ok := req.Body["temperature"]; ok {
+			return jsonResponse(http.StatusBadRequest, `{"error":{"message":"Unsupported parameter: 'temperature' is not supported with this model.","type":"invalid_request_error"}}`)
+		}
+		return jsonResponse(http.StatusOK, chatCompletionResponse)
+	})
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL}))
+
+	// 第一个请求：上游拒绝 temperature，删除后重试成功
+	status, resp := postJSON(t, app, "/v1/messages", toolRequest)
+	if status != http.StatusOK {
+		t.Fatalf("第一个请求状态码 = %d，响应: %s", status, resp)
+	}
+	calls := upstream.calls()
+	if len(calls) < 2 || len(calls) > maxParameterRetries+1 {
+		t.Fatalf("第一个请求的上游调用次数 = %d，应在 2 到 %d 之间", len(calls), maxParameterRetries+1)
+	}
+	last := calls[len(calls)-1].Body
+	if _, ok := last["temperature"]; ok {
+		t.Errorf("重试请求仍然包含 temperature")
+	}
+	if _, ok := last["tools"]; !ok {
+		t.Errorf("重试请求不应删除 tools")
+	}
+
+	// 第二个请求：直接删除已学习的参数，工具调用照常发送到上游而不是在本地被拒绝
+	status, resp = postJSON(t, app, "/v1/messages", toolRequest)
+	if status != http.StatusOK {
+		t.Fatalf("第二个请求状态码 = %d，响应: %s", status, resp)
+	}
+	calls = upstream.calls()
+	if len(calls) != 1 {
+		t.Fatalf("第二个请求的上游调用次数 = %d，应为 1", len(calls))
+	}
+	if _, ok := calls[0].Body["temperature"]; ok {
+		t.Errorf("第二个请求应直接删除已学习的 temperature")
+	}
+	if _, ok := calls[0].Body["tools"]; !ok {
+		t.Errorf("第二个请求应包含 tools")
+	}
+	if !strings.Contains(resp, `"ok"`) {
+		t.Errorf("响应缺少上游内容: %s", resp)
+	}
+}
@@ -0,0 +1,363 @@
+// chat_completions.go 实现 OpenAI 兼容的 /v1/chat/completions 入站端点。
+//
+// 入站 OpenAI 请求先反向转换为 Claude 请求，再走与 /v1/messages 相同的
+// 模型路由、上游调用、重试和日志流程；得到的 Claude 响应（或 Claude SSE 事件流）
+// 再转换回 OpenAI 格式返回给客户端。
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// handleChatCompletions 是 /v1/chat/completions 端点的处理器
+func handleChatCompletions(c *fiber.Ctx, cfg *config.Config) error {
+	// 调试：记录原始请求
+	if cfg.Debug {
+		fmt.Printf("\n=== OpenAI 入站请求 ===\n%s\n=======================\n", string(c.Body()))
+	}
+
+	var inboundReq models.OpenAIRequest
+	if err := c.BodyParser(&inboundReq); err != nil {
+		return sendOpenAIError(c, errors.NewInvalidRequestError(fmt.Sprintf("Invalid request body: %v", err)))
+	}
+
+	// OpenAI → Claude → 上游格式
+	claudeReq, err := converter.ConvertOpenAIRequest(&inboundReq)
+	if err != nil {
+		return sendOpenAIError(c, errors.NewInvalidRequestError(err.Error()))
+	}
+
+	includeUsage := false
+	if v, ok := inboundReq.StreamOptions["include_usage"].(bool); ok {
+		includeUsage = v
+	}
+
+	// 按单个请求的覆盖或模型路由规则选择后端
+	route, pe := requestRoute(c, claudeReq, cfg)
+	if pe != nil {
+		return sendOpenAIError(c, pe)
+	}
+	cfg = cfg.WithBackend(route.Backend)
+	logRoute(cfg, claudeReq.Model, route)
+
+	// Anthropic 原生后端直接发送转换后的 Claude 请求
+	prov := provider.New(cfg)
+	if prov.Backend().Protocol == config.ProtocolAnthropic {
+		return handleAnthropicChatCompletions(c, prov, claudeReq, route, inboundReq.Model, includeUsage, cfg)
+	}
+
+	openaiReq, err := converter.ConvertRequest(*claudeReq, route, cfg)
+	if err != nil {
+		return sendOpenAIError(c, errors.NewInvalidRequestError(err.Error()))
+	}
+
+	upstreamStreaming := prov.SupportsStreaming() && !cfg.IsStreamingDisabled(openaiReq.Model)
+	streamRequested := inboundReq.Stream != nil && *inboundReq.Stream
+	// 与 /v1/messages 相同：后端可以流式时流式调用，在应用参数策略之前设置
+	converter.SetUpstreamStreaming(openaiReq, streamRequested && upstreamStreaming, cfg)
+	logParamPolicy(cfg, converter.ApplyParamPolicy(openaiReq, route.Policy))
+
+	if cfg.Debug {
+		openaiReqJSON, _ := json.MarshalIndent(openaiReq, "", "  ")
+		fmt.Printf("\n=== 上游请求（来自 /v1/chat/completions）===\n%s\n===================\n", string(openaiReqJSON))
+	}
+
+	if streamRequested && upstreamStreaming {
+		return handleChatCompletionsStream(c, prov, openaiReq, inboundReq.Model, includeUsage, cfg)
+	}
+
+	// 记录计时用于简单日志
+	startTime := time.Now()
+
+	// 客户端断开连接时取消上游请求
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	claudeResp, upstreamHeader, err := completeMessage(ctx, prov, openaiReq, inboundReq.Model, cfg, startTime)
+	if err != nil {
+		if ctx.Err() != nil {
+			logClientDisconnected(cfg, openaiReq.Model)
+			return nil
+		}
+		return sendOpenAIError(c, toProxyError(err, "OpenAI API 错误"))
+	}
+	applyRateLimitHeaders(c, prov, upstreamHeader)
+
+	if !streamRequested {
+		return c.JSON(converter.ConvertClaudeResponseToOpenAI(claudeResp, inboundReq.Model))
+	}
+
+	// 后端无法流式传输：将完整响应重放为 OpenAI 数据块
+	setSSEHeaders(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var sseBuf bytes.Buffer
+		sseWriter := bufio.NewWriter(&sseBuf)
+		writeClaudeResponseSSE(sseWriter, claudeResp)
+		_ = sseWriter.Flush()
+		_ = writeClaudeSSEAsOpenAI(w, &sseBuf, inboundReq.Model, includeUsage)
+	})
+	return nil
+}
+
+// handleChatCompletionsStream 处理流式的 /v1/chat/completions 请求。
+// 上游流先经 streamOpenAIToClaude 转换为 Claude SSE（写入管道），再转换为 OpenAI 数据块。
+func handleChatCompletionsStream(c *fiber.Ctx, prov provider.Provider, openaiReq *models.OpenAIRequest, requestedModel string, includeUsage bool, cfg *config.Config) error {
+	// 记录计时用于简单日志
+	startTime := time.Now()
+
+	ctx, cancel := requestContext(c)
+
+	resp, err := callOpenAIStream(ctx, prov, openaiReq, cfg)
+	if err != nil {
+		clientGone := ctx.Err() != nil
+		cancel()
+		if clientGone {
+			logClientDisconnected(cfg, openaiReq.Model)
+			return nil
+		}
+		return sendOpenAIError(c, toProxyError(err, "流式请求失败"))
+	}
+
+	setSSEHeaders(c)
+	applyRateLimitHeaders(c, prov, resp.Header)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		defer func() { _ = resp.Body.Close() }()
+
+		pr, pw := io.Pipe()
+		go func() {
+			claudeWriter := bufio.NewWriter(pw)
+			streamOpenAIToClaude(ctx, claudeWriter, resp.Body, prov, openaiReq, cfg, startTime)
+			_ = claudeWriter.Flush()
+			_ = pw.Close()
+		}()
+
+		if err := writeClaudeSSEAsOpenAI(w, pr, requestedModel, includeUsage); err != nil {
+			// 客户端已断开 - 关闭管道使 Claude 转换停止，并取消上游请求
+			_ = pr.CloseWithError(err)
+			cancel()
+			logClientDisconnected(cfg, openaiReq.Model)
+		}
+	})
+
+	return nil
+}
+
+// setSSEHeaders 设置 SSE 响应头
+func setSSEHeaders(c *fiber.Ctx) {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+}
+
+// sendOpenAIError 以 OpenAI 错误格式返回错误响应，并设置 retry-after 和 x-request-id 头
+func sendOpenAIError(c *fiber.Ctx, pe *errors.ProxyError) error {
+	if pe.RetryAfter != "" {
+		c.Set("retry-after", pe.RetryAfter)
+	}
+	if pe.RequestID != "" {
+		c.Set("x-request-id", pe.RequestID)
+	}
+	return c.Status(pe.StatusCode).JSON(pe.ToOpenAIError())
+}
+
+// openAIChunkWriter 将 Claude SSE 事件转换为 OpenAI 聊天完成数据块
+type openAIChunkWriter struct {
+	w            *bufio.Writer
+	id           string
+	model        string
+	created      int64
+	includeUsage bool
+
+	toolIndexes   map[int]int // Claude 内容块索引 -> OpenAI tool_calls 索引
+	nextToolIndex int
+	usage         models.Usage
+	finishReason  string
+	err           error
+}
+
+// writeClaudeSSEAsOpenAI 读取 Claude SSE 事件流并以 OpenAI 数据块格式写入客户端。
+// Claude 的 ping 事件转换为 SSE 注释以保持连接；error 事件转换为 OpenAI 错误对象。
+// 返回向客户端写入时的错误（客户端已断开）。
+func writeClaudeSSEAsOpenAI(w *bufio.Writer, reader io.Reader, model string, includeUsage bool) error {
+	cw := &openAIChunkWriter{
+		w:            w,
+		id:           fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		model:        model,
+		created:      time.Now().Unix(),
+		includeUsage: includeUsage,
+		toolIndexes:  make(map[int]int),
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() && cw.err == nil {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			continue
+		}
+		if cw.handleEvent(event) {
+			break
+		}
+	}
+
+	return cw.err
+}
+
+// handleEvent 处理单个 Claude SSE 事件，流结束（message_stop 或 error）时返回 true
+func (cw *openAIChunkWriter) handleEvent(event map[string]interface{}) bool {
+	switch event["type"] {
+	case constants.EventMessageStart:
+		if message, ok := event["message"].(map[string]interface{}); ok {
+			mergeUsage(&cw.usage, message["usage"])
+		}
+		cw.writeDelta(map[string]interface{}{"role": constants.RoleAssistant, "content": ""})
+
+	case constants.EventPing:
+		_, _ = cw.w.WriteString(": ping\n\n")
+		cw.flush()
+
+	case constants.EventContentBlockStart:
+		contentBlock, _ := event["content_block"].(map[string]interface{})
+		if contentBlock["type"] != constants.ContentTypeToolUse {
+			return false
+		}
+		toolIndex := cw.nextToolIndex
+		cw.nextToolIndex++
+		cw.toolIndexes[intField(event, "index")] = toolIndex
+		cw.writeDelta(map[string]interface{}{
+			"tool_calls": []interface{}{map[string]interface{}{
+				"index": toolIndex,
+				"id":    contentBlock["id"],
+				"type":  constants.ToolTypeFunction,
+				"function": map[string]interface{}{
+					"name":      contentBlock["name"],
+					"arguments": "",
+				},
+			}},
+		})
+
+	case constants.EventContentBlockDelta:
+		delta, _ := event["delta"].(map[string]interface{})
+		switch delta["type"] {
+		case constants.DeltaTypeTextDelta:
+			cw.writeDelta(map[string]interface{}{"content": delta["text"]})
+		case constants.DeltaTypeThinkingDelta:
+			cw.writeDelta(map[string]interface{}{"reasoning_content": delta["thinking"]})
+		case constants.DeltaTypeInputJSONDelta:
+			toolIndex, ok := cw.toolIndexes[intField(event, "index")]
+			if !ok {
+				return false
+			}
+			cw.writeDelta(map[string]interface{}{
+				"tool_calls": []interface{}{map[string]interface{}{
+					"index":    toolIndex,
+					"function": map[string]interface{}{"arguments": delta["partial_json"]},
+				}},
+			})
+		}
+
+	case constants.EventMessageDelta:
+		if delta, ok := event["delta"].(map[string]interface{}); ok {
+			if stopReason, ok := delta["stop_reason"].(string); ok && stopReason != "" {
+				cw.finishReason = converter.ConvertStopReason(stopReason)
+			}
+		}
+		mergeUsage(&cw.usage, event["usage"])
+
+	case constants.EventMessageStop:
+		cw.finish()
+		return true
+
+	case constants.EventError:
+		errObj, _ := event["error"].(map[string]interface{})
+		cw.writeData(map[string]interface{}{"error": errObj})
+		cw.flush()
+		return true
+	}
+	return false
+}
+
+// finish 写入带 finish_reason 的最终数据块、可选的使用量数据块和 [DONE] 标记
+func (cw *openAIChunkWriter) finish() {
+	finishReason := cw.finishReason
+	if finishReason == "" {
+		finishReason = constants.FinishReasonStop
+	}
+	cw.writeData(cw.chunk(map[string]interface{}{}, finishReason))
+
+	if cw.includeUsage {
+		usageChunk := cw.chunk(nil, "")
+		usageChunk["choices"] = []interface{}{}
+		usageChunk["usage"] = map[string]interface{}{
+			"prompt_tokens":     cw.usage.InputTokens,
+			"completion_tokens": cw.usage.OutputTokens,
+			"total_tokens":      cw.usage.InputTokens + cw.usage.OutputTokens,
+		}
+		cw.writeData(usageChunk)
+	}
+
+	_, _ = cw.w.WriteString("data: [DONE]\n\n")
+	cw.flush()
+}
+
+// writeDelta 写入只包含 delta 的数据块
+func (cw *openAIChunkWriter) writeDelta(delta map[string]interface{}) {
+	cw.writeData(cw.chunk(delta, ""))
+	cw.flush()
+}
+
+// chunk 构建 chat.completion.chunk 对象
+func (cw *openAIChunkWriter) chunk(delta map[string]interface{}, finishReason string) map[string]interface{} {
+	choice := map[string]interface{}{
+		"index":         0,
+		"delta":         delta,
+		"finish_reason": nil,
+	}
+	if finishReason != "" {
+		choice["finish_reason"] = finishReason
+	}
+	return map[string]interface{}{
+		"id":      cw.id,
+		"object":  "chat.completion.chunk",
+		"created": cw.created,
+		"model":   cw.model,
+		"choices": []interface{}{choice},
+	}
+}
+
+// writeData 写入一行 SSE data
+func (cw *openAIChunkWriter) writeData(data interface{}) {
+	dataJSON, _ := json.Marshal(data)
+	_, _ = fmt.Fprintf(cw.w, "data: %s\n\n", dataJSON)
+}
+
+// flush 刷新写入器并记录首个写入错误
+func (cw *openAIChunkWriter) flush() {
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+}
@@ -0,0 +1,363 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// openAIStream 是客户端收到的 OpenAI 数据块流
+type openAIStream struct {
+	Chunks   []map[string]interface{}
+	Comments int  // SSE 注释行（ping）的数量
+	Done     bool // 是否以 [DONE] 结束
+}
+
+// parseOpenAIStream 解析 OpenAI SSE 响应体
+func parseOpenAIStream(t *testing.T, body string) openAIStream {
+	t.Helper()
+	var s openAIStream
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case strings.HasPrefix(line, ":"):
+			s.Comments++
+		case line == "data: [DONE]":
+			s.Done = true
+		case strings.HasPrefix(line, "data: "):
+			var chunk map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk); err != nil {
+				t.Fatalf("解析数据块失败: %v: %s", err, line)
+			}
+			s.Chunks = append(s.Chunks, chunk)
+		}
+	}
+	return s
+}
+
+// choice 返回数据块的第一个选择（没有时为 nil）
+func choice(chunk map[string]interface{}) map[string]interface{} {
+	choices, _ := chunk["choices"].([]interface{})
+	if len(choices) == 0 {
+		return nil
+	}
+	c, _ := choices[0].(map[string]interface{})
+	return c
+}
+
+// content 拼接所有数据块 delta 中的文本内容
+func (s openAIStream) content() string {
+	var sb strings.Builder
+	for _, chunk := range s.Chunks {
+		if delta, ok := choice(chunk)["delta"].(map[string]interface{}); ok {
+			text, _ := delta["content"].(string)
+			sb.WriteString(text)
+		}
+	}
+	return sb.String()
+}
+
+// toolCalls 按 tool_calls 索引汇总数据块中的工具调用：返回每个调用的 id、名称和拼接后的参数
+func (s openAIStream) toolCalls() []map[string]string {
+	var calls []map[string]string
+	for _, chunk := range s.Chunks {
+		delta, _ := choice(chunk)["delta"].(map[string]interface{})
+		deltas, _ := delta["tool_calls"].([]interface{})
+		for _, d := range deltas {
+			tc := d.(map[string]interface{})
+			index := int(tc["index"].(float64))
+			for len(calls) <= index {
+				calls = append(calls, map[string]string{})
+			}
+			if id, ok := tc["id"].(string); ok {
+				calls[index]["id"] = id
+			}
+			fn, _ := tc["function"].(map[string]interface{})
+			if name, ok := fn["name"].(string); ok {
+				calls[index]["name"] = name
+			}
+			args, _ := fn["arguments"].(string)
+			calls[index]["arguments"] += args
+		}
+	}
+	return calls
+}
+
+// finishReason 返回数据块中出现的 finish_reason
+func (s openAIStream) finishReason() string {
+	for _, chunk := range s.Chunks {
+		if reason, ok := choice(chunk)["finish_reason"].(string); ok {
+			return reason
+		}
+	}
+	return ""
+}
+
+// usage 返回使用量数据块（没有时为 nil）
+func (s openAIStream) usage() map[string]interface{} {
+	for _, chunk := range s.Chunks {
+		if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+			return usage
+		}
+	}
+	return nil
+}
+
+func TestWriteClaudeSSEAsOpenAI(t *testing.T) {
+	toolUse := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":10,"output_tokens":0}}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"读取中"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"read","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"a.txt\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_2","name":"list","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":2}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+	errorStream := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":10,"output_tokens":0}}}
+
+event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"过载"}}
+
+`
+
+	tests := []struct {
+		name         string
+		sse          string
+		includeUsage bool
+		check        func(t *testing.T, s openAIStream)
+	}{
+		{
+			name: "文本和工具调用增量",
+			sse:  toolUse,
+			check: func(t *testing.T, s openAIStream) {
+				if !s.Done {
+					t.Errorf("流没有以 [DONE] 结束")
+				}
+				if s.Comments != 1 {
+					t.Errorf("ping 注释数 = %d，应为 1", s.Comments)
+				}
+				if delta := choice(s.Chunks[0])["delta"].(map[string]interface{}); delta["role"] != "assistant" {
+					t.Errorf("第一个数据块 delta = %v，应带 role", delta)
+				}
+				if got := s.content(); got != "读取中" {
+					t.Errorf("内容 = %q", got)
+				}
+				calls := s.toolCalls()
+				want := []map[string]string{
+					{"id": "toolu_1", "name": "read", "arguments": `{"path":"a.txt"}`},
+					{"id": "toolu_2", "name": "list", "arguments": "{}"},
+				}
+				if len(calls) != len(want) {
+					t.Fatalf("工具调用 = %v", calls)
+				}
+				for i := range want {
+					for k, v := range want[i] {
+						if calls[i][k] != v {
+							t.Errorf("工具调用 %d 的 %s = %q，应为 %q", i, k, calls[i][k], v)
+						}
+					}
+				}
+				if got := s.finishReason(); got != "tool_calls" {
+					t.Errorf("finish_reason = %q，应为 tool_calls", got)
+				}
+				if s.usage() != nil {
+					t.Errorf("未请求 include_usage 时不应发送使用量数据块")
+				}
+			},
+		},
+		{
+			name:         "include_usage 发送使用量数据块",
+			sse:          toolUse,
+			includeUsage: true,
+			check: func(t *testing.T, s openAIStream) {
+				usage := s.usage()
+				if usage == nil {
+					t.Fatalf("缺少使用量数据块")
+				}
+				if usage["prompt_tokens"] != float64(10) || usage["completion_tokens"] != float64(7) || usage["total_tokens"] != float64(17) {
+					t.Errorf("usage = %v", usage)
+				}
+				// 使用量数据块位于 finish_reason 数据块之后，choices 为空
+				last := s.Chunks[len(s.Chunks)-1]
+				if choices, _ := last["choices"].([]interface{}); len(choices) != 0 || last["usage"] == nil {
+					t.Errorf("最后一个数据块 = %v，应为使用量数据块", last)
+				}
+			},
+		},
+		{
+			name:         "错误事件转为 OpenAI 错误对象",
+			sse:          errorStream,
+			includeUsage: true,
+			check: func(t *testing.T, s openAIStream) {
+				if s.Done {
+					t.Errorf("出错的流不应以 [DONE] 结束")
+				}
+				errObj, _ := s.Chunks[len(s.Chunks)-1]["error"].(map[string]interface{})
+				if errObj["type"] != "overloaded_error" {
+					t.Errorf("最后一个数据块 = %v，应为错误对象", s.Chunks[len(s.Chunks)-1])
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := bufio.NewWriter(&out)
+			if err := writeClaudeSSEAsOpenAI(w, strings.NewReader(tt.sse), "claude-sonnet-4-5", tt.includeUsage); err != nil {
+				t.Fatalf("writeClaudeSSEAsOpenAI 返回错误: %v", err)
+			}
+			s := parseOpenAIStream(t, out.String())
+			for _, chunk := range s.Chunks {
+				if _, isErr := chunk["error"]; !isErr && chunk["model"] != "claude-sonnet-4-5" {
+					t.Errorf("数据块 model = %v，应为请求的模型", chunk["model"])
+				}
+			}
+			tt.check(t, s)
+		})
+	}
+}
+
+func TestWriteClaudeSSEAsOpenAIReplay(t *testing.T) {
+	// 非流式响应先重放为 Claude SSE，再转换为 OpenAI 数据块（后端不支持流式时的路径）
+	stopReason := "max_tokens"
+	resp := &models.ClaudeResponse{
+		ID:         "msg_1",
+		Model:      "test-model",
+		Content:    []models.ContentBlock{{Type: "text", Text: "部分回答"}},
+		StopReason: &stopReason,
+		Usage:      models.Usage{InputTokens: 3, OutputTokens: 4},
+	}
+	var sse bytes.Buffer
+	sseWriter := bufio.NewWriter(&sse)
+	writeClaudeResponseSSE(sseWriter, resp)
+	_ = sseWriter.Flush()
+
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	if err := writeClaudeSSEAsOpenAI(w, &sse, "claude-sonnet-4-5", true); err != nil {
+		t.Fatalf("writeClaudeSSEAsOpenAI 返回错误: %v", err)
+	}
+	s := parseOpenAIStream(t, out.String())
+	if !s.Done || s.content() != "部分回答" || s.finishReason() != "length" {
+		t.Errorf("done = %v，内容 = %q，finish_reason = %q", s.Done, s.content(), s.finishReason())
+	}
+	if usage := s.usage(); usage == nil || usage["prompt_tokens"] != float64(3) || usage["completion_tokens"] != float64(4) {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+// chatStreamRequest 是带工具和 include_usage 的流式 OpenAI 请求
+const chatStreamRequest = `{"model":"claude-sonnet-4-5","stream":true,"stream_options":{"include_usage":true},` +
+	`"messages":[{"role":"user","content":"读取 a.txt"}],` +
+	`"tools":[{"type":"function","function":{"name":"read","parameters":{"type":"object","properties":{"path":{"type":"string"}}}}}]}`
+
+func TestChatCompletionsStream(t *testing.T) {
+	upstream := streamingUpstream(t, "text/event-stream",
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"test-model","choices":[{"index":0,"delta":{"role":"assistant","content":"好的"}}]}`+"\n\n",
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"test-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read","arguments":""}}]}}]}`+"\n\n",
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"test-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":\"a.txt\"}"}}]}}]}`+"\n\n",
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"test-model","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`+"\n\n",
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"test-model","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":6,"total_tokens":18}}`+"\n\n",
+		"data: [DONE]\n\n",
+	)
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL}))
+
+	status, resp := postJSON(t, app, "/v1/chat/completions", chatStreamRequest)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, resp)
+	}
+	req := upstream.last(t)
+	if stream, _ := req.Body["stream"].(bool); !stream || req.Body["model"] != testModel {
+		t.Errorf("上游请求 stream = %v，model = %v", req.Body["stream"], req.Body["model"])
+	}
+
+	s := parseOpenAIStream(t, resp)
+	if !s.Done || s.content() != "好的" || s.finishReason() != "tool_calls" {
+		t.Errorf("done = %v，内容 = %q，finish_reason = %q", s.Done, s.content(), s.finishReason())
+	}
+	calls := s.toolCalls()
+	if len(calls) != 1 || calls[0]["id"] != "call_1" || calls[0]["name"] != "read" || calls[0]["arguments"] != `{"path":"a.txt"}` {
+		t.Errorf("工具调用 = %v", calls)
+	}
+	if usage := s.usage(); usage == nil || usage["prompt_tokens"] != float64(12) || usage["completion_tokens"] != float64(6) {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+func TestChatCompletionsWithoutUpstreamStreaming(t *testing.T) {
+	upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+		return jsonResponse(http.StatusOK, chatCompletionResponse)
+	})
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL, DisableStreaming: true}))
+
+	// 流式请求：非流式调用上游，重放为 OpenAI 数据块
+	status, resp := postJSON(t, app, "/v1/chat/completions", chatStreamRequest)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, resp)
+	}
+	if stream, _ := upstream.last(t).Body["stream"].(bool); stream {
+		t.Errorf("streaming: false 的后端不应收到流式请求")
+	}
+	s := parseOpenAIStream(t, resp)
+	if !s.Done || s.content() != "ok" || s.finishReason() != "stop" {
+		t.Errorf("done = %v，内容 = %q，finish_reason = %q", s.Done, s.content(), s.finishReason())
+	}
+	if usage := s.usage(); usage == nil || usage["prompt_tokens"] != float64(10) {
+		t.Errorf("usage = %v", usage)
+	}
+
+	// 非流式请求：返回 OpenAI 聊天完成响应
+	status, resp = postJSON(t, app, "/v1/chat/completions",
+		`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, resp)
+	}
+	var completion models.OpenAIResponse
+	if err := json.Unmarshal([]byte(resp), &completion); err != nil {
+		t.Fatalf("解析响应失败: %v: %s", err, resp)
+	}
+	if completion.Model != "claude-sonnet-4-5" || len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "ok" {
+		t.Errorf("响应 = %s", resp)
+	}
+}
@@ -0,0 +1,41 @@
+// disconnect.go 检测客户端断开连接（例如 Claude Code 中按下 Esc），
+// 并取消对应的上游请求，避免为无人读取的令牌付费。
+
+package server
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// clientPollInterval 检查客户端连接是否关闭的间隔
+const clientPollInterval = 500 * time.Millisecond
+
+// newClientContext 创建在客户端断开连接时自动取消的请求上下文。
+// 后台 goroutine 定期以非破坏方式探测连接；调用方必须调用返回的 cancel 以停止探测。
+// conn 为 nil 或平台不支持探测时，上下文只会在 cancel 时取消。
+func newClientContext(conn net.Conn) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if conn == nil {
+		return ctx, cancel
+	}
+
+	go func() {
+		ticker := time.NewTicker(clientPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if isConnClosed(conn) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package server
+
+import "net"
+
+// isConnClosed 在不支持 MSG_PEEK 探测的平台上始终返回 false。
+// 这些平台上只能通过写入失败检测客户端断开连接。
+func isConnClosed(conn net.Conn) bool {
+	return false
+}
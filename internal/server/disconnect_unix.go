@@ -0,0 +1,31 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"syscall"
+)
+
+// isConnClosed 通过 MSG_PEEK 非阻塞读取探测对端是否已关闭连接。
+// 不会消耗任何数据，因此不影响后续请求的解析。
+func isConnClosed(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	closed := false
+	_ = rc.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		// n == 0 且无错误表示对端已有序关闭；EAGAIN 表示连接仍然存活
+		closed = n == 0 && err == nil
+		return true
+	})
+	return closed
+}
@@ -0,0 +1,69 @@
+// errors.go 将上游和代理内部的错误统一转换为 ProxyError，
+// 使客户端收到正确的 Claude 错误类型、状态码以及 retry-after / request-id 头。
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/gofiber/fiber/v2"
+)
+
+// upstreamRequestIDHeaders 上游可能返回请求 ID 的响应头（按优先级）
+var upstreamRequestIDHeaders = []string{"request-id", "x-request-id", "openai-request-id", "x-openrouter-request-id"}
+
+// upstreamError 将上游的非 200 响应转换为 ProxyError，并保留 retry-after 和请求 ID
+func upstreamError(prov provider.Provider, resp *http.Response, body []byte) *errors.ProxyError {
+	recordRateLimits(prov, resp.Header)
+
+	pe := prov.HandleError(resp.StatusCode, body)
+	if retryAfter := resp.Header.Get("retry-after"); retryAfter != "" {
+		pe.WithRetryAfter(retryAfter)
+	}
+	for _, header := range upstreamRequestIDHeaders {
+		if requestID := resp.Header.Get(header); requestID != "" {
+			pe.WithRequestID(requestID)
+			break
+		}
+	}
+	return pe
+}
+
+// transportError 将发送请求时的网络错误转换为 ProxyError（超时或连接错误）
+func transportError(err error) *errors.ProxyError {
+	if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() {
+		return errors.NewTimeoutError(fmt.Sprintf("上游请求超时: %v", err)).WithCause(err)
+	}
+	return errors.NewConnectionError(fmt.Sprintf("无法连接上游: %v", err)).WithCause(err)
+}
+
+// toProxyError 将任意错误转换为 ProxyError。
+// 已经是 ProxyError 的原样返回，其他错误包装为带 message 前缀的 api_error。
+func toProxyError(err error, message string) *errors.ProxyError {
+	if pe, ok := errors.AsProxyError(err); ok {
+		return pe
+	}
+	return errors.NewAPIError(fmt.Sprintf("%s: %v", message, err)).WithCause(err)
+}
+
+// sendProxyError 以 Claude 错误格式返回错误响应，并设置 retry-after 和 request-id 头
+func sendProxyError(c *fiber.Ctx, pe *errors.ProxyError) error {
+	if pe.RetryAfter != "" {
+		c.Set("retry-after", pe.RetryAfter)
+	}
+	if pe.RequestID != "" {
+		c.Set("request-id", pe.RequestID)
+	}
+	return c.Status(pe.StatusCode).JSON(pe.ToClaudeError())
+}
+
+// writeSSEProxyError 在流中写入类型化的 error 事件
+func writeSSEProxyError(w *bufio.Writer, pe *errors.ProxyError) {
+	writeSSEEvent(w, "error", pe.ToClaudeError())
+	_ = w.Flush()
+}
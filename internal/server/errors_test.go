@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+)
+
+func TestUpstreamErrorMapping(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		header        map[string]string
+		body          string
+		wantStatus    int
+		wantType      string
+		wantRetry     string
+		wantRequestID string
+	}{
+		{
+			name:       "速率限制保留 retry-after 和请求 ID",
+			status:     http.StatusTooManyRequests,
+			header:     map[string]string{"retry-after": "17", "x-request-id": "req_rl"},
+			body:       `{"error":{"message":"Rate limit reached","type":"tokens","code":"rate_limit_exceeded"}}`,
+			wantStatus: http.StatusTooManyRequests, wantType: "rate_limit_error", wantRetry: "17", wantRequestID: "req_rl",
+		},
+		{
+			name:       "529 过载",
+			status:     529,
+			header:     map[string]string{"request-id": "req_529"},
+			body:       `{"error":{"message":"Overloaded"}}`,
+			wantStatus: 529, wantType: "overloaded_error", wantRequestID: "req_529",
+		},
+		{
+			name:       "503 映射为过载",
+			status:     http.StatusServiceUnavailable,
+			body:       `upstream unavailable`,
+			wantStatus: 529, wantType: "overloaded_error",
+		},
+		{
+			name:       "413 请求过大",
+			status:     http.StatusRequestEntityTooLarge,
+			header:     map[string]string{"openai-request-id": "req_big"},
+			body:       `{"error":{"message":"Request too large"}}`,
+			wantStatus: http.StatusRequestEntityTooLarge, wantType: "request_too_large", wantRequestID: "req_big",
+		},
+		{
+			name:       "401 认证错误",
+			status:     http.StatusUnauthorized,
+			body:       `{"error":{"message":"Incorrect API key","code":"invalid_api_key"}}`,
+			wantStatus: http.StatusUnauthorized, wantType: "authentication_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+				return upstreamResponse{Status: tt.status, Header: tt.header, Chunks: []string{tt.body}}
+			})
+			app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL}))
+
+			resp := post(t, app, "/v1/messages",
+				`{"model":"claude-sonnet-4-5","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("状态码 = %d，应为 %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("retry-after"); got != tt.wantRetry {
+				t.Errorf("retry-after = %q，应为 %q", got, tt.wantRetry)
+			}
+			if got := resp.Header.Get("request-id"); got != tt.wantRequestID {
+				t.Errorf("request-id = %q，应为 %q", got, tt.wantRequestID)
+			}
+
+			var body struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type string `json:"type"`
+				} `json:"error"`
+				RequestID string `json:"request_id"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("解析错误响应失败: %v", err)
+			}
+			if body.Type != "error" || body.Error.Type != tt.wantType {
+				t.Errorf("错误类型 = %q，应为 %q", body.Error.Type, tt.wantType)
+			}
+			if body.RequestID != tt.wantRequestID {
+				t.Errorf("响应 request_id = %q，应为 %q", body.RequestID, tt.wantRequestID)
+			}
+		})
+	}
+}
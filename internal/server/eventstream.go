@@ -0,0 +1,194 @@
+// eventstream.go 实现 AWS 事件流（application/vnd.amazon.eventstream）二进制分帧的解码。
+// Bedrock ConverseStream 以该格式返回事件，解码后每条消息转换为一行 JSON，以便复用按行处理的流式管道。
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+)
+
+const (
+	// eventStreamPreludeLen 是消息前导的长度（总长度、头部长度、前导 CRC）
+	eventStreamPreludeLen = 12
+	// eventStreamMinMessageLen 是没有头部和载荷的消息长度（前导加消息 CRC）
+	eventStreamMinMessageLen = eventStreamPreludeLen + 4
+	// eventStreamMaxMessageLen 是单条消息允许的最大长度
+	eventStreamMaxMessageLen = 16 * 1024 * 1024
+)
+
+// eventStreamFixedValueLen 是固定长度头部值类型的字节数（bool 没有值字节；6 和 7 为变长的字节数组和字符串）
+var eventStreamFixedValueLen = map[byte]int{0: 0, 1: 0, 2: 1, 3: 2, 4: 4, 5: 8, 8: 8, 9: 16}
+
+// eventStreamMessage 是解码后的事件流消息
+type eventStreamMessage struct {
+	Headers map[string]interface{}
+	Payload []byte
+}
+
+// eventStreamLineReader 将事件流消息逐条解码为 JSON 行：
+// 事件为 {"<:event-type>": 载荷}，异常为 {"<:exception-type>": 载荷}，
+// 错误消息为 {"error": {"code": "<:error-code>", "message": "<:error-message>"}}。
+type eventStreamLineReader struct {
+	source  io.Reader
+	pending bytes.Buffer
+}
+
+// newEventStreamLineReader 创建事件流到 JSON 行的转换读取器
+func newEventStreamLineReader(source io.Reader) io.Reader {
+	return &eventStreamLineReader{source: source}
+}
+
+// Read 实现 io.Reader：缓冲区为空时解码下一条消息
+func (r *eventStreamLineReader) Read(p []byte) (int, error) {
+	for r.pending.Len() == 0 {
+		msg, err := readEventStreamMessage(r.source)
+		if err != nil {
+			return 0, err
+		}
+		line, err := eventStreamLine(msg)
+		if err != nil {
+			return 0, err
+		}
+		if line != nil {
+			r.pending.Write(line)
+			r.pending.WriteByte('\n')
+		}
+	}
+	return r.pending.Read(p)
+}
+
+// eventStreamLine 将消息转换为 JSON 行，没有类型头的消息返回 nil
+func eventStreamLine(msg *eventStreamMessage) ([]byte, error) {
+	messageType, _ := msg.Headers[":message-type"].(string)
+
+	var key string
+	var payload json.RawMessage = msg.Payload
+	switch messageType {
+	case "error":
+		key = "error"
+		code, _ := msg.Headers[":error-code"].(string)
+		message, _ := msg.Headers[":error-message"].(string)
+		body, err := json.Marshal(map[string]string{"code": code, "message": message})
+		if err != nil {
+			return nil, err
+		}
+		payload = body
+	case "exception":
+		key, _ = msg.Headers[":exception-type"].(string)
+	default:
+		key, _ = msg.Headers[":event-type"].(string)
+	}
+	if key == "" {
+		return nil, nil
+	}
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	return json.Marshal(map[string]json.RawMessage{key: payload})
+}
+
+// readEventStreamMessage 读取并校验一条事件流消息。
+// 消息格式：总长度(4) 头部长度(4) 前导CRC(4) 头部 载荷 消息CRC(4)，整数均为大端序，CRC 为 CRC32 (IEEE)。
+func readEventStreamMessage(reader io.Reader) (*eventStreamMessage, error) {
+	prelude := make([]byte, eventStreamPreludeLen)
+	if _, err := io.ReadFull(reader, prelude); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("事件流消息前导不完整")
+		}
+		return nil, err
+	}
+
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("事件流消息前导 CRC 校验失败")
+	}
+	if totalLen < eventStreamMinMessageLen || totalLen > eventStreamMaxMessageLen ||
+		headersLen > totalLen-eventStreamMinMessageLen {
+		return nil, fmt.Errorf("事件流消息长度无效: 总长度=%d 头部长度=%d", totalLen, headersLen)
+	}
+
+	message := make([]byte, totalLen)
+	copy(message, prelude)
+	if _, err := io.ReadFull(reader, message[eventStreamPreludeLen:]); err != nil {
+		return nil, fmt.Errorf("事件流消息不完整: %w", err)
+	}
+	crcOffset := totalLen - 4
+	if crc32.ChecksumIEEE(message[:crcOffset]) != binary.BigEndian.Uint32(message[crcOffset:]) {
+		return nil, fmt.Errorf("事件流消息 CRC 校验失败")
+	}
+
+	headersEnd := eventStreamPreludeLen + headersLen
+	headers, err := parseEventStreamHeaders(message[eventStreamPreludeLen:headersEnd])
+	if err != nil {
+		return nil, err
+	}
+	return &eventStreamMessage{Headers: headers, Payload: message[headersEnd:crcOffset]}, nil
+}
+
+// parseEventStreamHeaders 解析消息头部：名称长度(1) 名称 值类型(1) 值。
+// 字符串和字节数组值带 2 字节长度前缀，其他类型为固定长度。
+func parseEventStreamHeaders(data []byte) (map[string]interface{}, error) {
+	headers := make(map[string]interface{})
+	errTruncated := fmt.Errorf("事件流消息头部不完整")
+
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+1 {
+			return nil, errTruncated
+		}
+		name := string(data[1 : 1+nameLen])
+		valueType := data[1+nameLen]
+		data = data[2+nameLen:]
+
+		if size, ok := eventStreamFixedValueLen[valueType]; ok {
+			if len(data) < size {
+				return nil, errTruncated
+			}
+			switch valueType {
+			case 0:
+				headers[name] = true
+			case 1:
+				headers[name] = false
+			case 2:
+				headers[name] = int64(int8(data[0]))
+			case 3:
+				headers[name] = int64(int16(binary.BigEndian.Uint16(data)))
+			case 4:
+				headers[name] = int64(int32(binary.BigEndian.Uint32(data)))
+			case 5, 8: // 时间戳为毫秒数
+				headers[name] = int64(binary.BigEndian.Uint64(data))
+			case 9:
+				headers[name] = append([]byte(nil), data[:size]...)
+			}
+			data = data[size:]
+			continue
+		}
+
+		if valueType != 6 && valueType != 7 {
+			return nil, fmt.Errorf("事件流消息头部 %s 的值类型未知: %d", name, valueType)
+		}
+		if len(data) < 2 {
+			return nil, errTruncated
+		}
+		valueLen := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+valueLen {
+			return nil, errTruncated
+		}
+		value := data[2 : 2+valueLen]
+		if valueType == 7 {
+			headers[name] = string(value)
+		} else {
+			headers[name] = append([]byte(nil), value...)
+		}
+		data = data[2+valueLen:]
+	}
+
+	return headers, nil
+}
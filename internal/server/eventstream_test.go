@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encodeEventStreamHeaders 编码事件流消息头部，headers 为名称和字符串值交替的列表
+func encodeEventStreamHeaders(headers ...string) []byte {
+	var buf bytes.Buffer
+	for i := 0; i+1 < len(headers); i += 2 {
+		buf.WriteByte(byte(len(headers[i])))
+		buf.WriteString(headers[i])
+		buf.WriteByte(7)
+		_ = binary.Write(&buf, binary.BigEndian, uint16(len(headers[i+1])))
+		buf.WriteString(headers[i+1])
+	}
+	return buf.Bytes()
+}
+
+// encodeEventStreamMessage 按事件流格式编码一条消息（前导、头部、载荷和两个 CRC）
+func encodeEventStreamMessage(headers []byte, payload string) []byte {
+	totalLen := eventStreamMinMessageLen + len(headers) + len(payload)
+	msg := make([]byte, eventStreamPreludeLen, totalLen)
+	binary.BigEndian.PutUint32(msg[0:4], uint32(totalLen))
+	binary.BigEndian.PutUint32(msg[4:8], uint32(len(headers)))
+	binary.BigEndian.PutUint32(msg[8:12], crc32.ChecksumIEEE(msg[0:8]))
+	msg = append(msg, headers...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+// bedrockEvent 编码一条 ConverseStream 事件消息
+func bedrockEvent(eventType, payload string) []byte {
+	return encodeEventStreamMessage(encodeEventStreamHeaders(
+		":message-type", "event", ":event-type", eventType, ":content-type", "application/json"), payload)
+}
+
+func TestReadEventStreamMessage(t *testing.T) {
+	// 除字符串外还包括布尔、int32 和字节数组头部
+	headers := encodeEventStreamHeaders(":message-type", "event", ":event-type", "contentBlockDelta")
+	headers = append(headers, 4, 'f', 'l', 'a', 'g', 0)
+	headers = append(headers, 5, 'c', 'o', 'u', 'n', 't', 4, 0xff, 0xff, 0xff, 0xfe)
+	headers = append(headers, 3, 'r', 'a', 'w', 6, 0, 2, 0xab, 0xcd)
+	payload := `{"contentBlockIndex":0,"delta":{"text":"hi"}}`
+	stream := bytes.NewReader(append(encodeEventStreamMessage(headers, payload), encodeEventStreamMessage(nil, "")...))
+
+	msg, err := readEventStreamMessage(stream)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	wantHeaders := map[string]interface{}{
+		":message-type": "event",
+		":event-type":   "contentBlockDelta",
+		"flag":          true,
+		"count":         int64(-2),
+		"raw":           []byte{0xab, 0xcd},
+	}
+	if !reflect.DeepEqual(msg.Headers, wantHeaders) {
+		t.Errorf("头部 = %#v", msg.Headers)
+	}
+	if string(msg.Payload) != payload {
+		t.Errorf("载荷 = %q", msg.Payload)
+	}
+
+	// 没有头部和载荷的最短消息
+	msg, err = readEventStreamMessage(stream)
+	if err != nil || len(msg.Headers) != 0 || len(msg.Payload) != 0 {
+		t.Errorf("空消息 = %#v, %v", msg, err)
+	}
+	if _, err := readEventStreamMessage(stream); err != io.EOF {
+		t.Errorf("流结束时错误 = %v，应为 io.EOF", err)
+	}
+}
+
+func TestReadEventStreamMessageErrors(t *testing.T) {
+	valid := bedrockEvent("messageStop", `{"stopReason":"end_turn"}`)
+	corrupt := func(offset int) []byte {
+		msg := append([]byte(nil), valid...)
+		msg[offset] ^= 0xff
+		return msg
+	}
+
+	// 头部长度声明超出实际头部数据：字符串值长度越界
+	truncatedHeaders := encodeEventStreamHeaders(":event-type", "messageStop")
+	truncatedHeaders[len(truncatedHeaders)-len("messageStop")-1] = 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"前导 CRC 错误", corrupt(8), "前导 CRC 校验失败"},
+		{"消息 CRC 错误", corrupt(len(valid) - 1), "消息 CRC 校验失败"},
+		{"载荷损坏", corrupt(len(valid) - 5), "消息 CRC 校验失败"},
+		{"前导不完整", valid[:6], "前导不完整"},
+		{"消息不完整", valid[:len(valid)-2], "消息不完整"},
+		{"头部不完整", encodeEventStreamMessage(truncatedHeaders, "{}"), "头部不完整"},
+		{"头部名称不完整", encodeEventStreamMessage([]byte{10, 'a'}, ""), "头部不完整"},
+		{"头部值类型未知", encodeEventStreamMessage([]byte{1, 'a', 42}, ""), "值类型未知"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readEventStreamMessage(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("错误 = %v，应包含 %q", err, tt.want)
+			}
+		})
+	}
+
+	// 总长度小于最短消息长度
+	prelude := make([]byte, eventStreamPreludeLen)
+	binary.BigEndian.PutUint32(prelude[0:4], 8)
+	binary.BigEndian.PutUint32(prelude[8:12], crc32.ChecksumIEEE(prelude[0:8]))
+	if _, err := readEventStreamMessage(bytes.NewReader(prelude)); err == nil || !strings.Contains(err.Error(), "长度无效") {
+		t.Errorf("错误 = %v，应为长度无效", err)
+	}
+}
+
+func TestEventStreamLineReader(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(bedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"hi"}}`))
+	stream.Write(encodeEventStreamMessage(encodeEventStreamHeaders(":message-type", "event"), `{}`)) // 没有类型头，跳过
+	stream.Write(bedrockEvent("contentBlockStop", ""))
+	stream.Write(encodeEventStreamMessage(encodeEventStreamHeaders(
+		":message-type", "exception", ":exception-type", "throttlingException", ":content-type", "application/json"),
+		`{"message":"Too many requests"}`))
+	stream.Write(encodeEventStreamMessage(encodeEventStreamHeaders(
+		":message-type", "error", ":error-code", "InternalFailure", ":error-message", "内部错误"), ""))
+
+	data, err := io.ReadAll(newEventStreamLineReader(&stream))
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	want := `{"contentBlockDelta":{"contentBlockIndex":0,"delta":{"text":"hi"}}}` + "\n" +
+		`{"contentBlockStop":{}}` + "\n" +
+		`{"throttlingException":{"message":"Too many requests"}}` + "\n" +
+		`{"error":{"code":"InternalFailure","message":"内部错误"}}` + "\n"
+	if string(data) != want {
+		t.Errorf("输出 =\n%s\n应为\n%s", data, want)
+	}
+
+	// 损坏的消息作为读取错误返回，而不是被跳过
+	bad := bedrockEvent("messageStop", `{}`)
+	bad[len(bad)-1] ^= 0xff
+	if _, err := io.ReadAll(newEventStreamLineReader(bytes.NewReader(bad))); err == nil {
+		t.Errorf("损坏的消息应返回错误")
+	}
+}
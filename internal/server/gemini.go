@@ -0,0 +1,92 @@
+// gemini.go 实现 Gemini streamGenerateContent 上游流的解析：
+// 每个 SSE 数据块都是一个完整的 GenerateContentResponse，流在上游关闭连接时结束（没有 [DONE] 标记）。
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// handleGeminiStreamLine 处理上游 Gemini 流中的一行数据。
+// 思考部分转换为思考增量，functionCall 部分作为完整的工具调用发送，
+// thoughtSignature 作为思考块签名发送；数据块中包含 error 时返回该错误。
+func handleGeminiStreamLine(processor *StreamProcessor, w *bufio.Writer, line string, cfg *config.Config) (done bool, streamErr *errors.ProxyError) {
+	if !strings.HasPrefix(line, "data: ") {
+		return false, nil
+	}
+
+	dataJSON := strings.TrimPrefix(line, "data: ")
+
+	var chunk models.GeminiResponse
+	if err := json.Unmarshal([]byte(dataJSON), &chunk); err != nil {
+		return false, nil
+	}
+
+	if cfg.Debug {
+		fmt.Printf("[调试] 来自提供商的 Gemini 数据块: %s\n", dataJSON)
+	}
+
+	if chunk.Error != nil {
+		return false, streamChunkError(map[string]interface{}{"error": chunk.Error})
+	}
+
+	for _, candidate := range chunk.Candidates {
+		for _, part := range candidate.Content.Parts {
+			// 签名先于所在部分发送，使没有思考摘要时创建的空思考块排在工具调用之前
+			if part.ThoughtSignature != "" {
+				processor.HandleThinkingSignature(converter.EncodeGeminiSignature(part.ThoughtSignature))
+			}
+
+			switch {
+			case part.FunctionCall != nil:
+				argsJSON, err := json.Marshal(part.FunctionCall.Args)
+				if err != nil || part.FunctionCall.Args == nil {
+					argsJSON = []byte("{}")
+				}
+				toolCall := map[string]interface{}{
+					"index": float64(len(processor.state.CurrentToolCalls)),
+					"function": map[string]interface{}{
+						"name":      part.FunctionCall.Name,
+						"arguments": string(argsJSON),
+					},
+				}
+				if part.FunctionCall.ID != "" {
+					toolCall["id"] = part.FunctionCall.ID
+				}
+				processor.HandleToolCallsDelta([]interface{}{toolCall})
+
+			case part.Thought:
+				if part.Text != "" {
+					processor.HandleThinkingDelta(map[string]interface{}{"reasoning_content": part.Text})
+				}
+
+			case part.Text != "":
+				processor.HandleTextDelta(part.Text)
+			}
+		}
+
+		if candidate.FinishReason != "" {
+			processor.HandleFinishReason(converter.GeminiFinishReason(candidate.FinishReason, len(processor.state.CurrentToolCalls) > 0))
+		}
+	}
+
+	if usage := chunk.UsageMetadata; usage != nil {
+		processor.HandleUsageData(map[string]interface{}{
+			"prompt_tokens":     float64(usage.PromptTokenCount),
+			"completion_tokens": float64(usage.CandidatesTokenCount + usage.ThoughtsTokenCount),
+			"prompt_tokens_details": map[string]interface{}{
+				"cached_tokens": float64(usage.CachedContentTokenCount),
+			},
+		})
+	}
+
+	return false, nil
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+)
+
+// geminiStreamChunks 是 streamGenerateContent 返回的 SSE 数据块：思考摘要、文本、带 thoughtSignature 的函数调用，最后是用量
+var geminiStreamChunks = []string{
+	`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"需要读取文件","thought":true}]}}],"modelVersion":"test-model","responseId":"resp_1"}` + "\n\n",
+	`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"我来读取。"}]}}],"modelVersion":"test-model","responseId":"resp_1"}` + "\n\n",
+	`data: {"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"read","args":{"path":"a.txt"}},"thoughtSignature":"U0lH"}]},"finishReason":"STOP"}],` +
+		`"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"thoughtsTokenCount":3,"totalTokenCount":18},"modelVersion":"test-model","responseId":"resp_1"}` + "\n\n",
+}
+
+// geminiResponse 是 generateContent 返回的非流式响应
+const geminiResponse = `{"candidates":[{"content":{"role":"model","parts":[{"text":"想一想","thought":true},{"text":"文件内容是 ok","thoughtSignature":"VEFJTA=="}]},"finishReason":"STOP"}],` +
+	`"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":4,"totalTokenCount":24},"modelVersion":"test-model","responseId":"resp_2"}`
+
+// newGeminiUpstream 模拟 Gemini API：streamGenerateContent 返回 geminiStreamChunks，generateContent 返回 geminiResponse
+func newGeminiUpstream(t *testing.T) *fakeUpstream {
+	return newFakeUpstream(t, func(req upstreamRequest) upstreamResponse {
+		if req.Header.Get("x-goog-api-key") != "test-key" {
+			return jsonResponse(http.StatusUnauthorized, `{"error":{"code":401,"message":"API key not valid","status":"UNAUTHENTICATED"}}`)
+		}
+		switch {
+		case strings.HasSuffix(req.Path, ":streamGenerateContent?alt=sse"):
+			return streamResponse("text/event-stream", geminiStreamChunks...)
+		case strings.HasSuffix(req.Path, ":generateContent"):
+			return jsonResponse(http.StatusOK, geminiResponse)
+		}
+		return upstreamResponse{Status: http.StatusNotFound}
+	})
+}
+
+func TestGeminiStreamRoundTrip(t *testing.T) {
+	upstream := newGeminiUpstream(t)
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL, APIKey: "test-key", Protocol: config.ProtocolGemini}))
+
+	// 第一轮：流式请求，上游返回思考摘要、文本和带 thoughtSignature 的函数调用
+	status, resp := postJSON(t, app, "/v1/messages", `{"model":"claude-sonnet-4-5","max_tokens":4096,"stream":true,`+
+		`"thinking":{"type":"enabled","budget_tokens":2048},`+
+		`"system":"你是助手",`+
+		`"messages":[{"role":"user","content":"读取 a.txt"}],`+
+		`"tools":[{"name":"read","description":"读取文件","input_schema":{"type":"object","properties":{"path":{"type":"string"}}}}]}`)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, resp)
+	}
+
+	req := upstream.last(t)
+	path, body := req.Path, req.Body
+	if path != "/models/"+testModel+":streamGenerateContent?alt=sse" {
+		t.Errorf("上游路径 = %q", path)
+	}
+	contents, _ := body["contents"].([]interface{})
+	if len(contents) != 1 {
+		t.Fatalf("contents = %#v", body["contents"])
+	}
+	tools, _ := body["tools"].([]interface{})
+	if len(tools) != 1 {
+		t.Fatalf("tools = %#v", body["tools"])
+	}
+	decls := tools[0].(map[string]interface{})["functionDeclarations"].([]interface{})
+	if decl := decls[0].(map[string]interface{}); decl["name"] != "read" || decl["parametersJsonSchema"] == nil {
+		t.Errorf("functionDeclarations = %#v", decls)
+	}
+	system := body["systemInstruction"].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})
+	if text, _ := system["text"].(string); !strings.HasPrefix(text, "你是助手") {
+		t.Errorf("systemInstruction = %#v", body["systemInstruction"])
+	}
+	gen := body["generationConfig"].(map[string]interface{})
+	thinking := gen["thinkingConfig"].(map[string]interface{})
+	if gen["maxOutputTokens"] != float64(4096) || thinking["includeThoughts"] != true || thinking["thinkingBudget"] != float64(2048) {
+		t.Errorf("generationConfig = %#v", gen)
+	}
+
+	events := parseSSE(t, resp)
+	messageDelta := checkClaudeStream(t, events, "thinking", "text", "tool_use")
+	if got := deltaText(events, "thinking"); got != "需要读取文件" {
+		t.Errorf("思考内容 = %q", got)
+	}
+	if got := deltaText(events, "text"); got != "我来读取。" {
+		t.Errorf("文本内容 = %q", got)
+	}
+	if got := deltaText(events, "partial_json"); got != `{"path":"a.txt"}` {
+		t.Errorf("工具参数 = %q", got)
+	}
+	signature := deltaText(events, "signature")
+	if signature != "gemini:U0lH" {
+		t.Errorf("思考签名 = %q，应为带 gemini: 前缀的 thoughtSignature", signature)
+	}
+	if reason := messageDelta["delta"].(map[string]interface{})["stop_reason"]; reason != "tool_use" {
+		t.Errorf("stop_reason = %v", reason)
+	}
+	var toolID string
+	for _, e := range events {
+		if block, ok := e.Data["content_block"].(map[string]interface{}); ok && block["type"] == "tool_use" {
+			toolID, _ = block["id"].(string)
+		}
+	}
+	if toolID == "" {
+		t.Fatalf("工具调用块缺少 id")
+	}
+
+	// 第二轮：Claude Code 原样发回思考块（含签名）、工具调用和工具结果，thoughtSignature 应回到 functionCall 部分
+	status, resp = postJSON(t, app, "/v1/messages", `{"model":"claude-sonnet-4-5","max_tokens":4096,`+
+		`"thinking":{"type":"enabled","budget_tokens":2048},`+
+		`"messages":[{"role":"user","content":"读取 a.txt"},`+
+		`{"role":"assistant","content":[{"type":"thinking","thinking":"需要读取文件","signature":"`+signature+`"},`+
+		`{"type":"text","text":"我来读取。"},`+
+		`{"type":"tool_use","id":"`+toolID+`","name":"read","input":{"path":"a.txt"}}]},`+
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"`+toolID+`","content":"ok"}]}],`+
+		`"tools":[{"name":"read","description":"读取文件","input_schema":{"type":"object","properties":{"path":{"type":"string"}}}}]}`)
+	if status != http.StatusOK {
+		t.Fatalf("第二轮状态码 = %d，响应: %s", status, resp)
+	}
+
+	req = upstream.last(t)
+	path, body = req.Path, req.Body
+	if path != "/models/"+testModel+":generateContent" {
+		t.Errorf("第二轮上游路径 = %q", path)
+	}
+	contents = body["contents"].([]interface{})
+	if len(contents) != 3 {
+		t.Fatalf("第二轮 contents = %#v", contents)
+	}
+	model := contents[1].(map[string]interface{})
+	if model["role"] != "model" {
+		t.Errorf("助手消息的 role = %v，应为 model", model["role"])
+	}
+	var call map[string]interface{}
+	for _, p := range model["parts"].([]interface{}) {
+		if part := p.(map[string]interface{}); part["functionCall"] != nil {
+			call = part
+		}
+	}
+	if call == nil || call["thoughtSignature"] != "U0lH" {
+		t.Errorf("functionCall 部分 = %#v，应带有原样发回的 thoughtSignature", call)
+	}
+	result := contents[2].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})["functionResponse"].(map[string]interface{})
+	if result["name"] != "read" || result["response"].(map[string]interface{})["content"] != "ok" {
+		t.Errorf("functionResponse = %#v", result)
+	}
+
+	// 非流式响应：思考块带有 gemini: 签名，文本正常返回
+	var msg struct {
+		Content []map[string]interface{} `json:"content"`
+		Usage   map[string]interface{}   `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(resp), &msg); err != nil {
+		t.Fatalf("解析响应失败: %v: %s", err, resp)
+	}
+	if len(msg.Content) != 2 || msg.Content[0]["type"] != "thinking" || msg.Content[0]["signature"] != "gemini:VEFJTA==" ||
+		msg.Content[1]["type"] != "text" || msg.Content[1]["text"] != "文件内容是 ok" {
+		t.Errorf("响应内容 = %#v", msg.Content)
+	}
+	if msg.Usage["input_tokens"] != float64(20) {
+		t.Errorf("用量 = %#v", msg.Usage)
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,22 +12,14 @@ import (
 
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/tokenizer"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
 	"github.com/gofiber/fiber/v2"
 )
 
-// addOpenRouterHeaders 添加 OpenRouter 特定的 HTTP 头以获得更好的速率限制。
-// 配置后设置 HTTP-Referer 和 X-Title 头，有助于 OpenRouter 的速率限制和使用量跟踪。
-func addOpenRouterHeaders(req *http.Request, cfg *config.Config) {
-	if cfg.OpenRouterAppURL != "" {
-		req.Header.Set("HTTP-Referer", cfg.OpenRouterAppURL)
-	}
-	if cfg.OpenRouterAppName != "" {
-		req.Header.Set("X-Title", cfg.OpenRouterAppName)
-	}
-}
-
 // handleMessages 是 /v1/messages 端点的主处理器。
 // 解析 Claude 请求，转换为 OpenAI 格式，并根据请求的 stream 参数路由到流式或非流式处理器。
 func handleMessages(c *fiber.Ctx, cfg *config.Config) error {
@@ -41,94 +34,40 @@ func handleMessages(c *fiber.Ctx, cfg *config.Config) error {
 		// 记录错误和原始请求体以便调试
 		fmt.Printf("[错误] 解析请求体失败: %v\n", err)
 		fmt.Printf("[错误] 原始请求体: %s\n", string(c.Body()))
-		return c.Status(400).JSON(fiber.Map{
-			"type": "error",
-			"error": fiber.Map{
-				"type":    "invalid_request_error",
-				"message": fmt.Sprintf("Invalid request body: %v", err),
-			},
-		})
-	}
-
-	// 验证 API 密钥（如果已配置）
-	if cfg.AnthropicAPIKey != "" {
-		apiKey := c.Get("x-api-key")
-		if apiKey != cfg.AnthropicAPIKey {
-			return c.Status(401).JSON(fiber.Map{
-				"type": "error",
-				"error": fiber.Map{
-					"type":    "authentication_error",
-					"message": "API 密钥无效",
-				},
-			})
-		}
+		return sendProxyError(c, errors.NewInvalidRequestError(fmt.Sprintf("Invalid request body: %v", err)))
+	}
+
+	// 按单个请求的覆盖或模型路由规则选择后端
+	route, pe := requestRoute(c, &claudeReq, cfg)
+	if pe != nil {
+		return sendProxyError(c, pe)
+	}
+	cfg = cfg.WithBackend(route.Backend)
+	logRoute(cfg, claudeReq.Model, route)
+
+	// Anthropic 原生后端直接转发 Claude 请求，无需转换
+	prov := provider.New(cfg)
+	if prov.Backend().Protocol == config.ProtocolAnthropic {
+		return handleAnthropicMessages(c, prov, claudeReq, route, cfg)
 	}
 
 	// 将 Claude 请求转换为 OpenAI 格式
-	openaiReq, err := converter.ConvertRequest(claudeReq, cfg)
+	openaiReq, err := converter.ConvertRequest(claudeReq, route, cfg)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"type": "error",
-			"error": fiber.Map{
-				"type":    "invalid_request_error",
-				"message": err.Error(),
-			},
-		})
+		return sendProxyError(c, errors.NewInvalidRequestError(err.Error()))
 	}
 
+	// 上游是否可以流式传输：提供商支持且该模型未被禁用
+	upstreamStreaming := prov.SupportsStreaming() && !cfg.IsStreamingDisabled(openaiReq.Model)
+	streamRequested := claudeReq.Stream != nil && *claudeReq.Stream
+	// 流式请求在后端可以流式时流式调用（否则伪流式），非流式请求在启用流式聚合时流式调用；
+	// 在应用参数策略之前设置，使策略可以覆盖流式参数
+	converter.SetUpstreamStreaming(openaiReq, upstreamStreaming && (streamRequested || cfg.StreamAggregation), cfg)
+	logParamPolicy(cfg, converter.ApplyParamPolicy(openaiReq, route.Policy))
+
 	// 注入指令以防止模型使用无效的 "query" 参数
 	// 这对于通过 OpenAI 兼容 API 访问的 Claude 模型至关重要
-	if len(openaiReq.Messages) > 0 {
-		instruction := `
-
-[CRITICAL TOOL PARAMETER REQUIREMENTS - READ CAREFULLY]
-
-When using tools, you MUST use the EXACT parameter names defined in each tool's schema. The parameter "query" DOES NOT EXIST in any tool.
-
-REQUIRED PARAMETERS FOR EACH TOOL:
-- Edit: file_path, old_string, new_string (ALL THREE are required)
-- Read: file_path (required)
-- Write: file_path, content (BOTH required)
-- Bash: command (required)
-- Grep: pattern (required)
-- Glob: pattern (required)
-- LSP: operation, filePath, line, character (ALL required)
-- Task: description, prompt, subagent_type (ALL required)
-- WebFetch: url, prompt (BOTH required)
-
-⚠️ NEVER use "query" as a parameter name - it will cause tool execution to FAIL.
-⚠️ Always check the tool schema before calling any tool.
-
-【关键工具参数要求 - 必须仔细阅读】
-
-使用工具时，必须使用每个工具 schema 中定义的确切参数名称。任何工具都不存在 "query" 参数。
-
-各工具必需参数：
-- Edit: file_path, old_string, new_string（三个都必需，且必须是不同的值）
-- Read: file_path（必需）
-- Write: file_path, content（两个都必需）
-- Bash: command（必需，不是 query）
-- Grep: pattern（必需，不是 query）
-- Glob: pattern（必需，不是 query）
-- LSP: operation, filePath, line, character（全部必需）
-- Task: description, prompt, subagent_type（全部必需）
-- WebFetch: url, prompt（两个都必需）
-
-⚠️ 绝对不要使用 "query" 作为参数名称 - 这会导致工具执行失败。
-⚠️ 调用工具前务必检查工具的 schema。`
-
-		// 如果第一条消息是系统消息，则追加到其中
-		if openaiReq.Messages[0].Role == "system" {
-			// 将 interface{} 类型断言为 string
-			contentStr, _ := openaiReq.Messages[0].Content.(string)
-			openaiReq.Messages[0].Content = contentStr + instruction
-		} else {
-			// 否则在前面添加新的系统消息
-			openaiReq.Messages = append([]models.OpenAIMessage{
-				{Role: "system", Content: instruction},
-			}, openaiReq.Messages...)
-		}
-	}
+	injectToolParameterInstruction(openaiReq)
 
 	// 调试：记录转换后的 OpenAI 请求
 	if cfg.Debug {
@@ -152,23 +91,46 @@ REQUIRED PARAMETERS FOR EACH TOOL:
 	}
 
 	// 处理流式与非流式请求
-	if openaiReq.Stream != nil && *openaiReq.Stream {
-		return handleStreamingMessages(c, openaiReq, cfg)
+	if streamRequested {
+		if !upstreamStreaming {
+			// 后端无法流式传输 - 以非流式调用并重放为 Claude SSE
+			return handleFakeStreamingMessages(c, prov, openaiReq, claudeReq.Model, cfg)
+		}
+		return handleStreamingMessages(c, prov, openaiReq, cfg)
+	}
+
+	// 流式聚合：使用流式上游调用服务非流式请求，避免长生成触发非流式超时
+	if cfg.StreamAggregation && upstreamStreaming {
+		return handleAggregatedMessages(c, prov, openaiReq, claudeReq.Model, cfg)
 	}
 
 	// 记录计时用于简单日志
 	startTime := time.Now()
 
+	// 客户端断开连接时取消上游请求
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
 	// 非流式响应
-	openaiResp, err := callOpenAI(openaiReq, cfg)
+	claudeResp, upstreamHeader, err := completeMessage(ctx, prov, openaiReq, claudeReq.Model, cfg, startTime)
+	if err != nil {
+		if ctx.Err() != nil {
+			logClientDisconnected(cfg, openaiReq.Model)
+			return nil
+		}
+		return sendProxyError(c, toProxyError(err, "OpenAI API 错误"))
+	}
+	applyRateLimitHeaders(c, prov, upstreamHeader)
+
+	return c.JSON(claudeResp)
+}
+
+// completeMessage 以非流式方式调用上游并返回转换后的 Claude 响应及上游响应头。
+// 包括使用量估算、简单日志摘要和工具参数清理；/v1/messages 和 /v1/chat/completions 共用。
+func completeMessage(ctx context.Context, prov provider.Provider, openaiReq *models.OpenAIRequest, requestedModel string, cfg *config.Config, startTime time.Time) (*models.ClaudeResponse, http.Header, error) {
+	openaiResp, upstreamHeader, err := callOpenAI(ctx, prov, openaiReq, cfg)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"type": "error",
-			"error": fiber.Map{
-				"type":    "api_error",
-				"message": fmt.Sprintf("OpenAI API 错误: %v", err),
-			},
-		})
+		return nil, nil, err
 	}
 
 	// 调试：记录 OpenAI 响应
@@ -185,17 +147,14 @@ REQUIRED PARAMETERS FOR EACH TOOL:
 	}
 
 	// 将 OpenAI 响应转换为 Claude 格式
-	claudeResp, err := converter.ConvertResponse(openaiResp, claudeReq.Model)
+	claudeResp, err := converter.ConvertResponse(openaiResp, requestedModel)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"type": "error",
-			"error": fiber.Map{
-				"type":    "api_error",
-				"message": fmt.Sprintf("响应转换错误: %v", err),
-			},
-		})
+		return nil, nil, errors.NewConversionError(fmt.Sprintf("响应转换错误: %v", err)).WithCause(err)
 	}
 
+	// 后端未返回使用量时，使用本地分词器估算
+	usageEstimated := fillEstimatedUsage(claudeResp, openaiReq, cfg)
+
 	// 调试：记录 Claude 响应
 	if cfg.Debug {
 		claudeRespJSON, _ := json.MarshalIndent(claudeResp, "", "  ")
@@ -211,21 +170,8 @@ REQUIRED PARAMETERS FOR EACH TOOL:
 	}
 
 	// 简单日志：单行摘要
-	if cfg.SimpleLog {
-		duration := time.Since(startTime).Seconds()
-		tokensPerSec := 0.0
-		if duration > 0 && claudeResp.Usage.OutputTokens > 0 {
-			tokensPerSec = float64(claudeResp.Usage.OutputTokens) / duration
-		}
-		timestamp := time.Now().Format("15:04:05")
-		fmt.Printf("[%s] [请求] %s 模型=%s 输入=%d 输出=%d 令牌/秒=%.1f\n",
-			timestamp,
-			cfg.OpenAIBaseURL,
-			openaiReq.Model,
-			claudeResp.Usage.InputTokens,
-			claudeResp.Usage.OutputTokens,
-			tokensPerSec)
-	}
+	logRequestSummary(cfg, openaiReq.Model, claudeResp.Usage, startTime, usageEstimated)
+	recordUsage(ctx, cfg, openaiReq.Model, claudeResp.Usage)
 
 	// 在非流式模式下清理工具参数（移除无效的 query 参数）
 	for i := range claudeResp.Content {
@@ -237,56 +183,277 @@ REQUIRED PARAMETERS FOR EACH TOOL:
 		}
 	}
 
-	return c.JSON(claudeResp)
+	return claudeResp, upstreamHeader, nil
+}
+
+// toolParameterInstruction 注入到系统提示中的工具参数说明，防止模型使用不存在的 "query" 参数
+const toolParameterInstruction = `
+
+[CRITICAL TOOL PARAMETER REQUIREMENTS - READ CAREFULLY]
+
+When using tools, you MUST use the EXACT parameter names defined in each tool's schema. The parameter "query" DOES NOT EXIST in any tool.
+
+REQUIRED PARAMETERS FOR EACH TOOL:
+- Edit: file_path, old_string, new_string (ALL THREE are required)
+- Read: file_path (required)
+- Write: file_path, content (BOTH required)
+- Bash: command (required)
+- Grep: pattern (required)
+- Glob: pattern (required)
+- LSP: operation, filePath, line, character (ALL required)
+- Task: description, prompt, subagent_type (ALL required)
+- WebFetch: url, prompt (BOTH required)
+
+⚠️ NEVER use "query" as a parameter name - it will cause tool execution to FAIL.
+⚠️ Always check the tool schema before calling any tool.
+
+【关键工具参数要求 - 必须仔细阅读】
+
+使用工具时，必须使用每个工具 schema 中定义的确切参数名称。任何工具都不存在 "query" 参数。
+
+各工具必需参数：
+- Edit: file_path, old_string, new_string（三个都必需，且必须是不同的值）
+- Read: file_path（必需）
+- Write: file_path, content（两个都必需）
+- Bash: command（必需，不是 query）
+- Grep: pattern（必需，不是 query）
+- Glob: pattern（必需，不是 query）
+- LSP: operation, filePath, line, character（全部必需）
+- Task: description, prompt, subagent_type（全部必需）
+- WebFetch: url, prompt（两个都必需）
+
+⚠️ 绝对不要使用 "query" 作为参数名称 - 这会导致工具执行失败。
+⚠️ 调用工具前务必检查工具的 schema。`
+
+// injectToolParameterInstruction 将工具参数说明追加到系统消息（没有系统消息时在前面添加）
+func injectToolParameterInstruction(openaiReq *models.OpenAIRequest) {
+	if len(openaiReq.Messages) == 0 {
+		return
+	}
+
+	// 如果第一条消息是系统消息，则追加到其中
+	if openaiReq.Messages[0].Role == "system" {
+		// 将 interface{} 类型断言为 string
+		contentStr, _ := openaiReq.Messages[0].Content.(string)
+		openaiReq.Messages[0].Content = contentStr + toolParameterInstruction
+	} else {
+		// 否则在前面添加新的系统消息
+		openaiReq.Messages = append([]models.OpenAIMessage{
+			{Role: "system", Content: toolParameterInstruction},
+		}, openaiReq.Messages...)
+	}
+}
+
+// fillEstimatedUsage 在后端未返回使用量（全零）时，使用本地分词器填充估算值。
+// 返回 true 表示使用量为估算值。
+func fillEstimatedUsage(claudeResp *models.ClaudeResponse, openaiReq *models.OpenAIRequest, cfg *config.Config) bool {
+	if claudeResp.Usage.InputTokens != 0 || claudeResp.Usage.OutputTokens != 0 {
+		return false
+	}
+
+	claudeResp.Usage.InputTokens = tokenizer.EstimateOpenAIRequest(openaiReq)
+	claudeResp.Usage.OutputTokens = estimateResponseTokens(claudeResp)
+	if cfg.Debug {
+		fmt.Printf("[调试] 后端未返回使用量数据，使用本地估算: 输入=%d 输出=%d\n",
+			claudeResp.Usage.InputTokens, claudeResp.Usage.OutputTokens)
+	}
+	return true
+}
+
+// logRequestSummary 在简单日志模式下输出单行请求摘要
+func logRequestSummary(cfg *config.Config, model string, usage models.Usage, startTime time.Time, estimated bool) {
+	if !cfg.SimpleLog {
+		return
+	}
+
+	duration := time.Since(startTime).Seconds()
+	tokensPerSec := 0.0
+	if duration > 0 && usage.OutputTokens > 0 {
+		tokensPerSec = float64(usage.OutputTokens) / duration
+	}
+	estimatedFlag := ""
+	if estimated {
+		estimatedFlag = " (估算)"
+	}
+	timestamp := time.Now().Format("15:04:05")
+	fmt.Printf("[%s] [请求] %s 模型=%s 输入=%d 输出=%d 令牌/秒=%.1f%s\n",
+		timestamp,
+		cfg.OpenAIBaseURL,
+		model,
+		usage.InputTokens,
+		usage.OutputTokens,
+		tokensPerSec,
+		estimatedFlag)
+}
+
+// estimateResponseTokens 估算非流式 Claude 响应的输出令牌数。
+// 统计文本、思考内容以及工具调用名称和参数 JSON。
+func estimateResponseTokens(resp *models.ClaudeResponse) int {
+	var counter tokenizer.Counter
+	for _, block := range resp.Content {
+		counter.Add(block.Text)
+		counter.Add(block.Thinking)
+		if block.Type == "tool_use" {
+			counter.Add(block.Name)
+			if inputJSON, err := json.Marshal(block.Input); err == nil {
+				counter.Add(string(inputJSON))
+			}
+		}
+	}
+	return counter.Tokens()
 }
 
 // handleStreamingMessages 处理来自提供商的流式 SSE 响应。
 // 转发 OpenAI 请求，接收流式数据块，并使用 streamOpenAIToClaude 实时转换为 Claude 的 SSE 事件格式。
-func handleStreamingMessages(c *fiber.Ctx, openaiReq *models.OpenAIRequest, cfg *config.Config) error {
+// 上游请求在响应体开始之前发出，使错误可以以正确的状态码返回，
+// 速率限制头也能在 SSE 响应头中发送给客户端。
+func handleStreamingMessages(c *fiber.Ctx, prov provider.Provider, openaiReq *models.OpenAIRequest, cfg *config.Config) error {
 	// 记录计时用于简单日志
 	startTime := time.Now()
 
+	// 每个请求独立的上下文：客户端断开时取消，流写入器返回时立即关闭上游连接
+	ctx, cancel := requestContext(c)
+
+	if cfg.Debug {
+		fmt.Printf("[调试] 流式请求：正在向 %s 发送流式请求\n", prov.RequestEndpoint(openaiReq))
+	}
+
+	// 使用自动重试逻辑发送流式请求
+	resp, err := callOpenAIStream(ctx, prov, openaiReq, cfg)
+	if err != nil {
+		clientGone := ctx.Err() != nil
+		cancel()
+		if clientGone {
+			logClientDisconnected(cfg, openaiReq.Model)
+			return nil
+		}
+		if cfg.Debug {
+			fmt.Printf("[调试] 流式请求：请求失败: %v\n", err)
+		}
+		return sendProxyError(c, toProxyError(err, "流式请求失败"))
+	}
+
 	// 设置 SSE 头
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
 	c.Set("Connection", "keep-alive")
 	c.Set("X-Accel-Buffering", "no")
+	applyRateLimitHeaders(c, prov, resp.Header)
 
 	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
-		if cfg.Debug {
-			fmt.Printf("[调试] 流写入器：开始\n")
-		}
+		defer cancel()
+		defer func() { _ = resp.Body.Close() }()
 
 		if cfg.Debug {
-			fmt.Printf("[调试] 流写入器：正在向 %s 发送流式请求\n", cfg.OpenAIBaseURL+"/chat/completions")
+			fmt.Printf("[调试] 流写入器：收到响应，开始 streamOpenAIToClaude 转换\n")
 		}
 
-		// 使用自动重试逻辑发送流式请求
-		resp, err := callOpenAIStream(openaiReq, cfg)
-		if err != nil {
-			if cfg.Debug {
-				fmt.Printf("[调试] 流写入器：请求失败: %v\n", err)
-			}
-			writeSSEError(w, fmt.Sprintf("流式请求失败: %v", err))
+		// 流式转换
+		streamOpenAIToClaude(ctx, w, resp.Body, prov, openaiReq, cfg, startTime)
+		if ctx.Err() != nil {
+			logClientDisconnected(cfg, openaiReq.Model)
 			return
 		}
-		defer func() { _ = resp.Body.Close() }()
 
 		if cfg.Debug {
-			fmt.Printf("[调试] 流写入器：收到响应，开始 streamOpenAIToClaude 转换\n")
+			fmt.Printf("[调试] 流写入器：完成\n")
 		}
+	})
 
-		// 流式转换
-		streamOpenAIToClaude(w, resp.Body, openaiReq.Model, cfg, startTime)
+	return nil
+}
+
+// handleFakeStreamingMessages 为无法流式传输的后端提供伪流式响应。
+// 以非流式方式调用上游，将完整的 Claude 响应重放为格式正确的 Claude SSE 序列。
+func handleFakeStreamingMessages(c *fiber.Ctx, prov provider.Provider, openaiReq *models.OpenAIRequest, requestedModel string, cfg *config.Config) error {
+	// 记录计时用于简单日志
+	startTime := time.Now()
+
+	// 客户端断开连接时取消上游请求
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	if cfg.Debug {
+		fmt.Printf("[调试] 伪流式：正在以非流式方式请求模型 %s\n", openaiReq.Model)
+	}
+
+	claudeResp, upstreamHeader, err := completeMessage(ctx, prov, openaiReq, requestedModel, cfg, startTime)
+	if err != nil {
+		if ctx.Err() != nil {
+			logClientDisconnected(cfg, openaiReq.Model)
+			return nil
+		}
+		return sendProxyError(c, toProxyError(err, "OpenAI API 错误"))
+	}
+
+	// 设置 SSE 头
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	applyRateLimitHeaders(c, prov, upstreamHeader)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writeClaudeResponseSSE(w, claudeResp)
 
 		if cfg.Debug {
-			fmt.Printf("[调试] 流写入器：完成\n")
+			fmt.Printf("[调试] 伪流式：已重放 %d 个内容块\n", len(claudeResp.Content))
 		}
 	})
 
 	return nil
 }
 
+// handleAggregatedMessages 使用流式上游调用服务非流式 Claude 请求。
+// 上游流经 streamOpenAIToClaude 转换为 Claude SSE 后聚合为完整响应，
+// 从而使长生成受流式超时而不是非流式超时约束。
+func handleAggregatedMessages(c *fiber.Ctx, prov provider.Provider, openaiReq *models.OpenAIRequest, requestedModel string, cfg *config.Config) error {
+	// 记录计时用于简单日志
+	startTime := time.Now()
+
+	if cfg.Debug {
+		fmt.Printf("[调试] 流式聚合：正在以流式方式请求模型 %s\n", openaiReq.Model)
+	}
+
+	// 客户端断开连接时取消上游请求
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	resp, err := callOpenAIStream(ctx, prov, openaiReq, cfg)
+	if err != nil {
+		if ctx.Err() != nil {
+			logClientDisconnected(cfg, openaiReq.Model)
+			return nil
+		}
+		return sendProxyError(c, toProxyError(err, "OpenAI API 错误"))
+	}
+	defer func() { _ = resp.Body.Close() }()
+	applyRateLimitHeaders(c, prov, resp.Header)
+
+	// 将上游流转换为 Claude SSE 并缓冲在内存中
+	var sseBuf bytes.Buffer
+	w := bufio.NewWriter(&sseBuf)
+	streamOpenAIToClaude(ctx, w, resp.Body, prov, openaiReq, cfg, startTime)
+	_ = w.Flush()
+
+	if ctx.Err() != nil {
+		logClientDisconnected(cfg, openaiReq.Model)
+		return nil
+	}
+
+	claudeResp, err := aggregateClaudeSSE(&sseBuf)
+	if err != nil {
+		return sendProxyError(c, toProxyError(err, "流式聚合错误"))
+	}
+	claudeResp.Model = requestedModel
+
+	if cfg.Debug {
+		fmt.Printf("[调试] 流式聚合：已聚合 %d 个内容块\n", len(claudeResp.Content))
+	}
+
+	return c.JSON(claudeResp)
+}
+
 // ToolCallState 跟踪流式传输期间工具调用的状态
 type ToolCallState struct {
 	ID          string // 来自 OpenAI 的工具调用 ID
@@ -298,117 +465,257 @@ type ToolCallState struct {
 
 // streamOpenAIToClaude 将 OpenAI 流式响应转换为 Claude 的 SSE 事件格式。
 // 使用 StreamProcessor 进行模块化处理。
-func streamOpenAIToClaude(w *bufio.Writer, reader io.Reader, providerModel string, cfg *config.Config, startTime time.Time) {
+// 客户端断开连接（写入失败或 ctx 被取消）时立即返回，不再发送结束事件，
+// 调用方随后关闭上游响应体以停止生成。
+//
+// 上游静默期间按后端的 ping 间隔向客户端发送 ping 事件；
+// 超过空闲超时仍未收到任何数据时视为上游停滞，发送错误事件后返回。
+// 停滞时不切换到其他后端：message_start 已经发送，由客户端重试请求。
+func streamOpenAIToClaude(ctx context.Context, w *bufio.Writer, reader io.Reader, prov provider.Provider, openaiReq *models.OpenAIRequest, cfg *config.Config, startTime time.Time) {
 	if cfg.Debug {
 		fmt.Printf("[调试] streamOpenAIToClaude：开始转换\n")
 	}
 
 	// 创建流处理器
-	processor := NewStreamProcessor(w, providerModel, cfg, startTime)
+	processor := NewStreamProcessor(w, openaiReq.Model, cfg, startTime)
+	// 流结束（包括提前结束）时记录客户端用量
+	defer func() { recordUsage(ctx, cfg, openaiReq.Model, processor.Usage()) }()
+
+	// 预先估算输入令牌，以防后端不发送使用量数据
+	processor.SetEstimatedInputTokens(tokenizer.EstimateOpenAIRequest(openaiReq))
 
 	// 发送初始事件
 	processor.SendMessageStart()
 
-	// 创建扫描器
-	scanner := bufio.NewScanner(reader)
-	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	// 在独立 goroutine 中读取上游，使主循环可以同时处理 ping 和空闲超时
+	scanCtx, stopScan := context.WithCancel(ctx)
+	defer stopScan()
+	lines, readErr := scanLines(scanCtx, upstreamStreamReader(prov, reader))
+
+	// 按后端协议选择上游流的解析方式
+	handleLine := streamLineHandler(prov)
+
+	idleTimeout := time.Duration(prov.GetIdleTimeout()) * time.Second
+	pingInterval := time.Duration(prov.GetPingInterval()) * time.Second
+	idleTimer := time.NewTimer(idleTimeout)
+	defer idleTimer.Stop()
+	pingTicker := time.NewTicker(pingInterval)
+	defer pingTicker.Stop()
 
 	// 处理流式数据块
-	for scanner.Scan() {
-		line := scanner.Text()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			return
 
-		// 跳过空行和注释
-		if line == "" || strings.HasPrefix(line, ":") {
-			continue
-		}
+		case <-pingTicker.C:
+			processor.SendPingIfIdle(pingInterval)
+
+		case <-idleTimer.C:
+			if cfg.Debug || cfg.SimpleLog {
+				fmt.Printf("[%s] [超时] 上游流停滞：%v 内未收到任何数据 模型=%s\n",
+					time.Now().Format("15:04:05"), idleTimeout, openaiReq.Model)
+			}
+			writeSSEProxyError(w, errors.NewTimeoutError(fmt.Sprintf("上游流停滞：%v 内未收到任何数据", idleTimeout)))
+			return
 
-		// 检查 [DONE] 标记
-		if strings.Contains(line, "[DONE]") {
-			break
+		case line, ok := <-lines:
+			if !ok {
+				break loop
+			}
+			if !idleTimer.Stop() {
+				<-idleTimer.C
+			}
+			idleTimer.Reset(idleTimeout)
+
+			done, streamErr := handleLine(processor, w, line, cfg)
+			if streamErr != nil {
+				// 上游在流中途报错 - 转发类型化的错误事件后结束
+				writeSSEProxyError(w, streamErr)
+				return
+			}
+			if done {
+				break loop
+			}
 		}
 
-		// 解析数据行
-		if !strings.HasPrefix(line, "data: ") {
-			continue
+		// 客户端已断开 - 停止读取上游
+		if processor.ClientGone() {
+			return
 		}
+	}
 
-		dataJSON := strings.TrimPrefix(line, "data: ")
+	// 客户端已断开 - 无需发送结束事件
+	if processor.ClientGone() || ctx.Err() != nil {
+		return
+	}
 
-		var chunk map[string]interface{}
-		if err := json.Unmarshal([]byte(dataJSON), &chunk); err != nil {
-			continue
+	// 完成所有块并发送最终事件
+	processor.FinalizeBlocks()
+
+	// 检查扫描器错误（收到 [DONE] 提前结束时 readErr 可能尚未写入，不阻塞等待）
+	select {
+	case err := <-readErr:
+		if err != nil {
+			writeSSEProxyError(w, errors.NewStreamProcessingError(fmt.Sprintf("流读取错误: %v", err)).WithCause(err))
 		}
+	default:
+	}
+}
+
+// handleOpenAIStreamLine 处理上游 OpenAI 流中的一行数据。
+// 收到 [DONE] 标记时 done 为 true；上游在流中途发送错误对象时返回该错误。
+func handleOpenAIStreamLine(processor *StreamProcessor, w *bufio.Writer, line string, cfg *config.Config) (done bool, streamErr *errors.ProxyError) {
+	// 跳过空行和注释
+	if line == "" || strings.HasPrefix(line, ":") {
+		return false, nil
+	}
+
+	// 检查 [DONE] 标记
+	if strings.Contains(line, "[DONE]") {
+		return true, nil
+	}
+
+	// 解析数据行
+	if !strings.HasPrefix(line, "data: ") {
+		return false, nil
+	}
+
+	dataJSON := strings.TrimPrefix(line, "data: ")
+
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(dataJSON), &chunk); err != nil {
+		return false, nil
+	}
 
+	if cfg.Debug {
+		fmt.Printf("[调试] 来自提供商的原始数据块: %s\n", dataJSON)
+	}
+
+	// 检测 Claude 原生 SSE 事件格式，直接透传
+	if chunkType, ok := chunk["type"].(string); ok {
 		if cfg.Debug {
-			fmt.Printf("[调试] 来自提供商的原始数据块: %s\n", dataJSON)
+			fmt.Printf("[调试] 检测到 Claude 原生格式: type=%s\n", chunkType)
 		}
+		_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", chunkType, dataJSON)
+		processor.flush()
+		return false, nil
+	}
 
-		// 检测 Claude 原生 SSE 事件格式，直接透传
-		if chunkType, ok := chunk["type"].(string); ok {
-			if cfg.Debug {
-				fmt.Printf("[调试] 检测到 Claude 原生格式: type=%s\n", chunkType)
-			}
-			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", chunkType, dataJSON); err == nil {
-				_ = w.Flush()
-			}
-			continue
-		}
+	// 上游在流中途返回的错误对象（例如 OpenRouter 的提供商错误）
+	if _, ok := chunk["error"].(map[string]interface{}); ok {
+		return false, streamChunkError(chunk)
+	}
 
-		// 处理使用量数据
-		if usage, ok := chunk["usage"].(map[string]interface{}); ok {
-			processor.HandleUsageData(usage)
-		}
+	// 处理使用量数据
+	if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+		processor.HandleUsageData(usage)
+	}
 
-		// 从 choices 中提取 delta
-		choices, ok := chunk["choices"].([]interface{})
-		if !ok || len(choices) == 0 {
-			continue
-		}
+	// 从 choices 中提取 delta
+	choices, ok := chunk["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return false, nil
+	}
 
-		choice := choices[0].(map[string]interface{})
+	choice := choices[0].(map[string]interface{})
 
-		// 尝试从 delta 或 message 字段获取数据
-		delta, ok := choice["delta"].(map[string]interface{})
-		if !ok {
-			if message, msgOk := choice["message"].(map[string]interface{}); msgOk {
-				delta = message
-				ok = true
-			}
-		}
-		if !ok {
-			continue
+	// 尝试从 delta 或 message 字段获取数据
+	delta, ok := choice["delta"].(map[string]interface{})
+	if !ok {
+		if message, msgOk := choice["message"].(map[string]interface{}); msgOk {
+			delta = message
+			ok = true
 		}
+	}
+	if !ok {
+		return false, nil
+	}
 
-		// 处理思考块（reasoning_content, reasoning_details, reasoning）
-		processor.HandleThinkingDelta(delta)
+	// 处理思考块（reasoning_content, reasoning_details, reasoning）
+	processor.HandleThinkingDelta(delta)
 
-		// 处理文本 delta
-		if content, ok := delta["content"].(string); ok && content != "" {
-			processor.HandleTextDelta(content)
-		}
+	// 处理文本 delta
+	if content, ok := delta["content"].(string); ok && content != "" {
+		processor.HandleTextDelta(content)
+	}
 
-		// 处理 content 数组格式（Claude 原生格式）
-		if contentArr, ok := delta["content"].([]interface{}); ok && len(contentArr) > 0 {
-			processor.HandleContentArray(contentArr)
-		}
+	// 处理 content 数组格式（Claude 原生格式）
+	if contentArr, ok := delta["content"].([]interface{}); ok && len(contentArr) > 0 {
+		processor.HandleContentArray(contentArr)
+	}
+
+	// 处理工具调用 delta
+	if toolCallsRaw, ok := delta["tool_calls"]; ok {
+		processor.HandleToolCallsDelta(toolCallsRaw)
+	}
+
+	// 处理完成原因
+	if finishReason, ok := choice["finish_reason"].(string); ok && finishReason != "" {
+		processor.HandleFinishReason(finishReason)
+	}
 
-		// 处理工具调用 delta
-		if toolCallsRaw, ok := delta["tool_calls"]; ok {
-			processor.HandleToolCallsDelta(toolCallsRaw)
+	return false, nil
+}
+
+// streamChunkError 将流中的 OpenAI 错误对象转换为 ProxyError。
+// OpenRouter 在 error.code 中给出数字状态码，缺失时按上游服务器错误处理。
+func streamChunkError(chunk map[string]interface{}) *errors.ProxyError {
+	statusCode := http.StatusInternalServerError
+	if errObj, ok := chunk["error"].(map[string]interface{}); ok {
+		if code, ok := errObj["code"].(float64); ok && code >= 400 {
+			statusCode = int(code)
 		}
+	}
+	return errors.FromOpenAIError(statusCode, chunk)
+}
 
-		// 处理完成原因
-		if finishReason, ok := choice["finish_reason"].(string); ok && finishReason != "" {
-			processor.HandleFinishReason(finishReason)
+// scanLines 在独立 goroutine 中逐行读取上游响应。
+// 读取结束后关闭 lines 并向 errc 发送扫描错误（可能为 nil）；
+// ctx 取消后 goroutine 不再阻塞在发送上，调用方关闭响应体即可使其退出。
+func scanLines(ctx context.Context, reader io.Reader) (<-chan string, <-chan error) {
+	lines := make(chan string)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
 		}
+		errc <- scanner.Err()
+	}()
+
+	return lines, errc
+}
+
+// logClientDisconnected 记录客户端断开连接导致上游请求被取消
+func logClientDisconnected(cfg *config.Config, model string) {
+	if cfg.Debug || cfg.SimpleLog {
+		fmt.Printf("[%s] [取消] 客户端已断开连接，已取消上游请求 模型=%s\n",
+			time.Now().Format("15:04:05"), model)
 	}
+}
 
-	// 完成所有块并发送最终事件
-	processor.FinalizeBlocks()
+// logRoute 在调试模式下记录模型路由结果
+func logRoute(cfg *config.Config, model string, route converter.Route) {
+	if cfg.Debug {
+		fmt.Printf("[调试] 模型路由: %s → %s:%s（%s）\n", model, route.Backend, route.Model, route.Rule)
+	}
+}
 
-	// 检查扫描器错误
-	if err := scanner.Err(); err != nil {
-		writeSSEError(w, fmt.Sprintf("流读取错误: %v", err))
+// logParamPolicy 在调试模式下记录参数策略对上游请求的修改
+func logParamPolicy(cfg *config.Config, changes []string) {
+	if cfg.Debug && len(changes) > 0 {
+		fmt.Printf("[调试] 参数策略: %s\n", strings.Join(changes, "；"))
 	}
 }
 
@@ -419,147 +726,105 @@ func writeSSEEvent(w *bufio.Writer, event string, data interface{}) {
 	_, _ = fmt.Fprintf(w, "data: %s\n\n", string(dataJSON))
 }
 
-// writeSSEError 写入错误事件
-func writeSSEError(w *bufio.Writer, message string) {
-	writeSSEEvent(w, "error", map[string]interface{}{
-		"type": "error",
-		"error": map[string]interface{}{
-			"type":    "api_error",
-			"message": message,
-		},
-	})
-	_ = w.Flush()
-}
-
 // callOpenAI 向 OpenAI API 发送 HTTP 请求，带有自动重试逻辑
-// 用于处理 max_completion_tokens 参数错误。使用按模型的能力缓存。
-func callOpenAI(req *models.OpenAIRequest, cfg *config.Config) (*models.OpenAIResponse, error) {
-	// 使用配置的参数尝试请求
-	resp, err := callOpenAIInternal(req, cfg)
-	if err != nil {
-		// 检查是否是 max_tokens 参数错误
-		if isMaxTokensParameterError(err.Error()) {
-			if cfg.Debug {
-				fmt.Printf("[调试] 检测到模型 %s 的 max_completion_tokens 参数错误，正在重试\n", req.Model)
+// 用于处理 max_completion_tokens 和其他上游不支持的可选参数错误。使用按模型的能力缓存。
+// 同时返回上游响应头（用于转发速率限制信息）。
+func callOpenAI(ctx context.Context, prov provider.Provider, req *models.OpenAIRequest, cfg *config.Config) (*models.OpenAIResponse, http.Header, error) {
+	// 已知模型不支持的功能直接返回错误
+	if pe := checkLearnedCapabilities(prov, req, cfg); pe != nil {
+		return nil, nil, pe
+	}
+	// 删除已知模型不支持的可选参数
+	req = stripLearnedParameters(prov, req, cfg)
+
+	for attempt := 0; ; attempt++ {
+		// 使用配置的参数尝试请求
+		resp, header, err := callOpenAIInternal(ctx, prov, req, cfg)
+		if err == nil {
+			// 成功 - 缓存此（提供商，模型）支持请求中使用的参数和功能
+			// 仅在实际发送了 max_completion_tokens 时缓存该项
+			learnRequestCapabilities(prov, req, cfg)
+			return resp, header, nil
+		}
+
+		// 参数错误 - 删除上游不支持的参数后重试，并按模型缓存能力
+		if attempt < maxParameterRetries {
+			if retryReq := retryRequestFor(prov, req, err, cfg); retryReq != nil {
+				req = retryReq
+				continue
 			}
-			// 不使用 max_completion_tokens 重试，并按模型缓存能力
-			return retryWithoutMaxCompletionTokens(req, cfg)
 		}
-		// 其他错误 - 原样返回
-		return nil, err
-	}
 
-	// 首次尝试成功 - 缓存此（提供商，模型）支持 max_completion_tokens
-	// 仅在实际发送了 max_completion_tokens 时缓存
-	if req.MaxCompletionTokens > 0 {
-		cacheKey := config.CacheKey{
-			BaseURL: cfg.OpenAIBaseURL,
-			Model:   req.Model,
-		}
-		config.SetModelCapabilities(cacheKey, &config.ModelCapabilities{
-			UsesMaxCompletionTokens: true,
-		})
-		if cfg.Debug {
-			fmt.Printf("[调试] 已缓存：模型 %s 支持 max_completion_tokens\n", req.Model)
-		}
+		// 其他错误 - 记录错误表明的不支持功能后原样返回
+		learnFromUpstreamError(prov, req, err, cfg)
+		return nil, nil, err
 	}
-
-	return resp, nil
 }
 
 // callOpenAIStream 发送流式 HTTP 请求，带有参数错误重试逻辑。
 // 使用按模型的能力缓存。
-func callOpenAIStream(req *models.OpenAIRequest, cfg *config.Config) (*http.Response, error) {
-	// 使用配置的参数尝试
-	resp, err := callOpenAIStreamInternal(req, cfg)
-	if err != nil {
-		// 检查是否是 max_tokens 参数错误
-		if isMaxTokensParameterError(err.Error()) {
-			if cfg.Debug {
-				fmt.Printf("[调试] 检测到流式模型 %s 的 max_completion_tokens 参数错误，正在重试\n", req.Model)
-			}
-			// 创建不带 max tokens 的重试请求
-			retryReq := *req
-			retryReq.MaxCompletionTokens = 0
-			retryReq.MaxTokens = 0
-
-			// 缓存此（提供商，模型）不支持 max_completion_tokens
-			cacheKey := config.CacheKey{
-				BaseURL: cfg.OpenAIBaseURL,
-				Model:   req.Model,
+func callOpenAIStream(ctx context.Context, prov provider.Provider, req *models.OpenAIRequest, cfg *config.Config) (*http.Response, error) {
+	// 已知模型不支持的功能直接返回错误
+	if pe := checkLearnedCapabilities(prov, req, cfg); pe != nil {
+		return nil, pe
+	}
+	// 删除已知模型不支持的可选参数
+	req = stripLearnedParameters(prov, req, cfg)
+
+	for attempt := 0; ; attempt++ {
+		// 使用配置的参数尝试
+		resp, err := callOpenAIStreamInternal(ctx, prov, req, cfg)
+		if err == nil {
+			// 成功 - 缓存请求中使用的参数和功能（发送了 max_completion_tokens 时包括该项）
+			learnRequestCapabilities(prov, req, cfg)
+			return resp, nil
+		}
+
+		// 参数错误 - 删除上游不支持的参数后重试
+		if attempt < maxParameterRetries {
+			if retryReq := retryRequestFor(prov, req, err, cfg); retryReq != nil {
+				req = retryReq
+				continue
 			}
-			config.SetModelCapabilities(cacheKey, &config.ModelCapabilities{
-				UsesMaxCompletionTokens: false,
-			})
-
-			return callOpenAIStreamInternal(&retryReq, cfg)
 		}
-		return nil, err
-	}
 
-	// 成功 - 如果发送了 max_completion_tokens 则缓存能力
-	if req.MaxCompletionTokens > 0 {
-		cacheKey := config.CacheKey{
-			BaseURL: cfg.OpenAIBaseURL,
-			Model:   req.Model,
-		}
-		config.SetModelCapabilities(cacheKey, &config.ModelCapabilities{
-			UsesMaxCompletionTokens: true,
-		})
-		if cfg.Debug {
-			fmt.Printf("[调试] 已缓存：模型 %s 支持 max_completion_tokens（流式）\n", req.Model)
-		}
+		learnFromUpstreamError(prov, req, err, cfg)
+		return nil, err
 	}
-
-	return resp, nil
 }
 
 // callOpenAIStreamInternal 发送流式 HTTP 请求，不带重试逻辑
-func callOpenAIStreamInternal(req *models.OpenAIRequest, cfg *config.Config) (*http.Response, error) {
-	// 将请求序列化为 JSON
-	reqBody, err := json.Marshal(req)
+func callOpenAIStreamInternal(ctx context.Context, prov provider.Provider, req *models.OpenAIRequest, cfg *config.Config) (*http.Response, error) {
+	// 按后端协议序列化请求
+	reqBody, err := upstreamRequestBody(prov, req)
 	if err != nil {
 		return nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
-	// 构建 API URL
-	apiURL := cfg.OpenAIBaseURL + "/chat/completions"
-
 	// 创建 HTTP 请求
-	httpReq, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", prov.RequestEndpoint(req), bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	// 设置请求头
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	// 跳过 Ollama（localhost）的认证
-	if !cfg.IsLocalhost() {
-		httpReq.Header.Set("Authorization", "Bearer "+cfg.OpenAIAPIKey)
-	}
-
-	// OpenRouter 特定的请求头
-	if cfg.DetectProvider() == config.ProviderOpenRouter {
-		addOpenRouterHeaders(httpReq, cfg)
-	}
+	// 设置提供商特定的请求头（认证、OpenRouter 应用信息等）
+	prov.AddHeaders(httpReq)
 
-	// 创建带有较长超时的 HTTP 客户端用于流式传输
-	client := &http.Client{
-		Timeout: 300 * time.Second,
-	}
+	// 使用后端共享连接池创建带有较长总超时的 HTTP 客户端用于流式传输
+	// 上游停滞由空闲超时单独检测，见 streamOpenAIToClaude
+	client := newHTTPClient(prov, time.Duration(prov.GetStreamTimeout())*time.Second)
 
 	// 发送请求
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+		return nil, transportError(err)
 	}
 
 	// 检查错误
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
-		return nil, fmt.Errorf("OpenAI API 返回状态码 %d: %s", resp.StatusCode, string(body))
+		return nil, upstreamError(prov, resp, body)
 	}
 
 	return resp, nil
@@ -585,9 +850,21 @@ func isMaxTokensParameterError(errorMessage string) bool {
 	return hasParamIndicator && hasOurParam
 }
 
-// retryWithoutMaxCompletionTokens 尝试不使用 max_completion_tokens 重新发送请求。
+// retryRequestFor 在上游错误是参数错误时返回删除相应参数后的重试请求，其他错误返回 nil。
+// max_tokens 相关的错误优先处理，其他参数见 withoutUnsupportedParameter。
+func retryRequestFor(prov provider.Provider, req *models.OpenAIRequest, err error, cfg *config.Config) *models.OpenAIRequest {
+	if (req.MaxCompletionTokens > 0 || req.MaxTokens > 0) && isMaxTokensParameterError(err.Error()) {
+		if cfg.Debug {
+			fmt.Printf("[调试] 检测到模型 %s 的 max_completion_tokens 参数错误，正在重试\n", req.Model)
+		}
+		return withoutMaxCompletionTokens(prov, req, cfg)
+	}
+	return withoutUnsupportedParameter(prov, req, err, cfg)
+}
+
+// withoutMaxCompletionTokens 返回不使用 max_completion_tokens 的请求副本。
 // 按（提供商，模型）组合缓存结果以供将来请求使用。
-func retryWithoutMaxCompletionTokens(req *models.OpenAIRequest, cfg *config.Config) (*models.OpenAIResponse, error) {
+func withoutMaxCompletionTokens(prov provider.Provider, req *models.OpenAIRequest, cfg *config.Config) *models.OpenAIRequest {
 	// 创建不带 max_completion_tokens 的请求副本
 	retryReq := *req
 	retryReq.MaxCompletionTokens = 0
@@ -598,82 +875,62 @@ func retryWithoutMaxCompletionTokens(req *models.OpenAIRequest, cfg *config.Conf
 	}
 
 	// 缓存此特定（提供商，模型）不支持 max_completion_tokens
-	cacheKey := config.CacheKey{
-		BaseURL: cfg.OpenAIBaseURL,
-		Model:   req.Model,
-	}
-	config.SetModelCapabilities(cacheKey, &config.ModelCapabilities{
-		UsesMaxCompletionTokens: false,
-	})
-
-	// 发送重试请求
-	return callOpenAIInternal(&retryReq, cfg)
+	config.LearnCapability(capabilityKey(prov, req), config.CapabilityMaxCompletionTokens, false)
+	return &retryReq
 }
 
 // callOpenAIInternal 是不带重试逻辑的内部实现
-func callOpenAIInternal(req *models.OpenAIRequest, cfg *config.Config) (*models.OpenAIResponse, error) {
-	// 将请求序列化为 JSON
-	reqBody, err := json.Marshal(req)
+func callOpenAIInternal(ctx context.Context, prov provider.Provider, req *models.OpenAIRequest, cfg *config.Config) (*models.OpenAIResponse, http.Header, error) {
+	// 按后端协议序列化请求
+	reqBody, err := upstreamRequestBody(prov, req)
 	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %w", err)
+		return nil, nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
-	// 构建 API URL
-	apiURL := cfg.OpenAIBaseURL + "/chat/completions"
-
 	// 创建 HTTP 请求
-	httpReq, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", prov.RequestEndpoint(req), bytes.NewBuffer(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+		return nil, nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	// 设置请求头
-	httpReq.Header.Set("Content-Type", "application/json")
+	// 设置提供商特定的请求头（认证、OpenRouter 应用信息等）
+	prov.AddHeaders(httpReq)
 
-	// 跳过 Ollama（localhost）的认证 - Ollama 不需要认证
-	if !cfg.IsLocalhost() {
-		httpReq.Header.Set("Authorization", "Bearer "+cfg.OpenAIAPIKey)
-	}
-
-	// OpenRouter 特定的请求头以获得更好的速率限制
-	if cfg.DetectProvider() == config.ProviderOpenRouter {
-		addOpenRouterHeaders(httpReq, cfg)
-	}
-
-	// 创建带超时的 HTTP 客户端
-	client := &http.Client{
-		Timeout: 90 * time.Second,
-	}
+	// 使用后端共享连接池创建带超时的 HTTP 客户端
+	client := newHTTPClient(prov, time.Duration(prov.GetTimeout())*time.Second)
 
 	// 发送请求
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+		return nil, nil, transportError(err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	// 读取响应体
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	// 检查错误
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenAI API 返回状态码 %d: %s", resp.StatusCode, string(respBody))
+		return nil, nil, upstreamError(prov, resp, respBody)
 	}
 
-	// 解析响应
-	var openaiResp models.OpenAIResponse
-	if err := json.Unmarshal(respBody, &openaiResp); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+	// 按后端协议解析响应
+	openaiResp, err := decodeUpstreamResponse(prov, respBody, req.Model)
+	if err != nil {
+		if pe, ok := errors.AsProxyError(err); ok {
+			return nil, nil, pe
+		}
+		return nil, nil, fmt.Errorf("解析响应失败: %w", err)
 	}
 
-	return &openaiResp, nil
+	return openaiResp, resp.Header, nil
 }
 
-func handleCountTokens(c *fiber.Ctx, cfg *config.Config) error {
-	// 简单的令牌计数端点
+// handleCountTokens 是 /v1/messages/count_tokens 端点的处理器（兼容性占位，固定返回 100）
+func handleCountTokens(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"input_tokens": 100,
 	})
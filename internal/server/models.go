@@ -0,0 +1,355 @@
+// models.go 实现 Anthropic 兼容的 /v1/models 和 /v1/models/{id} 端点。
+// 列表包括代理路由的 Claude 模型别名（元数据中给出映射到的后端模型），
+// 以及可选的上游模型列表（OpenAI 兼容或 Gemini 的 /models，或 Ollama 的 /api/tags），后者按 TTL 缓存。
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// defaultModelsLimit 和 maxModelsLimit 对应 Anthropic API 的分页默认值和上限
+	defaultModelsLimit = 20
+	maxModelsLimit     = 1000
+	// upstreamModelsTimeout 获取上游模型列表的超时
+	upstreamModelsTimeout = 10 * time.Second
+)
+
+// modelInfo 是 /v1/models 返回的单个模型
+type modelInfo struct {
+	Type        string    `json:"type"`
+	ID          string    `json:"id"`
+	DisplayName string    `json:"display_name"`
+	CreatedAt   time.Time `json:"created_at"`
+	Proxy       fiber.Map `json:"proxy,omitempty"` // 代理扩展元数据：后端、后端模型、来源
+}
+
+// upstreamModels 缓存的上游模型列表
+type upstreamModels struct {
+	models    []modelInfo
+	fetchedAt time.Time
+}
+
+// 后端名称 -> 缓存的上游模型列表
+var (
+	upstreamModelCache      = make(map[string]*upstreamModels)
+	upstreamModelCacheMutex sync.Mutex
+)
+
+// handleListModels 是 GET /v1/models 端点的处理器。
+// 支持 Anthropic 的分页参数 limit、after_id 和 before_id。
+func handleListModels(c *fiber.Ctx, cfg *config.Config) error {
+	models := listModels(c.Context(), cfg)
+
+	limit := c.QueryInt("limit", defaultModelsLimit)
+	if limit < 1 || limit > maxModelsLimit {
+		return sendProxyError(c, errors.NewInvalidRequestError(
+			fmt.Sprintf("limit 必须在 1 到 %d 之间", maxModelsLimit)))
+	}
+
+	start, end := 0, len(models)
+	if afterID := c.Query("after_id"); afterID != "" {
+		start = indexOfModel(models, afterID) + 1
+	}
+	if beforeID := c.Query("before_id"); beforeID != "" {
+		if i := indexOfModel(models, beforeID); i >= 0 {
+			end = i
+		}
+		// before_id 返回紧邻其前的一页
+		if end-start > limit {
+			start = end - limit
+		}
+	}
+	if start > end {
+		start = end
+	}
+	if end-start > limit {
+		end = start + limit
+	}
+	page := models[start:end]
+
+	result := fiber.Map{
+		"data":     page,
+		"has_more": end < len(models),
+		"first_id": nil,
+		"last_id":  nil,
+	}
+	if len(page) > 0 {
+		result["first_id"] = page[0].ID
+		result["last_id"] = page[len(page)-1].ID
+	}
+	return c.JSON(result)
+}
+
+// handleGetModel 是 GET /v1/models/{id} 端点的处理器。
+// 未列出但匹配路由规则或名称包含 opus/sonnet/haiku 的模型同样可以路由，因此也会返回。
+func handleGetModel(c *fiber.Ctx, cfg *config.Config) error {
+	id := c.Params("id")
+
+	for _, model := range listModels(c.Context(), cfg) {
+		if model.ID == id {
+			return c.JSON(model)
+		}
+	}
+
+	if route := converter.ResolveRoute(id, cfg); route.Model != id || route.Backend != config.DefaultBackendName {
+		return c.JSON(modelInfo{
+			Type:        "model",
+			ID:          id,
+			DisplayName: id,
+			Proxy:       aliasMetadata(route),
+		})
+	}
+
+	return sendProxyError(c, errors.NewNotFoundError(fmt.Sprintf("模型不存在: %s", id)))
+}
+
+// listModels 返回 Claude 模型别名，以及（启用时）各后端的上游模型
+func listModels(ctx context.Context, cfg *config.Config) []modelInfo {
+	models := make([]modelInfo, 0, len(converter.KnownClaudeModels))
+	seen := make(map[string]bool)
+
+	for _, alias := range converter.KnownClaudeModels {
+		models = append(models, modelInfo{
+			Type:        "model",
+			ID:          alias.ID,
+			DisplayName: alias.DisplayName,
+			CreatedAt:   alias.CreatedAt,
+			Proxy:       aliasMetadata(converter.ResolveRoute(alias.ID, cfg)),
+		})
+		seen[alias.ID] = true
+	}
+
+	// 路由规则中的完整名称别名（如 fast、cheap）
+	for _, rule := range cfg.Routes {
+		if rule.Match != config.RouteExact || seen[rule.Pattern] {
+			continue
+		}
+		models = append(models, modelInfo{
+			Type:        "model",
+			ID:          rule.Pattern,
+			DisplayName: rule.Pattern,
+			Proxy:       aliasMetadata(converter.ResolveRoute(rule.Pattern, cfg)),
+		})
+		seen[rule.Pattern] = true
+	}
+
+	if !cfg.ModelsIncludeUpstream {
+		return models
+	}
+
+	names := make([]string, 0, len(cfg.Backends))
+	for name := range cfg.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, model := range cachedUpstreamModels(ctx, cfg, cfg.Backends[name]) {
+			if !seen[model.ID] {
+				models = append(models, model)
+				seen[model.ID] = true
+			}
+		}
+	}
+	return models
+}
+
+// aliasMetadata 返回别名的代理元数据
+func aliasMetadata(route converter.Route) fiber.Map {
+	return fiber.Map{
+		"source":        "alias",
+		"backend":       route.Backend,
+		"backend_model": route.Model,
+		"rule":          route.Rule,
+	}
+}
+
+// cachedUpstreamModels 返回后端的上游模型列表，缓存过期时重新获取。
+// 获取失败时保留旧缓存（如果有），不影响别名列表。
+func cachedUpstreamModels(ctx context.Context, cfg *config.Config, backend *config.Backend) []modelInfo {
+	upstreamModelCacheMutex.Lock()
+	cached := upstreamModelCache[backend.Name]
+	upstreamModelCacheMutex.Unlock()
+
+	if cached != nil && time.Since(cached.fetchedAt) < cfg.ModelsCacheTTL {
+		return cached.models
+	}
+
+	models, err := fetchUpstreamModels(ctx, cfg, backend)
+	if err != nil {
+		if cfg.Debug {
+			fmt.Printf("[调试] 获取后端 %s 的模型列表失败: %v\n", backend.Name, err)
+		}
+		if cached != nil {
+			return cached.models
+		}
+		return nil
+	}
+
+	upstreamModelCacheMutex.Lock()
+	upstreamModelCache[backend.Name] = &upstreamModels{models: models, fetchedAt: time.Now()}
+	upstreamModelCacheMutex.Unlock()
+	return models
+}
+
+// fetchUpstreamModels 从上游获取模型列表。
+// Ollama 使用原生的 /api/tags，Gemini 协议使用原生的 /models，Anthropic 协议使用 /v1/models，其他后端使用 OpenAI 兼容的 /models。
+// Bedrock Runtime 端点不提供模型列表，只返回别名。
+func fetchUpstreamModels(ctx context.Context, cfg *config.Config, backend *config.Backend) ([]modelInfo, error) {
+	if backend.Protocol == config.ProtocolBedrock {
+		return nil, fmt.Errorf("Bedrock Runtime 不提供模型列表")
+	}
+
+	prov := provider.ForBackend(cfg, backend)
+	isGemini := backend.Protocol == config.ProtocolGemini
+	isOllama := backend.Protocol == config.ProtocolOllama ||
+		(!isGemini && backend.DetectProvider() == config.ProviderOllama)
+
+	modelsURL := strings.TrimSuffix(backend.BaseURL, "/") + "/models"
+	if isOllama {
+		modelsURL = backend.OllamaAPIURL(constants.EndpointOllamaTags)
+	} else if backend.Protocol == config.ProtocolAnthropic {
+		// Anthropic 的模型列表与 OpenAI 一样在 data[].id 中
+		modelsURL = strings.TrimSuffix(prov.GetEndpoint(), "/messages") + "/models"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, upstreamModelsTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", modelsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	prov.AddHeaders(httpReq)
+
+	resp, err := newHTTPClient(prov, upstreamModelsTimeout).Do(httpReq)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstreamError(prov, resp, body)
+	}
+
+	if isOllama {
+		return parseOllamaTags(body, backend.Name)
+	}
+	if isGemini {
+		return parseGeminiModels(body, backend.Name)
+	}
+	return parseOpenAIModels(body, backend.Name)
+}
+
+// parseOpenAIModels 解析 OpenAI 兼容的 /models 响应（OpenRouter 额外提供 name 字段）
+func parseOpenAIModels(body []byte, backendName string) ([]modelInfo, error) {
+	var list struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Name    string `json:"name"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("解析模型列表失败: %w", err)
+	}
+
+	models := make([]modelInfo, 0, len(list.Data))
+	for _, m := range list.Data {
+		displayName := m.Name
+		if displayName == "" {
+			displayName = m.ID
+		}
+		models = append(models, upstreamModel(m.ID, displayName, time.Unix(m.Created, 0), backendName))
+	}
+	return models, nil
+}
+
+// parseOllamaTags 解析 Ollama 的 /api/tags 响应
+func parseOllamaTags(body []byte, backendName string) ([]modelInfo, error) {
+	var tags struct {
+		Models []struct {
+			Name       string    `json:"name"`
+			ModifiedAt time.Time `json:"modified_at"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, fmt.Errorf("解析模型列表失败: %w", err)
+	}
+
+	models := make([]modelInfo, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		models = append(models, upstreamModel(m.Name, m.Name, m.ModifiedAt, backendName))
+	}
+	return models, nil
+}
+
+// parseGeminiModels 解析 Gemini 的 /models 响应（名称带 models/ 前缀，转发时会去掉）
+func parseGeminiModels(body []byte, backendName string) ([]modelInfo, error) {
+	var list struct {
+		Models []struct {
+			Name        string `json:"name"`
+			DisplayName string `json:"displayName"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("解析模型列表失败: %w", err)
+	}
+
+	models := make([]modelInfo, 0, len(list.Models))
+	for _, m := range list.Models {
+		id := strings.TrimPrefix(m.Name, "models/")
+		displayName := m.DisplayName
+		if displayName == "" {
+			displayName = id
+		}
+		models = append(models, upstreamModel(id, displayName, time.Time{}, backendName))
+	}
+	return models, nil
+}
+
+// upstreamModel 创建上游模型条目；上游模型名称原样传递给后端
+func upstreamModel(id, displayName string, createdAt time.Time, backendName string) modelInfo {
+	return modelInfo{
+		Type:        "model",
+		ID:          id,
+		DisplayName: displayName,
+		CreatedAt:   createdAt.UTC(),
+		Proxy: fiber.Map{
+			"source":        "upstream",
+			"backend":       backendName,
+			"backend_model": id,
+		},
+	}
+}
+
+// indexOfModel 返回模型在列表中的位置，不存在时返回 -1
+func indexOfModel(models []modelInfo, id string) int {
+	for i, model := range models {
+		if model.ID == id {
+			return i
+		}
+	}
+	return -1
+}
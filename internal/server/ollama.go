@@ -0,0 +1,77 @@
+// ollama.go 实现 Ollama 原生 /api/chat 上游流的解析：
+// 流为 NDJSON 格式（每行一个完整的 JSON 对象），最后一行 done 为 true 并带有令牌计数。
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// handleOllamaStreamLine 处理上游 Ollama 流中的一行数据。
+// thinking 字段转换为思考增量，tool_calls 作为完整的工具调用发送；
+// 收到 done 为 true 的行时记录 prompt_eval_count/eval_count 并结束，行中包含 error 时返回该错误。
+func handleOllamaStreamLine(processor *StreamProcessor, w *bufio.Writer, line string, cfg *config.Config) (done bool, streamErr *errors.ProxyError) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return false, nil
+	}
+
+	var chunk models.OllamaChatResponse
+	if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+		return false, nil
+	}
+
+	if cfg.Debug {
+		fmt.Printf("[调试] 来自提供商的 Ollama 数据块: %s\n", line)
+	}
+
+	if chunk.Error != "" {
+		return false, ollamaStreamError(chunk.Error)
+	}
+
+	if chunk.Message.Thinking != "" {
+		processor.HandleThinkingDelta(map[string]interface{}{"reasoning_content": chunk.Message.Thinking})
+	}
+	if chunk.Message.Content != "" {
+		processor.HandleTextDelta(chunk.Message.Content)
+	}
+	for _, tc := range chunk.Message.ToolCalls {
+		argsJSON, err := json.Marshal(tc.Function.Arguments)
+		if err != nil || tc.Function.Arguments == nil {
+			argsJSON = []byte("{}")
+		}
+		processor.HandleToolCallsDelta([]interface{}{map[string]interface{}{
+			"index": float64(len(processor.state.CurrentToolCalls)),
+			"function": map[string]interface{}{
+				"name":      tc.Function.Name,
+				"arguments": string(argsJSON),
+			},
+		}})
+	}
+
+	if !chunk.Done {
+		return false, nil
+	}
+
+	processor.HandleUsageData(map[string]interface{}{
+		"prompt_tokens":     float64(chunk.PromptEvalCount),
+		"completion_tokens": float64(chunk.EvalCount),
+	})
+	processor.HandleFinishReason(converter.OllamaFinishReason(chunk.DoneReason, len(processor.state.CurrentToolCalls) > 0))
+	return true, nil
+}
+
+// ollamaStreamError 将 Ollama 的错误消息（字符串形式）转换为代理错误
+func ollamaStreamError(message string) *errors.ProxyError {
+	return streamChunkError(map[string]interface{}{
+		"error": map[string]interface{}{"message": message},
+	})
+}
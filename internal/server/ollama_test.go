@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+// ndjson 将每个 JSON 对象编码为一行
+func ndjson(lines ...string) []string {
+	chunks := make([]string, 0, len(lines))
+	for _, line := range lines {
+		chunks = append(chunks, line+"\n")
+	}
+	return chunks
+}
+
+func TestOllamaStream(t *testing.T) {
+	upstream := streamingUpstream(t, "application/x-ndjson", ndjson(
+		`{"model":"test-model","message":{"role":"assistant","content":"","thinking":"需要"},"done":false}`,
+		`{"model":"test-model","message":{"role":"assistant","content":"","thinking":"读取"},"done":false}`,
+		`{"model":"test-model","message":{"role":"assistant","content":"我来读取。"},"done":false}`,
+		``,
+		`{"model":"test-model","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read","arguments":{"path":"a.txt"}}}]},"done":false}`,
+		`{"model":"test-model","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":8}`,
+	)...)
+	// OpenAI 兼容的 /v1 后缀会被去掉，请求发送到原生 /api/chat
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL + "/v1", Protocol: config.ProtocolOllama}))
+
+	status, resp := postJSON(t, app, "/v1/messages", `{"model":"claude-sonnet-4-5","max_tokens":1000,"stream":true,`+
+		`"thinking":{"type":"enabled","budget_tokens":1024},`+
+		`"messages":[{"role":"user","content":"`+strings.Repeat("abcd", 10000)+`"}],`+
+		`"tools":[{"name":"read","description":"读取文件","input_schema":{"type":"object","properties":{"path":{"type":"string"}}}}]}`)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, resp)
+	}
+
+	req := upstream.last(t)
+	if req.Path != "/api/chat" {
+		t.Errorf("上游路径 = %q", req.Path)
+	}
+	body := req.Body
+	// 约 10000 令牌的提示加上 1000 输出令牌，num_ctx 自动调整为 16384
+	options, _ := body["options"].(map[string]interface{})
+	if options["num_ctx"] != float64(16384) || options["num_predict"] != float64(1000) {
+		t.Errorf("options = %v", options)
+	}
+	if body["stream"] != true || body["think"] != true {
+		t.Errorf("stream = %v, think = %v", body["stream"], body["think"])
+	}
+
+	events := parseSSE(t, resp)
+	messageDelta := checkClaudeStream(t, events, "thinking", "text", "tool_use")
+	if got := deltaText(events, "thinking"); got != "需要读取" {
+		t.Errorf("思考内容 = %q", got)
+	}
+	if got := deltaText(events, "text"); got != "我来读取。" {
+		t.Errorf("文本内容 = %q", got)
+	}
+	if got := deltaText(events, "partial_json"); got != `{"path":"a.txt"}` {
+		t.Errorf("工具参数 = %q", got)
+	}
+	// Ollama 工具调用时 done_reason 同样为 stop
+	if reason := messageDelta["delta"].(map[string]interface{})["stop_reason"]; reason != "tool_use" {
+		t.Errorf("stop_reason = %v", reason)
+	}
+	if usage := messageDelta["usage"].(map[string]interface{}); usage["output_tokens"] != float64(8) {
+		t.Errorf("用量 = %v", usage)
+	}
+}
+
+func TestOllamaStreamError(t *testing.T) {
+	upstream := streamingUpstream(t, "application/x-ndjson", ndjson(
+		`{"model":"test-model","message":{"role":"assistant","content":"部分"},"done":false}`,
+		`{"error":"model runner has unexpectedly stopped"}`,
+		`{"model":"test-model","message":{"role":"assistant","content":"不应出现"},"done":false}`,
+	)...)
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL, Protocol: config.ProtocolOllama}))
+
+	_, resp := postJSON(t, app, "/v1/messages", streamRequest)
+	events := parseSSE(t, resp)
+	last := events[len(events)-1]
+	if last.Event != "error" {
+		t.Fatalf("最后一个事件 = %s，应为 error；事件: %v", last.Event, eventTypes(events))
+	}
+	errObj := last.Data["error"].(map[string]interface{})
+	if errObj["type"] != "api_error" || errObj["message"] != "model runner has unexpectedly stopped" {
+		t.Errorf("错误 = %v", errObj)
+	}
+	if got := deltaText(events, "text"); got != "部分" {
+		t.Errorf("文本内容 = %q，错误之后的数据不应发送", got)
+	}
+}
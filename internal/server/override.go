@@ -0,0 +1,109 @@
+// override.go 处理单个请求的后端和模型覆盖：x-proxy-model 请求头或模型名称的 @ 后缀，
+// 用于会话中临时试用其他模型而无需修改配置或重启守护进程。
+
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// modelOverrideHeader 为单个请求指定后端和模型的请求头，值为 "后端:模型" 或 "模型"
+const modelOverrideHeader = "x-proxy-model"
+
+// requestRoute 返回请求的路由。
+// x-proxy-model 请求头或模型名称的 @ 后缀（如 claude-sonnet-4@openrouter:deepseek/deepseek-r1）指定的覆盖优先于路由规则，
+// 请求头优先于后缀；后缀（见 modelSuffixOverride）会从请求的模型名称中移除。覆盖只能指向允许的已配置后端（见 MODEL_OVERRIDE_BACKENDS）。
+// 禁用覆盖时忽略请求头，模型名称保持原样。
+// 认证的客户端（见 auth.go）设置了默认模型时，没有覆盖的请求使用默认模型；路由结果必须在客户端允许的路由中，
+// 且客户端的用量不能超出预算（见 budget.go）。
+func requestRoute(c *fiber.Ctx, claudeReq *models.ClaudeRequest, cfg *config.Config) (converter.Route, *errors.ProxyError) {
+	client := requestClient(c)
+	route, pe := selectRoute(c, claudeReq, client, cfg)
+	if pe != nil {
+		return route, pe
+	}
+	if pe := applyClientRoute(&route, client, cfg); pe != nil {
+		return route, pe
+	}
+	if cfg.ClampMaxTokens {
+		route.Policy = route.Policy.WithMaxTokensAuto()
+	}
+	return route, checkClientBudget(c, client, cfg)
+}
+
+// selectRoute 按单个请求的覆盖、客户端的默认模型或路由规则选择路由
+func selectRoute(c *fiber.Ctx, claudeReq *models.ClaudeRequest, client *config.Client, cfg *config.Config) (converter.Route, *errors.ProxyError) {
+	if !cfg.ModelOverrideEnabled() {
+		return defaultRoute(claudeReq, client, cfg), nil
+	}
+
+	target, source := c.Get(modelOverrideHeader), modelOverrideHeader+" 请求头"
+	fromSuffix := false
+	if model, suffix, ok := modelSuffixOverride(claudeReq.Model); ok {
+		if target == "" {
+			target, source, fromSuffix = suffix, "模型名称后缀", true
+		}
+		claudeReq.Model = model
+	}
+	if strings.TrimSpace(target) == "" {
+		return defaultRoute(claudeReq, client, cfg), nil
+	}
+
+	route := converter.OverrideRoute(target, source, cfg)
+	if fromSuffix && !hasBackendPrefix(target, cfg) {
+		// 只写模型的后缀（如 claude-sonnet-4@qwen3-coder）发送到去掉后缀的模型名称原本路由到的后端
+		route.Backend = defaultRoute(claudeReq, client, cfg).Backend
+	}
+	if route.Model == "" {
+		return route, errors.NewInvalidRequestError(fmt.Sprintf("%s中的模型名称不能为空", source))
+	}
+	if !cfg.ModelOverrideAllowed(route.Backend) {
+		return route, errors.NewPermissionError(fmt.Sprintf("不允许覆盖到后端 %q", route.Backend))
+	}
+	return route, nil
+}
+
+// vertexVersionSuffix 匹配 Vertex AI 模型名称中的日期版本后缀（如 claude-sonnet-4@20250514 的 20250514）
+var vertexVersionSuffix = regexp.MustCompile(`^\d{8}$`)
+
+// modelSuffixOverride 拆分模型名称中 @ 后缀指定的覆盖目标，后缀为 "后端:模型" 或 "模型"。
+// 没有后缀、后缀为空或是 Vertex AI 的日期版本（如 claude-sonnet-4@20250514）时 @ 是模型名称的一部分，ok 为 false。
+func modelSuffixOverride(model string) (base, target string, ok bool) {
+	i := strings.LastIndex(model, "@")
+	if i <= 0 || i == len(model)-1 || vertexVersionSuffix.MatchString(model[i+1:]) {
+		return model, "", false
+	}
+	return model[:i], model[i+1:], true
+}
+
+// hasBackendPrefix 如果覆盖目标以 "已配置的后端:" 开头则返回 true
+func hasBackendPrefix(target string, cfg *config.Config) bool {
+	backend, _, found := strings.Cut(strings.TrimSpace(target), ":")
+	if !found {
+		return false
+	}
+	_, known := cfg.Backends[backend]
+	return known
+}
+
+// defaultRoute 返回没有单个请求覆盖时的路由：客户端设置了默认模型时使用默认模型，否则按路由规则
+func defaultRoute(claudeReq *models.ClaudeRequest, client *config.Client, cfg *config.Config) converter.Route {
+	if client == nil || client.DefaultModel == "" {
+		return converter.RouteRequest(claudeReq, cfg)
+	}
+	route := converter.OverrideRoute(client.DefaultModel, "", cfg)
+	route.Rule = fmt.Sprintf("客户端 %s 的默认模型", client.Name)
+	route.Source = cfg.AuthKeysFile
+	if !client.Position.IsZero() {
+		route.Source = client.Position.String()
+	}
+	return route
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+func TestModelOverride(t *testing.T) {
+	tests := []struct {
+		name          string
+		model         string
+		header        string   // x-proxy-model 请求头
+		allowed       []string // MODEL_OVERRIDE_BACKENDS
+		wantStatus    int
+		wantBackend   string
+		wantModel     string
+		wantErrorType string
+	}{
+		{"后缀指定后端和模型", "claude-sonnet-4@local:qwen3-coder", "", nil, http.StatusOK, "local", "qwen3-coder", ""},
+		{"只写模型的后缀使用默认后端", "claude-sonnet-4@qwen3-coder", "", nil, http.StatusOK, "default", "qwen3-coder", ""},
+		{"只写模型的后缀使用路由规则选择的后端", "claude-opus-4@big-model", "", nil, http.StatusOK, "local", "big-model", ""},
+		{"冒号前不是已配置的后端时整个后缀作为模型名称", "claude-sonnet-4@qwen3:8b", "", nil, http.StatusOK, "default", "qwen3:8b", ""},
+		{"Vertex AI 的日期版本不是覆盖", "gpt-4o@20250514", "", nil, http.StatusOK, "default", "gpt-4o@20250514", ""},
+		{"空的后缀不是覆盖", "gpt-4o@", "", nil, http.StatusOK, "default", "gpt-4o@", ""},
+		{"请求头优先于后缀", "claude-sonnet-4@local:a", "default:b", nil, http.StatusOK, "default", "b", ""},
+		{"请求头只写模型", "claude-sonnet-4", "qwen3:8b", nil, http.StatusOK, "default", "qwen3:8b", ""},
+		{"请求头指定后端", "claude-sonnet-4", "local:qwen3-coder", nil, http.StatusOK, "local", "qwen3-coder", ""},
+		{"请求头中的模型名称为空", "claude-sonnet-4", "local:", nil, http.StatusBadRequest, "", "", "invalid_request_error"},
+		{"不允许的后端", "claude-sonnet-4@local:qwen3-coder", "", []string{"default"}, http.StatusForbidden, "", "", "permission_error"},
+		{"不允许的后端（只写模型的后缀）", "claude-opus-4@big-model", "", []string{"default"}, http.StatusForbidden, "", "", "permission_error"},
+		{"允许的后端", "claude-sonnet-4@local:qwen3-coder", "", []string{"local"}, http.StatusOK, "local", "qwen3-coder", ""},
+		{"禁用时忽略请求头且模型名称原样处理", "gpt-4o@local:x", "default:y", []string{"none"}, http.StatusOK, "default", "gpt-4o@local:x", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreams := map[string]*fakeUpstream{}
+			for _, name := range []string{"default", "local"} {
+				upstreams[name] = newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+					return jsonResponse(http.StatusOK, chatCompletionResponse)
+				})
+			}
+			cfg := newTestConfig(&config.Backend{BaseURL: upstreams["default"].URL})
+			cfg.Backends["local"] = &config.Backend{Name: "local", BaseURL: upstreams["local"].URL, Provider: config.ProviderUnknown}
+			cfg.Routes = []config.ModelRoute{{Match: config.RouteExact, Pattern: "claude-opus-4", Backend: "local", Model: "routed-model"}}
+			cfg.ModelOverrideBackends = tt.allowed
+
+			var header map[string]string
+			if tt.header != "" {
+				header = map[string]string{modelOverrideHeader: tt.header}
+			}
+			resp := postWithHeaders(t, newTestApp(cfg), "/v1/messages",
+				`{"model":"`+tt.model+`","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`, header)
+			defer func() { _ = resp.Body.Close() }()
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("状态码 = %d，应为 %d，响应: %s", resp.StatusCode, tt.wantStatus, data)
+			}
+			if tt.wantErrorType != "" {
+				if !strings.Contains(string(data), `"`+tt.wantErrorType+`"`) {
+					t.Errorf("响应 = %s，应为 %s", data, tt.wantErrorType)
+				}
+				for name, upstream := range upstreams {
+					if len(upstream.calls()) != 0 {
+						t.Errorf("被拒绝的请求不应发送到后端 %s", name)
+					}
+				}
+				return
+			}
+
+			for name, upstream := range upstreams {
+				calls := upstream.calls()
+				if name != tt.wantBackend {
+					if len(calls) != 0 {
+						t.Errorf("后端 %s 收到 %d 个请求，应发送到后端 %s", name, len(calls), tt.wantBackend)
+					}
+					continue
+				}
+				if len(calls) != 1 {
+					t.Fatalf("后端 %s 收到 %d 个请求，应为 1", name, len(calls))
+				}
+				if got := calls[0].Body["model"]; got != tt.wantModel {
+					t.Errorf("上游模型 = %v，应为 %s", got, tt.wantModel)
+				}
+			}
+		})
+	}
+}
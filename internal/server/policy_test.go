@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+func TestMaxTokensClampedWithoutPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		clamp bool
+		want  float64
+	}{
+		{"默认按已知模型限制", true, 16384},
+		{"CLAMP_MAX_TOKENS=false 不限制", false, 32000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+				return jsonResponse(http.StatusOK, chatCompletionResponse)
+			})
+			cfg := newTestConfig(&config.Backend{BaseURL: upstream.URL})
+			cfg.SonnetModel = "gpt-4o"
+			cfg.ClampMaxTokens = tt.clamp
+
+			status, resp := postJSON(t, newTestApp(cfg), "/v1/messages",
+				`{"model":"claude-sonnet-4-5","max_tokens":32000,"messages":[{"role":"user","content":"hi"}]}`)
+			if status != http.StatusOK {
+				t.Fatalf("状态码 = %d，响应: %s", status, resp)
+			}
+			calls := upstream.calls()
+			if len(calls) != 1 {
+				t.Fatalf("上游调用次数 = %d，应为 1", len(calls))
+			}
+			got, ok := calls[0].Body["max_completion_tokens"]
+			if !ok {
+				got = calls[0].Body["max_tokens"]
+			}
+			if got != tt.want {
+				t.Errorf("上游收到的输出令牌上限 = %v，应为 %v", got, tt.want)
+			}
+		})
+	}
+}
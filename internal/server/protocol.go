@@ -0,0 +1,130 @@
+// protocol.go 按后端的上游协议（聊天完成、Responses API、Gemini、Ollama、Bedrock）编码请求、解码响应和选择流解析方式。
+// 代理内部统一使用聊天完成格式，协议差异只在与上游交互的边界处理；Anthropic 原生协议不经过转换，由 anthropic.go 直接转发。
+
+package server
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// streamLineFunc 处理上游流中的一行数据，done 为 true 时结束读取
+type streamLineFunc func(processor *StreamProcessor, w *bufio.Writer, line string, cfg *config.Config) (done bool, streamErr *errors.ProxyError)
+
+// upstreamRequestBody 按后端协议序列化上游请求，并应用路由参数策略删除和添加的顶层字段
+func upstreamRequestBody(prov provider.Provider, req *models.OpenAIRequest) ([]byte, error) {
+	body, err := encodeUpstreamRequest(prov, req)
+	if err != nil {
+		return nil, err
+	}
+	return converter.MergeRequestBody(body, req)
+}
+
+// encodeUpstreamRequest 按后端协议序列化上游请求
+func encodeUpstreamRequest(prov provider.Provider, req *models.OpenAIRequest) ([]byte, error) {
+	switch prov.Backend().Protocol {
+	case config.ProtocolResponses:
+		return json.Marshal(converter.ConvertToResponsesRequest(req))
+	case config.ProtocolGemini:
+		geminiReq, err := converter.ConvertToGeminiRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(geminiReq)
+	case config.ProtocolOllama:
+		ollamaReq, err := converter.ConvertToOllamaRequest(req, prov.Backend().Ollama)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(ollamaReq)
+	case config.ProtocolBedrock:
+		bedrockReq, err := converter.ConvertToBedrockRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(bedrockReq)
+	default:
+		return json.Marshal(req)
+	}
+}
+
+// decodeUpstreamResponse 按后端协议解析非流式上游响应，统一为聊天完成格式。
+// model 为请求的上游模型，用于响应中不包含模型名称的协议。
+func decodeUpstreamResponse(prov provider.Provider, body []byte, model string) (*models.OpenAIResponse, error) {
+	switch prov.Backend().Protocol {
+	case config.ProtocolResponses:
+		var respResp models.ResponsesResponse
+		if err := json.Unmarshal(body, &respResp); err != nil {
+			return nil, err
+		}
+		if respResp.Status == "failed" && respResp.Error != nil {
+			return nil, streamChunkError(map[string]interface{}{"error": respResp.Error})
+		}
+		return converter.ConvertResponsesResponse(&respResp), nil
+
+	case config.ProtocolGemini:
+		var geminiResp models.GeminiResponse
+		if err := json.Unmarshal(body, &geminiResp); err != nil {
+			return nil, err
+		}
+		if geminiResp.Error != nil {
+			return nil, streamChunkError(map[string]interface{}{"error": geminiResp.Error})
+		}
+		return converter.ConvertGeminiResponse(&geminiResp)
+
+	case config.ProtocolOllama:
+		var ollamaResp models.OllamaChatResponse
+		if err := json.Unmarshal(body, &ollamaResp); err != nil {
+			return nil, err
+		}
+		if ollamaResp.Error != "" {
+			return nil, ollamaStreamError(ollamaResp.Error)
+		}
+		return converter.ConvertOllamaResponse(&ollamaResp), nil
+
+	case config.ProtocolBedrock:
+		var bedrockResp models.BedrockConverseResponse
+		if err := json.Unmarshal(body, &bedrockResp); err != nil {
+			return nil, err
+		}
+		return converter.ConvertBedrockResponse(&bedrockResp, model), nil
+
+	default:
+		var openaiResp models.OpenAIResponse
+		if err := json.Unmarshal(body, &openaiResp); err != nil {
+			return nil, err
+		}
+		return &openaiResp, nil
+	}
+}
+
+// streamLineHandler 按后端协议选择上游流的解析方式
+func streamLineHandler(prov provider.Provider) streamLineFunc {
+	switch prov.Backend().Protocol {
+	case config.ProtocolResponses:
+		return handleResponsesStreamLine
+	case config.ProtocolGemini:
+		return handleGeminiStreamLine
+	case config.ProtocolOllama:
+		return handleOllamaStreamLine
+	case config.ProtocolBedrock:
+		return handleBedrockStreamLine
+	default:
+		return handleOpenAIStreamLine
+	}
+}
+
+// upstreamStreamReader 按后端协议包装上游流：Bedrock 的二进制事件流解码为 JSON 行，其他协议原样返回
+func upstreamStreamReader(prov provider.Provider, body io.Reader) io.Reader {
+	if prov.Backend().Protocol == config.ProtocolBedrock {
+		return newEventStreamLineReader(body)
+	}
+	return body
+}
@@ -0,0 +1,156 @@
+// ratelimit.go 将上游（OpenAI、OpenRouter 等）的速率限制响应头转换为
+// Claude Code 读取的 anthropic-ratelimit-* 头，并按后端记录最近一次的值供状态端点查询。
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimitInfo 保存从上游响应头解析出的速率限制信息。
+// 数值字段为 -1 表示上游未提供；重置时间为零值表示未知。
+type RateLimitInfo struct {
+	RequestsLimit     int
+	RequestsRemaining int
+	RequestsReset     time.Time
+	TokensLimit       int
+	TokensRemaining   int
+	TokensReset       time.Time
+	RetryAfter        string
+	UpdatedAt         time.Time
+}
+
+// 后端名称 -> 最近一次看到的速率限制信息
+var (
+	lastRateLimits      = make(map[string]*RateLimitInfo)
+	lastRateLimitsMutex sync.RWMutex
+)
+
+// parseRateLimitHeaders 解析上游速率限制头。
+// 支持 OpenAI 风格（x-ratelimit-{limit,remaining,reset}-{requests,tokens}，重置值为时长如 "6m0s"）
+// OpenRouter 风格（X-RateLimit-{Limit,Remaining,Reset}，重置值为毫秒时间戳，按请求数计）
+// 和 Anthropic 风格（anthropic-ratelimit-{requests,tokens}-{limit,remaining,reset}，重置值为 RFC 3339 时间）。
+// 没有任何速率限制头时返回 nil。
+func parseRateLimitHeaders(h http.Header, now time.Time) *RateLimitInfo {
+	info := &RateLimitInfo{
+		RequestsLimit:     headerInt(h, "x-ratelimit-limit-requests", "x-ratelimit-limit", "anthropic-ratelimit-requests-limit"),
+		RequestsRemaining: headerInt(h, "x-ratelimit-remaining-requests", "x-ratelimit-remaining", "anthropic-ratelimit-requests-remaining"),
+		RequestsReset:     headerReset(h, now, "x-ratelimit-reset-requests", "x-ratelimit-reset", "anthropic-ratelimit-requests-reset"),
+		TokensLimit:       headerInt(h, "x-ratelimit-limit-tokens", "anthropic-ratelimit-tokens-limit"),
+		TokensRemaining:   headerInt(h, "x-ratelimit-remaining-tokens", "anthropic-ratelimit-tokens-remaining"),
+		TokensReset:       headerReset(h, now, "x-ratelimit-reset-tokens", "anthropic-ratelimit-tokens-reset"),
+		RetryAfter:        h.Get("retry-after"),
+		UpdatedAt:         now,
+	}
+
+	if info.RequestsLimit < 0 && info.RequestsRemaining < 0 && info.RequestsReset.IsZero() &&
+		info.TokensLimit < 0 && info.TokensRemaining < 0 && info.TokensReset.IsZero() &&
+		info.RetryAfter == "" {
+		return nil
+	}
+	return info
+}
+
+// anthropicHeaders 返回对应的 Anthropic 速率限制响应头
+func (r *RateLimitInfo) anthropicHeaders() map[string]string {
+	headers := make(map[string]string)
+	setInt := func(name string, v int) {
+		if v >= 0 {
+			headers[name] = strconv.Itoa(v)
+		}
+	}
+	setTime := func(name string, t time.Time) {
+		if !t.IsZero() {
+			headers[name] = t.UTC().Format(time.RFC3339)
+		}
+	}
+
+	setInt("anthropic-ratelimit-requests-limit", r.RequestsLimit)
+	setInt("anthropic-ratelimit-requests-remaining", r.RequestsRemaining)
+	setTime("anthropic-ratelimit-requests-reset", r.RequestsReset)
+	setInt("anthropic-ratelimit-tokens-limit", r.TokensLimit)
+	setInt("anthropic-ratelimit-tokens-remaining", r.TokensRemaining)
+	setTime("anthropic-ratelimit-tokens-reset", r.TokensReset)
+	if r.RetryAfter != "" {
+		headers["retry-after"] = r.RetryAfter
+	}
+	return headers
+}
+
+// applyRateLimitHeaders 解析上游响应的速率限制头，记录到所属后端，
+// 并在客户端响应上设置对应的 Anthropic 头。必须在响应体开始写入之前调用。
+func applyRateLimitHeaders(c *fiber.Ctx, prov provider.Provider, upstream http.Header) {
+	info := recordRateLimits(prov, upstream)
+	if info == nil {
+		return
+	}
+	for name, value := range info.anthropicHeaders() {
+		c.Set(name, value)
+	}
+}
+
+// recordRateLimits 解析并记录上游速率限制头，返回解析结果（可能为 nil）
+func recordRateLimits(prov provider.Provider, upstream http.Header) *RateLimitInfo {
+	info := parseRateLimitHeaders(upstream, time.Now())
+	if info == nil {
+		return nil
+	}
+	lastRateLimitsMutex.Lock()
+	lastRateLimits[prov.Backend().Name] = info
+	lastRateLimitsMutex.Unlock()
+	return info
+}
+
+// getLastRateLimits 返回后端最近一次看到的速率限制信息
+func getLastRateLimits(backendName string) *RateLimitInfo {
+	lastRateLimitsMutex.RLock()
+	defer lastRateLimitsMutex.RUnlock()
+	return lastRateLimits[backendName]
+}
+
+// headerInt 读取第一个存在的整数头，均不存在时返回 -1
+func headerInt(h http.Header, names ...string) int {
+	for _, name := range names {
+		if value := h.Get(name); value != "" {
+			if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// headerReset 读取第一个存在的重置时间头并转换为绝对时间。
+// 接受时长（"1s"、"6m0s"、"20ms"）、秒数、Unix 时间戳（秒或毫秒）和 RFC3339 时间。
+func headerReset(h http.Header, now time.Time, names ...string) time.Time {
+	for _, name := range names {
+		value := strings.TrimSpace(h.Get(name))
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err == nil {
+			return now.Add(d)
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			switch {
+			case n > 1e12: // 毫秒时间戳（OpenRouter）
+				return time.UnixMilli(n)
+			case n > 1e9: // 秒时间戳
+				return time.Unix(n, 0)
+			default: // 距现在的秒数
+				return now.Add(time.Duration(n) * time.Second)
+			}
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,152 @@
+// reload.go 实现配置的热重新加载：收到 SIGHUP 或检测到配置文件变化时重新加载并验证配置，
+// 验证通过后原子替换当前配置。每个请求在开始时取一次配置快照，进行中的请求（包括流式响应）继续使用旧配置。
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/daemon"
+)
+
+// watchDisabledRecheck 禁用文件监视时重新检查监视间隔设置的间隔（SIGHUP 重新加载后可能重新启用）
+const watchDisabledRecheck = 5 * time.Second
+
+// configStore 保存当前生效的配置
+type configStore struct {
+	current  atomic.Pointer[config.Config]
+	reloadMu sync.Mutex // 串行化重新加载
+}
+
+// newConfigStore 创建保存初始配置的存储
+func newConfigStore(cfg *config.Config) *configStore {
+	store := &configStore{}
+	store.current.Store(cfg)
+	return store
+}
+
+// Load 返回当前配置
+func (s *configStore) Load() *config.Config {
+	return s.current.Load()
+}
+
+// reload 重新加载配置，结果写入临时目录供 reload 命令读取
+func (s *configStore) reload(trigger string) {
+	result := s.reloadConfig(trigger)
+	if err := daemon.WriteReloadResult(result); err != nil {
+		fmt.Printf("⚠️  记录重新加载结果失败: %v\n", err)
+	}
+}
+
+// reloadConfig 重新加载并验证配置，成功时替换当前配置；加载或验证失败时保留当前配置
+func (s *configStore) reloadConfig(trigger string) *daemon.ReloadResult {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	oldCfg := s.Load()
+	result := &daemon.ReloadResult{Time: time.Now(), Trigger: trigger}
+
+	newCfg, err := config.Load()
+	if err == nil {
+		// 命令行标志不来自配置文件，沿用启动时的设置
+		newCfg.Debug = oldCfg.Debug
+		newCfg.SimpleLog = oldCfg.SimpleLog
+		err = newCfg.Validate()
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		fmt.Printf("[%s] ❌ 配置重新加载失败（%s），继续使用当前配置:\n%v\n",
+			time.Now().Format("15:04:05"), trigger, err)
+	} else {
+		result.Changes = config.Diff(oldCfg, newCfg)
+		// 监听地址在启动后无法更改
+		if newCfg.Host != oldCfg.Host || newCfg.Port != oldCfg.Port {
+			for i, change := range result.Changes {
+				if strings.HasPrefix(change, "Host:") || strings.HasPrefix(change, "Port:") {
+					result.Changes[i] = change + "（需要重启才能生效）"
+				}
+			}
+			newCfg.Host, newCfg.Port = oldCfg.Host, oldCfg.Port
+		}
+		s.current.Store(newCfg)
+
+		fmt.Printf("[%s] 🔄 配置已重新加载（%s），%d 项变化\n",
+			time.Now().Format("15:04:05"), trigger, len(result.Changes))
+		for _, change := range result.Changes {
+			fmt.Printf("   %s\n", change)
+		}
+	}
+	return result
+}
+
+// watchSignals 收到 SIGHUP 时重新加载配置
+func (s *configStore) watchSignals() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	for range sigChan {
+		s.reload("SIGHUP")
+	}
+}
+
+// watchFiles 定期检查配置文件（包括尚不存在的位置）的修改时间和大小，变化时重新加载配置
+func (s *configStore) watchFiles() {
+	snapshot := fileSnapshot(s.Load().WatchedFiles)
+	for {
+		interval := s.Load().WatchInterval
+		if interval <= 0 {
+			time.Sleep(watchDisabledRecheck)
+			snapshot = fileSnapshot(s.Load().WatchedFiles)
+			continue
+		}
+		time.Sleep(interval)
+
+		// 重新加载后监视列表可能变化，新出现的文件从下一轮开始比较
+		current := fileSnapshot(s.Load().WatchedFiles)
+		changed := changedFiles(snapshot, current)
+		snapshot = current
+		if len(changed) > 0 {
+			s.reload("文件变化: " + strings.Join(changed, ", "))
+		}
+	}
+}
+
+// fileState 是文件的修改时间（纳秒）和大小（文件不存在时为零值）
+type fileState struct {
+	modTime int64
+	size    int64
+}
+
+// fileSnapshot 记录文件的当前状态
+func fileSnapshot(files []string) map[string]fileState {
+	snapshot := make(map[string]fileState, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			snapshot[file] = fileState{modTime: info.ModTime().UnixNano(), size: info.Size()}
+		} else {
+			snapshot[file] = fileState{}
+		}
+	}
+	return snapshot
+}
+
+// changedFiles 返回状态发生变化（修改、创建或删除）的文件
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+	for file, state := range after {
+		if previous, ok := before[file]; ok && previous != state {
+			changed = append(changed, file)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
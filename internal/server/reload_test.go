@@ -0,0 +1,147 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+// setupConfigFile 隔离配置相关的环境变量和文件位置，返回 CLAUDE_PROXY_CONFIG 指向的配置文件路径
+func setupConfigFile(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	for _, name := range []string{
+		"OPENAI_BASE_URL", "OPENAI_API_KEY", "OPENAI_PROVIDER", "OPENAI_PROTOCOL",
+		"ANTHROPIC_API_KEY", "AUTH_KEYS_FILE", "HOST", "PORT",
+		"ANTHROPIC_DEFAULT_OPUS_MODEL", "ANTHROPIC_DEFAULT_SONNET_MODEL", "ANTHROPIC_DEFAULT_HAIKU_MODEL",
+	} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(dir, "proxy.yaml")
+	t.Setenv(config.ConfigFileEnv, path)
+	return path
+}
+
+// writeFile 覆盖写入文件
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("写入 %s 失败: %v", path, err)
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	path := setupConfigFile(t)
+	writeFile(t, path, `
+port: 8082
+models:
+  sonnet: model-a
+backends:
+  default:
+    base_url: http://localhost:8000/v1
+    api_key: sk-old-secret
+`)
+	initial, err := config.Load()
+	if err != nil {
+		t.Fatalf("加载初始配置失败: %v", err)
+	}
+	initial.Debug = true
+	store := newConfigStore(initial)
+
+	// 有效的修改：替换配置并列出变化
+	writeFile(t, path, `
+port: 9090
+models:
+  sonnet: model-b
+backends:
+  default:
+    base_url: http://localhost:8000/v1
+    api_key: sk-new-secret
+  local:
+    base_url: http://localhost:11434
+`)
+	result := store.reloadConfig("测试")
+	if result.Error != "" {
+		t.Fatalf("重新加载失败: %s", result.Error)
+	}
+	reloaded := store.Load()
+	if reloaded == initial || reloaded.SonnetModel != "model-b" {
+		t.Fatalf("重新加载后 SonnetModel = %q，应替换为 model-b", reloaded.SonnetModel)
+	}
+	if !reloaded.Debug {
+		t.Errorf("命令行标志（Debug）应沿用启动时的设置")
+	}
+	// 监听地址在启动后无法更改
+	if reloaded.Port != "8082" {
+		t.Errorf("Port = %q，应保持启动时的 8082", reloaded.Port)
+	}
+
+	changes := strings.Join(result.Changes, "\n")
+	for _, want := range []string{
+		"SonnetModel: model-a → model-b",
+		"Backends.default.APIKey: 已更改",
+		"OpenAIAPIKey: 已更改",
+		"Backends.local: 已添加",
+		"Port: 8082 → 9090（需要重启才能生效）",
+	} {
+		if !strings.Contains(changes, want) {
+			t.Errorf("变化列表缺少 %q:\n%s", want, changes)
+		}
+	}
+	if strings.Contains(changes, "secret") {
+		t.Errorf("变化列表不应包含密钥:\n%s", changes)
+	}
+
+	// 无效的文件：保留当前配置，错误指出出错的位置或设置
+	invalid := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"语法错误", "models:\n\tsonnet: model-c\n", "proxy.yaml:2"},
+		{"类型错误", "port: abc\n", "proxy.yaml:1:7"},
+		{"验证失败", "backends:\n  default:\n    base_url: ftp://localhost/v1\n", "OPENAI_BASE_URL"},
+	}
+	for _, tt := range invalid {
+		writeFile(t, path, tt.data)
+		result := store.reloadConfig("测试")
+		if !strings.Contains(result.Error, tt.wantErr) || len(result.Changes) != 0 {
+			t.Errorf("%s：结果 = %+v，应报告包含 %q 的错误且没有变化", tt.name, result, tt.wantErr)
+		}
+		if store.Load() != reloaded {
+			t.Errorf("%s：无效的配置不应替换当前配置", tt.name)
+		}
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "a.yaml")
+	missing := filepath.Join(dir, "b.yaml")
+	writeFile(t, existing, "port: 1\n")
+
+	before := fileSnapshot([]string{existing, missing})
+	if got := changedFiles(before, fileSnapshot([]string{existing, missing})); len(got) != 0 {
+		t.Errorf("未修改时变化的文件 = %v", got)
+	}
+
+	// 修改已有文件、创建原来不存在的文件
+	writeFile(t, existing, "port: 12\n")
+	writeFile(t, missing, "port: 2\n")
+	got := changedFiles(before, fileSnapshot([]string{existing, missing}))
+	if strings.Join(got, ",") != existing+","+missing {
+		t.Errorf("变化的文件 = %v", got)
+	}
+
+	// 删除文件
+	before = fileSnapshot([]string{missing})
+	_ = os.Remove(missing)
+	if got := changedFiles(before, fileSnapshot([]string{missing})); len(got) != 1 {
+		t.Errorf("删除后变化的文件 = %v", got)
+	}
+}
@@ -0,0 +1,125 @@
+// responses.go 实现 OpenAI Responses API 上游流的解析：
+// 将 Responses API 的类型化 SSE 事件交给 StreamProcessor 转换为 Claude SSE。
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+)
+
+// handleResponsesStreamLine 处理上游 Responses API 流中的一行数据。
+// 事件类型包含在 data 的 type 字段中，event: 行被忽略。
+// 收到 response.completed 或 response.incomplete 时 done 为 true；
+// 收到 response.failed 或 error 事件时返回该错误。
+func handleResponsesStreamLine(processor *StreamProcessor, w *bufio.Writer, line string, cfg *config.Config) (done bool, streamErr *errors.ProxyError) {
+	if !strings.HasPrefix(line, "data: ") {
+		return false, nil
+	}
+
+	dataJSON := strings.TrimPrefix(line, "data: ")
+
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(dataJSON), &event); err != nil {
+		return false, nil
+	}
+
+	if cfg.Debug {
+		fmt.Printf("[调试] 来自提供商的 Responses 事件: %s\n", dataJSON)
+	}
+
+	switch event["type"] {
+	case "response.output_text.delta", "response.refusal.delta":
+		delta, _ := event["delta"].(string)
+		processor.HandleTextDelta(delta)
+
+	case "response.reasoning_summary_text.delta":
+		processor.HandleThinkingDelta(map[string]interface{}{"reasoning_content": event["delta"]})
+
+	case "response.reasoning_summary_part.added":
+		// 多段推理摘要之间用空行分隔
+		if intField(event, "summary_index") > 0 {
+			processor.HandleThinkingDelta(map[string]interface{}{"reasoning_content": "\n\n"})
+		}
+
+	case "response.output_item.added":
+		// 函数调用项开始：以 output_index 作为工具调用索引
+		item, _ := event["item"].(map[string]interface{})
+		if item["type"] == "function_call" {
+			processor.HandleToolCallsDelta([]interface{}{map[string]interface{}{
+				"index":    event["output_index"],
+				"id":       item["call_id"],
+				"function": map[string]interface{}{"name": item["name"]},
+			}})
+		}
+
+	case "response.function_call_arguments.delta":
+		processor.HandleToolCallsDelta([]interface{}{map[string]interface{}{
+			"index":    event["output_index"],
+			"function": map[string]interface{}{"arguments": event["delta"]},
+		}})
+
+	case "response.output_item.done":
+		// 推理项结束：加密内容作为思考块签名发送，供下一轮还原推理项
+		item, _ := event["item"].(map[string]interface{})
+		if item["type"] == "reasoning" {
+			id, _ := item["id"].(string)
+			if encryptedContent, _ := item["encrypted_content"].(string); encryptedContent != "" {
+				processor.HandleThinkingSignature(converter.EncodeReasoningSignature(id, encryptedContent))
+			}
+		}
+
+	case "response.completed", "response.incomplete":
+		response, _ := event["response"].(map[string]interface{})
+		if usage, ok := response["usage"].(map[string]interface{}); ok {
+			processor.HandleUsageData(responsesUsageToOpenAI(usage))
+		}
+		status, _ := response["status"].(string)
+		var reason string
+		if details, ok := response["incomplete_details"].(map[string]interface{}); ok {
+			reason, _ = details["reason"].(string)
+		}
+		processor.HandleFinishReason(converter.ResponsesFinishReason(status, reason, len(processor.state.CurrentToolCalls) > 0))
+		return true, nil
+
+	case "response.failed":
+		response, _ := event["response"].(map[string]interface{})
+		errObj, _ := response["error"].(map[string]interface{})
+		if errObj == nil {
+			errObj = map[string]interface{}{"message": "上游响应失败"}
+		}
+		return false, streamChunkError(map[string]interface{}{"error": errObj})
+
+	case "error":
+		return false, errors.FromOpenAIError(http.StatusInternalServerError, map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    event["code"],
+				"message": event["message"],
+				"param":   event["param"],
+			},
+		})
+	}
+
+	return false, nil
+}
+
+// responsesUsageToOpenAI 将 Responses API 的使用量转换为 HandleUsageData 接受的聊天完成格式
+func responsesUsageToOpenAI(usage map[string]interface{}) map[string]interface{} {
+	openaiUsage := map[string]interface{}{
+		"prompt_tokens":     usage["input_tokens"],
+		"completion_tokens": usage["output_tokens"],
+	}
+	if details, ok := usage["input_tokens_details"].(map[string]interface{}); ok {
+		openaiUsage["prompt_tokens_details"] = map[string]interface{}{
+			"cached_tokens": details["cached_tokens"],
+		}
+	}
+	return openaiUsage
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+// responsesSSE 将 Responses API 事件编码为 SSE（带 event: 行，与 OpenAI 一致）
+func responsesSSE(events ...string) []string {
+	chunks := make([]string, 0, len(events))
+	for _, data := range events {
+		typ := data[strings.Index(data, `"type":"`)+len(`"type":"`):]
+		typ = typ[:strings.Index(typ, `"`)]
+		chunks = append(chunks, "event: "+typ+"\ndata: "+data+"\n\n")
+	}
+	return chunks
+}
+
+const streamRequest = `{"model":"claude-sonnet-4-5","max_tokens":1000,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+
+func TestResponsesStream(t *testing.T) {
+	upstream := streamingUpstream(t, "text/event-stream", responsesSSE(
+		`{"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}`,
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"reasoning","id":"rs_1"}}`,
+		`{"type":"response.reasoning_summary_part.added","output_index":0,"summary_index":0}`,
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"delta":"先想"}`,
+		`{"type":"response.reasoning_summary_part.added","output_index":0,"summary_index":1}`,
+		`{"type":"response.reasoning_summary_text.delta","output_index":0,"delta":"再想"}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1","encrypted_content":"ZW5j"}}`,
+		`{"type":"response.output_item.added","output_index":1,"item":{"type":"message","role":"assistant"}}`,
+		`{"type":"response.output_text.delta","output_index":1,"delta":"读取"}`,
+		`{"type":"response.output_text.delta","output_index":1,"delta":"文件"}`,
+		`{"type":"response.output_item.added","output_index":2,"item":{"type":"function_call","call_id":"call_1","name":"read"}}`,
+		`{"type":"response.function_call_arguments.delta","output_index":2,"delta":"{\"path\":"}`,
+		`{"type":"response.function_call_arguments.delta","output_index":2,"delta":"\"a.txt\"}"}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed","usage":{"input_tokens":12,"output_tokens":7,"input_tokens_details":{"cached_tokens":4}}}}`,
+	)...)
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL, Protocol: config.ProtocolResponses}))
+
+	status, body := postJSON(t, app, "/v1/messages", streamRequest)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, body)
+	}
+	events := parseSSE(t, body)
+
+	messageDelta := checkClaudeStream(t, events, "thinking", "text", "tool_use")
+	if got := deltaText(events, "thinking"); got != "先想\n\n再想" {
+		t.Errorf("思考内容 = %q", got)
+	}
+	if got := deltaText(events, "signature"); got != "rs_1:ZW5j" {
+		t.Errorf("思考签名 = %q，应为推理项 ID 和加密内容", got)
+	}
+	if got := deltaText(events, "text"); got != "读取文件" {
+		t.Errorf("文本 = %q", got)
+	}
+	if got := deltaText(events, "partial_json"); got != `{"path":"a.txt"}` {
+		t.Errorf("工具参数 = %q", got)
+	}
+
+	if stop := messageDelta["delta"].(map[string]interface{})["stop_reason"]; stop != "tool_use" {
+		t.Errorf("stop_reason = %v，应为 tool_use", stop)
+	}
+	usage := messageDelta["usage"].(map[string]interface{})
+	if usage["output_tokens"] != float64(7) {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+func TestResponsesStreamFailed(t *testing.T) {
+	upstream := streamingUpstream(t, "text/event-stream", responsesSSE(
+		`{"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}`,
+		`{"type":"response.output_text.delta","output_index":0,"delta":"部分"}`,
+		`{"type":"response.failed","response":{"id":"resp_1","status":"failed","error":{"code":"server_error","message":"模型崩溃"}}}`,
+	)...)
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL, Protocol: config.ProtocolResponses}))
+
+	_, body := postJSON(t, app, "/v1/messages", streamRequest)
+	events := parseSSE(t, body)
+	last := events[len(events)-1]
+	if last.Event != "error" {
+		t.Fatalf("最后一个事件 = %s，应为 error；事件: %v", last.Event, eventTypes(events))
+	}
+	errObj := last.Data["error"].(map[string]interface{})
+	if errObj["type"] != "api_error" || errObj["message"] != "模型崩溃" {
+		t.Errorf("错误 = %v", errObj)
+	}
+}
+
+func TestResponsesFailedStatus(t *testing.T) {
+	upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+		return jsonResponse(http.StatusOK, `{"id":"resp_1","status":"failed","output":[],"error":{"code":"rate_limit_exceeded","message":"超出配额"}}`)
+	})
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL, Protocol: config.ProtocolResponses}))
+
+	status, body := postJSON(t, app, "/v1/messages",
+		`{"model":"claude-sonnet-4-5","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	if status != http.StatusTooManyRequests || !strings.Contains(body, `"rate_limit_error"`) || !strings.Contains(body, "超出配额") {
+		t.Errorf("状态码 = %d，响应: %s", status, body)
+	}
+}
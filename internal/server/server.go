@@ -26,8 +26,25 @@ const (
 	ProxyVersion = "1.0.0"
 )
 
-// Start 初始化并启动 HTTP 服务器
+// Start 初始化并启动 HTTP 服务器。
+// 配置可以在运行时重新加载（SIGHUP 或配置文件变化），处理器在每个请求开始时读取当前配置。
 func Start(cfg *config.Config) error {
+	store := newConfigStore(cfg)
+
+	// 加载之前学习到的模型能力（是否支持 max_completion_tokens 等），避免重启后重复失败的请求
+	if n, err := config.LoadCapabilityCache(daemon.CapabilityCacheFile()); err != nil {
+		fmt.Printf("⚠️  加载能力缓存失败，将重新检测: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("📁 已从 %s 加载 %d 个模型的能力缓存\n", daemon.CapabilityCacheFile(), n)
+	}
+
+	// 加载客户端的每日和每月用量，重启后预算继续累计
+	if n, err := config.LoadUsageStore(cfg.UsageStoreFile); err != nil {
+		fmt.Printf("⚠️  加载用量文件失败，用量将从零开始: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("📁 已从 %s 加载 %d 个客户端的用量\n", cfg.UsageStoreFile, n)
+	}
+
 	app := fiber.New(fiber.Config{
 		DisableStartupMessage: true,
 		ServerHeader:          "Claude-Code-Proxy",
@@ -57,8 +74,14 @@ func Start(cfg *config.Config) error {
 		})
 	})
 
+	// 状态端点 - 各后端的最近速率限制信息（包含后端地址和客户端用量，与 /v1 端点一样验证入站密钥）
+	app.Get("/status", authMiddleware(store), func(c *fiber.Ctx) error {
+		return handleStatus(c, store.Load())
+	})
+
 	// 根端点 - 代理信息
 	app.Get("/", func(c *fiber.Ctx) error {
+		cfg := store.Load()
 		return c.JSON(fiber.Map{
 			"message": "Claude Code Proxy",
 			"version": ProxyVersion,
@@ -72,14 +95,21 @@ func Start(cfg *config.Config) error {
 			},
 			"endpoints": fiber.Map{
 				"health":       "/health",
+				"status":       "/status",
 				"messages":     "/v1/messages",
 				"count_tokens": "/v1/messages/count_tokens",
+				"models":       "/v1/models",
+				"chat":         "/v1/chat/completions",
 			},
 		})
 	})
 
 	// Claude API 端点
-	setupClaudeEndpoints(app, cfg)
+	setupClaudeEndpoints(app, store)
+
+	// 配置热重新加载
+	go store.watchSignals()
+	go store.watchFiles()
 
 	// 优雅关闭
 	go func() {
@@ -87,6 +117,9 @@ func Start(cfg *config.Config) error {
 		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 		<-sigChan
 		fmt.Println("\n🛑 正在关闭...")
+		if err := config.FlushUsageStore(); err != nil {
+			fmt.Printf("⚠️  写入用量文件失败: %v\n", err)
+		}
 		daemon.Cleanup()
 		_ = app.Shutdown()
 	}()
@@ -114,16 +147,26 @@ func Start(cfg *config.Config) error {
 				fmt.Printf("     - Haiku  → %s\n", cfg.HaikuModel)
 			}
 		}
+		if len(cfg.Routes) > 0 {
+			fmt.Printf("   路由规则:\n")
+			for i, route := range cfg.Routes {
+				fmt.Printf("     %d. %s\n", i+1, route)
+			}
+		}
 	}
 
 	return app.Listen(addr)
 }
 
 func getRoutingMode(cfg *config.Config) string {
+	mode := "基于模式"
 	if cfg.OpusModel != "" || cfg.SonnetModel != "" || cfg.HaikuModel != "" {
-		return "自定义（环境变量覆盖）"
+		mode = "自定义（环境变量覆盖）"
 	}
-	return "基于模式"
+	if len(cfg.Routes) > 0 {
+		return fmt.Sprintf("%d 条路由规则，其他模型%s", len(cfg.Routes), mode)
+	}
+	return mode
 }
 
 func getOpusModel(cfg *config.Config) string {
@@ -147,14 +190,28 @@ func getHaikuModel(cfg *config.Config) string {
 	return converter.DefaultHaikuModel + "（基于模式）"
 }
 
-func setupClaudeEndpoints(app *fiber.App, cfg *config.Config) {
+func setupClaudeEndpoints(app *fiber.App, store *configStore) {
+	// 入站认证 - 在解析请求体之前验证 API 密钥
+	app.Use("/v1", authMiddleware(store))
+
 	// 消息端点 - 主 Claude API
 	app.Post("/v1/messages", func(c *fiber.Ctx) error {
-		return handleMessages(c, cfg)
+		return handleMessages(c, store.Load())
 	})
 
 	// 令牌计数端点
-	app.Post("/v1/messages/count_tokens", func(c *fiber.Ctx) error {
-		return handleCountTokens(c, cfg)
+	app.Post("/v1/messages/count_tokens", handleCountTokens)
+
+	// OpenAI 兼容端点 - 与 /v1/messages 共用路由和上游处理
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		return handleChatCompletions(c, store.Load())
+	})
+
+	// 模型列表端点
+	app.Get("/v1/models", func(c *fiber.Ctx) error {
+		return handleListModels(c, store.Load())
+	})
+	app.Get("/v1/models/:id", func(c *fiber.Ctx) error {
+		return handleGetModel(c, store.Load())
 	})
 }
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/gofiber/fiber/v2"
+)
+
+// testModel 是测试配置中所有 Claude 模型路由到的后端模型
+const testModel = "test-model"
+
+// newTestConfig 返回以指定后端作为默认后端的配置，所有 Claude 模型都路由到 testModel
+func newTestConfig(backend *config.Backend) *config.Config {
+	backend.Name = config.DefaultBackendName
+	if backend.Provider == "" {
+		backend.Provider = config.ProviderUnknown
+	}
+	return &config.Config{
+		OpenAIBaseURL: backend.BaseURL,
+		OpenAIAPIKey:  backend.APIKey,
+		OpusModel:     testModel,
+		SonnetModel:   testModel,
+		HaikuModel:    testModel,
+		Backends:      map[string]*config.Backend{config.DefaultBackendName: backend},
+	}
+}
+
+// newTestApp 返回只注册 Claude API 端点的应用
+func newTestApp(cfg *config.Config) *fiber.App {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	setupClaudeEndpoints(app, newConfigStore(cfg))
+	return app
+}
+
+// post 向应用发送 JSON 请求，调用方负责关闭响应体
+func post(t *testing.T, app *fiber.App, path, body string) *http.Response {
+	t.Helper()
+	return postWithHeaders(t, app, path, body, nil)
+}
+
+// postWithHeaders 向应用发送带有额外请求头的 JSON 请求，调用方负责关闭响应体
+func postWithHeaders(t *testing.T, app *fiber.App, path, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("请求 %s 失败: %v", path, err)
+	}
+	return resp
+}
+
+// postJSON 向应用发送 JSON 请求，返回状态码和响应体
+func postJSON(t *testing.T, app *fiber.App, path, body string) (int, string) {
+	t.Helper()
+	resp := post(t, app, path, body)
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("读取响应失败: %v", err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// sseEvent 是客户端收到的一个 Claude SSE 事件
+type sseEvent struct {
+	Event string
+	Data  map[string]interface{}
+}
+
+// parseSSE 解析 SSE 响应体中的事件（不包括 ping）
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var event string
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && event != "ping":
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+				t.Fatalf("解析 SSE 数据失败: %v: %s", err, line)
+			}
+			events = append(events, sseEvent{Event: event, Data: data})
+		}
+	}
+	return events
+}
+
+// eventTypes 返回事件类型序列，content_block_start 带有内容块类型（如 content_block_start:text）
+func eventTypes(events []sseEvent) []string {
+	types := make([]string, 0, len(events))
+	for _, e := range events {
+		name := e.Event
+		if block, ok := e.Data["content_block"].(map[string]interface{}); ok {
+			name += ":" + block["type"].(string)
+		}
+		types = append(types, name)
+	}
+	return types
+}
+
+// checkClaudeStream 检查 Claude SSE 事件流的结构：以 message_start 开始、以 message_delta 和 message_stop 结束，
+// 内容块按 wantBlocks 的类型顺序开始，每个内容块恰好结束一次。返回 message_delta 事件的数据。
+func checkClaudeStream(t *testing.T, events []sseEvent, wantBlocks ...string) map[string]interface{} {
+	t.Helper()
+	types := eventTypes(events)
+	n := len(events)
+	if n < 3 || types[0] != "message_start" || types[n-2] != "message_delta" || types[n-1] != "message_stop" {
+		t.Fatalf("事件序列 = %v", types)
+	}
+
+	var blocks []string
+	stopped := map[float64]int{}
+	for _, e := range events {
+		switch e.Event {
+		case "content_block_start":
+			blocks = append(blocks, e.Data["content_block"].(map[string]interface{})["type"].(string))
+		case "content_block_stop":
+			stopped[e.Data["index"].(float64)]++
+		}
+	}
+	if !reflect.DeepEqual(blocks, wantBlocks) {
+		t.Errorf("内容块 = %v，应为 %v（事件序列 %v）", blocks, wantBlocks, types)
+	}
+	for i := range blocks {
+		if stopped[float64(i)] != 1 {
+			t.Errorf("内容块 %d 结束了 %d 次，应为 1 次（事件序列 %v）", i, stopped[float64(i)], types)
+		}
+	}
+	return events[n-2].Data
+}
+
+// deltaText 拼接所有 content_block_delta 事件中指定字段（text、thinking、partial_json、signature）的内容
+func deltaText(events []sseEvent, field string) string {
+	var sb strings.Builder
+	for _, e := range events {
+		if delta, ok := e.Data["delta"].(map[string]interface{}); ok && e.Event == "content_block_delta" {
+			if s, ok := delta[field].(string); ok {
+				sb.WriteString(s)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// upstreamRequest 是假上游收到的一个请求
+type upstreamRequest struct {
+	Path   string // 包括查询字符串
+	Header http.Header
+	Body   map[string]interface{} // JSON 请求体（无法解析时为 nil）
+}
+
+// upstreamResponse 是假上游返回的响应：Chunks 依次写出，每块之后刷新（用于流式响应）
+type upstreamResponse struct {
+	Status      int // 零值为 200
+	ContentType string
+	Header      map[string]string
+	Chunks      []string
+	Stall       time.Duration // 写出 Chunks 后保持连接不发送数据的时长（客户端断开时提前结束）
+}
+
+// jsonResponse 返回 JSON 响应
+func jsonResponse(status int, body string) upstreamResponse {
+	return upstreamResponse{Status: status, ContentType: "application/json", Chunks: []string{body}}
+}
+
+// streamResponse 返回按块写出的流式响应
+func streamResponse(contentType string, chunks ...string) upstreamResponse {
+	return upstreamResponse{ContentType: contentType, Chunks: chunks}
+}
+
+// fakeUpstream 是记录请求并按 respond 返回响应的假上游，测试结束时自动关闭
+type fakeUpstream struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []upstreamRequest
+}
+
+// newFakeUpstream 启动假上游
+func newFakeUpstream(t *testing.T, respond func(req upstreamRequest) upstreamResponse) *fakeUpstream {
+	t.Helper()
+	u := &fakeUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req := upstreamRequest{Path: r.URL.RequestURI(), Header: r.Header.Clone()}
+		_ = json.Unmarshal(data, &req.Body)
+		u.mu.Lock()
+		u.requests = append(u.requests, req)
+		u.mu.Unlock()
+
+		resp := respond(req)
+		for k, v := range resp.Header {
+			w.Header().Set(k, v)
+		}
+		if resp.ContentType != "" {
+			w.Header().Set("Content-Type", resp.ContentType)
+		}
+		if resp.Status == 0 {
+			resp.Status = http.StatusOK
+		}
+		w.WriteHeader(resp.Status)
+		for _, chunk := range resp.Chunks {
+			_, _ = w.Write([]byte(chunk))
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		if resp.Stall > 0 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(resp.Stall):
+			}
+		}
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// streamingUpstream 返回对每个请求都按顺序写出 chunks 的假上游
+func streamingUpstream(t *testing.T, contentType string, chunks ...string) *fakeUpstream {
+	t.Helper()
+	return newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+		return streamResponse(contentType, chunks...)
+	})
+}
+
+// calls 返回记录的上游请求并清空记录
+func (u *fakeUpstream) calls() []upstreamRequest {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	requests := u.requests
+	u.requests = nil
+	return requests
+}
+
+// last 返回最近一个上游请求
+func (u *fakeUpstream) last(t *testing.T) upstreamRequest {
+	t.Helper()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.requests) == 0 {
+		t.Fatalf("上游没有收到请求")
+	}
+	return u.requests[len(u.requests)-1]
+}
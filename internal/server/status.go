@@ -0,0 +1,59 @@
+// status.go 实现 /status 端点，按后端报告连接信息和最近一次看到的上游速率限制，
+// 并列出模型路由规则、已加载的配置文件、学习到的模型能力和客户端用量。
+// 启用入站认证时需要 API 密钥；密钥文件中的客户端只能看到自己的用量。
+
+package server
+
+import (
+	"sort"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// handleStatus 是 /status 端点的处理器
+func handleStatus(c *fiber.Ctx, cfg *config.Config) error {
+	names := make([]string, 0, len(cfg.Backends))
+	for name := range cfg.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	backends := make([]fiber.Map, 0, len(names))
+	for _, name := range names {
+		backend := cfg.Backends[name]
+		entry := fiber.Map{
+			"name":     backend.Name,
+			"base_url": backend.BaseURL,
+			"provider": string(backend.DetectProvider()),
+		}
+		if info := getLastRateLimits(backend.Name); info != nil {
+			entry["rate_limits"] = info.anthropicHeaders()
+			entry["rate_limits_updated_at"] = info.UpdatedAt
+		}
+		backends = append(backends, entry)
+	}
+
+	routes := make([]string, len(cfg.Routes))
+	for i, route := range cfg.Routes {
+		routes[i] = route.String()
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       "ok",
+		"version":      ProxyVersion,
+		"backends":     backends,
+		"routes":       routes,
+		"config_files": cfg.ConfigFiles,
+		"capabilities": config.CapabilitySnapshot(),
+		"usage":        statusUsage(requestClient(c)),
+	})
+}
+
+// statusUsage 返回状态端点中的客户端用量：共享密钥（或未启用认证）时返回所有客户端，客户端密钥只返回自己的用量
+func statusUsage(client *config.Client) []config.UsageEntry {
+	if client == nil {
+		return config.UsageSnapshot()
+	}
+	return []config.UsageEntry{{Client: client.Name, ClientUsage: config.UsageFor(client.Name)}}
+}
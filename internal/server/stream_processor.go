@@ -9,8 +9,10 @@ import (
 
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
 	"github.com/CyrilPeng/claude-code-proxy-golang/internal/converter"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/tokenizer"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
 	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
 )
 
 // StreamState 跟踪流式传输过程中的状态
@@ -27,6 +29,7 @@ type StreamState struct {
 	TextBlockStarted       bool
 	ThinkingBlockStarted   bool
 	ThinkingBlockHasContent bool
+	ThinkingBlockSigned     bool
 
 	// 工具调用跟踪
 	CurrentToolCalls  map[int]*ToolCallState
@@ -37,6 +40,12 @@ type StreamState struct {
 
 	// 使用量数据
 	UsageData map[string]interface{}
+
+	// UsageReceived 后端是否发送过使用量数据
+	UsageReceived bool
+
+	// UsageEstimated 使用量是否由本地分词器估算得出
+	UsageEstimated bool
 }
 
 // NewStreamState 创建新的流状态
@@ -72,6 +81,15 @@ type StreamProcessor struct {
 	providerModel string
 	startTime     time.Time
 	state         *StreamState
+
+	// 后端未返回使用量时的估算依据
+	estimatedInputTokens int
+	outputCounter        tokenizer.Counter
+
+	// writeErr 记录向客户端写入时的首个错误（客户端已断开连接）
+	writeErr error
+	// lastFlush 最近一次向客户端刷新数据的时间，用于决定是否需要发送 ping
+	lastFlush time.Time
 }
 
 // NewStreamProcessor 创建新的流处理器
@@ -85,6 +103,48 @@ func NewStreamProcessor(w *bufio.Writer, providerModel string, cfg *config.Confi
 	}
 }
 
+// SetEstimatedInputTokens 设置根据转换后请求估算的输入令牌数。
+// 仅在后端未发送使用量数据时用于合成 usage。
+func (p *StreamProcessor) SetEstimatedInputTokens(tokens int) {
+	p.estimatedInputTokens = tokens
+}
+
+// flush 刷新写入器并记录写入错误。
+// 写入失败表示客户端已断开连接，之后的事件都会被丢弃。
+func (p *StreamProcessor) flush() {
+	if p.writeErr != nil {
+		return
+	}
+	if err := p.writer.Flush(); err != nil {
+		p.writeErr = err
+		if p.cfg.Debug {
+			fmt.Printf("[调试] 向客户端写入失败，客户端可能已断开连接: %v\n", err)
+		}
+		return
+	}
+	p.lastFlush = time.Now()
+}
+
+// ClientGone 返回客户端是否已断开连接（写入失败）
+func (p *StreamProcessor) ClientGone() bool {
+	return p.writeErr != nil
+}
+
+// SendPingIfIdle 在距上次向客户端写入已超过 interval 时发送 ping 事件。
+// 上游长时间思考而没有输出时，防止中间代理和 Claude Code 因空闲而断开连接。
+func (p *StreamProcessor) SendPingIfIdle(interval time.Duration) {
+	if p.writeErr != nil || time.Since(p.lastFlush) < interval {
+		return
+	}
+	writeSSEEvent(p.writer, constants.EventPing, map[string]interface{}{
+		"type": constants.EventPing,
+	})
+	p.flush()
+	if p.cfg.Debug {
+		fmt.Printf("[调试] 上游静默超过 %v，已发送 ping\n", interval)
+	}
+}
+
 // SendMessageStart 发送初始 message_start 事件
 func (p *StreamProcessor) SendMessageStart() {
 	writeSSEEvent(p.writer, constants.EventMessageStart, map[string]interface{}{
@@ -114,7 +174,7 @@ func (p *StreamProcessor) SendMessageStart() {
 		"type": constants.EventPing,
 	})
 
-	_ = p.writer.Flush()
+	p.flush()
 }
 
 // HandleThinkingDelta 处理思考块增量
@@ -163,7 +223,7 @@ func (p *StreamProcessor) sendThinkingContent(content string) {
 			},
 		})
 		p.state.ThinkingBlockStarted = true
-		_ = p.writer.Flush()
+		p.flush()
 	}
 
 	// 发送思考块 delta
@@ -176,7 +236,45 @@ func (p *StreamProcessor) sendThinkingContent(content string) {
 		},
 	})
 	p.state.ThinkingBlockHasContent = true
-	_ = p.writer.Flush()
+	p.outputCounter.Add(content)
+	p.flush()
+}
+
+// HandleThinkingSignature 为思考块发送签名增量（Responses API 的加密推理项）。
+// 尚未开始思考块时（模型未返回摘要）先启动一个空的思考块。
+// 流中只有一个思考块，因此只保留第一个推理项的签名。
+func (p *StreamProcessor) HandleThinkingSignature(signature string) {
+	if signature == "" || p.state.ThinkingBlockSigned {
+		return
+	}
+	if !p.state.ThinkingBlockStarted {
+		p.state.ThinkingBlockIndex = p.state.NextIndex
+		p.state.NextIndex++
+
+		writeSSEEvent(p.writer, constants.EventContentBlockStart, map[string]interface{}{
+			"type":  constants.EventContentBlockStart,
+			"index": p.state.ThinkingBlockIndex,
+			"content_block": map[string]interface{}{
+				"type":      constants.ContentTypeThinking,
+				"thinking":  "",
+				"signature": "",
+			},
+		})
+		p.state.ThinkingBlockStarted = true
+	}
+
+	writeSSEEvent(p.writer, constants.EventContentBlockDelta, map[string]interface{}{
+		"type":  constants.EventContentBlockDelta,
+		"index": p.state.ThinkingBlockIndex,
+		"delta": map[string]interface{}{
+			"type":      constants.DeltaTypeSignatureDelta,
+			"signature": signature,
+		},
+	})
+	// 有签名的思考块即使没有文本也需要在结束时关闭
+	p.state.ThinkingBlockHasContent = true
+	p.state.ThinkingBlockSigned = true
+	p.flush()
 }
 
 // HandleTextDelta 处理文本块增量
@@ -199,7 +297,7 @@ func (p *StreamProcessor) HandleTextDelta(content string) {
 			},
 		})
 		p.state.TextBlockStarted = true
-		_ = p.writer.Flush()
+		p.flush()
 	}
 
 	writeSSEEvent(p.writer, constants.EventContentBlockDelta, map[string]interface{}{
@@ -210,7 +308,8 @@ func (p *StreamProcessor) HandleTextDelta(content string) {
 			"text": content,
 		},
 	})
-	_ = p.writer.Flush()
+	p.outputCounter.Add(content)
+	p.flush()
 }
 
 // HandleContentArray 处理 content 数组格式（Claude 原生格式）
@@ -300,7 +399,7 @@ func (p *StreamProcessor) handleToolUseFromContentArray(blockMap map[string]inte
 			"input": map[string]interface{}{},
 		},
 	})
-	_ = p.writer.Flush()
+	p.flush()
 }
 
 // ensureTextBlockStarted 确保文本块已启动（用于工具调用前的占位符）
@@ -329,7 +428,7 @@ func (p *StreamProcessor) ensureTextBlockStarted() {
 		},
 	})
 	p.state.TextBlockStarted = true
-	_ = p.writer.Flush()
+	p.flush()
 }
 
 // HandleToolCallsDelta 处理 tool_calls 数组格式的工具调用
@@ -429,7 +528,7 @@ func (p *StreamProcessor) processToolCallFunction(tcIndex int, toolCall *ToolCal
 				"input": map[string]interface{}{},
 			},
 		})
-		_ = p.writer.Flush()
+		p.flush()
 
 		// 注意：不再在这里发送累积的参数
 		// 参数将在 finalizeToolCall 中统一清理和发送
@@ -448,11 +547,14 @@ func (p *StreamProcessor) processToolCallFunction(tcIndex int, toolCall *ToolCal
 func (p *StreamProcessor) extractToolArgs(toolCall *ToolCallState, functionData map[string]interface{}) string {
 	var argsChunk string
 
-	if args, ok := functionData["arguments"].(string); ok && args != "" {
-		toolCall.ArgsBuffer += args
-		argsChunk = args
-		if p.cfg.Debug {
-			fmt.Printf("[调试] 工具 %s 累积参数(字符串): '%s', 当前缓冲区: '%s'\n", toolCall.Name, args, toolCall.ArgsBuffer)
+	if args, ok := functionData["arguments"].(string); ok {
+		// 工具调用的第一个数据块通常带空字符串参数，不累积
+		if args != "" {
+			toolCall.ArgsBuffer += args
+			argsChunk = args
+			if p.cfg.Debug {
+				fmt.Printf("[调试] 工具 %s 累积参数(字符串): '%s', 当前缓冲区: '%s'\n", toolCall.Name, args, toolCall.ArgsBuffer)
+			}
 		}
 	} else if argsMap, ok := functionData["arguments"].(map[string]interface{}); ok {
 		if argsJSON, err := json.Marshal(argsMap); err == nil {
@@ -495,6 +597,7 @@ func (p *StreamProcessor) HandleUsageData(usage map[string]interface{}) {
 		"input_tokens":  inputTokens,
 		"output_tokens": outputTokens,
 	}
+	p.state.UsageReceived = true
 
 	// 如果存在缓存指标则添加
 	if promptTokensDetails, ok := usage["prompt_tokens_details"].(map[string]interface{}); ok {
@@ -531,7 +634,7 @@ func (p *StreamProcessor) FinalizeBlocks() {
 			"type":  constants.EventContentBlockStop,
 			"index": p.state.TextBlockIndex,
 		})
-		_ = p.writer.Flush()
+		p.flush()
 	}
 
 	// 为每个工具调用发送最终 JSON 和 content_block_stop
@@ -545,17 +648,11 @@ func (p *StreamProcessor) FinalizeBlocks() {
 			"type":  constants.EventContentBlockStop,
 			"index": p.state.ThinkingBlockIndex,
 		})
-		_ = p.writer.Flush()
+		p.flush()
 	}
 
-	// 调试：检查是否收到使用量数据
-	if p.cfg.Debug {
-		inputTokens, _ := p.state.UsageData["input_tokens"].(int)
-		outputTokens, _ := p.state.UsageData["output_tokens"].(int)
-		if inputTokens == 0 && outputTokens == 0 {
-			fmt.Printf("[调试] OpenRouter 流式传输：使用量数据不可用（流式 API 的预期限制）\n")
-		}
-	}
+	// 后端未发送使用量数据时，使用本地分词器估算
+	p.synthesizeUsageIfMissing()
 
 	// 发送 message_delta
 	if p.cfg.Debug {
@@ -570,18 +667,38 @@ func (p *StreamProcessor) FinalizeBlocks() {
 		},
 		"usage": p.state.UsageData,
 	})
-	_ = p.writer.Flush()
+	p.flush()
 
 	// 发送 message_stop
 	writeSSEEvent(p.writer, constants.EventMessageStop, map[string]interface{}{
 		"type": constants.EventMessageStop,
 	})
-	_ = p.writer.Flush()
+	p.flush()
 
 	// 简单日志
 	p.logSimpleSummary()
 }
 
+// synthesizeUsageIfMissing 在后端未返回使用量（或返回全零）时合成估算值。
+// 输入令牌来自转换后的请求，输出令牌来自已发送的文本、思考和工具参数 JSON。
+// 否则 Claude Code 会统计为 0 令牌，自动压缩永远不会触发。
+func (p *StreamProcessor) synthesizeUsageIfMissing() {
+	inputTokens, _ := p.state.UsageData["input_tokens"].(int)
+	outputTokens, _ := p.state.UsageData["output_tokens"].(int)
+	if p.state.UsageReceived && (inputTokens > 0 || outputTokens > 0) {
+		return
+	}
+
+	p.state.UsageData["input_tokens"] = p.estimatedInputTokens
+	p.state.UsageData["output_tokens"] = p.outputCounter.Tokens()
+	p.state.UsageEstimated = true
+
+	if p.cfg.Debug {
+		fmt.Printf("[调试] 后端未返回使用量数据，使用本地估算: 输入=%d 输出=%d\n",
+			p.estimatedInputTokens, p.outputCounter.Tokens())
+	}
+}
+
 // finalizeToolCall 完成单个工具调用
 func (p *StreamProcessor) finalizeToolCall(tcIndex int, toolData *ToolCallState) {
 	// 如果工具调用有 Name 但还没有启动，在这里启动它
@@ -615,7 +732,7 @@ func (p *StreamProcessor) finalizeToolCall(tcIndex int, toolData *ToolCallState)
 				"input": map[string]interface{}{},
 			},
 		})
-		_ = p.writer.Flush()
+		p.flush()
 
 		if p.cfg.Debug {
 			fmt.Printf("[调试] 延迟启动工具块: ID=%s, Name=%s, Index=%d\n", toolData.ID, toolData.Name, toolData.ClaudeIndex)
@@ -632,7 +749,7 @@ func (p *StreamProcessor) finalizeToolCall(tcIndex int, toolData *ToolCallState)
 			"type":  constants.EventContentBlockStop,
 			"index": toolData.ClaudeIndex,
 		})
-		_ = p.writer.Flush()
+		p.flush()
 	}
 }
 
@@ -673,7 +790,9 @@ func (p *StreamProcessor) sendFinalToolArgs(toolData *ToolCallState) {
 			"partial_json": string(sanitizedJSON),
 		},
 	})
-	_ = p.writer.Flush()
+	p.outputCounter.Add(toolData.Name)
+	p.outputCounter.Add(string(sanitizedJSON))
+	p.flush()
 }
 
 // logSimpleSummary 输出简单日志摘要
@@ -707,12 +826,29 @@ func (p *StreamProcessor) logSimpleSummary() {
 		tokensPerSec = float64(outputTokens) / duration
 	}
 
+	estimatedFlag := ""
+	if p.state.UsageEstimated {
+		estimatedFlag = " (估算)"
+	}
+
 	timestamp := time.Now().Format("15:04:05")
-	fmt.Printf("[%s] [请求] %s 模型=%s 输入=%d 输出=%d 令牌/秒=%.1f\n",
+	fmt.Printf("[%s] [请求] %s 模型=%s 输入=%d 输出=%d 令牌/秒=%.1f%s\n",
 		timestamp,
 		p.cfg.OpenAIBaseURL,
 		p.providerModel,
 		inputTokens,
 		outputTokens,
-		tokensPerSec)
+		tokensPerSec,
+		estimatedFlag)
+}
+
+// Usage 返回流的令牌使用量（用于客户端预算）。
+// 流提前结束（客户端断开或上游出错）且后端未返回使用量时，使用本地估算的输入令牌和已发送内容的输出令牌。
+func (p *StreamProcessor) Usage() models.Usage {
+	inputTokens, _ := p.state.UsageData["input_tokens"].(int)
+	outputTokens, _ := p.state.UsageData["output_tokens"].(int)
+	if inputTokens == 0 && outputTokens == 0 {
+		return models.Usage{InputTokens: p.estimatedInputTokens, OutputTokens: p.outputCounter.Tokens()}
+	}
+	return models.Usage{InputTokens: inputTokens, OutputTokens: outputTokens}
 }
@@ -0,0 +1,280 @@
+// stream_replay.go 在完整的 Claude 响应和 Claude SSE 事件序列之间互相转换：
+//   - 伪流式：后端不支持流式时，将非流式响应重放为格式正确的 SSE 序列
+//   - 流式聚合：非流式请求改用流式上游调用，再将 SSE 序列聚合为完整响应
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/constants"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/errors"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+// writeClaudeResponseSSE 将完整的 Claude 响应重放为 Claude SSE 事件序列。
+// 每个内容块输出为 content_block_start、单个 delta 和 content_block_stop。
+func writeClaudeResponseSSE(w *bufio.Writer, resp *models.ClaudeResponse) {
+	writeSSEEvent(w, constants.EventMessageStart, map[string]interface{}{
+		"type": constants.EventMessageStart,
+		"message": map[string]interface{}{
+			"id":            resp.ID,
+			"type":          constants.MessageTypeMessage,
+			"role":          constants.RoleAssistant,
+			"model":         resp.Model,
+			"content":       []interface{}{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage": map[string]interface{}{
+				"input_tokens":  resp.Usage.InputTokens,
+				"output_tokens": 0,
+			},
+		},
+	})
+	writeSSEEvent(w, constants.EventPing, map[string]interface{}{
+		"type": constants.EventPing,
+	})
+	_ = w.Flush()
+
+	for index, block := range resp.Content {
+		var contentBlock, delta map[string]interface{}
+
+		switch block.Type {
+		case constants.ContentTypeThinking:
+			signature := ""
+			if block.Signature != nil {
+				signature = *block.Signature
+			}
+			contentBlock = map[string]interface{}{
+				"type":      constants.ContentTypeThinking,
+				"thinking":  "",
+				"signature": signature,
+			}
+			delta = map[string]interface{}{
+				"type":     constants.DeltaTypeThinkingDelta,
+				"thinking": block.Thinking,
+			}
+		case constants.ContentTypeToolUse:
+			inputJSON, err := json.Marshal(block.Input)
+			if err != nil || block.Input == nil {
+				inputJSON = []byte("{}")
+			}
+			contentBlock = map[string]interface{}{
+				"type":  constants.ContentTypeToolUse,
+				"id":    block.ID,
+				"name":  block.Name,
+				"input": map[string]interface{}{},
+			}
+			delta = map[string]interface{}{
+				"type":         constants.DeltaTypeInputJSONDelta,
+				"partial_json": string(inputJSON),
+			}
+		default:
+			contentBlock = map[string]interface{}{
+				"type": constants.ContentTypeText,
+				"text": "",
+			}
+			delta = map[string]interface{}{
+				"type": constants.DeltaTypeTextDelta,
+				"text": block.Text,
+			}
+		}
+
+		writeSSEEvent(w, constants.EventContentBlockStart, map[string]interface{}{
+			"type":          constants.EventContentBlockStart,
+			"index":         index,
+			"content_block": contentBlock,
+		})
+		writeSSEEvent(w, constants.EventContentBlockDelta, map[string]interface{}{
+			"type":  constants.EventContentBlockDelta,
+			"index": index,
+			"delta": delta,
+		})
+		writeSSEEvent(w, constants.EventContentBlockStop, map[string]interface{}{
+			"type":  constants.EventContentBlockStop,
+			"index": index,
+		})
+		_ = w.Flush()
+	}
+
+	stopReason := constants.StopReasonEndTurn
+	if resp.StopReason != nil {
+		stopReason = *resp.StopReason
+	}
+	writeSSEEvent(w, constants.EventMessageDelta, map[string]interface{}{
+		"type": constants.EventMessageDelta,
+		"delta": map[string]interface{}{
+			"stop_reason":   stopReason,
+			"stop_sequence": resp.StopSequence,
+		},
+		"usage": map[string]interface{}{
+			"input_tokens":  resp.Usage.InputTokens,
+			"output_tokens": resp.Usage.OutputTokens,
+		},
+	})
+	writeSSEEvent(w, constants.EventMessageStop, map[string]interface{}{
+		"type": constants.EventMessageStop,
+	})
+	_ = w.Flush()
+}
+
+// aggregatedBlock 聚合过程中单个内容块的累积状态
+type aggregatedBlock struct {
+	block     models.ContentBlock
+	signature string
+	inputJSON strings.Builder
+}
+
+// aggregateClaudeSSE 读取 Claude SSE 事件序列并聚合为完整的 Claude 响应。
+// 如果流中包含 error 事件，返回对应类型的 ProxyError。
+func aggregateClaudeSSE(reader io.Reader) (*models.ClaudeResponse, error) {
+	resp := &models.ClaudeResponse{
+		Type: constants.MessageTypeMessage,
+		Role: constants.RoleAssistant,
+	}
+	blocks := make(map[int]*aggregatedBlock)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			continue
+		}
+
+		eventType, _ := event["type"].(string)
+		switch eventType {
+		case constants.EventMessageStart:
+			if message, ok := event["message"].(map[string]interface{}); ok {
+				resp.ID, _ = message["id"].(string)
+				resp.Model, _ = message["model"].(string)
+				mergeUsage(&resp.Usage, message["usage"])
+			}
+
+		case constants.EventContentBlockStart:
+			index := intField(event, "index")
+			contentBlock, _ := event["content_block"].(map[string]interface{})
+			agg := &aggregatedBlock{}
+			agg.block.Type, _ = contentBlock["type"].(string)
+			agg.block.ID, _ = contentBlock["id"].(string)
+			agg.block.Name, _ = contentBlock["name"].(string)
+			agg.block.Text, _ = contentBlock["text"].(string)
+			agg.block.Thinking, _ = contentBlock["thinking"].(string)
+			agg.signature, _ = contentBlock["signature"].(string)
+			blocks[index] = agg
+
+		case constants.EventContentBlockDelta:
+			agg, ok := blocks[intField(event, "index")]
+			if !ok {
+				continue
+			}
+			delta, _ := event["delta"].(map[string]interface{})
+			switch delta["type"] {
+			case constants.DeltaTypeTextDelta:
+				text, _ := delta["text"].(string)
+				agg.block.Text += text
+			case constants.DeltaTypeThinkingDelta:
+				thinking, _ := delta["thinking"].(string)
+				agg.block.Thinking += thinking
+			case constants.DeltaTypeSignatureDelta:
+				signature, _ := delta["signature"].(string)
+				agg.signature += signature
+			case constants.DeltaTypeInputJSONDelta:
+				partial, _ := delta["partial_json"].(string)
+				agg.inputJSON.WriteString(partial)
+			}
+
+		case constants.EventMessageDelta:
+			if delta, ok := event["delta"].(map[string]interface{}); ok {
+				if stopReason, ok := delta["stop_reason"].(string); ok && stopReason != "" {
+					resp.StopReason = &stopReason
+				}
+				if stopSequence, ok := delta["stop_sequence"].(string); ok && stopSequence != "" {
+					resp.StopSequence = &stopSequence
+				}
+			}
+			mergeUsage(&resp.Usage, event["usage"])
+
+		case constants.EventError:
+			message := "上游流式响应返回错误"
+			errType := string(errors.ErrorTypeAPI)
+			if errObj, ok := event["error"].(map[string]interface{}); ok {
+				if msg, ok := errObj["message"].(string); ok {
+					message = msg
+				}
+				if typ, ok := errObj["type"].(string); ok && typ != "" {
+					errType = typ
+				}
+			}
+			return nil, errors.FromClaudeError(errType, message)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取流式响应失败: %w", err)
+	}
+
+	// 按索引顺序组装内容块
+	indices := make([]int, 0, len(blocks))
+	for index := range blocks {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	resp.Content = make([]models.ContentBlock, 0, len(indices))
+	for _, index := range indices {
+		agg := blocks[index]
+		switch agg.block.Type {
+		case constants.ContentTypeThinking:
+			signature := agg.signature
+			agg.block.Signature = &signature
+		case constants.ContentTypeToolUse:
+			var input map[string]interface{}
+			if err := json.Unmarshal([]byte(agg.inputJSON.String()), &input); err != nil || input == nil {
+				input = map[string]interface{}{}
+			}
+			agg.block.Input = input
+		}
+		resp.Content = append(resp.Content, agg.block)
+	}
+
+	if resp.StopReason == nil {
+		stopReason := constants.StopReasonEndTurn
+		resp.StopReason = &stopReason
+	}
+
+	return resp, nil
+}
+
+// mergeUsage 将 SSE 事件中的使用量字段合并到响应使用量中（非零值覆盖）
+func mergeUsage(usage *models.Usage, raw interface{}) {
+	usageMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if v := intField(usageMap, "input_tokens"); v > 0 {
+		usage.InputTokens = v
+	}
+	if v := intField(usageMap, "output_tokens"); v > 0 {
+		usage.OutputTokens = v
+	}
+}
+
+// intField 从 JSON 解码的 map 中读取整数字段
+func intField(m map[string]interface{}, key string) int {
+	if v, ok := m[key].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
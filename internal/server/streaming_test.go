@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+)
+
+func TestBackendStreamingDisabled(t *testing.T) {
+	upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+		return jsonResponse(http.StatusOK, chatCompletionResponse)
+	})
+	app := newTestApp(newTestConfig(&config.Backend{BaseURL: upstream.URL, DisableStreaming: true}))
+
+	status, resp := postJSON(t, app, "/v1/messages",
+		`{"model":"claude-sonnet-4-5","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, resp)
+	}
+	if stream, _ := upstream.last(t).Body["stream"].(bool); stream {
+		t.Errorf("streaming: false 的后端不应收到流式请求")
+	}
+
+	// 非流式响应重放为 Claude SSE
+	events := parseSSE(t, resp)
+	checkClaudeStream(t, events, "text")
+	if got := deltaText(events, "text"); got != "ok" {
+		t.Errorf("文本 = %q，应为 %q", got, "ok")
+	}
+}
+
+func TestStreamStallSendsPingsThenTimeoutError(t *testing.T) {
+	upstream := newFakeUpstream(t, func(upstreamRequest) upstreamResponse {
+		resp := streamResponse("text/event-stream",
+			`data: {"id":"c1","object":"chat.completion.chunk","model":"test-model","choices":[{"index":0,"delta":{"role":"assistant","content":"he"}}]}`+"\n\n")
+		resp.Stall = 10 * time.Second
+		return resp
+	})
+	app := newTestApp(newTestConfig(&config.Backend{
+		BaseURL:      upstream.URL,
+		IdleTimeout:  2 * time.Second,
+		PingInterval: time.Second,
+	}))
+
+	start := time.Now()
+	status, resp := postJSON(t, app, "/v1/messages",
+		`{"model":"claude-sonnet-4-5","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 = %d，响应: %s", status, resp)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("停滞的流用时 %v 才结束，应在空闲超时后结束", elapsed)
+	}
+
+	// 上游静默期间发送 ping
+	if !strings.Contains(resp, "event: ping") {
+		t.Errorf("上游静默期间没有发送 ping 事件: %s", resp)
+	}
+
+	// 停滞后以 timeout_error 错误事件结束，不发送 message_stop
+	events := parseSSE(t, resp)
+	types := eventTypes(events)
+	if len(events) == 0 || types[0] != "message_start" || types[len(types)-1] != "error" {
+		t.Fatalf("事件序列 = %v", types)
+	}
+	errObj, _ := events[len(events)-1].Data["error"].(map[string]interface{})
+	if errObj["type"] != "timeout_error" {
+		t.Errorf("错误类型 = %v，应为 timeout_error", errObj["type"])
+	}
+	if got := deltaText(events, "text"); got != "he" {
+		t.Errorf("停滞前的文本 = %q，应为 %q", got, "he")
+	}
+}
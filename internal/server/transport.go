@@ -0,0 +1,108 @@
+// transport.go 为每个后端维护一个共享的 http.Transport，使并发请求
+// （例如 Claude Code 大量并行的 Haiku 辅助请求）可以复用 TCP/TLS 连接。
+
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/config"
+	"github.com/CyrilPeng/claude-code-proxy-golang/internal/provider"
+)
+
+// 连接池默认值（后端未配置时使用）
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 32
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultDialTimeout         = 30 * time.Second
+	defaultDialKeepAlive       = 30 * time.Second
+)
+
+// backendTransport 记录传输层及创建它时使用的设置
+type backendTransport struct {
+	baseURL   string
+	settings  config.HTTPSettings
+	transport *http.Transport
+}
+
+// 后端名称 -> 共享传输层
+// 由互斥锁保护；设置变化（例如配置重新加载）时替换并关闭旧传输层的空闲连接
+var (
+	transports      = make(map[string]*backendTransport)
+	transportsMutex sync.Mutex
+)
+
+// transportFor 返回后端的共享传输层，必要时创建
+func transportFor(backend *config.Backend) *http.Transport {
+	transportsMutex.Lock()
+	defer transportsMutex.Unlock()
+
+	if entry, ok := transports[backend.Name]; ok {
+		if entry.baseURL == backend.BaseURL && entry.settings == backend.HTTP {
+			return entry.transport
+		}
+		entry.transport.CloseIdleConnections()
+	}
+
+	transport := newTransport(backend.HTTP)
+	transports[backend.Name] = &backendTransport{
+		baseURL:   backend.BaseURL,
+		settings:  backend.HTTP,
+		transport: transport,
+	}
+	return transport
+}
+
+// newTransport 根据连接池设置创建传输层
+func newTransport(settings config.HTTPSettings) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: defaultDialKeepAlive,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     !settings.DisableHTTP2,
+		MaxIdleConns:          intOr(settings.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost:   intOr(settings.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		IdleConnTimeout:       durationOr(settings.IdleConnTimeout, defaultIdleConnTimeout),
+		TLSHandshakeTimeout:   durationOr(settings.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: settings.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if settings.DisableHTTP2 {
+		// 非 nil 的空映射会阻止 ALPN 协商 h2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// newHTTPClient 创建使用提供商后端共享传输层的客户端。
+// Client 本身很轻量，每次调用创建以便设置不同的总超时。
+func newHTTPClient(prov provider.Provider, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: transportFor(prov.Backend()),
+		Timeout:   timeout,
+	}
+}
+
+func intOr(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,156 @@
+// Package tokenizer 提供本地令牌数估算功能。
+//
+// 当后端未返回使用量数据时（旧版 vLLM、LM Studio、某些 Ollama 版本），
+// 代理使用此包根据文本长度估算令牌数。估算基于字符类别的经验比例：
+// ASCII 文本约 4 个字符一个令牌，CJK 字符约 1 个字符一个令牌，其他非 ASCII 字符约 2 个字符一个令牌。
+// 结果只是近似值，仅用于让 Claude Code 的令牌统计和自动压缩正常工作。
+package tokenizer
+
+import (
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/json"
+	"github.com/CyrilPeng/claude-code-proxy-golang/pkg/models"
+)
+
+const (
+	// asciiCharsPerToken ASCII 文本每个令牌的平均字符数
+	asciiCharsPerToken = 4
+	// otherRunesPerToken 非 CJK 的非 ASCII 字符每个令牌的平均字符数
+	otherRunesPerToken = 2
+	// messageOverheadTokens 每条消息的格式开销（角色、分隔符）
+	messageOverheadTokens = 4
+	// requestOverheadTokens 每个请求的固定开销（回复引导）
+	requestOverheadTokens = 3
+)
+
+// Counter 增量累积文本并估算令牌数。
+// 流式处理时逐块调用 Add，避免对每个小块单独取整造成的高估。
+// 零值可直接使用。
+type Counter struct {
+	asciiChars int
+	cjkRunes   int
+	otherRunes int
+}
+
+// Add 累积一段文本
+func (c *Counter) Add(text string) {
+	for _, r := range text {
+		switch {
+		case r < 0x80:
+			c.asciiChars++
+		case isCJK(r):
+			c.cjkRunes++
+		default:
+			c.otherRunes++
+		}
+	}
+}
+
+// Tokens 返回已累积文本的估算令牌数
+func (c *Counter) Tokens() int {
+	return ceilDiv(c.asciiChars, asciiCharsPerToken) +
+		c.cjkRunes +
+		ceilDiv(c.otherRunes, otherRunesPerToken)
+}
+
+// CountText 估算单段文本的令牌数
+func CountText(text string) int {
+	var c Counter
+	c.Add(text)
+	return c.Tokens()
+}
+
+// EstimateOpenAIRequest 估算转换后的 OpenAI 请求的输入令牌数。
+// 包括所有消息内容、工具调用参数和工具定义（按 JSON 序列化后计算）。
+func EstimateOpenAIRequest(req *models.OpenAIRequest) int {
+	if req == nil {
+		return 0
+	}
+
+	var c Counter
+	tokens := requestOverheadTokens
+
+	for _, msg := range req.Messages {
+		tokens += messageOverheadTokens
+		addContent(&c, msg.Content)
+		for _, tc := range msg.ToolCalls {
+			c.Add(tc.Function.Name)
+			c.Add(tc.Function.Arguments)
+		}
+	}
+
+	for _, tool := range req.Tools {
+		if toolJSON, err := json.Marshal(tool.Function); err == nil {
+			c.Add(string(toolJSON))
+		}
+	}
+
+	return tokens + c.Tokens()
+}
+
+// EstimateClaudeRequest 估算 Claude 请求的输入令牌数。
+// 用于尚未转换为 OpenAI 格式的场景（例如令牌计数端点）。
+func EstimateClaudeRequest(req *models.ClaudeRequest) int {
+	if req == nil {
+		return 0
+	}
+
+	var c Counter
+	tokens := requestOverheadTokens
+
+	addContent(&c, req.System)
+	for _, msg := range req.Messages {
+		tokens += messageOverheadTokens
+		addContent(&c, msg.Content)
+	}
+
+	for _, tool := range req.Tools {
+		c.Add(tool.Name)
+		c.Add(tool.Description)
+		if schemaJSON, err := json.Marshal(tool.InputSchema); err == nil {
+			c.Add(string(schemaJSON))
+		}
+	}
+
+	return tokens + c.Tokens()
+}
+
+// addContent 累积字符串或内容块数组形式的消息内容。
+// 对于内容块，只统计文本类字段；图片等二进制数据不计入。
+func addContent(c *Counter, content interface{}) {
+	switch v := content.(type) {
+	case string:
+		c.Add(v)
+	case []interface{}:
+		for _, block := range v {
+			blockMap, ok := block.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, key := range []string{"text", "thinking", "name"} {
+				if s, ok := blockMap[key].(string); ok {
+					c.Add(s)
+				}
+			}
+			if input, ok := blockMap["input"]; ok && input != nil {
+				if inputJSON, err := json.Marshal(input); err == nil {
+					c.Add(string(inputJSON))
+				}
+			}
+			if nested, ok := blockMap["content"]; ok {
+				addContent(c, nested)
+			}
+		}
+	}
+}
+
+// isCJK 判断字符是否属于中日韩统一表意文字、假名或韩文音节
+func isCJK(r rune) bool {
+	return (r >= 0x2E80 && r <= 0x9FFF) ||
+		(r >= 0xAC00 && r <= 0xD7AF) ||
+		(r >= 0xF900 && r <= 0xFAFF) ||
+		(r >= 0x20000 && r <= 0x2FA1F)
+}
+
+func ceilDiv(n, d int) int {
+	return (n + d - 1) / d
+}
@@ -24,6 +24,8 @@ const (
 	ContentTypeToolUse = "tool_use"
 	// ContentTypeToolResult 工具结果内容块
 	ContentTypeToolResult = "tool_result"
+	// ContentTypeImage 图片内容块
+	ContentTypeImage = "image"
 )
 
 // 消息角色
@@ -90,6 +92,8 @@ const (
 	DeltaTypeThinkingDelta = "thinking_delta"
 	// DeltaTypeInputJSONDelta 工具输入 JSON 增量
 	DeltaTypeInputJSONDelta = "input_json_delta"
+	// DeltaTypeSignatureDelta 思考块签名增量
+	DeltaTypeSignatureDelta = "signature_delta"
 )
 
 // 推理详情类型（OpenRouter 格式）
@@ -106,6 +110,12 @@ const (
 const (
 	// EndpointChatCompletions OpenAI 聊天完成端点
 	EndpointChatCompletions = "/chat/completions"
+	// EndpointResponses OpenAI Responses API 端点
+	EndpointResponses = "/responses"
+	// EndpointOllamaChat Ollama 原生聊天端点（相对于 Ollama 服务根地址）
+	EndpointOllamaChat = "/api/chat"
+	// EndpointOllamaTags Ollama 原生模型列表端点（相对于 Ollama 服务根地址）
+	EndpointOllamaTags = "/api/tags"
 	// EndpointMessages Claude 消息端点
 	EndpointMessages = "/v1/messages"
 	// EndpointCountTokens 令牌计数端点
@@ -3,6 +3,7 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
 	"net/http"
 )
@@ -16,6 +17,7 @@ const (
 	ErrorTypeAuthentication   ErrorType = "authentication_error"
 	ErrorTypePermission       ErrorType = "permission_error"
 	ErrorTypeNotFound         ErrorType = "not_found_error"
+	ErrorTypeRequestTooLarge  ErrorType = "request_too_large"
 	ErrorTypeRateLimit        ErrorType = "rate_limit_error"
 	ErrorTypeAPI              ErrorType = "api_error"
 	ErrorTypeOverloaded       ErrorType = "overloaded_error"
@@ -25,6 +27,9 @@ const (
 	ErrorTypeStreamProcessing ErrorType = "stream_processing_error"
 )
 
+// StatusOverloaded 是 Anthropic API 表示过载的非标准 HTTP 状态码
+const StatusOverloaded = 529
+
 // ProxyError 是代理的统一错误类型
 type ProxyError struct {
 	Type       ErrorType `json:"type"`
@@ -33,6 +38,8 @@ type ProxyError struct {
 	Cause      error     `json:"-"` // 原始错误，不序列化到 JSON
 	Provider   string    `json:"-"` // 提供商名称（用于日志）
 	Model      string    `json:"-"` // 模型名称（用于日志）
+	RetryAfter string    `json:"-"` // 上游 retry-after 头（秒数或 HTTP 日期），原样转发给客户端
+	RequestID  string    `json:"-"` // 上游请求 ID，作为 request-id 头和响应字段返回
 }
 
 // Error 实现 error 接口
@@ -50,13 +57,49 @@ func (e *ProxyError) Unwrap() error {
 
 // ToClaudeError 转换为 Claude API 错误响应格式
 func (e *ProxyError) ToClaudeError() map[string]interface{} {
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"type": "error",
 		"error": map[string]interface{}{
-			"type":    string(e.Type),
+			"type":    string(e.ClaudeType()),
 			"message": e.Message,
 		},
 	}
+	if e.RequestID != "" {
+		result["request_id"] = e.RequestID
+	}
+	return result
+}
+
+// ToOpenAIError 转换为 OpenAI API 错误响应格式（用于 /v1/chat/completions 端点）
+func (e *ProxyError) ToOpenAIError() map[string]interface{} {
+	var code interface{}
+	switch e.Type {
+	case ErrorTypeAuthentication:
+		code = "invalid_api_key"
+	case ErrorTypeRateLimit:
+		code = "rate_limit_exceeded"
+	case ErrorTypeNotFound:
+		code = "model_not_found"
+	}
+	return map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": e.Message,
+			"type":    string(e.ClaudeType()),
+			"param":   nil,
+			"code":    code,
+		},
+	}
+}
+
+// ClaudeType 返回发送给客户端的错误类型。
+// 代理内部类型（连接、转换、流处理）不属于 Claude API，统一映射为 api_error。
+func (e *ProxyError) ClaudeType() ErrorType {
+	switch e.Type {
+	case ErrorTypeConnection, ErrorTypeConversion, ErrorTypeStreamProcessing:
+		return ErrorTypeAPI
+	default:
+		return e.Type
+	}
 }
 
 // WithCause 添加原始错误
@@ -77,6 +120,27 @@ func (e *ProxyError) WithModel(model string) *ProxyError {
 	return e
 }
 
+// WithRetryAfter 添加 retry-after 信息
+func (e *ProxyError) WithRetryAfter(retryAfter string) *ProxyError {
+	e.RetryAfter = retryAfter
+	return e
+}
+
+// WithRequestID 添加上游请求 ID
+func (e *ProxyError) WithRequestID(requestID string) *ProxyError {
+	e.RequestID = requestID
+	return e
+}
+
+// AsProxyError 在错误链中查找 ProxyError
+func AsProxyError(err error) (*ProxyError, bool) {
+	var pe *ProxyError
+	if stderrors.As(err, &pe) {
+		return pe, true
+	}
+	return nil, false
+}
+
 // 错误构造函数
 
 // NewInvalidRequestError 创建无效请求错误
@@ -124,6 +188,15 @@ func NewRateLimitError(message string) *ProxyError {
 	}
 }
 
+// NewRequestTooLargeError 创建请求过大错误
+func NewRequestTooLargeError(message string) *ProxyError {
+	return &ProxyError{
+		Type:       ErrorTypeRequestTooLarge,
+		Message:    message,
+		StatusCode: http.StatusRequestEntityTooLarge,
+	}
+}
+
 // NewAPIError 创建 API 错误
 func NewAPIError(message string) *ProxyError {
 	return &ProxyError{
@@ -138,7 +211,7 @@ func NewOverloadedError(message string) *ProxyError {
 	return &ProxyError{
 		Type:       ErrorTypeOverloaded,
 		Message:    message,
-		StatusCode: http.StatusServiceUnavailable,
+		StatusCode: StatusOverloaded,
 	}
 }
 
@@ -189,56 +262,85 @@ func FromHTTPStatus(statusCode int, message string) *ProxyError {
 		return NewPermissionError(message)
 	case http.StatusNotFound:
 		return NewNotFoundError(message)
+	case http.StatusRequestEntityTooLarge:
+		return NewRequestTooLargeError(message)
 	case http.StatusTooManyRequests:
 		return NewRateLimitError(message)
-	case http.StatusServiceUnavailable:
+	case http.StatusServiceUnavailable, StatusOverloaded:
 		return NewOverloadedError(message)
-	case http.StatusGatewayTimeout:
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
 		return NewTimeoutError(message)
 	case http.StatusBadGateway:
 		return NewConnectionError(message)
 	default:
+		// 其他 4xx（例如 422）视为请求无效
+		if statusCode >= 400 && statusCode < 500 {
+			return NewInvalidRequestError(message)
+		}
 		return NewAPIError(message)
 	}
 }
 
-// FromOpenAIError 从 OpenAI API 错误响应创建 ProxyError
+// FromClaudeError 根据 Claude API 错误类型字符串创建 ProxyError（例如 SSE error 事件）
+func FromClaudeError(errType string, message string) *ProxyError {
+	pe := &ProxyError{
+		Type:    ErrorType(errType),
+		Message: message,
+	}
+	pe.StatusCode = statusForType(pe.Type)
+	return pe
+}
+
+// FromOpenAIError 从 OpenAI API 错误响应创建 ProxyError。
+// 优先使用 error.type / error.code 映射错误类型，无法识别时根据 HTTP 状态码推断；
+// 返回的状态码是该错误类型在 Claude API 中对应的状态码。
 func FromOpenAIError(statusCode int, errorBody map[string]interface{}) *ProxyError {
 	message := "Unknown error"
-	errorType := ErrorTypeAPI
+	var errorType ErrorType
 
 	// 尝试从 OpenAI 错误格式中提取信息
 	if errObj, ok := errorBody["error"].(map[string]interface{}); ok {
 		if msg, ok := errObj["message"].(string); ok {
 			message = msg
 		}
-		if typ, ok := errObj["type"].(string); ok {
-			// 映射 OpenAI 错误类型到 Claude 错误类型
-			switch typ {
-			case "invalid_request_error":
-				errorType = ErrorTypeInvalidRequest
-			case "authentication_error", "invalid_api_key":
-				errorType = ErrorTypeAuthentication
-			case "permission_denied":
-				errorType = ErrorTypePermission
-			case "not_found":
-				errorType = ErrorTypeNotFound
-			case "rate_limit_exceeded":
-				errorType = ErrorTypeRateLimit
-			case "server_error", "internal_error":
-				errorType = ErrorTypeAPI
-			case "overloaded":
-				errorType = ErrorTypeOverloaded
-			default:
-				errorType = ErrorTypeAPI
+		// OpenAI 的速率限制通常在 code 中标识（type 为 "requests"/"tokens"）
+		for _, key := range []string{"type", "code"} {
+			if typ, ok := errObj[key].(string); ok && errorType == "" {
+				errorType = mapOpenAIErrorType(typ)
 			}
 		}
 	}
 
+	if errorType == "" {
+		return FromHTTPStatus(statusCode, message)
+	}
+
 	return &ProxyError{
 		Type:       errorType,
 		Message:    message,
-		StatusCode: statusCode,
+		StatusCode: statusForType(errorType),
+	}
+}
+
+// mapOpenAIErrorType 映射 OpenAI 错误类型到 Claude 错误类型，无法识别时返回空字符串
+func mapOpenAIErrorType(typ string) ErrorType {
+	switch typ {
+	case "invalid_request_error":
+		return ErrorTypeInvalidRequest
+	case "authentication_error", "invalid_api_key":
+		return ErrorTypeAuthentication
+	case "permission_denied", "permission_error":
+		return ErrorTypePermission
+	case "not_found", "model_not_found":
+		return ErrorTypeNotFound
+	case "rate_limit_exceeded", "rate_limit_error":
+		return ErrorTypeRateLimit
+	case "server_error", "internal_error":
+		return ErrorTypeAPI
+	case "overloaded", "overloaded_error":
+		return ErrorTypeOverloaded
+	default:
+		return ""
 	}
 }
 
@@ -284,33 +386,36 @@ func WrapWithType(err error, errType ErrorType, message string) *ProxyError {
 		return nil
 	}
 
-	pe := &ProxyError{
-		Type:    errType,
-		Message: message,
-		Cause:   err,
+	return &ProxyError{
+		Type:       errType,
+		Message:    message,
+		Cause:      err,
+		StatusCode: statusForType(errType),
 	}
+}
 
-	// 设置适当的状态码
+// statusForType 返回错误类型对应的 HTTP 状态码
+func statusForType(errType ErrorType) int {
 	switch errType {
 	case ErrorTypeInvalidRequest:
-		pe.StatusCode = http.StatusBadRequest
+		return http.StatusBadRequest
 	case ErrorTypeAuthentication:
-		pe.StatusCode = http.StatusUnauthorized
+		return http.StatusUnauthorized
 	case ErrorTypePermission:
-		pe.StatusCode = http.StatusForbidden
+		return http.StatusForbidden
 	case ErrorTypeNotFound:
-		pe.StatusCode = http.StatusNotFound
+		return http.StatusNotFound
+	case ErrorTypeRequestTooLarge:
+		return http.StatusRequestEntityTooLarge
 	case ErrorTypeRateLimit:
-		pe.StatusCode = http.StatusTooManyRequests
+		return http.StatusTooManyRequests
 	case ErrorTypeOverloaded:
-		pe.StatusCode = http.StatusServiceUnavailable
+		return StatusOverloaded
 	case ErrorTypeTimeout:
-		pe.StatusCode = http.StatusGatewayTimeout
+		return http.StatusGatewayTimeout
 	case ErrorTypeConnection:
-		pe.StatusCode = http.StatusBadGateway
+		return http.StatusBadGateway
 	default:
-		pe.StatusCode = http.StatusInternalServerError
+		return http.StatusInternalServerError
 	}
-
-	return pe
 }
@@ -0,0 +1,134 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestFromOpenAIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       map[string]interface{}
+		wantType   ErrorType
+		wantStatus int
+	}{
+		{"无效请求", http.StatusBadRequest, openAIError("invalid_request_error", nil), ErrorTypeInvalidRequest, http.StatusBadRequest},
+		{"密钥无效（code）", http.StatusUnauthorized, openAIError("", "invalid_api_key"), ErrorTypeAuthentication, http.StatusUnauthorized},
+		{"权限", http.StatusForbidden, openAIError("permission_denied", nil), ErrorTypePermission, http.StatusForbidden},
+		{"模型不存在", http.StatusNotFound, openAIError("", "model_not_found"), ErrorTypeNotFound, http.StatusNotFound},
+		{"速率限制（type 为 tokens）", http.StatusTooManyRequests, openAIError("tokens", "rate_limit_exceeded"), ErrorTypeRateLimit, http.StatusTooManyRequests},
+		{"过载", http.StatusServiceUnavailable, openAIError("overloaded_error", nil), ErrorTypeOverloaded, StatusOverloaded},
+		{"服务器错误", http.StatusInternalServerError, openAIError("server_error", nil), ErrorTypeAPI, http.StatusInternalServerError},
+		{"未知类型按状态码 413", http.StatusRequestEntityTooLarge, openAIError("whatever", nil), ErrorTypeRequestTooLarge, http.StatusRequestEntityTooLarge},
+		{"未知类型按状态码 529", StatusOverloaded, openAIError("", nil), ErrorTypeOverloaded, StatusOverloaded},
+		{"未知类型按状态码 503", http.StatusServiceUnavailable, map[string]interface{}{}, ErrorTypeOverloaded, StatusOverloaded},
+		{"未知类型按状态码 422", http.StatusUnprocessableEntity, map[string]interface{}{}, ErrorTypeInvalidRequest, http.StatusBadRequest},
+		{"未知类型按状态码 504", http.StatusGatewayTimeout, map[string]interface{}{}, ErrorTypeTimeout, http.StatusGatewayTimeout},
+		{"未知类型按状态码 502", http.StatusBadGateway, map[string]interface{}{}, ErrorTypeConnection, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pe := FromOpenAIError(tt.status, tt.body)
+			if pe.Type != tt.wantType {
+				t.Errorf("类型 = %s，应为 %s", pe.Type, tt.wantType)
+			}
+			if pe.StatusCode != tt.wantStatus {
+				t.Errorf("状态码 = %d，应为 %d", pe.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestClaudeType(t *testing.T) {
+	tests := []struct {
+		err  *ProxyError
+		want ErrorType
+	}{
+		{NewConnectionError("x"), ErrorTypeAPI},
+		{NewConversionError("x"), ErrorTypeAPI},
+		{NewStreamProcessingError("x"), ErrorTypeAPI},
+		{NewOverloadedError("x"), ErrorTypeOverloaded},
+		{NewRequestTooLargeError("x"), ErrorTypeRequestTooLarge},
+		{NewTimeoutError("x"), ErrorTypeTimeout},
+	}
+	for _, tt := range tests {
+		if got := tt.err.ClaudeType(); got != tt.want {
+			t.Errorf("%s 的 Claude 类型 = %s，应为 %s", tt.err.Type, got, tt.want)
+		}
+		claude := tt.err.ToClaudeError()["error"].(map[string]interface{})
+		if claude["type"] != string(tt.want) {
+			t.Errorf("%s 的 Claude 错误响应类型 = %v，应为 %s", tt.err.Type, claude["type"], tt.want)
+		}
+	}
+}
+
+func TestFromClaudeError(t *testing.T) {
+	tests := []struct {
+		errType    string
+		wantStatus int
+	}{
+		{"overloaded_error", StatusOverloaded},
+		{"request_too_large", http.StatusRequestEntityTooLarge},
+		{"rate_limit_error", http.StatusTooManyRequests},
+		{"api_error", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := FromClaudeError(tt.errType, "x").StatusCode; got != tt.wantStatus {
+			t.Errorf("%s 的状态码 = %d，应为 %d", tt.errType, got, tt.wantStatus)
+		}
+	}
+}
+
+func TestToOpenAIError(t *testing.T) {
+	tests := []struct {
+		err      *ProxyError
+		wantType string
+		wantCode interface{}
+	}{
+		{NewAuthenticationError("x"), "authentication_error", "invalid_api_key"},
+		{NewRateLimitError("x"), "rate_limit_error", "rate_limit_exceeded"},
+		{NewNotFoundError("x"), "not_found_error", "model_not_found"},
+		{NewOverloadedError("x"), "overloaded_error", nil},
+		{NewConnectionError("x"), "api_error", nil},
+	}
+	for _, tt := range tests {
+		body := tt.err.ToOpenAIError()["error"].(map[string]interface{})
+		if body["type"] != tt.wantType || body["code"] != tt.wantCode {
+			t.Errorf("%s 的 OpenAI 错误 = type %v code %v，应为 type %s code %v",
+				tt.err.Type, body["type"], body["code"], tt.wantType, tt.wantCode)
+		}
+	}
+}
+
+func TestAsProxyError(t *testing.T) {
+	pe := NewRateLimitError("slow down").WithRetryAfter("30").WithRequestID("req_123")
+	wrapped := fmt.Errorf("调用上游失败: %w", pe)
+
+	got, ok := AsProxyError(wrapped)
+	if !ok || got != pe {
+		t.Fatalf("AsProxyError 未找到包装的 ProxyError")
+	}
+	if got.RetryAfter != "30" || got.RequestID != "req_123" {
+		t.Errorf("retry-after = %q，request-id = %q", got.RetryAfter, got.RequestID)
+	}
+	if got.ToClaudeError()["request_id"] != "req_123" {
+		t.Errorf("Claude 错误响应缺少 request_id")
+	}
+	if _, ok := AsProxyError(fmt.Errorf("普通错误")); ok {
+		t.Errorf("普通错误不应识别为 ProxyError")
+	}
+}
+
+// openAIError 返回 OpenAI 格式的错误响应体（typ 或 code 为空时省略）
+func openAIError(typ string, code interface{}) map[string]interface{} {
+	errObj := map[string]interface{}{"message": "upstream failed"}
+	if typ != "" {
+		errObj["type"] = typ
+	}
+	if code != nil {
+		errObj["code"] = code
+	}
+	return map[string]interface{}{"error": errObj}
+}
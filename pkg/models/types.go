@@ -28,6 +28,13 @@ type ClaudeRequest struct {
 	Stream        *bool           `json:"stream,omitempty"`
 	System        interface{}     `json:"system,omitempty"` // 可以是字符串或内容块数组
 	Tools         []Tool          `json:"tools,omitempty"`
+	Thinking      *ThinkingConfig `json:"thinking,omitempty"`
+}
+
+// ThinkingConfig 表示 Claude 请求的扩展思考设置
+type ThinkingConfig struct {
+	Type         string `json:"type"` // enabled 或 disabled
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
 }
 
 // Tool 表示函数/工具定义
@@ -44,6 +51,7 @@ type OpenAIMessage struct {
 	ToolCalls        []OpenAIToolCall `json:"tool_calls,omitempty"`
 	ToolCallID       string           `json:"tool_call_id,omitempty"`
 	ReasoningDetails []interface{}    `json:"reasoning_details,omitempty"` // OpenRouter 推理
+	ReasoningContent string           `json:"reasoning_content,omitempty"` // 推理内容（反向转换时输出思考块）
 }
 
 // OpenAIToolCall 表示 OpenAI 格式的工具调用
@@ -72,6 +80,15 @@ type OpenAIRequest struct {
 	ReasoningEffort     string                 `json:"reasoning_effort,omitempty"` // OpenAI 聊天完成推理（GPT-5 模型）
 	Tools               []OpenAITool           `json:"tools,omitempty"`
 	ToolChoice          interface{}            `json:"tool_choice,omitempty"` // 强制使用工具："auto"、"required" 或特定工具
+
+	// Original 转换前的 Claude 请求（不序列化）。
+	// 聊天完成消息无法表示推理项、思考签名和图片等内容块，
+	// Responses API 和 Gemini 等上游协议直接从原始请求转换。
+	Original *ClaudeRequest `json:"-"`
+
+	// Extra 和 Drop 是路由参数策略在序列化上游请求体时合并和删除的顶层字段（不序列化）
+	Extra map[string]interface{} `json:"-"`
+	Drop  []string               `json:"-"`
 }
 
 // OpenAITool 表示 OpenAI 格式的工具
@@ -125,3 +142,366 @@ type OpenAIUsage struct {
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
 }
+
+// ResponsesRequest 表示 OpenAI Responses API 请求
+type ResponsesRequest struct {
+	Model           string                 `json:"model"`
+	Instructions    string                 `json:"instructions,omitempty"`
+	Input           []interface{}          `json:"input"`
+	MaxOutputTokens int                    `json:"max_output_tokens,omitempty"`
+	Temperature     *float64               `json:"temperature,omitempty"`
+	TopP            *float64               `json:"top_p,omitempty"`
+	Stream          bool                   `json:"stream,omitempty"`
+	Tools           []ResponsesTool        `json:"tools,omitempty"`
+	ToolChoice      interface{}            `json:"tool_choice,omitempty"`
+	Reasoning       map[string]interface{} `json:"reasoning,omitempty"`
+	Include         []string               `json:"include,omitempty"`
+	Store           *bool                  `json:"store,omitempty"`
+}
+
+// ResponsesTool 表示 Responses API 的函数工具（字段不嵌套在 function 中）
+type ResponsesTool struct {
+	Type        string      `json:"type"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters"`
+}
+
+// ResponsesResponse 表示 OpenAI Responses API 响应
+type ResponsesResponse struct {
+	ID                string                `json:"id"`
+	Model             string                `json:"model"`
+	Status            string                `json:"status"` // completed、incomplete、failed
+	Output            []ResponsesOutputItem `json:"output"`
+	Usage             ResponsesUsage        `json:"usage"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"` // max_output_tokens、content_filter
+	} `json:"incomplete_details,omitempty"`
+	Error map[string]interface{} `json:"error,omitempty"` // status 为 failed 时的错误（code、message）
+}
+
+// ResponsesOutputItem 表示 Responses API 的输出项（message、reasoning 或 function_call）
+type ResponsesOutputItem struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+
+	// message
+	Role    string                 `json:"role,omitempty"`
+	Content []ResponsesContentPart `json:"content,omitempty"`
+
+	// reasoning
+	Summary          []ResponsesContentPart `json:"summary,omitempty"`
+	EncryptedContent string                 `json:"encrypted_content,omitempty"`
+
+	// function_call
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// ResponsesContentPart 表示输出消息或推理摘要中的内容部分（output_text、summary_text、refusal）
+type ResponsesContentPart struct {
+	Type    string `json:"type"`
+	Text    string `json:"text,omitempty"`
+	Refusal string `json:"refusal,omitempty"`
+}
+
+// ResponsesUsage 表示 Responses API 的令牌使用量
+type ResponsesUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	OutputTokens       int `json:"output_tokens"`
+	TotalTokens        int `json:"total_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+}
+
+// GeminiRequest 表示 Gemini generateContent 请求
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	ToolConfig        map[string]interface{}  `json:"toolConfig,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent 表示 Gemini 的一轮对话内容（role 为 user 或 model）
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart 表示 Gemini 内容中的一个部分
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`          // 思考摘要
+	ThoughtSignature string                  `json:"thoughtSignature,omitempty"` // 思考签名，需在下一轮原样发回
+	InlineData       *GeminiInlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiInlineData 表示内联的二进制数据（图片等）
+type GeminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiFunctionCall 表示模型发起的函数调用
+type GeminiFunctionCall struct {
+	ID   string                 `json:"id,omitempty"`
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// GeminiFunctionResponse 表示发回给模型的函数调用结果
+type GeminiFunctionResponse struct {
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// GeminiTool 表示 Gemini 的工具定义
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GeminiFunctionDeclaration 表示函数声明。
+// 使用 parametersJsonSchema 原样传递 JSON Schema，避免转换为 OpenAPI 子集时丢失信息。
+type GeminiFunctionDeclaration struct {
+	Name                 string      `json:"name"`
+	Description          string      `json:"description,omitempty"`
+	ParametersJSONSchema interface{} `json:"parametersJsonSchema,omitempty"`
+}
+
+// GeminiGenerationConfig 表示 Gemini 的生成参数
+type GeminiGenerationConfig struct {
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64              `json:"temperature,omitempty"`
+	TopP            *float64              `json:"topP,omitempty"`
+	StopSequences   []string              `json:"stopSequences,omitempty"`
+	ThinkingConfig  *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+// GeminiThinkingConfig 表示 Gemini 的思考设置
+type GeminiThinkingConfig struct {
+	IncludeThoughts bool `json:"includeThoughts"`
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+}
+
+// GeminiResponse 表示 Gemini generateContent 响应（流式时为每个 SSE 数据块）
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate      `json:"candidates"`
+	UsageMetadata *GeminiUsage           `json:"usageMetadata,omitempty"`
+	ModelVersion  string                 `json:"modelVersion"`
+	ResponseID    string                 `json:"responseId"`
+	Error         map[string]interface{} `json:"error,omitempty"` // 流中途的错误（code、message、status）
+}
+
+// GeminiCandidate 表示 Gemini 响应中的候选结果
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason"` // STOP、MAX_TOKENS、SAFETY 等
+}
+
+// GeminiUsage 表示 Gemini 的令牌使用量
+type GeminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// OllamaChatRequest 表示 Ollama 原生 /api/chat 请求
+type OllamaChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []OllamaMessage        `json:"messages"`
+	Tools     []OpenAITool           `json:"tools,omitempty"` // 与 OpenAI 工具格式相同
+	Stream    bool                   `json:"stream"`          // Ollama 默认流式，因此始终显式发送
+	Think     *bool                  `json:"think,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`    // num_ctx、num_predict、temperature 等
+	KeepAlive interface{}            `json:"keep_alive,omitempty"` // 时长字符串（如 "30m"）或秒数
+}
+
+// OllamaMessage 表示 Ollama 聊天消息
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"` // base64 编码的图片
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // tool 消息对应的函数名称
+}
+
+// OllamaToolCall 表示 Ollama 的工具调用（参数为对象而非 JSON 字符串）
+type OllamaToolCall struct {
+	Function OllamaFunctionCall `json:"function"`
+}
+
+// OllamaFunctionCall 表示 Ollama 工具调用中的函数
+type OllamaFunctionCall struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// OllamaChatResponse 表示 Ollama /api/chat 响应（流式时为每个 NDJSON 行）
+type OllamaChatResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       string        `json:"created_at"`
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason,omitempty"` // stop、length 等
+	PromptEvalCount int           `json:"prompt_eval_count,omitempty"`
+	EvalCount       int           `json:"eval_count,omitempty"`
+	Error           string        `json:"error,omitempty"` // 流中途的错误
+}
+
+// BedrockConverseRequest 表示 Bedrock Converse/ConverseStream 请求（模型 ID 在 URL 路径中）
+type BedrockConverseRequest struct {
+	Messages                     []BedrockMessage        `json:"messages"`
+	System                       []BedrockSystemBlock    `json:"system,omitempty"`
+	InferenceConfig              *BedrockInferenceConfig `json:"inferenceConfig,omitempty"`
+	ToolConfig                   *BedrockToolConfig      `json:"toolConfig,omitempty"`
+	AdditionalModelRequestFields map[string]interface{}  `json:"additionalModelRequestFields,omitempty"` // 模型特定参数（如 Claude 的 thinking）
+}
+
+// BedrockMessage 表示 Converse 消息
+type BedrockMessage struct {
+	Role    string                `json:"role"`
+	Content []BedrockContentBlock `json:"content"`
+}
+
+// BedrockSystemBlock 表示 Converse 系统提示块
+type BedrockSystemBlock struct {
+	Text string `json:"text"`
+}
+
+// BedrockContentBlock 表示 Converse 内容块（联合类型，只设置一个字段）
+type BedrockContentBlock struct {
+	Text             string                   `json:"text,omitempty"`
+	Image            *BedrockImage            `json:"image,omitempty"`
+	ToolUse          *BedrockToolUse          `json:"toolUse,omitempty"`
+	ToolResult       *BedrockToolResult       `json:"toolResult,omitempty"`
+	ReasoningContent *BedrockReasoningContent `json:"reasoningContent,omitempty"`
+}
+
+// BedrockImage 表示 Converse 图片块
+type BedrockImage struct {
+	Format string `json:"format"` // png、jpeg、gif、webp
+	Source struct {
+		Bytes string `json:"bytes"` // base64 编码的图片数据
+	} `json:"source"`
+}
+
+// BedrockToolUse 表示 Converse 工具调用块
+type BedrockToolUse struct {
+	ToolUseID string      `json:"toolUseId"`
+	Name      string      `json:"name"`
+	Input     interface{} `json:"input"`
+}
+
+// BedrockToolResult 表示 Converse 工具结果块
+type BedrockToolResult struct {
+	ToolUseID string                     `json:"toolUseId"`
+	Content   []BedrockToolResultContent `json:"content"`
+	Status    string                     `json:"status,omitempty"` // success 或 error
+}
+
+// BedrockToolResultContent 表示工具结果中的内容
+type BedrockToolResultContent struct {
+	Text string `json:"text"`
+}
+
+// BedrockReasoningContent 表示 Converse 推理块（Claude 的扩展思考）
+type BedrockReasoningContent struct {
+	ReasoningText   *BedrockReasoningText `json:"reasoningText,omitempty"`
+	RedactedContent string                `json:"redactedContent,omitempty"`
+}
+
+// BedrockReasoningText 表示推理文本及其签名
+type BedrockReasoningText struct {
+	Text      string `json:"text"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// BedrockInferenceConfig 表示 Converse 推理参数
+type BedrockInferenceConfig struct {
+	MaxTokens     int      `json:"maxTokens,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+// BedrockToolConfig 表示 Converse 工具配置
+type BedrockToolConfig struct {
+	Tools      []BedrockTool          `json:"tools"`
+	ToolChoice map[string]interface{} `json:"toolChoice,omitempty"` // {"auto":{}}、{"any":{}} 或 {"tool":{"name":...}}
+}
+
+// BedrockTool 表示 Converse 工具定义
+type BedrockTool struct {
+	ToolSpec BedrockToolSpec `json:"toolSpec"`
+}
+
+// BedrockToolSpec 表示 Converse 工具规格
+type BedrockToolSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema struct {
+		JSON interface{} `json:"json"`
+	} `json:"inputSchema"`
+}
+
+// BedrockConverseResponse 表示 Bedrock Converse 响应
+type BedrockConverseResponse struct {
+	Output struct {
+		Message BedrockMessage `json:"message"`
+	} `json:"output"`
+	StopReason string       `json:"stopReason"` // end_turn、tool_use、max_tokens、stop_sequence、guardrail_intervened、content_filtered
+	Usage      BedrockUsage `json:"usage"`
+}
+
+// BedrockUsage 表示 Bedrock 的令牌使用量
+type BedrockUsage struct {
+	InputTokens           int `json:"inputTokens"`
+	OutputTokens          int `json:"outputTokens"`
+	TotalTokens           int `json:"totalTokens"`
+	CacheReadInputTokens  int `json:"cacheReadInputTokens,omitempty"`
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens,omitempty"`
+}
+
+// BedrockContentBlockStart 表示 ConverseStream 的 contentBlockStart 事件（只有工具调用块有 start 内容）
+type BedrockContentBlockStart struct {
+	ContentBlockIndex int `json:"contentBlockIndex"`
+	Start             struct {
+		ToolUse *struct {
+			ToolUseID string `json:"toolUseId"`
+			Name      string `json:"name"`
+		} `json:"toolUse,omitempty"`
+	} `json:"start"`
+}
+
+// BedrockContentBlockDelta 表示 ConverseStream 的 contentBlockDelta 事件
+type BedrockContentBlockDelta struct {
+	ContentBlockIndex int `json:"contentBlockIndex"`
+	Delta             struct {
+		Text    string `json:"text,omitempty"`
+		ToolUse *struct {
+			Input string `json:"input"` // 工具参数的 JSON 片段
+		} `json:"toolUse,omitempty"`
+		ReasoningContent *struct {
+			Text      string `json:"text,omitempty"`
+			Signature string `json:"signature,omitempty"`
+		} `json:"reasoningContent,omitempty"`
+	} `json:"delta"`
+}
+
+// BedrockMessageStop 表示 ConverseStream 的 messageStop 事件
+type BedrockMessageStop struct {
+	StopReason string `json:"stopReason"`
+}
+
+// BedrockStreamMetadata 表示 ConverseStream 的 metadata 事件（流的最后一个事件）
+type BedrockStreamMetadata struct {
+	Usage BedrockUsage `json:"usage"`
+}